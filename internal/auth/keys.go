@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	fetchTimeout = 10 * time.Second
+	// minRefreshInterval bounds the refreshes triggered by tokens with an unknown kid.
+	minRefreshInterval = time.Minute
+	maxJWKSSize        = 1 << 20
+)
+
+var errNoKeys = errors.New("no usable verification keys")
+
+// Key is a verification key. An empty ID matches tokens without a kid, an empty Algorithm any algorithm
+// the key type supports.
+type Key struct {
+	ID        string
+	Algorithm string
+	Public    crypto.PublicKey
+}
+
+// KeySet holds the active verification keys. Keys loaded from a file or a JWKS URL are reloaded by Refresh,
+// so a new signing key is picked up without a restart.
+type KeySet struct {
+	log       *logrus.Logger
+	load      func(ctx context.Context) ([]Key, error)
+	mu        sync.RWMutex
+	keys      []Key
+	refreshed time.Time
+}
+
+func NewKeySet(keys ...Key) *KeySet {
+	return &KeySet{keys: keys, refreshed: time.Now()}
+}
+
+// NewFileKeySet loads keys from a JWKS document or from PEM encoded public keys.
+func NewFileKeySet(ctx context.Context, log *logrus.Logger, path string) (*KeySet, error) {
+	keys := &KeySet{
+		log: log,
+		load: func(_ context.Context) ([]Key, error) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read keys: %w", err)
+			}
+			return ParseKeys(data)
+		},
+	}
+	if err := keys.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// NewRemoteKeySet loads keys from a JWKS URL.
+func NewRemoteKeySet(ctx context.Context, log *logrus.Logger, url string) (*KeySet, error) {
+	client := &http.Client{Timeout: fetchTimeout}
+	keys := &KeySet{
+		log: log,
+		load: func(ctx context.Context) ([]Key, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return nil, fmt.Errorf("unable to create jwks request: %w", err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil, fmt.Errorf("unable to fetch jwks: %w", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("unable to fetch jwks: status %d", resp.StatusCode)
+			}
+			data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+			if err != nil {
+				return nil, fmt.Errorf("unable to read jwks: %w", err)
+			}
+			return ParseKeys(data)
+		},
+	}
+	if err := keys.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Refresh reloads the keys. On failure the previous keys stay active.
+func (s *KeySet) Refresh(ctx context.Context) error {
+	if s.load == nil {
+		return nil
+	}
+	keys, err := s.load(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshed = time.Now()
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	return nil
+}
+
+func (s *KeySet) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				s.log.Errorf("failed to refresh verification keys: %v", err)
+			}
+		}
+	}
+}
+
+// Lookup returns the keys that may have signed a token with the given kid and alg. A token without a kid
+// is checked against every matching key. An unknown kid triggers a refresh, at most once a minute.
+func (s *KeySet) Lookup(ctx context.Context, kid, alg string) []crypto.PublicKey {
+	candidates := s.lookup(kid, alg)
+	if len(candidates) > 0 || kid == "" || s.load == nil {
+		return candidates
+	}
+	s.mu.RLock()
+	stale := time.Since(s.refreshed) >= minRefreshInterval
+	s.mu.RUnlock()
+	if !stale {
+		return nil
+	}
+	if err := s.Refresh(ctx); err != nil {
+		s.log.Errorf("failed to refresh verification keys: %v", err)
+		return nil
+	}
+	return s.lookup(kid, alg)
+}
+
+func (s *KeySet) lookup(kid, alg string) []crypto.PublicKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	candidates := make([]crypto.PublicKey, 0, 1)
+	for _, key := range s.keys {
+		if kid != "" && key.ID != kid {
+			continue
+		}
+		if key.Algorithm != "" && key.Algorithm != alg {
+			continue
+		}
+		if supports(key.Public, alg) {
+			candidates = append(candidates, key.Public)
+		}
+	}
+	return candidates
+}
+
+func supports(key crypto.PublicKey, alg string) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS")
+	case *ecdsa.PublicKey:
+		return alg == "ES256" && k.Curve == elliptic.P256()
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+// ParseKeys reads a JWKS document or a sequence of PEM encoded public keys. Keys of unsupported types
+// are skipped.
+func ParseKeys(data []byte) ([]Key, error) {
+	var keys []Key
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "-----BEGIN") {
+		keys, err = parsePEM(data)
+	} else {
+		keys, err = parseJWKS(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errNoKeys
+	}
+	return keys, nil
+}
+
+func parsePEM(data []byte) ([]Key, error) {
+	keys := make([]Key, 0, 1)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return keys, nil
+		}
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse public key: %w", err)
+		}
+		keys = append(keys, Key{Public: public})
+	}
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func parseJWKS(data []byte) ([]Key, error) {
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("unable to decode jwks: %w", err)
+	}
+	keys := make([]Key, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		public, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode key %q: %w", k.Kid, err)
+		}
+		if public != nil {
+			keys = append(keys, Key{ID: k.Kid, Algorithm: k.Alg, Public: public})
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch {
+	case k.Kty == "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case k.Kty == "EC" && k.Crv == "P-256":
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case k.Kty == "OKP" && k.Crv == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid access token")
+	ErrUnknownKey   = errors.New("no verification key for token")
+)
+
+var supportedAlgorithms = []string{"RS256", "RS384", "RS512", "ES256", "EdDSA"}
+
+// Config holds the claim checks. Empty Issuer and Audience are not checked. Expiry and not-before are
+// always checked when present, RequireExpiry also rejects tokens without exp.
+type Config struct {
+	Issuer        string
+	Audience      string
+	RequireExpiry bool
+	ClockSkew     time.Duration
+}
+
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(value string) bool {
+	for _, item := range a {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// scopes accepts the space-delimited "scope" claim as well as a JSON array.
+type scopes []string
+
+func (s *scopes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = strings.Fields(single)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+type Claims struct {
+	AccountID int      `json:"account_id"`
+	Role      string   `json:"role"`
+	Scope     scopes   `json:"scope,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  audience `json:"aud,omitempty"`
+	ExpiresAt *int64   `json:"exp,omitempty"`
+	NotBefore *int64   `json:"nbf,omitempty"`
+	IssuedAt  *int64   `json:"iat,omitempty"`
+}
+
+// Valid is a no-op, claims are checked by the Verifier with the configured clock skew.
+func (c *Claims) Valid() error {
+	return nil
+}
+
+type Verifier struct {
+	keys   *KeySet
+	config Config
+	parser *jwt.Parser
+}
+
+func NewVerifier(keys *KeySet, config Config) *Verifier {
+	return &Verifier{
+		keys:   keys,
+		config: config,
+		parser: &jwt.Parser{ValidMethods: supportedAlgorithms, SkipClaimsValidation: true},
+	}
+}
+
+func (v *Verifier) ParseSession(ctx context.Context, accessToken string) (models.SessionInfo, error) {
+	claims, err := v.parseToken(ctx, accessToken)
+	if err != nil {
+		return models.SessionInfo{}, err
+	}
+	sessionInfo := models.SessionInfo{
+		AccountID: claims.AccountID,
+		Role:      claims.Role,
+		Scopes:    claims.Scope,
+	}
+	if len(sessionInfo.Scopes) == 0 {
+		sessionInfo.Scopes = models.RoleScopes(claims.Role)
+	}
+	return sessionInfo, nil
+}
+
+func (v *Verifier) parseToken(ctx context.Context, accessToken string) (*Claims, error) {
+	unverified, _, err := v.parser.ParseUnverified(accessToken, &Claims{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	kid, _ := unverified.Header["kid"].(string)
+	candidates := v.keys.Lookup(ctx, kid, unverified.Method.Alg())
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: kid %q, alg %s", ErrUnknownKey, kid, unverified.Method.Alg())
+	}
+	for _, key := range candidates {
+		claims := &Claims{}
+		token, err := v.parser.ParseWithClaims(accessToken, claims, func(*jwt.Token) (interface{}, error) {
+			return key, nil
+		})
+		if err != nil || !token.Valid {
+			continue
+		}
+		if err = v.validate(claims); err != nil {
+			return nil, err
+		}
+		return claims, nil
+	}
+	return nil, fmt.Errorf("%w: signature is invalid", ErrInvalidToken)
+}
+
+func (v *Verifier) validate(claims *Claims) error {
+	now := time.Now().Unix()
+	skew := int64(v.config.ClockSkew / time.Second)
+	switch {
+	case claims.ExpiresAt == nil && v.config.RequireExpiry:
+		return fmt.Errorf("%w: exp is required", ErrInvalidToken)
+	case claims.ExpiresAt != nil && now > *claims.ExpiresAt+skew:
+		return fmt.Errorf("%w: token is expired", ErrInvalidToken)
+	case claims.NotBefore != nil && now+skew < *claims.NotBefore:
+		return fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+	case claims.IssuedAt != nil && now+skew < *claims.IssuedAt:
+		return fmt.Errorf("%w: token is issued in the future", ErrInvalidToken)
+	case v.config.Issuer != "" && claims.Issuer != v.config.Issuer:
+		return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, claims.Issuer)
+	case v.config.Audience != "" && !claims.Audience.contains(v.config.Audience):
+		return fmt.Errorf("%w: token is not issued for %q", ErrInvalidToken, v.config.Audience)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+type StaticProvider struct {
+	rates map[string]models.Rate
+}
+
+func NewStaticProvider(rates map[string]models.Rate) *StaticProvider {
+	if rates == nil {
+		rates = make(map[string]models.Rate)
+	}
+	return &StaticProvider{
+		rates: rates,
+	}
+}
+
+// LoadStaticProvider reads rates from a JSON file of the form {"USD/RUB": "61.25"}.
+func LoadStaticProvider(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read exchange rates: %w", err)
+	}
+	rates := make(map[string]models.Rate)
+	if err = json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("unable to decode exchange rates: %w", err)
+	}
+	return NewStaticProvider(rates), nil
+}
+
+func (p *StaticProvider) GetRate(_ context.Context, from, to string) (models.Rate, error) {
+	if from == to {
+		return models.IdentityRate, nil
+	}
+	if rate, ok := p.rates[pair(from, to)]; ok {
+		return rate, nil
+	}
+	if rate, ok := p.rates[pair(to, from)]; ok {
+		return rate.Inverse(), nil
+	}
+	return 0, fmt.Errorf("%w: %s", models.ErrRateNotFound, pair(from, to))
+}
+
+func pair(from, to string) string {
+	return from + "/" + to
+}
@@ -0,0 +1,64 @@
+package grpcapi
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/DANDA322/balance-service/internal/rest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const auditMethod = "GRPC"
+
+// audit records every authenticated call like the REST middleware does. The status code is the gRPC one.
+func (s *server) audit(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	sessionInfo := ctx.Value(rest.SessionKey).(models.SessionInfo)
+	record := models.AuditRecord{
+		AccountID: sessionInfo.AccountID,
+		Role:      sessionInfo.Principal(),
+		SubjectID: sessionInfo.AccountID,
+		Method:    auditMethod,
+		Endpoint:  info.FullMethod,
+	}
+	if message, ok := req.(proto.Message); ok {
+		if payload, err := protojson.Marshal(message); err == nil {
+			record.Payload = string(payload)
+		}
+	}
+	if subject, ok := req.(interface{ GetAccountId() int64 }); ok && subject.GetAccountId() > 0 {
+		record.SubjectID = int(subject.GetAccountId())
+	}
+	mutating := !strings.HasPrefix(path.Base(info.FullMethod), "Get")
+	if mutating {
+		record.BalancesBefore = s.auditBalances(ctx, record.SubjectID)
+	}
+	resp, err := handler(ctx, req)
+	st := status.Convert(err)
+	record.StatusCode = int(st.Code())
+	record.Outcome = models.AuditOutcomeOK
+	if err != nil {
+		record.Outcome = st.Message()
+	}
+	if mutating {
+		record.BalancesAfter = s.auditBalances(ctx, record.SubjectID)
+	}
+	if auditErr := s.balance.RecordAudit(ctx, record); auditErr != nil {
+		s.log.Errorf("Error record audit: %v", auditErr)
+	}
+	return resp, err
+}
+
+func (s *server) auditBalances(ctx context.Context, accountID int) models.AuditBalances {
+	balances, err := s.balance.GetWalletBalances(ctx, accountID)
+	if err != nil {
+		s.log.Errorf("Error get balances for audit: %v", err)
+		return nil
+	}
+	return balances
+}
@@ -0,0 +1,51 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/DANDA322/balance-service/internal/auth"
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/DANDA322/balance-service/internal/rest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func (s *server) auth(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	values := md.Get("authorization")
+	if len(values) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	headerParts := strings.Split(values[0], " ")
+	if len(headerParts) != 2 {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	var sessionInfo models.SessionInfo
+	var err error
+	switch headerParts[0] {
+	case "Bearer":
+		sessionInfo, err = s.verifier.ParseSession(ctx, headerParts[1])
+	case "ApiKey":
+		sessionInfo, err = s.balance.AuthenticateAPIKey(ctx, headerParts[1])
+	default:
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrUnknownKey),
+		errors.Is(err, models.ErrAPIKeyNotFound):
+		s.log.Debugf("rejected credentials: %v", err)
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	default:
+		return nil, s.statusError("authenticate", err)
+	}
+	return handler(context.WithValue(ctx, rest.SessionKey, sessionInfo), req)
+}
@@ -0,0 +1,135 @@
+package grpcapi
+
+import (
+	"strconv"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/DANDA322/balance-service/pkg/balancepb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func transactionFromProto(req *balancepb.Transaction) (models.Transaction, error) {
+	amount, err := models.ParseMoney(req.GetAmount())
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return models.Transaction{
+		IdempotenceKey: int(req.GetIdempotenceKey()),
+		Amount:         amount,
+		Currency:       req.GetCurrency(),
+		Comment:        req.GetComment(),
+	}, nil
+}
+
+func transferFromProto(req *balancepb.TransferTransaction) (models.TransferTransaction, error) {
+	amount, err := models.ParseMoney(req.GetAmount())
+	if err != nil {
+		return models.TransferTransaction{}, err
+	}
+	return models.TransferTransaction{
+		IdempotenceKey: int(req.GetIdempotenceKey()),
+		Target:         int(req.GetTarget()),
+		Amount:         amount,
+		Currency:       req.GetCurrency(),
+		TargetCurrency: req.GetTargetCurrency(),
+		QuoteID:        req.GetQuoteId(),
+		Comment:        req.GetComment(),
+	}, nil
+}
+
+func reserveFromProto(req *balancepb.ReserveTransaction) (models.ReserveTransaction, error) {
+	amount, err := models.ParseMoney(req.GetAmount())
+	if err != nil {
+		return models.ReserveTransaction{}, err
+	}
+	transaction := models.ReserveTransaction{
+		IdempotenceKey: int(req.GetIdempotenceKey()),
+		AccountID:      int(req.GetAccountId()),
+		ServiceID:      int(req.GetServiceId()),
+		OrderID:        int(req.GetOrderId()),
+		Amount:         amount,
+		Currency:       req.GetCurrency(),
+		Final:          req.GetFinal(),
+		TTL:            int(req.GetTtl()),
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.GetExpiresAt().AsTime()
+		transaction.ExpiresAt = &expiresAt
+	}
+	return transaction, nil
+}
+
+func transactionsQueryFromProto(req *balancepb.GetTransactionsRequest) (models.TransactionsQueryParams, error) {
+	queryParams := models.TransactionsQueryParams{
+		Currency:     req.GetCurrency(),
+		From:         req.GetFrom().AsTime(),
+		Limit:        int(req.GetLimit()),
+		Offset:       int(req.GetOffset()),
+		Cursor:       req.GetCursor(),
+		Type:         req.GetType(),
+		Counterparty: int(req.GetCounterparty()),
+		ServiceID:    int(req.GetServiceId()),
+		Comment:      req.GetComment(),
+	}
+	if req.To != nil {
+		queryParams.To = req.GetTo().AsTime()
+	}
+	switch req.GetSorting() {
+	case balancepb.Sorting_SORTING_DATE:
+		queryParams.Sorting = models.SortingDate
+	case balancepb.Sorting_SORTING_AMOUNT:
+		queryParams.Sorting = models.SortingAmount
+	case balancepb.Sorting_SORTING_UNSPECIFIED:
+	}
+	if req.Descending != nil {
+		queryParams.Descending = strconv.FormatBool(req.GetDescending())
+	}
+	if req.MinAmount != nil {
+		amount, err := models.ParseMoney(req.GetMinAmount())
+		if err != nil {
+			return queryParams, err
+		}
+		queryParams.MinAmount = &amount
+	}
+	if req.MaxAmount != nil {
+		amount, err := models.ParseMoney(req.GetMaxAmount())
+		if err != nil {
+			return queryParams, err
+		}
+		queryParams.MaxAmount = &amount
+	}
+	return queryParams, nil
+}
+
+func transactionInfoToProto(t models.TransactionFullInfo) *balancepb.TransactionInfo {
+	info := &balancepb.TransactionInfo{
+		Id:        int64(t.ID),
+		Kind:      t.Kind,
+		Direction: t.Direction,
+		Amount:    t.Amount.String(),
+		Currency:  t.Currency,
+		Comment:   t.Comment,
+		Timestamp: timestamppb.New(t.Timestamp),
+	}
+	if t.Counterparty != nil {
+		counterparty := int64(*t.Counterparty)
+		info.Counterparty = &counterparty
+	}
+	if t.TargetAmount != nil {
+		targetAmount := t.TargetAmount.String()
+		info.TargetAmount = &targetAmount
+	}
+	if t.ExchangeRate != nil {
+		exchangeRate := t.ExchangeRate.String()
+		info.ExchangeRate = &exchangeRate
+	}
+	if t.ServiceID != nil {
+		serviceID := int64(*t.ServiceID)
+		info.ServiceId = &serviceID
+	}
+	if t.ReversalOf != nil {
+		reversalOf := int64(*t.ReversalOf)
+		info.ReversalOf = &reversalOf
+	}
+	return info
+}
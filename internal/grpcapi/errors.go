@@ -0,0 +1,55 @@
+package grpcapi
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *server) statusError(operation string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgerrcode.UniqueViolation == pgErr.SQLState() {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	switch {
+	case errors.Is(err, models.ErrInvalidAmount), errors.Is(err, models.ErrInvalidCurrency),
+		errors.Is(err, models.ErrInvalidExpiry), errors.Is(err, models.ErrInvalidRate),
+		errors.Is(err, models.ErrInvalidFilter), errors.Is(err, models.ErrInvalidCursor),
+		errors.Is(err, models.ErrIdempotenceKeyRequired), errors.Is(err, models.ErrInvalidIdempotenceKey):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, models.ErrWalletNotFound), errors.Is(err, models.ErrOrderNotFound),
+		errors.Is(err, models.ErrServiceNotFound), errors.Is(err, models.ErrRateNotFound),
+		errors.Is(err, models.ErrQuoteNotFound):
+		return status.Error(codes.NotFound, unwrapModelError(err).Error())
+	case errors.Is(err, models.ErrCurrencyMismatch), errors.Is(err, models.ErrNotEnoughMoney),
+		errors.Is(err, models.ErrNotEnoughReservedMoney), errors.Is(err, models.ErrServiceInactive),
+		errors.Is(err, models.ErrOrderNotCompleted), errors.Is(err, models.ErrRefundExceedsCaptured):
+		return status.Error(codes.FailedPrecondition, unwrapModelError(err).Error())
+	default:
+		s.log.Errorf("Error %s: %v", operation, err)
+		return status.Error(codes.Internal, fmt.Sprintf("Internal server error: %v", err))
+	}
+}
+
+// unwrapModelError hides storage wrapping from clients, as the REST handlers do for these errors.
+func unwrapModelError(err error) error {
+	for _, target := range []error{
+		models.ErrWalletNotFound, models.ErrOrderNotFound, models.ErrServiceNotFound, models.ErrRateNotFound,
+		models.ErrQuoteNotFound, models.ErrCurrencyMismatch, models.ErrNotEnoughMoney,
+		models.ErrNotEnoughReservedMoney, models.ErrServiceInactive, models.ErrOrderNotCompleted,
+		models.ErrRefundExceedsCaptured,
+	} {
+		if errors.Is(err, target) {
+			return target
+		}
+	}
+	return err
+}
@@ -0,0 +1,37 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/DANDA322/balance-service/internal/rest"
+	"github.com/DANDA322/balance-service/pkg/balancepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var permissions = map[string]string{
+	balancepb.BalanceService_GetBalance_FullMethodName:      models.ScopeWalletRead,
+	balancepb.BalanceService_GetTransactions_FullMethodName: models.ScopeWalletRead,
+	balancepb.BalanceService_AddDeposit_FullMethodName:      models.ScopeWalletDeposit,
+	balancepb.BalanceService_WithdrawMoney_FullMethodName:   models.ScopeWalletWithdraw,
+	balancepb.BalanceService_TransferMoney_FullMethodName:   models.ScopeWalletTransfer,
+	balancepb.BalanceService_ReserveMoney_FullMethodName:    models.ScopeReserveWrite,
+	balancepb.BalanceService_ApplyReserve_FullMethodName:    models.ScopeReserveWrite,
+	balancepb.BalanceService_CancelReserve_FullMethodName:   models.ScopeReserveWrite,
+	balancepb.BalanceService_GetReport_FullMethodName:       models.ScopeReportRead,
+}
+
+func (s *server) authorize(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	scope, ok := permissions[info.FullMethod]
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "Forbidden")
+	}
+	if !ctx.Value(rest.SessionKey).(models.SessionInfo).HasScope(scope) {
+		return nil, status.Error(codes.PermissionDenied, fmt.Sprintf("scope %s is required", scope))
+	}
+	return handler(ctx, req)
+}
@@ -0,0 +1,199 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/auth"
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/DANDA322/balance-service/internal/rest"
+	"github.com/DANDA322/balance-service/pkg/balancepb"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	yearMonthLayout = "2006-01"
+)
+
+type Balance interface {
+	AddDeposit(ctx context.Context, accountID int, transaction models.Transaction) error
+	GetBalance(ctx context.Context, accountID int, currency string) (*models.Balance, error)
+	WithdrawMoney(ctx context.Context, accountID int, transaction models.Transaction) error
+	TransferMoney(ctx context.Context, accountID int, transaction models.TransferTransaction) error
+	ReserveMoney(ctx context.Context, transaction models.ReserveTransaction) error
+	ApplyReservedMoney(ctx context.Context, transaction models.ReserveTransaction) error
+	GetWalletTransaction(ctx context.Context, accountID int,
+		queryParams *models.TransactionsQueryParams) (*models.TransactionsPage, error)
+	CancelReserve(ctx context.Context, transaction models.ReserveTransaction) error
+	GetReport(ctx context.Context, month time.Time) ([]models.ReportRow, error)
+	GetWalletBalances(ctx context.Context, accountID int) ([]models.WalletBalance, error)
+	RecordAudit(ctx context.Context, record models.AuditRecord) error
+	AuthenticateAPIKey(ctx context.Context, plain string) (models.SessionInfo, error)
+}
+
+type server struct {
+	balancepb.UnimplementedBalanceServiceServer
+	log      *logrus.Logger
+	balance  Balance
+	verifier *auth.Verifier
+}
+
+func NewServer(log *logrus.Logger, balance Balance, verifier *auth.Verifier) *grpc.Server {
+	srv := &server{
+		log:      log,
+		balance:  balance,
+		verifier: verifier,
+	}
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(srv.recoverer, srv.auth, srv.audit, srv.authorize))
+	balancepb.RegisterBalanceServiceServer(s, srv)
+	return s
+}
+
+func (s *server) GetBalance(ctx context.Context, req *balancepb.GetBalanceRequest) (*balancepb.Balance, error) {
+	sessionInfo := ctx.Value(rest.SessionKey).(models.SessionInfo)
+	balance, err := s.balance.GetBalance(ctx, sessionInfo.AccountID, req.GetCurrency())
+	if err != nil {
+		return nil, s.statusError("get balance", err)
+	}
+	return &balancepb.Balance{Currency: balance.Currency, Amount: balance.Amount.String()}, nil
+}
+
+func (s *server) AddDeposit(ctx context.Context, req *balancepb.Transaction) (*emptypb.Empty, error) {
+	transaction, err := transactionFromProto(req)
+	if err != nil {
+		return nil, s.statusError("deposit money", err)
+	}
+	sessionInfo := ctx.Value(rest.SessionKey).(models.SessionInfo)
+	if err = s.balance.AddDeposit(ctx, sessionInfo.AccountID, transaction); err != nil {
+		return nil, s.statusError("deposit money", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *server) WithdrawMoney(ctx context.Context, req *balancepb.Transaction) (*emptypb.Empty, error) {
+	transaction, err := transactionFromProto(req)
+	if err != nil {
+		return nil, s.statusError("withdraw money", err)
+	}
+	sessionInfo := ctx.Value(rest.SessionKey).(models.SessionInfo)
+	if err = s.balance.WithdrawMoney(ctx, sessionInfo.AccountID, transaction); err != nil {
+		return nil, s.statusError("withdraw money", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *server) TransferMoney(ctx context.Context, req *balancepb.TransferTransaction) (*emptypb.Empty, error) {
+	transaction, err := transferFromProto(req)
+	if err != nil {
+		return nil, s.statusError("transfer money", err)
+	}
+	sessionInfo := ctx.Value(rest.SessionKey).(models.SessionInfo)
+	if err = s.balance.TransferMoney(ctx, sessionInfo.AccountID, transaction); err != nil {
+		return nil, s.statusError("transfer money", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *server) ReserveMoney(ctx context.Context, req *balancepb.ReserveTransaction) (*emptypb.Empty, error) {
+	transaction, err := s.reserveFromProto(ctx, req)
+	if err != nil {
+		return nil, s.statusError("reserve money", err)
+	}
+	if err = s.balance.ReserveMoney(ctx, transaction); err != nil {
+		return nil, s.statusError("reserve money", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *server) ApplyReserve(ctx context.Context, req *balancepb.ReserveTransaction) (*emptypb.Empty, error) {
+	transaction, err := s.reserveFromProto(ctx, req)
+	if err != nil {
+		return nil, s.statusError("recognize money", err)
+	}
+	if err = s.balance.ApplyReservedMoney(ctx, transaction); err != nil {
+		return nil, s.statusError("recognize money", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *server) CancelReserve(ctx context.Context, req *balancepb.ReserveTransaction) (*emptypb.Empty, error) {
+	transaction, err := s.reserveFromProto(ctx, req)
+	if err != nil {
+		return nil, s.statusError("cancel reserve", err)
+	}
+	if err = s.balance.CancelReserve(ctx, transaction); err != nil {
+		return nil, s.statusError("cancel reserve", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *server) GetTransactions(ctx context.Context,
+	req *balancepb.GetTransactionsRequest) (*balancepb.GetTransactionsResponse, error) {
+	sessionInfo := ctx.Value(rest.SessionKey).(models.SessionInfo)
+	queryParams, err := transactionsQueryFromProto(req)
+	if err != nil {
+		return nil, s.statusError("get wallet transactions", err)
+	}
+	page, err := s.balance.GetWalletTransaction(ctx, sessionInfo.AccountID, &queryParams)
+	if err != nil {
+		return nil, s.statusError("get wallet transactions", err)
+	}
+	resp := &balancepb.GetTransactionsResponse{
+		Transactions: make([]*balancepb.TransactionInfo, 0, len(page.Transactions)),
+		NextCursor:   page.NextCursor,
+	}
+	for i := range page.Transactions {
+		resp.Transactions = append(resp.Transactions, transactionInfoToProto(page.Transactions[i]))
+	}
+	return resp, nil
+}
+
+func (s *server) GetReport(ctx context.Context, req *balancepb.GetReportRequest) (*balancepb.GetReportResponse, error) {
+	month, err := time.Parse(yearMonthLayout, req.GetMonth())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Can't parse time")
+	}
+	rows, err := s.balance.GetReport(ctx, month)
+	if err != nil {
+		return nil, s.statusError("get report", err)
+	}
+	resp := &balancepb.GetReportResponse{Rows: make([]*balancepb.ReportRow, 0, len(rows))}
+	for _, row := range rows {
+		resp.Rows = append(resp.Rows, &balancepb.ReportRow{
+			ServiceTitle: row.ServiceTitle,
+			Currency:     row.Currency,
+			Amount:       row.Amount.String(),
+		})
+	}
+	return resp, nil
+}
+
+func (s *server) reserveFromProto(ctx context.Context,
+	req *balancepb.ReserveTransaction) (models.ReserveTransaction, error) {
+	transaction, err := reserveFromProto(req)
+	if err != nil {
+		return transaction, err
+	}
+	sessionInfo := ctx.Value(rest.SessionKey).(models.SessionInfo)
+	if (!sessionInfo.HasScope(models.ScopeReserveAny) && transaction.AccountID != sessionInfo.AccountID) ||
+		!sessionInfo.AllowsService(transaction.ServiceID) {
+		return transaction, status.Error(codes.PermissionDenied, "Forbidden")
+	}
+	return transaction, nil
+}
+
+func (s *server) recoverer(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			s.log.Errorf("panic in %s: %v", info.FullMethod, rec)
+			err = status.Error(codes.Internal, fmt.Sprintf("Internal server error: %v", rec))
+		}
+	}()
+	return handler(ctx, req)
+}
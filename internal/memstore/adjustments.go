@@ -0,0 +1,186 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+func (db *DB) CreateAdjustment(ctx context.Context, adjustment models.Adjustment) (*models.Adjustment, error) {
+	var created models.Adjustment
+	err := db.update(func() error {
+		stored := &models.Adjustment{
+			ID:         len(db.adjustments) + 1,
+			AccountID:  adjustment.AccountID,
+			Amount:     adjustment.Amount,
+			Currency:   adjustment.Currency,
+			Comment:    adjustment.Comment,
+			Reason:     adjustment.Reason,
+			Attachment: adjustment.Attachment,
+			Status:     models.AdjustmentPending,
+			ProposedBy: adjustment.ProposedBy,
+			CreatedAt:  adjustment.CreatedAt.UTC().Truncate(time.Second),
+			ExpiresAt:  adjustment.ExpiresAt.UTC().Truncate(time.Second),
+		}
+		db.adjustments = append(db.adjustments, stored)
+		created = copyAdjustment(stored)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (db *DB) GetAdjustment(ctx context.Context, adjustmentID int) (*models.Adjustment, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if adjustmentID <= 0 || adjustmentID > len(db.adjustments) {
+		return nil, models.ErrAdjustmentNotFound
+	}
+	adjustment := copyAdjustment(db.adjustments[adjustmentID-1])
+	return &adjustment, nil
+}
+
+func (db *DB) GetAdjustments(ctx context.Context,
+	queryParams *models.AdjustmentsQueryParams) ([]models.Adjustment, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	adjustments := make([]models.Adjustment, 0)
+	for i := len(db.adjustments) - 1; i >= 0; i-- {
+		adjustment := db.adjustments[i]
+		if (queryParams.AccountID != 0 && adjustment.AccountID != queryParams.AccountID) ||
+			(queryParams.Status != "" && adjustment.Status != queryParams.Status) {
+			continue
+		}
+		adjustments = append(adjustments, copyAdjustment(adjustment))
+	}
+	from, to := page(len(adjustments), queryParams.Offset, queryParams.Limit)
+	return adjustments[from:to], nil
+}
+
+// ApproveAdjustment applies a pending adjustment to the wallet and marks it approved in one step.
+func (db *DB) ApproveAdjustment(ctx context.Context, reviewerID int, review models.AdjustmentReview,
+	now time.Time) (*models.Adjustment, error) {
+	err := db.update(func() error {
+		adjustment, err := db.lockPendingAdjustment(reviewerID, review.ID, now)
+		if err != nil {
+			return fmt.Errorf("err executing [ApproveAdjustment]: %w", err)
+		}
+		transactionID, err := db.adjustBalance(adjustment.AccountID, adjustment.Transaction(reviewerID))
+		if err != nil {
+			return fmt.Errorf("err executing [ApproveAdjustment]: %w", err)
+		}
+		db.reviewAdjustment(adjustment, models.AdjustmentApproved, reviewerID, review, &transactionID, now)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.GetAdjustment(ctx, review.ID)
+}
+
+func (db *DB) RejectAdjustment(ctx context.Context, reviewerID int, review models.AdjustmentReview,
+	now time.Time) (*models.Adjustment, error) {
+	err := db.update(func() error {
+		adjustment, err := db.lockPendingAdjustment(reviewerID, review.ID, now)
+		if err != nil {
+			return fmt.Errorf("err executing [RejectAdjustment]: %w", err)
+		}
+		db.reviewAdjustment(adjustment, models.AdjustmentRejected, reviewerID, review, nil, now)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.GetAdjustment(ctx, review.ID)
+}
+
+func (db *DB) ExpireAdjustments(ctx context.Context, now time.Time) (int, error) {
+	var count int
+	err := db.update(func() error {
+		for _, adjustment := range db.adjustments {
+			if adjustment.Status == models.AdjustmentPending && !adjustment.ExpiresAt.After(now) {
+				adjustment.Status = models.AdjustmentExpired
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (db *DB) lockPendingAdjustment(reviewerID, adjustmentID int, now time.Time) (*models.Adjustment, error) {
+	if adjustmentID <= 0 || adjustmentID > len(db.adjustments) {
+		return nil, models.ErrAdjustmentNotFound
+	}
+	adjustment := db.adjustments[adjustmentID-1]
+	switch {
+	case adjustment.Status != models.AdjustmentPending:
+		return nil, fmt.Errorf("%w: adjustment is %s", models.ErrAdjustmentNotPending, adjustment.Status)
+	case !adjustment.ExpiresAt.After(now):
+		return nil, models.ErrAdjustmentExpired
+	case adjustment.ProposedBy == reviewerID:
+		return nil, models.ErrSelfReview
+	}
+	return adjustment, nil
+}
+
+func (db *DB) reviewAdjustment(adjustment *models.Adjustment, status string, reviewerID int,
+	review models.AdjustmentReview, transactionID *int, now time.Time) {
+	reviewedAt := now.UTC().Truncate(time.Second)
+	adjustment.Status = status
+	adjustment.ReviewedBy = &reviewerID
+	adjustment.ReviewComment = review.Comment
+	adjustment.TransactionID = copyInt(transactionID)
+	adjustment.ReviewedAt = &reviewedAt
+}
+
+// adjustBalance applies a signed correction to the wallet. Negative adjustments cannot overdraw it.
+func (db *DB) adjustBalance(ownerID int, transaction models.Transaction) (int, error) {
+	var wallet *models.Wallet
+	var err error
+	if err = db.checkIdempotenceKey(&transaction.IdempotenceKey); err != nil {
+		return 0, fmt.Errorf("err executing [adjustBalance]: %w", err)
+	}
+	if transaction.Amount < 0 {
+		if wallet, err = db.checkBalance(ownerID, transaction.Currency, -transaction.Amount); err != nil {
+			return 0, fmt.Errorf("err executing [adjustBalance]: %w", err)
+		}
+	} else {
+		var ok bool
+		if wallet, ok = db.wallet(ownerID, transaction.Currency); !ok {
+			return 0, fmt.Errorf("err executing [adjustBalance]: %w", db.walletNotFoundReason(ownerID))
+		}
+	}
+	db.depositToWallet(wallet, transaction.Amount)
+	transactionID := db.insertTransaction(transactionRecord{
+		idempotenceKey: &transaction.IdempotenceKey,
+		kind:           models.TransactionKindAdjustment,
+		walletID:       wallet.ID,
+		amount:         transaction.Amount,
+		comment:        transaction.Comment,
+	})
+	db.insertJournalEntry(entryAdjustment, transactionReference(transactionID), &transactionID,
+		adjustments(transaction.Currency, -transaction.Amount),
+		available(ownerID, transaction.Currency, transaction.Amount))
+	db.insertEvent(models.EventBalanceAdjusted, models.BalanceEvent{
+		AccountID:     ownerID,
+		TransactionID: &transactionID,
+		Currency:      transaction.Currency,
+		Amount:        transaction.Amount,
+		AdminID:       transaction.Actor.ID(),
+	})
+	db.insertAdminAction(models.AdminActionAdjustment, ownerID, transactionID, transaction)
+	return transactionID, nil
+}
+
+func copyAdjustment(adjustment *models.Adjustment) models.Adjustment {
+	c := *adjustment
+	c.ReviewedBy = copyInt(adjustment.ReviewedBy)
+	c.TransactionID = copyInt(adjustment.TransactionID)
+	c.ReviewedAt = copyTime(adjustment.ReviewedAt)
+	return c
+}
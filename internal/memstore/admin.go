@@ -0,0 +1,50 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+// AdjustBalance applies a signed correction to the wallet right away, without a second admin's approval.
+func (db *DB) AdjustBalance(ctx context.Context, ownerID int, transaction models.Transaction) error {
+	return db.update(func() error {
+		if _, err := db.adjustBalance(ownerID, transaction); err != nil {
+			return fmt.Errorf("err executing [AdjustBalance]: %w", err)
+		}
+		return nil
+	})
+}
+
+func (db *DB) GetAdminActions(ctx context.Context,
+	queryParams *models.AdminActionsQueryParams) ([]models.AdminAction, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	actions := make([]models.AdminAction, 0)
+	for i := len(db.adminActions) - 1; i >= 0; i-- {
+		action := *db.adminActions[i]
+		if (queryParams.AccountID != 0 && action.AccountID != queryParams.AccountID) ||
+			(queryParams.AdminID != 0 && action.AdminID != queryParams.AdminID) {
+			continue
+		}
+		action.TransactionID = copyInt(action.TransactionID)
+		actions = append(actions, action)
+	}
+	from, to := page(len(actions), queryParams.Offset, queryParams.Limit)
+	return actions[from:to], nil
+}
+
+func (db *DB) insertAdminAction(action string, accountID, transactionID int, transaction models.Transaction) {
+	db.adminActions = append(db.adminActions, &models.AdminAction{
+		ID:            len(db.adminActions) + 1,
+		AdminID:       transaction.Actor.AdminID,
+		AccountID:     accountID,
+		Action:        action,
+		TransactionID: &transactionID,
+		Currency:      transaction.Currency,
+		Amount:        transaction.Amount,
+		Reason:        transaction.Actor.Reason,
+		CreatedAt:     timestamp(),
+	})
+}
@@ -0,0 +1,142 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+func (db *DB) CreateAPIKey(ctx context.Context, key models.APIKey) (*models.APIKey, error) {
+	var created models.APIKey
+	err := db.update(func() error {
+		if _, ok := db.apiKey(key.Prefix); ok {
+			return fmt.Errorf("err executing [CreateAPIKey]: %w", uniqueViolation("api_keys_key_prefix_key"))
+		}
+		key.RotatedFrom = nil
+		created = copyAPIKey(db.insertAPIKey(key))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (db *DB) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	keys := make([]models.APIKey, 0, len(db.apiKeys))
+	for _, key := range db.apiKeys {
+		keys = append(keys, copyAPIKey(key))
+	}
+	return keys, nil
+}
+
+func (db *DB) GetAPIKey(ctx context.Context, keyID int) (*models.APIKey, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if keyID <= 0 || keyID > len(db.apiKeys) {
+		return nil, models.ErrAPIKeyNotFound
+	}
+	key := copyAPIKey(db.apiKeys[keyID-1])
+	return &key, nil
+}
+
+func (db *DB) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	stored, ok := db.apiKey(prefix)
+	if !ok {
+		return nil, models.ErrAPIKeyNotFound
+	}
+	key := copyAPIKey(stored)
+	return &key, nil
+}
+
+// RevokeAPIKey never moves an earlier revocation, e.g. a rotation grace period, to a later time.
+func (db *DB) RevokeAPIKey(ctx context.Context, keyID int, at time.Time) (*models.APIKey, error) {
+	var revoked models.APIKey
+	err := db.update(func() error {
+		if keyID <= 0 || keyID > len(db.apiKeys) {
+			return models.ErrAPIKeyNotFound
+		}
+		key := db.apiKeys[keyID-1]
+		at = at.UTC().Truncate(time.Second)
+		if key.RevokedAt == nil || at.Before(*key.RevokedAt) {
+			key.RevokedAt = &at
+		}
+		revoked = copyAPIKey(key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &revoked, nil
+}
+
+// RotateAPIKey stores the replacement of an active key and schedules the revocation of the old one.
+func (db *DB) RotateAPIKey(ctx context.Context, key models.APIKey, revokeAt time.Time) (*models.APIKey, error) {
+	var rotated models.APIKey
+	err := db.update(func() error {
+		oldID := *key.RotatedFrom
+		now := key.CreatedAt.UTC().Truncate(time.Second)
+		if oldID <= 0 || oldID > len(db.apiKeys) || !db.apiKeys[oldID-1].Active(now) {
+			return fmt.Errorf("%w: key is revoked or expired", models.ErrAPIKeyNotFound)
+		}
+		if _, ok := db.apiKey(key.Prefix); ok {
+			return fmt.Errorf("err executing [RotateAPIKey]: %w", uniqueViolation("api_keys_key_prefix_key"))
+		}
+		revokeAt = revokeAt.UTC().Truncate(time.Second)
+		db.apiKeys[oldID-1].RevokedAt = &revokeAt
+		key.RotatedFrom = &oldID
+		rotated = copyAPIKey(db.insertAPIKey(key))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rotated, nil
+}
+
+func (db *DB) TouchAPIKey(ctx context.Context, keyID int, now time.Time) error {
+	return db.update(func() error {
+		if keyID <= 0 || keyID > len(db.apiKeys) {
+			return nil
+		}
+		now = now.UTC().Truncate(time.Second)
+		db.apiKeys[keyID-1].LastUsedAt = &now
+		return nil
+	})
+}
+
+func (db *DB) insertAPIKey(key models.APIKey) *models.APIKey {
+	key.ID = len(db.apiKeys) + 1
+	key.Key = ""
+	key.CreatedAt = key.CreatedAt.UTC().Truncate(time.Second)
+	key.RevokedAt, key.LastUsedAt = nil, nil
+	stored := copyAPIKey(&key)
+	db.apiKeys = append(db.apiKeys, &stored)
+	return &stored
+}
+
+func (db *DB) apiKey(prefix string) (*models.APIKey, bool) {
+	for _, key := range db.apiKeys {
+		if key.Prefix == prefix {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func copyAPIKey(key *models.APIKey) models.APIKey {
+	c := *key
+	c.Scopes = append(models.ScopeList(nil), key.Scopes...)
+	c.ServiceIDs = append(models.ServiceIDList(nil), key.ServiceIDs...)
+	c.RotatedFrom = copyInt(key.RotatedFrom)
+	c.ExpiresAt = copyTime(key.ExpiresAt)
+	c.RevokedAt = copyTime(key.RevokedAt)
+	c.LastUsedAt = copyTime(key.LastUsedAt)
+	return c
+}
@@ -0,0 +1,66 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+// InsertAuditRecord appends a record to the audit log and chains it to the last one.
+func (db *DB) InsertAuditRecord(ctx context.Context, record models.AuditRecord) error {
+	return db.update(func() error {
+		record.PrevHash = ""
+		if len(db.auditLog) > 0 {
+			record.PrevHash = db.auditLog[len(db.auditLog)-1].Hash
+		}
+		record.Hash = record.Digest()
+		record.ID = int64(len(db.auditLog) + 1)
+		record.BalancesBefore = copyBalances(record.BalancesBefore)
+		record.BalancesAfter = copyBalances(record.BalancesAfter)
+		db.auditLog = append(db.auditLog, record)
+		return nil
+	})
+}
+
+// GetAuditRecords returns up to limit records with ids after AfterID in chain order.
+func (db *DB) GetAuditRecords(ctx context.Context, queryParams *models.AuditQueryParams) ([]models.AuditRecord, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	records := make([]models.AuditRecord, 0)
+	for _, record := range db.auditLog {
+		if len(records) == queryParams.Limit || (queryParams.ToID != 0 && record.ID > queryParams.ToID) {
+			break
+		}
+		if record.ID <= queryParams.AfterID || record.CreatedAt.Before(queryParams.From) ||
+			!record.CreatedAt.Before(queryParams.To) {
+			continue
+		}
+		record.BalancesBefore = copyBalances(record.BalancesBefore)
+		record.BalancesAfter = copyBalances(record.BalancesAfter)
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// GetAuditHash returns the hash of the last record with an id not greater than auditID,
+// or an empty string at the start of the chain.
+func (db *DB) GetAuditHash(ctx context.Context, auditID int64) (string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if auditID <= 0 || len(db.auditLog) == 0 {
+		return "", nil
+	}
+	if auditID > int64(len(db.auditLog)) {
+		auditID = int64(len(db.auditLog))
+	}
+	return db.auditLog[auditID-1].Hash, nil
+}
+
+func copyBalances(balances models.AuditBalances) models.AuditBalances {
+	if balances == nil {
+		return nil
+	}
+	c := make(models.AuditBalances, len(balances))
+	copy(c, balances)
+	return c
+}
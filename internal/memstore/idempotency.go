@@ -0,0 +1,89 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+type idempotencyKey struct {
+	accountID int
+	apiKeyID  int
+	key       string
+	endpoint  string
+}
+
+func recordKey(record models.IdempotencyRecord) idempotencyKey {
+	return idempotencyKey{accountID: record.AccountID, apiKeyID: record.APIKeyID, key: record.Key,
+		endpoint: record.Endpoint}
+}
+
+func (db *DB) InsertIdempotencyKey(ctx context.Context,
+	record models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	var existing *models.IdempotencyRecord
+	err := db.update(func() error {
+		key := recordKey(record)
+		stored, ok := db.idempotency[key]
+		now := time.Now()
+		if ok && (stored.ExpiresAt.Before(now) || (!stored.Completed() && stored.LockedUntil.Before(now))) {
+			ok = false
+		}
+		if ok {
+			existing = copyIdempotencyRecord(stored)
+			return nil
+		}
+		db.idempotency[key] = &models.IdempotencyRecord{
+			AccountID:   record.AccountID,
+			APIKeyID:    record.APIKeyID,
+			Key:         record.Key,
+			Endpoint:    record.Endpoint,
+			Fingerprint: record.Fingerprint,
+			LockedUntil: record.LockedUntil.UTC(),
+			ExpiresAt:   record.ExpiresAt.UTC(),
+		}
+		return nil
+	})
+	return existing, err
+}
+
+func (db *DB) CompleteIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) error {
+	return db.update(func() error {
+		if stored, ok := db.idempotency[recordKey(record)]; ok {
+			stored.StatusCode = copyInt(record.StatusCode)
+			stored.ContentType = record.ContentType
+			stored.Body = append([]byte(nil), record.Body...)
+		}
+		return nil
+	})
+}
+
+func (db *DB) DeleteIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) error {
+	return db.update(func() error {
+		delete(db.idempotency, recordKey(record))
+		return nil
+	})
+}
+
+func (db *DB) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int, error) {
+	var count int
+	err := db.update(func() error {
+		for key, stored := range db.idempotency {
+			if stored.ExpiresAt.Before(now) {
+				delete(db.idempotency, key)
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func copyIdempotencyRecord(record *models.IdempotencyRecord) *models.IdempotencyRecord {
+	c := *record
+	c.StatusCode = copyInt(record.StatusCode)
+	if record.Body != nil {
+		c.Body = append([]byte(nil), record.Body...)
+	}
+	return &c
+}
@@ -0,0 +1,186 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+// Ledger accounts hold signed postings the same way they do in pgstore.
+const (
+	accountUserAvailable = "user_available"
+	accountUserReserved  = "user_reserved"
+	accountRevenue       = "company_revenue"
+	accountExternalCash  = "external_cash"
+	accountFXClearing    = "fx_clearing"
+	accountAdjustments   = "balance_adjustments"
+
+	systemOwnerID = 0
+)
+
+const (
+	entryDeposit       = "deposit"
+	entryWithdrawal    = "withdrawal"
+	entryTransfer      = "transfer"
+	entryReserve       = "reserve"
+	entryReserveApply  = "reserve_apply"
+	entryReserveCancel = "reserve_cancel"
+	entryReserveExpire = "reserve_expire"
+	entryRefund        = "refund"
+	entryAdjustment    = "adjustment"
+)
+
+type posting struct {
+	accountType string
+	ownerID     int
+	currency    string
+	amount      models.Money
+}
+
+type journalEntry struct {
+	id            int
+	kind          string
+	reference     string
+	transactionID *int
+	createdAt     time.Time
+	postings      []posting
+}
+
+func available(ownerID int, currency string, amount models.Money) posting {
+	return posting{accountType: accountUserAvailable, ownerID: ownerID, currency: currency, amount: amount}
+}
+
+func reserved(ownerID int, currency string, amount models.Money) posting {
+	return posting{accountType: accountUserReserved, ownerID: ownerID, currency: currency, amount: amount}
+}
+
+func revenue(currency string, amount models.Money) posting {
+	return posting{accountType: accountRevenue, ownerID: systemOwnerID, currency: currency, amount: amount}
+}
+
+func externalCash(currency string, amount models.Money) posting {
+	return posting{accountType: accountExternalCash, ownerID: systemOwnerID, currency: currency, amount: amount}
+}
+
+func fxClearing(currency string, amount models.Money) posting {
+	return posting{accountType: accountFXClearing, ownerID: systemOwnerID, currency: currency, amount: amount}
+}
+
+func adjustments(currency string, amount models.Money) posting {
+	return posting{accountType: accountAdjustments, ownerID: systemOwnerID, currency: currency, amount: amount}
+}
+
+func transferPostings(accountID int, transaction models.TransferTransaction, conversion models.Conversion) []posting {
+	if conversion.TargetCurrency == transaction.Currency {
+		return []posting{
+			available(accountID, transaction.Currency, -transaction.Amount),
+			available(transaction.Target, conversion.TargetCurrency, conversion.TargetAmount),
+		}
+	}
+	return []posting{
+		available(accountID, transaction.Currency, -transaction.Amount),
+		fxClearing(transaction.Currency, transaction.Amount),
+		fxClearing(conversion.TargetCurrency, -conversion.TargetAmount),
+		available(transaction.Target, conversion.TargetCurrency, conversion.TargetAmount),
+	}
+}
+
+func transactionReference(transactionID int) string {
+	return fmt.Sprintf("transaction:%d", transactionID)
+}
+
+func orderReference(orderID int) string {
+	return fmt.Sprintf("order:%d", orderID)
+}
+
+func (db *DB) insertJournalEntry(kind, reference string, transactionID *int, postings ...posting) {
+	db.entries = append(db.entries, &journalEntry{
+		id:            len(db.entries) + 1,
+		kind:          kind,
+		reference:     reference,
+		transactionID: copyInt(transactionID),
+		createdAt:     timestamp(),
+		postings:      postings,
+	})
+}
+
+func (db *DB) CheckLedger(ctx context.Context) (*models.LedgerCheck, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	check := models.LedgerCheck{
+		UnbalancedEntries: make([]int, 0),
+		MismatchedWallets: make([]int, 0),
+	}
+	balances := make(map[posting]models.Money)
+	for _, entry := range db.entries {
+		sums := make(map[string]models.Money)
+		for _, p := range entry.postings {
+			sums[p.currency] += p.amount
+			balances[posting{accountType: p.accountType, ownerID: p.ownerID, currency: p.currency}] += p.amount
+		}
+		for _, sum := range sums {
+			if sum != 0 {
+				check.UnbalancedEntries = append(check.UnbalancedEntries, entry.id)
+				break
+			}
+		}
+	}
+	for _, wallet := range db.wallets {
+		if wallet.Balance != balances[available(wallet.Owner, wallet.Currency, 0)] ||
+			wallet.ReservedBalance != balances[reserved(wallet.Owner, wallet.Currency, 0)] {
+			check.MismatchedWallets = append(check.MismatchedWallets, wallet.ID)
+		}
+	}
+	check.Balanced = len(check.UnbalancedEntries) == 0 && len(check.MismatchedWallets) == 0
+	return &check, nil
+}
+
+func (db *DB) GetStatement(ctx context.Context, accountID int, currency string,
+	from, to time.Time) (*models.Statement, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	wallet, ok := db.wallet(accountID, currency)
+	if !ok {
+		return nil, models.ErrWalletNotFound
+	}
+	statement := &models.Statement{
+		AccountID:     accountID,
+		Currency:      currency,
+		From:          from,
+		To:            to,
+		WalletBalance: wallet.Balance,
+		Lines:         make([]models.StatementLine, 0),
+	}
+	for _, entry := range db.entries {
+		var amount models.Money
+		var posted bool
+		for _, p := range entry.postings {
+			if p.accountType == accountUserAvailable && p.ownerID == accountID && p.currency == currency {
+				amount += p.amount
+				posted = true
+			}
+		}
+		switch {
+		case !posted:
+		case entry.createdAt.Before(from):
+			statement.OpeningBalance += amount
+		case entry.createdAt.Before(to):
+			line := models.StatementLine{
+				EntryID:       entry.id,
+				Kind:          entry.kind,
+				Reference:     entry.reference,
+				TransactionID: copyInt(entry.transactionID),
+				Amount:        amount,
+				Timestamp:     entry.createdAt,
+			}
+			if entry.transactionID != nil {
+				line.Comment = db.transactions[*entry.transactionID-1].comment
+			}
+			statement.Lines = append(statement.Lines, line)
+		}
+	}
+	statement.Close()
+	return statement, nil
+}
@@ -0,0 +1,1084 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const defaultServiceTitle = "Услуга"
+
+type walletKey struct {
+	ownerID  int
+	currency string
+}
+
+type transaction struct {
+	transactionRecord
+	id        int
+	timestamp time.Time
+}
+
+type capture struct {
+	id            int
+	orderID       int
+	serviceID     int
+	serviceTitle  string
+	currency      string
+	amount        models.Money
+	refunded      models.Money
+	transactionID int
+	createdAt     time.Time
+}
+
+type refund struct {
+	id            int
+	orderID       int
+	ownerID       int
+	serviceID     int
+	serviceTitle  string
+	currency      string
+	amount        models.Money
+	transactionID int
+	captures      []captureRefund
+	createdAt     time.Time
+}
+
+type captureRefund struct {
+	capture *capture
+	amount  models.Money
+}
+
+type exchangeQuote struct {
+	models.ExchangeQuote
+	usedAt *time.Time
+}
+
+// DB keeps the whole state in memory behind one lock. Every write checks all of its preconditions
+// before it changes anything, so a failed call leaves the state untouched, like a rolled back transaction.
+type DB struct {
+	mu             sync.RWMutex
+	relayMu        sync.Mutex
+	listenersMu    sync.Mutex
+	listeners      map[int]func(accountID int)
+	lastListenerID int
+	notifications  []int
+
+	wallets         []*models.Wallet
+	walletIDs       map[walletKey]int
+	transactions    []*transaction
+	idempotenceKeys map[int]int
+	reservations    map[int]*models.Reservation
+	captures        []*capture
+	refunds         []*refund
+	quotes          map[string]*exchangeQuote
+	entries         []*journalEntry
+	services        map[int]*models.Service
+	lastServiceID   int
+	idempotency     map[idempotencyKey]*models.IdempotencyRecord
+	events          []*outboxEvent
+	sequences       map[int]int64
+	webhooks        map[int]*models.Webhook
+	lastWebhookID   int
+	deliveries      []*models.WebhookDelivery
+	lastDeliveryID  int
+	attempts        map[int][]models.WebhookAttempt
+	adminActions    []*models.AdminAction
+	adjustments     []*models.Adjustment
+	auditLog        []models.AuditRecord
+	apiKeys         []*models.APIKey
+}
+
+func NewMemStore() *DB {
+	now := timestamp()
+	return &DB{
+		listeners:       make(map[int]func(accountID int)),
+		walletIDs:       make(map[walletKey]int),
+		idempotenceKeys: make(map[int]int),
+		reservations:    make(map[int]*models.Reservation),
+		quotes:          make(map[string]*exchangeQuote),
+		services: map[int]*models.Service{
+			1: {ID: 1, Title: defaultServiceTitle, Active: true, CreatedAt: now, UpdatedAt: now},
+		},
+		lastServiceID: 1,
+		idempotency:   make(map[idempotencyKey]*models.IdempotencyRecord),
+		sequences:     make(map[int]int64),
+		webhooks:      make(map[int]*models.Webhook),
+		attempts:      make(map[int][]models.WebhookAttempt),
+	}
+}
+
+// timestamp matches the precision pgstore stores its own timestamps with.
+func timestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// update runs fn under the write lock and tells listeners about the accounts it added events for.
+func (db *DB) update(fn func() error) error {
+	db.mu.Lock()
+	err := fn()
+	notifications := db.notifications
+	db.notifications = nil
+	db.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	db.notify(notifications)
+	return nil
+}
+
+// constraintError is the error Postgres reports for the same violation, so that callers handle both stores alike.
+func constraintError(code, message string) error {
+	return &pgconn.PgError{Severity: "ERROR", Code: code, Message: message}
+}
+
+func uniqueViolation(constraint string) error {
+	return constraintError(pgerrcode.UniqueViolation,
+		fmt.Sprintf("duplicate key value violates unique constraint %q", constraint))
+}
+
+func foreignKeyViolation(table, constraint string) error {
+	return constraintError(pgerrcode.ForeignKeyViolation,
+		fmt.Sprintf("insert or update on table %q violates foreign key constraint %q", table, constraint))
+}
+
+func (db *DB) GetWallet(ctx context.Context, accountID int, currency string) (*models.Wallet, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	walletID, ok := db.walletIDs[walletKey{ownerID: accountID, currency: currency}]
+	if !ok {
+		return nil, models.ErrWalletNotFound
+	}
+	wallet := *db.wallets[walletID-1]
+	return &wallet, nil
+}
+
+func (db *DB) UpsertDepositToWallet(ctx context.Context, ownerID int, transaction models.Transaction) error {
+	return db.update(func() error {
+		if err := db.checkIdempotenceKey(&transaction.IdempotenceKey); err != nil {
+			return fmt.Errorf("err executing [UpsertDepositToWallet]: %w", err)
+		}
+		wallet, ok := db.wallet(ownerID, transaction.Currency)
+		if !ok {
+			wallet = db.insertWallet(ownerID, transaction.Currency)
+		}
+		db.depositToWallet(wallet, transaction.Amount)
+		transactionID := db.insertTransaction(transactionRecord{
+			idempotenceKey: &transaction.IdempotenceKey,
+			kind:           models.TransactionKindDeposit,
+			walletID:       wallet.ID,
+			amount:         transaction.Amount,
+			comment:        transaction.Comment,
+		})
+		db.insertJournalEntry(entryDeposit, transactionReference(transactionID), &transactionID,
+			externalCash(transaction.Currency, -transaction.Amount),
+			available(ownerID, transaction.Currency, transaction.Amount))
+		db.insertEvent(models.EventFundsDeposited, models.BalanceEvent{
+			AccountID:     ownerID,
+			TransactionID: &transactionID,
+			Currency:      transaction.Currency,
+			Amount:        transaction.Amount,
+			AdminID:       transaction.Actor.ID(),
+		})
+		if transaction.Actor != nil {
+			db.insertAdminAction(models.AdminActionDeposit, ownerID, transactionID, transaction)
+		}
+		return nil
+	})
+}
+
+func (db *DB) WithdrawMoneyFromWallet(ctx context.Context, ownerID int, transaction models.Transaction) error {
+	return db.update(func() error {
+		wallet, err := db.checkBalance(ownerID, transaction.Currency, transaction.Amount)
+		if err != nil {
+			return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
+		}
+		if err = db.checkIdempotenceKey(&transaction.IdempotenceKey); err != nil {
+			return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
+		}
+		db.withdrawMoney(wallet, transaction.Amount)
+		transactionID := db.insertTransaction(transactionRecord{
+			idempotenceKey: &transaction.IdempotenceKey,
+			kind:           models.TransactionKindWithdrawal,
+			walletID:       wallet.ID,
+			amount:         -transaction.Amount,
+			comment:        transaction.Comment,
+		})
+		db.insertJournalEntry(entryWithdrawal, transactionReference(transactionID), &transactionID,
+			available(ownerID, transaction.Currency, -transaction.Amount),
+			externalCash(transaction.Currency, transaction.Amount))
+		db.insertEvent(models.EventFundsWithdrawn, models.BalanceEvent{
+			AccountID:     ownerID,
+			TransactionID: &transactionID,
+			Currency:      transaction.Currency,
+			Amount:        transaction.Amount,
+			AdminID:       transaction.Actor.ID(),
+		})
+		if transaction.Actor != nil {
+			db.insertAdminAction(models.AdminActionWithdrawal, ownerID, transactionID, transaction)
+		}
+		return nil
+	})
+}
+
+func (db *DB) TransferMoney(ctx context.Context, accountID int, transaction models.TransferTransaction,
+	conversion models.Conversion) error {
+	return db.update(func() error {
+		var quote *exchangeQuote
+		var err error
+		if conversion.QuoteID != "" {
+			if quote, err = db.activeExchangeQuote(accountID, conversion.QuoteID); err != nil {
+				return fmt.Errorf("err executing [TransferMoney]: %w", err)
+			}
+		}
+		wallet, err := db.checkBalance(accountID, transaction.Currency, transaction.Amount)
+		if err != nil {
+			return fmt.Errorf("err executing [TransferMoney]: %w", err)
+		}
+		targetWallet, ok := db.wallet(transaction.Target, conversion.TargetCurrency)
+		if !ok {
+			return fmt.Errorf("err executing [TransferMoney]: %w", db.walletNotFoundReason(transaction.Target))
+		}
+		if err = db.checkIdempotenceKey(&transaction.IdempotenceKey); err != nil {
+			return fmt.Errorf("err executing [TransferMoney]: %w", err)
+		}
+		if quote != nil {
+			usedAt := timestamp()
+			quote.usedAt = &usedAt
+		}
+		db.withdrawMoney(wallet, transaction.Amount)
+		db.depositToWallet(targetWallet, conversion.TargetAmount)
+		record := transactionRecord{
+			idempotenceKey: &transaction.IdempotenceKey,
+			kind:           models.TransactionKindTransfer,
+			walletID:       wallet.ID,
+			targetWalletID: &targetWallet.ID,
+			amount:         -transaction.Amount,
+			comment:        transaction.Comment,
+			targetAmount:   &conversion.TargetAmount,
+			exchangeRate:   &conversion.Rate,
+		}
+		if conversion.QuoteID != "" {
+			record.quoteID = &conversion.QuoteID
+		}
+		transactionID := db.insertTransaction(record)
+		db.insertJournalEntry(entryTransfer, transactionReference(transactionID), &transactionID,
+			transferPostings(accountID, transaction, conversion)...)
+		db.insertTransferEvents(accountID, transactionID, transaction, conversion)
+		return nil
+	})
+}
+
+func (db *DB) ReserveMoneyFromWallet(ctx context.Context, transaction models.ReserveTransaction) error {
+	return db.update(func() error {
+		wallet, err := db.checkBalance(transaction.AccountID, transaction.Currency, transaction.Amount)
+		if err != nil {
+			return fmt.Errorf("err executing [ReserveMoneyFromWallet]: %w", err)
+		}
+		if _, ok := db.reservations[transaction.OrderID]; ok {
+			return fmt.Errorf("err executing [ReserveMoneyFromWallet]: err executing [insertReservedFunds]: %w",
+				uniqueViolation("reserved_funds_order_id_key"))
+		}
+		if _, ok := db.services[transaction.ServiceID]; !ok {
+			return fmt.Errorf("err executing [ReserveMoneyFromWallet]: err executing [insertReservedFunds]: %w",
+				foreignKeyViolation("reserved_funds", "reserved_funds_service_id_fkey"))
+		}
+		db.withdrawMoney(wallet, transaction.Amount)
+		db.reserveMoney(wallet, transaction.Amount)
+		db.insertReservedFunds(transaction)
+		transactionID := db.insertTransaction(transactionRecord{
+			kind:      models.TransactionKindReserve,
+			walletID:  wallet.ID,
+			amount:    -transaction.Amount,
+			serviceID: &transaction.ServiceID,
+			comment:   "Резервирование средств",
+		})
+		db.insertJournalEntry(entryReserve, orderReference(transaction.OrderID), &transactionID,
+			available(transaction.AccountID, transaction.Currency, -transaction.Amount),
+			reserved(transaction.AccountID, transaction.Currency, transaction.Amount))
+		db.insertEvent(models.EventFundsReserved, models.BalanceEvent{
+			AccountID:     transaction.AccountID,
+			TransactionID: &transactionID,
+			OrderID:       transaction.OrderID,
+			ServiceID:     transaction.ServiceID,
+			Currency:      transaction.Currency,
+			Amount:        transaction.Amount,
+		})
+		return nil
+	})
+}
+
+func (db *DB) ApplyReservedMoney(ctx context.Context, transaction models.ReserveTransaction) error {
+	return db.update(func() error {
+		wallet, err := db.checkReservedBalance(transaction.AccountID, transaction.Currency, transaction.Amount)
+		if err != nil {
+			return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+		}
+		order, err := db.lockReservedOrder(transaction)
+		if err != nil {
+			return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+		}
+		if transaction.Amount > order.Amount-order.CapturedAmount {
+			return models.ErrNotEnoughReservedMoney
+		}
+		serviceTitle, err := db.getActiveServiceTitle(transaction.ServiceID)
+		if err != nil {
+			return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+		}
+		// Without a key only the first capture can be made, keyed by the order as a single full capture was.
+		idempotenceKey := transaction.IdempotenceKey
+		if idempotenceKey == 0 {
+			if order.CapturedAmount > 0 {
+				return models.ErrIdempotenceKeyRequired
+			}
+			idempotenceKey = transaction.OrderID
+		}
+		if err = db.checkIdempotenceKey(&idempotenceKey); err != nil {
+			return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+		}
+		db.withdrawReservedMoney(wallet, transaction.Amount)
+		transactionID := db.insertTransaction(transactionRecord{
+			idempotenceKey: &idempotenceKey,
+			kind:           models.TransactionKindServiceCharge,
+			walletID:       wallet.ID,
+			amount:         -transaction.Amount,
+			serviceID:      &transaction.ServiceID,
+			comment:        serviceTitle,
+		})
+		db.insertCapture(order, serviceTitle, transaction.Amount, transactionID)
+		db.insertJournalEntry(entryReserveApply, orderReference(transaction.OrderID), &transactionID,
+			reserved(transaction.AccountID, transaction.Currency, -transaction.Amount),
+			revenue(transaction.Currency, transaction.Amount))
+		order.CapturedAmount += transaction.Amount
+		status := models.ReservationActive
+		if transaction.Final || order.CapturedAmount == order.Amount {
+			status = models.ReservationCompleted
+			db.releaseReserve(wallet, order, order.Amount-order.CapturedAmount, entryReserveCancel,
+				"Возврат остатка резервирования")
+		}
+		db.updateOrder(order, status)
+		db.insertEvent(models.EventReserveApplied, models.BalanceEvent{
+			AccountID:     transaction.AccountID,
+			TransactionID: &transactionID,
+			OrderID:       transaction.OrderID,
+			ServiceID:     transaction.ServiceID,
+			Currency:      transaction.Currency,
+			Amount:        transaction.Amount,
+			Final:         status == models.ReservationCompleted,
+		})
+		return nil
+	})
+}
+
+func (db *DB) CancelReserve(ctx context.Context, transaction models.ReserveTransaction) error {
+	return db.update(func() error {
+		wallet, err := db.checkReservedBalance(transaction.AccountID, transaction.Currency, 0)
+		if err != nil {
+			return fmt.Errorf("err executing [CancelReserve]: %w", err)
+		}
+		order, err := db.lockReservedOrder(transaction)
+		if err != nil {
+			return fmt.Errorf("err executing [CancelReserve]: %w", err)
+		}
+		if transaction.Amount != order.Amount-order.CapturedAmount {
+			return models.ErrOrderNotFound
+		}
+		if err = db.cancelOrder(wallet, order, models.ReservationCancelled); err != nil {
+			return fmt.Errorf("err executing [CancelReserve]: %w", err)
+		}
+		return nil
+	})
+}
+
+func (db *DB) GetExpiredReservations(ctx context.Context, now time.Time,
+	limit int) ([]models.ReserveTransaction, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	expired := make([]*models.Reservation, 0)
+	for _, order := range db.reservations {
+		if order.Status == models.ReservationActive && order.ExpiresAt != nil && !order.ExpiresAt.After(now) {
+			expired = append(expired, order)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		if !expired[i].ExpiresAt.Equal(*expired[j].ExpiresAt) {
+			return expired[i].ExpiresAt.Before(*expired[j].ExpiresAt)
+		}
+		return expired[i].OrderID < expired[j].OrderID
+	})
+	reservations := make([]models.ReserveTransaction, 0)
+	for _, order := range expired {
+		if len(reservations) == limit {
+			break
+		}
+		reservations = append(reservations, models.ReserveTransaction{
+			OrderID:   order.OrderID,
+			AccountID: order.AccountID,
+			ServiceID: order.ServiceID,
+			Currency:  order.Currency,
+			Amount:    order.Amount,
+		})
+	}
+	return reservations, nil
+}
+
+func (db *DB) ExpireReserve(ctx context.Context, transaction models.ReserveTransaction) error {
+	return db.update(func() error {
+		wallet, err := db.checkReservedBalance(transaction.AccountID, transaction.Currency, 0)
+		if err != nil {
+			return fmt.Errorf("err executing [ExpireReserve]: %w", err)
+		}
+		order, err := db.lockReservedOrder(transaction)
+		if err != nil {
+			return fmt.Errorf("err executing [ExpireReserve]: %w", err)
+		}
+		if err = db.cancelOrder(wallet, order, models.ReservationExpired); err != nil {
+			return fmt.Errorf("err executing [ExpireReserve]: %w", err)
+		}
+		return nil
+	})
+}
+
+func (db *DB) RefundOrder(ctx context.Context, refund models.RefundTransaction) error {
+	return db.update(func() error {
+		order, err := db.lockCapturedOrder(refund.AccountID, refund.OrderID)
+		if err != nil {
+			return fmt.Errorf("err executing [RefundOrder]: %w", err)
+		}
+		if order.refunded+refund.Amount > order.captured {
+			return models.ErrRefundExceedsCaptured
+		}
+		wallet, ok := db.wallet(refund.AccountID, order.currency)
+		if !ok {
+			return fmt.Errorf("err executing [RefundOrder]: %w", db.walletNotFoundReason(refund.AccountID))
+		}
+		if err = db.checkIdempotenceKey(&refund.IdempotenceKey); err != nil {
+			return fmt.Errorf("err executing [RefundOrder]: %w", err)
+		}
+		db.depositToWallet(wallet, refund.Amount)
+		comment := refund.Comment
+		if comment == "" {
+			comment = "Возврат: " + order.serviceTitle
+		}
+		reversed, reversalOf := order.reverseCaptures(refund.Amount)
+		transactionID := db.insertTransaction(transactionRecord{
+			idempotenceKey: &refund.IdempotenceKey,
+			kind:           models.TransactionKindRefund,
+			walletID:       wallet.ID,
+			amount:         refund.Amount,
+			serviceID:      &order.serviceID,
+			comment:        comment,
+			reversalOf:     &reversalOf,
+		})
+		db.insertRefund(order, refund.Amount, transactionID, reversed)
+		db.insertJournalEntry(entryRefund, orderReference(refund.OrderID), &transactionID,
+			revenue(order.currency, -refund.Amount),
+			available(refund.AccountID, order.currency, refund.Amount))
+		db.insertEvent(models.EventOrderRefunded, models.BalanceEvent{
+			AccountID:     refund.AccountID,
+			TransactionID: &transactionID,
+			OrderID:       refund.OrderID,
+			ServiceID:     order.serviceID,
+			Currency:      order.currency,
+			Amount:        refund.Amount,
+		})
+		return nil
+	})
+}
+
+func (db *DB) GetWalletTransactions(ctx context.Context, accountID int, queryParams *models.TransactionsQueryParams,
+	cursor *models.TransactionsCursor) ([]models.TransactionFullInfo, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if !db.walletExists(accountID, queryParams.Currency) {
+		return nil, fmt.Errorf("err executing [GetWalletTransactions]: %w", models.ErrWalletNotFound)
+	}
+	owned := func(wallet *models.Wallet) bool {
+		return wallet.Owner == accountID && (queryParams.Currency == "" || wallet.Currency == queryParams.Currency)
+	}
+	history := make([]models.TransactionFullInfo, 0)
+	for _, t := range db.transactions {
+		wallet := db.wallets[t.walletID-1]
+		var targetWallet *models.Wallet
+		if t.targetWalletID != nil {
+			targetWallet = db.wallets[*t.targetWalletID-1]
+		}
+		row := models.TransactionFullInfo{
+			ID:           t.id,
+			Kind:         t.kind,
+			Amount:       t.amount,
+			Currency:     wallet.Currency,
+			TargetAmount: copyMoney(t.targetAmount),
+			ExchangeRate: copyRate(t.exchangeRate),
+			ServiceID:    copyInt(t.serviceID),
+			ReversalOf:   copyInt(t.reversalOf),
+			Comment:      t.comment,
+			Timestamp:    t.timestamp,
+		}
+		// A transfer between the caller's own wallets shows up once per wallet.
+		rows := make([]models.TransactionFullInfo, 0, 2)
+		if owned(wallet) {
+			if targetWallet != nil {
+				row.Counterparty = &targetWallet.Owner
+			}
+			rows = append(rows, row)
+		}
+		if targetWallet != nil && owned(targetWallet) {
+			incoming := row
+			incoming.Amount, incoming.Currency, incoming.Counterparty = -t.amount, targetWallet.Currency, &wallet.Owner
+			if t.targetAmount != nil {
+				incoming.Amount = *t.targetAmount
+			}
+			rows = append(rows, incoming)
+		}
+		for _, row := range rows {
+			row.Direction = models.DirectionIn
+			if row.Amount < 0 {
+				row.Direction = models.DirectionOut
+			}
+			row.Counterparty = copyInt(row.Counterparty)
+			if matchesTransactionFilter(row, queryParams) {
+				history = append(history, row)
+			}
+		}
+	}
+	less := func(a, b models.TransactionFullInfo) bool {
+		if queryParams.Sorting == models.SortingAmount {
+			if a.Amount != b.Amount {
+				return a.Amount < b.Amount
+			}
+		} else if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.Before(b.Timestamp)
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Amount < b.Amount
+	}
+	descending := queryParams.Descending != "false"
+	if cursor != nil {
+		last := models.TransactionFullInfo{ID: cursor.ID, Amount: cursor.Amount, Timestamp: cursor.Timestamp}
+		rest := history[:0]
+		for _, row := range history {
+			if (descending && less(row, last)) || (!descending && less(last, row)) {
+				rest = append(rest, row)
+			}
+		}
+		history = rest
+	}
+	sort.Slice(history, func(i, j int) bool {
+		if descending {
+			return less(history[j], history[i])
+		}
+		return less(history[i], history[j])
+	})
+	from, to := page(len(history), queryParams.Offset, queryParams.Limit)
+	return history[from:to], nil
+}
+
+func matchesTransactionFilter(row models.TransactionFullInfo, queryParams *models.TransactionsQueryParams) bool {
+	if row.Timestamp.Before(queryParams.From) || row.Timestamp.After(queryParams.To) {
+		return false
+	}
+	switch queryParams.Type {
+	case "":
+	case models.TransactionTypeTransferIn:
+		if row.Kind != models.TransactionKindTransfer || row.Amount < 0 {
+			return false
+		}
+	case models.TransactionTypeTransferOut:
+		if row.Kind != models.TransactionKindTransfer || row.Amount >= 0 {
+			return false
+		}
+	default:
+		if row.Kind != queryParams.Type {
+			return false
+		}
+	}
+	if queryParams.Counterparty != 0 && (row.Counterparty == nil || *row.Counterparty != queryParams.Counterparty) {
+		return false
+	}
+	if queryParams.ServiceID != 0 && (row.ServiceID == nil || *row.ServiceID != queryParams.ServiceID) {
+		return false
+	}
+	amount := row.Amount
+	if amount < 0 {
+		amount = -amount
+	}
+	if queryParams.MinAmount != nil && amount < *queryParams.MinAmount {
+		return false
+	}
+	if queryParams.MaxAmount != nil && amount > *queryParams.MaxAmount {
+		return false
+	}
+	return queryParams.Comment == "" ||
+		strings.Contains(strings.ToLower(row.Comment), strings.ToLower(queryParams.Comment))
+}
+
+func (db *DB) GetReservation(ctx context.Context, orderID int) (*models.Reservation, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	order, ok := db.reservations[orderID]
+	if !ok {
+		return nil, models.ErrOrderNotFound
+	}
+	reservation := reservationView(order)
+	return &reservation, nil
+}
+
+func (db *DB) GetReservations(ctx context.Context,
+	queryParams *models.ReservationsQueryParams) ([]models.Reservation, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	reservations := make([]models.Reservation, 0)
+	for _, order := range db.reservations {
+		switch {
+		case queryParams.AccountID != 0 && order.AccountID != queryParams.AccountID,
+			queryParams.Status != "" && order.Status != queryParams.Status,
+			queryParams.ServiceID != 0 && order.ServiceID != queryParams.ServiceID,
+			order.CreatedAt.Before(queryParams.From), order.CreatedAt.After(queryParams.To):
+			continue
+		}
+		reservations = append(reservations, reservationView(order))
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		if !reservations[i].CreatedAt.Equal(reservations[j].CreatedAt) {
+			return reservations[i].CreatedAt.After(reservations[j].CreatedAt)
+		}
+		return reservations[i].OrderID > reservations[j].OrderID
+	})
+	from, to := page(len(reservations), queryParams.Offset, queryParams.Limit)
+	return reservations[from:to], nil
+}
+
+func reservationView(order *models.Reservation) models.Reservation {
+	reservation := *order
+	reservation.ExpiresAt = copyTime(order.ExpiresAt)
+	reservation.RemainingAmount = 0
+	if order.Status == models.ReservationActive {
+		reservation.RemainingAmount = order.Amount - order.CapturedAmount
+	}
+	return reservation
+}
+
+func (db *DB) GetReport(ctx context.Context, month time.Time) ([]models.ReportRow, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	type reportKey struct {
+		title    string
+		currency string
+	}
+	end := month.AddDate(0, 1, 0)
+	within := func(t time.Time) bool {
+		return !t.Before(month) && !t.After(end)
+	}
+	totals := make(map[reportKey]models.Money)
+	for _, c := range db.captures {
+		if within(c.createdAt) {
+			totals[reportKey{title: c.serviceTitle, currency: c.currency}] += c.amount
+		}
+	}
+	for _, r := range db.refunds {
+		if within(r.createdAt) {
+			totals[reportKey{title: r.serviceTitle, currency: r.currency}] -= r.amount
+		}
+	}
+	var report []models.ReportRow
+	for key, amount := range totals {
+		report = append(report, models.ReportRow{ServiceTitle: key.title, Currency: key.currency, Amount: amount})
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].ServiceTitle != report[j].ServiceTitle {
+			return report[i].ServiceTitle < report[j].ServiceTitle
+		}
+		return report[i].Currency < report[j].Currency
+	})
+	return report, nil
+}
+
+func (db *DB) InsertExchangeQuote(ctx context.Context, quote models.ExchangeQuote) error {
+	return db.update(func() error {
+		if _, ok := db.quotes[quote.ID]; ok {
+			return fmt.Errorf("err executing [InsertExchangeQuote]: %w", uniqueViolation("exchange_quote_pkey"))
+		}
+		quote.ExpiresAt = quote.ExpiresAt.UTC().Truncate(time.Second)
+		db.quotes[quote.ID] = &exchangeQuote{ExchangeQuote: quote}
+		return nil
+	})
+}
+
+func (db *DB) GetExchangeQuote(ctx context.Context, accountID int, quoteID string) (*models.ExchangeQuote, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	quote, err := db.activeExchangeQuote(accountID, quoteID)
+	if err != nil {
+		return nil, err
+	}
+	exchangeQuote := quote.ExchangeQuote
+	return &exchangeQuote, nil
+}
+
+func (db *DB) activeExchangeQuote(accountID int, quoteID string) (*exchangeQuote, error) {
+	quote, ok := db.quotes[quoteID]
+	if !ok || quote.AccountID != accountID || quote.usedAt != nil || !quote.ExpiresAt.After(timestamp()) {
+		return nil, models.ErrQuoteNotFound
+	}
+	return quote, nil
+}
+
+type capturedOrder struct {
+	orderID      int
+	ownerID      int
+	serviceID    int
+	currency     string
+	serviceTitle string
+	captured     models.Money
+	refunded     models.Money
+	captures     []*capture
+}
+
+// reverseCaptures spreads a refund over the captures it reverses, latest capture first,
+// and returns the transaction of the first of them.
+func (order *capturedOrder) reverseCaptures(amount models.Money) ([]captureRefund, int) {
+	reversed := make([]captureRefund, 0, 1)
+	var reversalOf int
+	for _, c := range order.captures {
+		if amount == 0 {
+			break
+		}
+		if c.amount <= c.refunded {
+			continue
+		}
+		part := c.amount - c.refunded
+		if part > amount {
+			part = amount
+		}
+		if len(reversed) == 0 {
+			reversalOf = c.transactionID
+		}
+		reversed = append(reversed, captureRefund{capture: c, amount: part})
+		amount -= part
+	}
+	return reversed, reversalOf
+}
+
+func (db *DB) lockCapturedOrder(ownerID, orderID int) (*capturedOrder, error) {
+	reservation, ok := db.reservations[orderID]
+	if !ok || reservation.AccountID != ownerID {
+		return nil, models.ErrOrderNotFound
+	}
+	if reservation.Status != models.ReservationCompleted || reservation.CapturedAmount == 0 {
+		return nil, models.ErrOrderNotCompleted
+	}
+	order := capturedOrder{
+		orderID:   reservation.OrderID,
+		ownerID:   reservation.AccountID,
+		serviceID: reservation.ServiceID,
+		currency:  reservation.Currency,
+		captured:  reservation.CapturedAmount,
+	}
+	for _, r := range db.refunds {
+		if r.orderID == orderID {
+			order.refunded += r.amount
+		}
+	}
+	for i := len(db.captures) - 1; i >= 0; i-- {
+		if db.captures[i].orderID == orderID {
+			order.captures = append(order.captures, db.captures[i])
+		}
+	}
+	if len(order.captures) == 0 {
+		return nil, models.ErrOrderNotCompleted
+	}
+	order.serviceTitle = order.captures[0].serviceTitle
+	return &order, nil
+}
+
+func (db *DB) insertRefund(order *capturedOrder, amount models.Money, transactionID int,
+	reversed []captureRefund) {
+	for _, r := range reversed {
+		r.capture.refunded += r.amount
+	}
+	db.refunds = append(db.refunds, &refund{
+		id:            len(db.refunds) + 1,
+		orderID:       order.orderID,
+		ownerID:       order.ownerID,
+		serviceID:     order.serviceID,
+		serviceTitle:  order.serviceTitle,
+		currency:      order.currency,
+		amount:        amount,
+		transactionID: transactionID,
+		captures:      reversed,
+		createdAt:     timestamp(),
+	})
+}
+
+func (db *DB) insertReservedFunds(transaction models.ReserveTransaction) {
+	now := timestamp()
+	db.reservations[transaction.OrderID] = &models.Reservation{
+		OrderID:   transaction.OrderID,
+		AccountID: transaction.AccountID,
+		ServiceID: transaction.ServiceID,
+		Currency:  transaction.Currency,
+		Amount:    transaction.Amount,
+		Status:    models.ReservationActive,
+		ExpiresAt: copyTime(transaction.ExpiresAt),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func (db *DB) wallet(ownerID int, currency string) (*models.Wallet, bool) {
+	walletID, ok := db.walletIDs[walletKey{ownerID: ownerID, currency: currency}]
+	if !ok {
+		return nil, false
+	}
+	return db.wallets[walletID-1], true
+}
+
+func (db *DB) insertWallet(ownerID int, currency string) *models.Wallet {
+	now := timestamp()
+	wallet := &models.Wallet{
+		ID:        len(db.wallets) + 1,
+		Owner:     ownerID,
+		Currency:  currency,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	db.wallets = append(db.wallets, wallet)
+	db.walletIDs[walletKey{ownerID: ownerID, currency: currency}] = wallet.ID
+	return wallet
+}
+
+func (db *DB) checkBalance(ownerID int, currency string, amount models.Money) (*models.Wallet, error) {
+	wallet, ok := db.wallet(ownerID, currency)
+	if !ok {
+		return nil, models.ErrWalletNotFound
+	}
+	if wallet.Balance-amount < 0 {
+		return nil, models.ErrNotEnoughMoney
+	}
+	return wallet, nil
+}
+
+func (db *DB) checkReservedBalance(ownerID int, currency string, amount models.Money) (*models.Wallet, error) {
+	wallet, ok := db.wallet(ownerID, currency)
+	if !ok {
+		return nil, models.ErrWalletNotFound
+	}
+	if wallet.ReservedBalance-amount < 0 {
+		return nil, models.ErrNotEnoughReservedMoney
+	}
+	return wallet, nil
+}
+
+func (db *DB) lockReservedOrder(transaction models.ReserveTransaction) (*models.Reservation, error) {
+	order, ok := db.reservations[transaction.OrderID]
+	if !ok || order.AccountID != transaction.AccountID || order.ServiceID != transaction.ServiceID ||
+		order.Status != models.ReservationActive {
+		return nil, models.ErrOrderNotFound
+	}
+	if order.Currency != transaction.Currency {
+		return nil, models.ErrCurrencyMismatch
+	}
+	return order, nil
+}
+
+func (db *DB) updateOrder(order *models.Reservation, status string) {
+	order.Status = status
+	order.UpdatedAt = timestamp()
+}
+
+func (db *DB) releaseReserve(wallet *models.Wallet, order *models.Reservation, amount models.Money,
+	kind, comment string) {
+	if amount == 0 {
+		return
+	}
+	db.withdrawReservedMoney(wallet, amount)
+	db.depositToWallet(wallet, amount)
+	transactionID := db.insertTransaction(transactionRecord{
+		kind:      models.TransactionKindReserveRelease,
+		walletID:  wallet.ID,
+		amount:    amount,
+		serviceID: &order.ServiceID,
+		comment:   comment,
+	})
+	db.insertJournalEntry(kind, orderReference(order.OrderID), &transactionID,
+		reserved(order.AccountID, order.Currency, -amount),
+		available(order.AccountID, order.Currency, amount))
+}
+
+func (db *DB) cancelOrder(wallet *models.Wallet, order *models.Reservation, status string) error {
+	remaining := order.Amount - order.CapturedAmount
+	if wallet.ReservedBalance < remaining {
+		return models.ErrNotEnoughReservedMoney
+	}
+	kind, comment, eventType := entryReserveCancel, "Отмена резервирования", models.EventReserveCancelled
+	if status == models.ReservationExpired {
+		kind, comment, eventType = entryReserveExpire, "Истек срок резервирования", models.EventReserveExpired
+	}
+	db.releaseReserve(wallet, order, remaining, kind, comment)
+	db.insertEvent(eventType, models.BalanceEvent{
+		AccountID: order.AccountID,
+		OrderID:   order.OrderID,
+		ServiceID: order.ServiceID,
+		Currency:  order.Currency,
+		Amount:    remaining,
+	})
+	db.updateOrder(order, status)
+	return nil
+}
+
+func (db *DB) insertCapture(order *models.Reservation, serviceTitle string, amount models.Money, transactionID int) {
+	db.captures = append(db.captures, &capture{
+		id:            len(db.captures) + 1,
+		orderID:       order.OrderID,
+		serviceID:     order.ServiceID,
+		serviceTitle:  serviceTitle,
+		currency:      order.Currency,
+		amount:        amount,
+		transactionID: transactionID,
+		createdAt:     timestamp(),
+	})
+}
+
+type transactionRecord struct {
+	idempotenceKey *int
+	kind           string
+	walletID       int
+	targetWalletID *int
+	amount         models.Money
+	serviceID      *int
+	comment        string
+	targetAmount   *models.Money
+	exchangeRate   *models.Rate
+	quoteID        *string
+	reversalOf     *int
+}
+
+func (db *DB) checkIdempotenceKey(idempotenceKey *int) error {
+	if idempotenceKey == nil {
+		return nil
+	}
+	if _, ok := db.idempotenceKeys[*idempotenceKey]; ok {
+		return fmt.Errorf("err executing [insertTransaction]: %w", uniqueViolation("transaction_idempotence_key_key"))
+	}
+	return nil
+}
+
+// insertTransaction expects the idempotence key to be checked with checkIdempotenceKey beforehand.
+func (db *DB) insertTransaction(record transactionRecord) int {
+	record.idempotenceKey = copyInt(record.idempotenceKey)
+	record.targetWalletID = copyInt(record.targetWalletID)
+	record.serviceID = copyInt(record.serviceID)
+	record.targetAmount = copyMoney(record.targetAmount)
+	record.exchangeRate = copyRate(record.exchangeRate)
+	record.reversalOf = copyInt(record.reversalOf)
+	if record.quoteID != nil {
+		quoteID := *record.quoteID
+		record.quoteID = &quoteID
+	}
+	t := &transaction{transactionRecord: record, id: len(db.transactions) + 1, timestamp: timestamp()}
+	db.transactions = append(db.transactions, t)
+	if record.idempotenceKey != nil {
+		db.idempotenceKeys[*record.idempotenceKey] = t.id
+	}
+	return t.id
+}
+
+func (db *DB) withdrawMoney(wallet *models.Wallet, amount models.Money) {
+	wallet.Balance -= amount
+	wallet.UpdatedAt = timestamp()
+}
+
+func (db *DB) reserveMoney(wallet *models.Wallet, amount models.Money) {
+	wallet.ReservedBalance += amount
+	wallet.UpdatedAt = timestamp()
+}
+
+func (db *DB) withdrawReservedMoney(wallet *models.Wallet, amount models.Money) {
+	wallet.ReservedBalance -= amount
+	wallet.UpdatedAt = timestamp()
+}
+
+func (db *DB) depositToWallet(wallet *models.Wallet, amount models.Money) {
+	wallet.Balance += amount
+	wallet.UpdatedAt = timestamp()
+}
+
+func (db *DB) walletNotFoundReason(ownerID int) error {
+	if db.walletExists(ownerID, "") {
+		return models.ErrCurrencyMismatch
+	}
+	return models.ErrWalletNotFound
+}
+
+func (db *DB) walletExists(ownerID int, currency string) bool {
+	for key := range db.walletIDs {
+		if key.ownerID == ownerID && (currency == "" || key.currency == currency) {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *DB) getActiveServiceTitle(serviceID int) (string, error) {
+	service, ok := db.services[serviceID]
+	if !ok {
+		return "", models.ErrServiceNotFound
+	}
+	if !service.Active {
+		return "", models.ErrServiceInactive
+	}
+	return service.Title, nil
+}
+
+// page returns the bounds OFFSET and LIMIT select from count sorted rows.
+func page(count, offset, limit int) (int, int) {
+	if offset > count {
+		offset = count
+	}
+	if limit > count-offset {
+		limit = count - offset
+	}
+	return offset, offset + limit
+}
+
+func copyInt(v *int) *int {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+func copyMoney(v *models.Money) *models.Money {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+func copyRate(v *models.Rate) *models.Rate {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+func copyTime(v *time.Time) *time.Time {
+	if v == nil {
+		return nil
+	}
+	c := v.UTC()
+	return &c
+}
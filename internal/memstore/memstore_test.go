@@ -0,0 +1,15 @@
+package memstore_test
+
+import (
+	"testing"
+
+	"github.com/DANDA322/balance-service/internal/memstore"
+	"github.com/DANDA322/balance-service/internal/storetest"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestMemStore(t *testing.T) {
+	suite.Run(t, &storetest.Suite{Reset: func() storetest.Store {
+		return memstore.NewMemStore()
+	}})
+}
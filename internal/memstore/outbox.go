@@ -0,0 +1,89 @@
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+type outboxEvent struct {
+	models.Event
+	publishedAt *time.Time
+}
+
+func (db *DB) insertEvent(eventType string, event models.BalanceEvent) {
+	payload, _ := json.Marshal(event)
+	db.sequences[event.AccountID]++
+	stored := &outboxEvent{Event: models.Event{
+		ID:        len(db.events) + 1,
+		AccountID: event.AccountID,
+		Sequence:  db.sequences[event.AccountID],
+		Type:      eventType,
+		Payload:   payload,
+		CreatedAt: timestamp(),
+	}}
+	db.events = append(db.events, stored)
+	db.insertWebhookDeliveries(stored.ID, eventType)
+	db.notifications = append(db.notifications, event.AccountID)
+}
+
+// RelayEvents hands up to limit pending events to publish in outbox order and marks the ones
+// it reports as delivered. Only one relay drains the outbox at a time, like with pgstore.
+func (db *DB) RelayEvents(ctx context.Context, limit int,
+	publish func(ctx context.Context, events []models.Event) []int) (int, error) {
+	if !db.relayMu.TryLock() {
+		return 0, nil
+	}
+	defer db.relayMu.Unlock()
+	db.mu.RLock()
+	events := make([]models.Event, 0)
+	for _, event := range db.events {
+		if len(events) == limit {
+			break
+		}
+		if event.publishedAt == nil {
+			events = append(events, event.Event)
+		}
+	}
+	db.mu.RUnlock()
+	if len(events) == 0 {
+		return 0, nil
+	}
+	published := publish(ctx, events)
+	if len(published) > 0 {
+		_ = db.update(func() error {
+			publishedAt := timestamp()
+			for _, eventID := range published {
+				if eventID > 0 && eventID <= len(db.events) {
+					db.events[eventID-1].publishedAt = &publishedAt
+				}
+			}
+			return nil
+		})
+	}
+	return len(published), nil
+}
+
+func (db *DB) insertTransferEvents(accountID, transactionID int, transaction models.TransferTransaction,
+	conversion models.Conversion) {
+	db.insertEvent(models.EventTransferCompleted, models.BalanceEvent{
+		AccountID:      accountID,
+		TransactionID:  &transactionID,
+		Counterparty:   transaction.Target,
+		Currency:       transaction.Currency,
+		Amount:         transaction.Amount,
+		TargetCurrency: conversion.TargetCurrency,
+		TargetAmount:   &conversion.TargetAmount,
+	})
+	db.insertEvent(models.EventTransferReceived, models.BalanceEvent{
+		AccountID:      transaction.Target,
+		TransactionID:  &transactionID,
+		Counterparty:   accountID,
+		Currency:       transaction.Currency,
+		Amount:         transaction.Amount,
+		TargetCurrency: conversion.TargetCurrency,
+		TargetAmount:   &conversion.TargetAmount,
+	})
+}
@@ -0,0 +1,115 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+func (db *DB) GetServices(ctx context.Context) ([]models.Service, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	services := make([]models.Service, 0, len(db.services))
+	for _, service := range db.services {
+		services = append(services, copyService(service))
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].ID < services[j].ID
+	})
+	return services, nil
+}
+
+func (db *DB) GetService(ctx context.Context, serviceID int) (*models.Service, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	service, ok := db.services[serviceID]
+	if !ok {
+		return nil, models.ErrServiceNotFound
+	}
+	found := copyService(service)
+	return &found, nil
+}
+
+func (db *DB) CreateService(ctx context.Context, service models.Service) (*models.Service, error) {
+	var created models.Service
+	err := db.update(func() error {
+		db.lastServiceID++
+		now := timestamp()
+		service.ID, service.CreatedAt, service.UpdatedAt = db.lastServiceID, now, now
+		created = copyService(&service)
+		stored := copyService(&service)
+		db.services[service.ID] = &stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (db *DB) UpdateService(ctx context.Context, service models.Service) (*models.Service, error) {
+	var updated models.Service
+	err := db.update(func() error {
+		stored, ok := db.services[service.ID]
+		if !ok {
+			return models.ErrServiceNotFound
+		}
+		service.CreatedAt, service.UpdatedAt = stored.CreatedAt, timestamp()
+		*stored = copyService(&service)
+		updated = copyService(stored)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (db *DB) DeleteService(ctx context.Context, serviceID int) error {
+	return db.update(func() error {
+		if _, ok := db.services[serviceID]; !ok {
+			return models.ErrServiceNotFound
+		}
+		if db.serviceInUse(serviceID) {
+			return models.ErrServiceInUse
+		}
+		delete(db.services, serviceID)
+		return nil
+	})
+}
+
+// serviceInUse reports whether a row references the service, where Postgres would fail a foreign key check.
+func (db *DB) serviceInUse(serviceID int) bool {
+	for _, t := range db.transactions {
+		if t.serviceID != nil && *t.serviceID == serviceID {
+			return true
+		}
+	}
+	for _, order := range db.reservations {
+		if order.ServiceID == serviceID {
+			return true
+		}
+	}
+	for _, c := range db.captures {
+		if c.serviceID == serviceID {
+			return true
+		}
+	}
+	for _, r := range db.refunds {
+		if r.serviceID == serviceID {
+			return true
+		}
+	}
+	return false
+}
+
+func copyService(service *models.Service) models.Service {
+	c := *service
+	c.DefaultPrice = copyMoney(service.DefaultPrice)
+	if service.Currency != nil {
+		currency := *service.Currency
+		c.Currency = &currency
+	}
+	return c
+}
@@ -0,0 +1,73 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+func (db *DB) GetWallets(ctx context.Context, accountID int) ([]models.Wallet, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	wallets := make([]models.Wallet, 0)
+	for _, wallet := range db.wallets {
+		if wallet.Owner == accountID {
+			wallets = append(wallets, *wallet)
+		}
+	}
+	sort.Slice(wallets, func(i, j int) bool {
+		return wallets[i].Currency < wallets[j].Currency
+	})
+	return wallets, nil
+}
+
+func (db *DB) GetEvents(ctx context.Context, accountID int, afterSequence int64, limit int) ([]models.Event, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	events := make([]models.Event, 0)
+	for _, event := range db.events {
+		if len(events) == limit {
+			break
+		}
+		if event.AccountID == accountID && event.Sequence > afterSequence {
+			events = append(events, event.Event)
+		}
+	}
+	return events, nil
+}
+
+func (db *DB) GetLastEventSequence(ctx context.Context, accountID int) (int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.sequences[accountID], nil
+}
+
+// ListenEvents calls notify with the account id of each committed event until ctx is done.
+func (db *DB) ListenEvents(ctx context.Context, notify func(accountID int)) error {
+	db.listenersMu.Lock()
+	db.lastListenerID++
+	listenerID := db.lastListenerID
+	db.listeners[listenerID] = notify
+	db.listenersMu.Unlock()
+	defer func() {
+		db.listenersMu.Lock()
+		delete(db.listeners, listenerID)
+		db.listenersMu.Unlock()
+	}()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (db *DB) notify(accountIDs []int) {
+	if len(accountIDs) == 0 {
+		return
+	}
+	db.listenersMu.Lock()
+	defer db.listenersMu.Unlock()
+	for _, notify := range db.listeners {
+		for _, accountID := range accountIDs {
+			notify(accountID)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+func (db *DB) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	webhooks := make([]models.Webhook, 0, len(db.webhooks))
+	for _, webhook := range db.webhooks {
+		webhooks = append(webhooks, copyWebhook(webhook))
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].ID < webhooks[j].ID
+	})
+	return webhooks, nil
+}
+
+func (db *DB) GetWebhook(ctx context.Context, webhookID int) (*models.Webhook, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	webhook, ok := db.webhooks[webhookID]
+	if !ok {
+		return nil, models.ErrWebhookNotFound
+	}
+	found := copyWebhook(webhook)
+	return &found, nil
+}
+
+func (db *DB) CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	var created models.Webhook
+	err := db.update(func() error {
+		db.lastWebhookID++
+		now := timestamp()
+		webhook.ID, webhook.CreatedAt, webhook.UpdatedAt = db.lastWebhookID, now, now
+		stored := copyWebhook(&webhook)
+		db.webhooks[webhook.ID] = &stored
+		created = copyWebhook(&stored)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (db *DB) UpdateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	var updated models.Webhook
+	err := db.update(func() error {
+		stored, ok := db.webhooks[webhook.ID]
+		if !ok {
+			return models.ErrWebhookNotFound
+		}
+		if webhook.Secret == "" {
+			webhook.Secret = stored.Secret
+		}
+		webhook.CreatedAt, webhook.UpdatedAt = stored.CreatedAt, timestamp()
+		*stored = copyWebhook(&webhook)
+		updated = copyWebhook(stored)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (db *DB) DeleteWebhook(ctx context.Context, webhookID int) error {
+	return db.update(func() error {
+		if _, ok := db.webhooks[webhookID]; !ok {
+			return models.ErrWebhookNotFound
+		}
+		delete(db.webhooks, webhookID)
+		deliveries := db.deliveries[:0]
+		for _, delivery := range db.deliveries {
+			if delivery.WebhookID == webhookID {
+				delete(db.attempts, delivery.ID)
+				continue
+			}
+			deliveries = append(deliveries, delivery)
+		}
+		db.deliveries = deliveries
+		return nil
+	})
+}
+
+func (db *DB) GetWebhookDeliveries(ctx context.Context,
+	queryParams *models.WebhookDeliveriesQueryParams) ([]models.WebhookDelivery, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	deliveries := make([]models.WebhookDelivery, 0)
+	for i := len(db.deliveries) - 1; i >= 0; i-- {
+		delivery := db.deliveries[i]
+		if (queryParams.WebhookID != 0 && delivery.WebhookID != queryParams.WebhookID) ||
+			(queryParams.Status != "" && delivery.Status != queryParams.Status) {
+			continue
+		}
+		deliveries = append(deliveries, copyDelivery(delivery))
+	}
+	from, to := page(len(deliveries), queryParams.Offset, queryParams.Limit)
+	return deliveries[from:to], nil
+}
+
+func (db *DB) GetWebhookDelivery(ctx context.Context, deliveryID int) (*models.WebhookDelivery, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	stored := db.delivery(deliveryID)
+	if stored == nil {
+		return nil, models.ErrDeliveryNotFound
+	}
+	delivery := copyDelivery(stored)
+	delivery.Attempts = make([]models.WebhookAttempt, 0, len(db.attempts[deliveryID]))
+	for _, attempt := range db.attempts[deliveryID] {
+		delivery.Attempts = append(delivery.Attempts, copyAttempt(attempt))
+	}
+	return &delivery, nil
+}
+
+// ReplayWebhookDelivery puts a delivery back in the queue with a fresh retry budget.
+func (db *DB) ReplayWebhookDelivery(ctx context.Context, deliveryID int, now time.Time) error {
+	return db.update(func() error {
+		delivery := db.delivery(deliveryID)
+		if delivery == nil {
+			return models.ErrDeliveryNotFound
+		}
+		now = now.UTC().Truncate(time.Second)
+		delivery.Status, delivery.AttemptCount = models.DeliveryPending, 0
+		delivery.NextAttemptAt, delivery.UpdatedAt = now, now
+		return nil
+	})
+}
+
+// ClaimWebhookDeliveries returns due deliveries and pushes their next attempt forward by lease,
+// so that another dispatcher does not pick them up while they are in flight.
+func (db *DB) ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration,
+	limit int) ([]models.WebhookDispatch, error) {
+	dispatches := make([]models.WebhookDispatch, 0)
+	err := db.update(func() error {
+		due := make([]*models.WebhookDelivery, 0)
+		for _, delivery := range db.deliveries {
+			if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+				due = append(due, delivery)
+			}
+		}
+		sort.SliceStable(due, func(i, j int) bool {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		})
+		nextAttemptAt := now.Add(lease).UTC().Truncate(time.Second)
+		for _, delivery := range due {
+			if len(dispatches) == limit {
+				break
+			}
+			webhook := db.webhooks[delivery.WebhookID]
+			dispatches = append(dispatches, models.WebhookDispatch{
+				DeliveryID:   delivery.ID,
+				AttemptCount: delivery.AttemptCount,
+				URL:          webhook.URL,
+				Secret:       webhook.Secret,
+				Event:        db.events[delivery.EventID-1].Event,
+			})
+			delivery.NextAttemptAt = nextAttemptAt
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dispatches, nil
+}
+
+// RecordWebhookAttempt logs an attempt and moves the delivery to its next state.
+func (db *DB) RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt, status string,
+	nextAttemptAt time.Time) error {
+	return db.update(func() error {
+		delivery := db.delivery(attempt.DeliveryID)
+		if delivery == nil {
+			return foreignKeyViolation("webhook_attempts", "webhook_attempts_delivery_id_fkey")
+		}
+		attempt.AttemptedAt = attempt.AttemptedAt.UTC().Truncate(time.Second)
+		db.attempts[delivery.ID] = append(db.attempts[delivery.ID], copyAttempt(attempt))
+		delivery.Status = status
+		delivery.AttemptCount++
+		delivery.NextAttemptAt = nextAttemptAt.UTC().Truncate(time.Second)
+		delivery.LastError = copyString(attempt.Error)
+		delivery.ResponseStatus = copyInt(attempt.ResponseStatus)
+		delivery.DeliveredAt = nil
+		if status == models.DeliveryDelivered {
+			delivery.DeliveredAt = copyTime(&attempt.AttemptedAt)
+		}
+		delivery.UpdatedAt = attempt.AttemptedAt
+		return nil
+	})
+}
+
+func (db *DB) insertWebhookDeliveries(eventID int, eventType string) {
+	now := timestamp()
+	webhookIDs := make([]int, 0)
+	for _, webhook := range db.webhooks {
+		if webhook.Active && subscribed(webhook, eventType) {
+			webhookIDs = append(webhookIDs, webhook.ID)
+		}
+	}
+	sort.Ints(webhookIDs)
+	for _, webhookID := range webhookIDs {
+		db.lastDeliveryID++
+		db.deliveries = append(db.deliveries, &models.WebhookDelivery{
+			ID:            db.lastDeliveryID,
+			WebhookID:     webhookID,
+			EventID:       eventID,
+			EventType:     eventType,
+			AccountID:     db.events[eventID-1].AccountID,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+}
+
+func (db *DB) delivery(deliveryID int) *models.WebhookDelivery {
+	for _, delivery := range db.deliveries {
+		if delivery.ID == deliveryID {
+			return delivery
+		}
+	}
+	return nil
+}
+
+func subscribed(webhook *models.Webhook, eventType string) bool {
+	for _, subscribedType := range webhook.EventTypes {
+		if subscribedType == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func copyWebhook(webhook *models.Webhook) models.Webhook {
+	c := *webhook
+	c.EventTypes = append(models.EventTypeList(nil), webhook.EventTypes...)
+	return c
+}
+
+func copyDelivery(delivery *models.WebhookDelivery) models.WebhookDelivery {
+	c := *delivery
+	c.LastError = copyString(delivery.LastError)
+	c.ResponseStatus = copyInt(delivery.ResponseStatus)
+	c.DeliveredAt = copyTime(delivery.DeliveredAt)
+	c.Attempts = nil
+	return c
+}
+
+func copyAttempt(attempt models.WebhookAttempt) models.WebhookAttempt {
+	attempt.ResponseStatus = copyInt(attempt.ResponseStatus)
+	attempt.Error = copyString(attempt.Error)
+	return attempt
+}
+
+func copyString(v *string) *string {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	AdjustmentPending  = "pending"
+	AdjustmentApproved = "approved"
+	AdjustmentRejected = "rejected"
+	AdjustmentExpired  = "expired"
+)
+
+// Adjustment is a signed balance correction proposed by one admin and reviewed by another.
+type Adjustment struct {
+	ID            int        `json:"id" db:"id"`
+	AccountID     int        `json:"account_id" db:"account_id"`
+	Amount        Money      `json:"amount" db:"amount"`
+	Currency      string     `json:"currency" db:"currency"`
+	Comment       string     `json:"comment" db:"comment"`
+	Reason        string     `json:"reason" db:"reason"`
+	Attachment    string     `json:"attachment" db:"attachment"`
+	Status        string     `json:"status" db:"status"`
+	ProposedBy    int        `json:"proposed_by" db:"proposed_by"`
+	ReviewedBy    *int       `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	ReviewComment string     `json:"review_comment,omitempty" db:"review_comment"`
+	TransactionID *int       `json:"transaction_id,omitempty" db:"transaction_id"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt     time.Time  `json:"expires_at" db:"expires_at"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty" db:"reviewed_at"`
+}
+
+func (a Adjustment) Validate() error {
+	if a.AccountID <= 0 {
+		return fmt.Errorf("%w: account_id is required", ErrInvalidAccount)
+	}
+	if strings.TrimSpace(a.Reason) == "" {
+		return ErrReasonRequired
+	}
+	if strings.TrimSpace(a.Attachment) == "" {
+		return fmt.Errorf("%w: attachment is required", ErrInvalidAdjustment)
+	}
+	amount := a.Amount
+	if amount < 0 {
+		amount = -amount
+	}
+	if err := amount.Validate(); err != nil {
+		return err
+	}
+	return ValidateCurrency(a.Currency)
+}
+
+// Transaction is the wallet operation an approved adjustment results in, attributed to the reviewer.
+// The idempotence key is the negated adjustment ID, so an adjustment posts once. Client keys must be positive
+// (see validateIdempotenceKey), which keeps the two apart.
+func (a Adjustment) Transaction(reviewerID int) Transaction {
+	return Transaction{
+		IdempotenceKey: -a.ID,
+		Amount:         a.Amount,
+		Currency:       a.Currency,
+		Comment:        a.Comment,
+		Actor:          &Actor{AdminID: reviewerID, Reason: a.Reason},
+	}
+}
+
+type AdjustmentReview struct {
+	ID      int    `json:"id"`
+	Comment string `json:"comment"`
+}
+
+type AdjustmentsQueryParams struct {
+	AccountID int
+	Status    string
+	Limit     int
+	Offset    int
+}
+
+func ValidateAdjustmentStatus(status string) error {
+	switch status {
+	case AdjustmentPending, AdjustmentApproved, AdjustmentRejected, AdjustmentExpired:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+}
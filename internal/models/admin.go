@@ -0,0 +1,87 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	AdminActionDeposit    = "deposit"
+	AdminActionWithdrawal = "withdrawal"
+	AdminActionAdjustment = "adjustment"
+)
+
+// Actor marks an operation performed by an admin on behalf of the account owner.
+type Actor struct {
+	AdminID int
+	Reason  string
+}
+
+// AdminTransaction is a balance operation support staff performs on someone else's account.
+type AdminTransaction struct {
+	AccountID      int    `json:"account_id"`
+	IdempotenceKey int    `json:"idempotence_key"`
+	Amount         Money  `json:"amount"`
+	Currency       string `json:"currency"`
+	Comment        string `json:"comment"`
+	Reason         string `json:"reason"`
+}
+
+// Validate checks the operation. Adjustments are signed, deposits and withdrawals must be positive.
+func (t AdminTransaction) Validate(signed bool) error {
+	if t.AccountID <= 0 {
+		return fmt.Errorf("%w: account_id is required", ErrInvalidAccount)
+	}
+	if strings.TrimSpace(t.Reason) == "" {
+		return ErrReasonRequired
+	}
+	if err := validateIdempotenceKey(t.IdempotenceKey); err != nil {
+		return err
+	}
+	amount := t.Amount
+	if signed && amount < 0 {
+		amount = -amount
+	}
+	if err := amount.Validate(); err != nil {
+		return err
+	}
+	return ValidateCurrency(t.Currency)
+}
+
+func (t AdminTransaction) Transaction(adminID int) Transaction {
+	return Transaction{
+		IdempotenceKey: t.IdempotenceKey,
+		Amount:         t.Amount,
+		Currency:       t.Currency,
+		Comment:        t.Comment,
+		Actor:          &Actor{AdminID: adminID, Reason: t.Reason},
+	}
+}
+
+type AdminAction struct {
+	ID            int       `json:"id" db:"id"`
+	AdminID       int       `json:"admin_id" db:"admin_id"`
+	AccountID     int       `json:"account_id" db:"account_id"`
+	Action        string    `json:"action" db:"action"`
+	TransactionID *int      `json:"transaction_id,omitempty" db:"transaction_id"`
+	Currency      string    `json:"currency" db:"currency"`
+	Amount        Money     `json:"amount" db:"amount"`
+	Reason        string    `json:"reason" db:"reason"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+type AdminActionsQueryParams struct {
+	AccountID int
+	AdminID   int
+	Limit     int
+	Offset    int
+}
+
+// ID returns the acting admin, or zero when the owner acts on their own account.
+func (a *Actor) ID() int {
+	if a == nil {
+		return 0
+	}
+	return a.AdminID
+}
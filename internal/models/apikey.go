@@ -0,0 +1,131 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const APIKeyPrefix = "bsk_"
+
+// ScopeList is stored as a jsonb array.
+type ScopeList []string
+
+func (l ScopeList) Value() (driver.Value, error) {
+	data, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (l *ScopeList) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	}
+	return errors.New("unsupported scope list")
+}
+
+// ServiceIDList is stored as a jsonb array.
+type ServiceIDList []int
+
+func (l ServiceIDList) Value() (driver.Value, error) {
+	data, err := json.Marshal([]int(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (l *ServiceIDList) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	}
+	return errors.New("unsupported service id list")
+}
+
+// APIKey is a machine credential. Only the sha256 Hash of the key is stored, the plain Key is returned once on
+// creation or rotation.
+type APIKey struct {
+	ID          int           `json:"id" db:"id"`
+	ClientName  string        `json:"client_name" db:"client_name"`
+	Prefix      string        `json:"prefix" db:"key_prefix"`
+	Hash        string        `json:"-" db:"key_hash"`
+	Key         string        `json:"key,omitempty" db:"-"`
+	Scopes      ScopeList     `json:"scopes" db:"scopes"`
+	ServiceIDs  ServiceIDList `json:"service_ids" db:"service_ids"`
+	CreatedBy   int           `json:"created_by" db:"created_by"`
+	RotatedFrom *int          `json:"rotated_from,omitempty" db:"rotated_from"`
+	CreatedAt   time.Time     `json:"created_at" db:"created_at"`
+	ExpiresAt   *time.Time    `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt   *time.Time    `json:"revoked_at,omitempty" db:"revoked_at"`
+	LastUsedAt  *time.Time    `json:"last_used_at,omitempty" db:"last_used_at"`
+}
+
+func (k APIKey) Validate() error {
+	if strings.TrimSpace(k.ClientName) == "" {
+		return fmt.Errorf("%w: client_name is required", ErrInvalidAPIKey)
+	}
+	if len(k.Scopes) == 0 {
+		return fmt.Errorf("%w: scopes are required", ErrInvalidAPIKey)
+	}
+	reserves := false
+	for _, scope := range k.Scopes {
+		if err := validateAPIKeyScope(scope); err != nil {
+			return err
+		}
+		reserves = reserves || strings.HasPrefix(scope, "reserve:")
+	}
+	// A key is always limited to its services, so with reserve scopes an empty list would leave it unusable.
+	if reserves && len(k.ServiceIDs) == 0 {
+		return fmt.Errorf("%w: service_ids are required for reserve scopes", ErrInvalidAPIKey)
+	}
+	for _, serviceID := range k.ServiceIDs {
+		if serviceID <= 0 {
+			return fmt.Errorf("%w: service_ids must be positive", ErrInvalidAPIKey)
+		}
+	}
+	return nil
+}
+
+func (k APIKey) Active(now time.Time) bool {
+	return (k.RevokedAt == nil || now.Before(*k.RevokedAt)) && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// Session drops scopes that API keys may no longer carry, keys issued before the restriction keep the rest.
+func (k APIKey) Session() SessionInfo {
+	scopes := make([]string, 0, len(k.Scopes))
+	for _, scope := range k.Scopes {
+		if isAPIKeyScope(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	serviceIDs := make([]int, len(k.ServiceIDs))
+	copy(serviceIDs, k.ServiceIDs)
+	return SessionInfo{
+		APIKeyID:   k.ID,
+		Role:       RoleService,
+		Scopes:     scopes,
+		Client:     k.ClientName,
+		ServiceIDs: serviceIDs,
+	}
+}
+
+// APIKeyRotation issues a new key with the same permissions. The old key keeps working for GracePeriod seconds.
+type APIKeyRotation struct {
+	ID          int `json:"id"`
+	GracePeriod int `json:"grace_period,omitempty"`
+}
+
+type APIKeyRevocation struct {
+	ID int `json:"id"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	RoleSystem     = "system"
+	AuditOutcomeOK = "ok"
+)
+
+// AuditBalances is a balance snapshot stored as jsonb. A nil snapshot is stored as NULL.
+type AuditBalances []WalletBalance
+
+func (b AuditBalances) Value() (driver.Value, error) {
+	if b == nil {
+		return nil, nil
+	}
+	return json.Marshal(b)
+}
+
+func (b *AuditBalances) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*b = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, b)
+	case string:
+		return json.Unmarshal([]byte(v), b)
+	}
+	return errors.New("unsupported audit balances type")
+}
+
+// AuditRecord is one entry of the append-only audit log. Hash covers every field but the ID together with
+// the previous record's hash, so an edited or deleted record breaks the chain.
+type AuditRecord struct {
+	ID             int64         `json:"id" db:"id"`
+	AccountID      int           `json:"account_id" db:"account_id"`
+	Role           string        `json:"role" db:"role"`
+	SubjectID      int           `json:"subject_id" db:"subject_id"`
+	Method         string        `json:"method" db:"method"`
+	Endpoint       string        `json:"endpoint" db:"endpoint"`
+	Payload        string        `json:"payload" db:"payload"`
+	StatusCode     int           `json:"status_code" db:"status_code"`
+	Outcome        string        `json:"outcome" db:"outcome"`
+	BalancesBefore AuditBalances `json:"balances_before" db:"balances_before"`
+	BalancesAfter  AuditBalances `json:"balances_after" db:"balances_after"`
+	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
+	PrevHash       string        `json:"prev_hash" db:"prev_hash"`
+	Hash           string        `json:"hash" db:"hash"`
+}
+
+func (r AuditRecord) Digest() string {
+	data, _ := json.Marshal([]interface{}{
+		r.PrevHash, r.AccountID, r.Role, r.SubjectID, r.Method, r.Endpoint, r.Payload, r.StatusCode, r.Outcome,
+		r.BalancesBefore, r.BalancesAfter, r.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+type AuditQueryParams struct {
+	AfterID int64
+	ToID    int64
+	From    time.Time
+	To      time.Time
+	Limit   int
+}
+
+type AuditVerification struct {
+	Valid     bool   `json:"valid"`
+	Checked   int    `json:"checked"`
+	InvalidID *int64 `json:"invalid_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
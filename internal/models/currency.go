@@ -0,0 +1,18 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultCurrency is the currency of the wallets that existed before wallets became per-currency.
+const DefaultCurrency = "RUB"
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+func ValidateCurrency(currency string) error {
+	if !currencyCode.MatchString(currency) {
+		return fmt.Errorf("%w: %q is not an ISO-4217 code", ErrInvalidCurrency, currency)
+	}
+	return nil
+}
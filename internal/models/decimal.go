@@ -0,0 +1,91 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func parseDecimal(s string, digits int) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty value")
+	}
+	raw := s
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+	integer, fraction, hasFraction := strings.Cut(s, ".")
+	if integer == "" || (hasFraction && fraction == "") {
+		return 0, fmt.Errorf("malformed value %q", raw)
+	}
+	if len(fraction) > digits {
+		return 0, fmt.Errorf("more than %d fractional digits in %q", digits, raw)
+	}
+	if !isDigits(integer) || !isDigits(fraction) {
+		return 0, fmt.Errorf("malformed value %q", raw)
+	}
+	scale := pow10(digits)
+	major, err := strconv.ParseInt(integer, 10, 64)
+	if err != nil || major > math.MaxInt64/scale-1 {
+		return 0, fmt.Errorf("value %q is out of range", raw)
+	}
+	var minor int64
+	if fraction != "" {
+		fraction += strings.Repeat("0", digits-len(fraction))
+		minor, _ = strconv.ParseInt(fraction, 10, 64)
+	}
+	v := major*scale + minor
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+func formatDecimal(v int64, digits int) string {
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	scale := pow10(digits)
+	return fmt.Sprintf("%s%d.%0*d", sign, v/scale, digits, v%scale)
+}
+
+func scanDecimal(src interface{}, digits int) (int64, error) {
+	switch v := src.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return v * pow10(digits), nil
+	case string:
+		return parseDecimal(v, digits)
+	case []byte:
+		return parseDecimal(string(v), digits)
+	default:
+		return 0, fmt.Errorf("unsupported decimal source type %T", src)
+	}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func pow10(n int) int64 {
+	v := int64(1)
+	for i := 0; i < n; i++ {
+		v *= 10
+	}
+	return v
+}
@@ -8,4 +8,38 @@ var (
 	ErrServiceNotFound        = errors.New("service not found")
 	ErrNotEnoughMoney         = errors.New("not enough money on the balance")
 	ErrNotEnoughReservedMoney = errors.New("not enough reserved money on the balance")
+	ErrInvalidAmount          = errors.New("invalid amount")
+	ErrInvalidCurrency        = errors.New("invalid currency")
+	ErrCurrencyMismatch       = errors.New("currency mismatch")
+	ErrInvalidRate            = errors.New("invalid exchange rate")
+	ErrRateNotFound           = errors.New("exchange rate not found")
+	ErrQuoteNotFound          = errors.New("exchange quote not found or expired")
+	ErrOrderNotCompleted      = errors.New("order is not completed")
+	ErrRefundExceedsCaptured  = errors.New("refund exceeds captured amount")
+	ErrIdempotenceKeyRequired = errors.New("idempotence_key is required for repeated captures")
+	ErrInvalidIdempotenceKey  = errors.New("invalid idempotence_key")
+	ErrInvalidExpiry          = errors.New("invalid reservation expiry")
+	ErrInvalidStatus          = errors.New("invalid reservation status")
+	ErrInvalidService         = errors.New("invalid service")
+	ErrServiceInactive        = errors.New("service is inactive")
+	ErrServiceInUse           = errors.New("service is referenced by orders")
+	ErrInvalidPeriod          = errors.New("invalid period")
+	ErrLedgerMismatch         = errors.New("ledger does not reconcile with wallet balance")
+	ErrInvalidIdempotencyKey  = errors.New("invalid idempotency key")
+	ErrIdempotencyKeyReused   = errors.New("idempotency key reused with a different payload")
+	ErrIdempotencyInProgress  = errors.New("request with this idempotency key is in progress")
+	ErrInvalidCursor          = errors.New("invalid cursor")
+	ErrInvalidFilter          = errors.New("invalid filter")
+	ErrInvalidWebhook         = errors.New("invalid webhook")
+	ErrWebhookNotFound        = errors.New("webhook not found")
+	ErrDeliveryNotFound       = errors.New("webhook delivery not found")
+	ErrInvalidAccount         = errors.New("invalid account")
+	ErrReasonRequired         = errors.New("reason is required")
+	ErrInvalidAdjustment      = errors.New("invalid adjustment")
+	ErrAdjustmentNotFound     = errors.New("adjustment not found")
+	ErrAdjustmentNotPending   = errors.New("adjustment is not pending")
+	ErrAdjustmentExpired      = errors.New("adjustment has expired")
+	ErrSelfReview             = errors.New("adjustment cannot be reviewed by its proposer")
+	ErrInvalidAPIKey          = errors.New("invalid api key")
+	ErrAPIKeyNotFound         = errors.New("api key not found")
 )
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	EventFundsDeposited    = "FundsDeposited"
+	EventFundsWithdrawn    = "FundsWithdrawn"
+	EventTransferCompleted = "TransferCompleted"
+	EventTransferReceived  = "TransferReceived"
+	EventFundsReserved     = "FundsReserved"
+	EventReserveApplied    = "ReserveApplied"
+	EventReserveCancelled  = "ReserveCancelled"
+	EventReserveExpired    = "ReserveExpired"
+	EventOrderRefunded     = "OrderRefunded"
+	EventBalanceAdjusted   = "BalanceAdjusted"
+)
+
+var EventTypes = []string{
+	EventFundsDeposited, EventFundsWithdrawn, EventTransferCompleted, EventTransferReceived, EventFundsReserved,
+	EventReserveApplied, EventReserveCancelled, EventReserveExpired, EventOrderRefunded, EventBalanceAdjusted,
+}
+
+// Event is a domain event stored in the outbox. Sequence grows by one per account without gaps,
+// so consumers can drop redeliveries and detect reordering.
+type Event struct {
+	ID        int             `json:"id" db:"id"`
+	AccountID int             `json:"account_id" db:"account_id"`
+	Sequence  int64           `json:"sequence" db:"sequence"`
+	Type      string          `json:"type" db:"event_type"`
+	Payload   json.RawMessage `json:"payload" db:"payload"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+type BalanceEvent struct {
+	AccountID      int    `json:"account_id"`
+	TransactionID  *int   `json:"transaction_id,omitempty"`
+	OrderID        int    `json:"order_id,omitempty"`
+	ServiceID      int    `json:"service_id,omitempty"`
+	Counterparty   int    `json:"counterparty,omitempty"`
+	Currency       string `json:"currency"`
+	Amount         Money  `json:"amount"`
+	TargetCurrency string `json:"target_currency,omitempty"`
+	TargetAmount   *Money `json:"target_amount,omitempty"`
+	Final          bool   `json:"final,omitempty"`
+	AdminID        int    `json:"admin_id,omitempty"`
+}
+
+func ValidateEventType(eventType string) error {
+	for _, known := range EventTypes {
+		if eventType == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, eventType)
+}
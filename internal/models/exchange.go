@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type ExchangeQuote struct {
+	ID        string    `json:"quote_id" db:"id"`
+	AccountID int       `json:"-" db:"owner_id"`
+	From      string    `json:"from" db:"from_currency"`
+	To        string    `json:"to" db:"to_currency"`
+	Rate      Rate      `json:"rate" db:"rate"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+type Conversion struct {
+	TargetCurrency string
+	TargetAmount   Money
+	Rate           Rate
+	QuoteID        string
+}
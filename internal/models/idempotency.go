@@ -0,0 +1,33 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyRecord keys are scoped to the caller, API keys have an account ID of 0 and get a namespace of their own.
+type IdempotencyRecord struct {
+	AccountID   int       `db:"account_id"`
+	APIKeyID    int       `db:"api_key_id"`
+	Key         string    `db:"key"`
+	Endpoint    string    `db:"endpoint"`
+	Fingerprint string    `db:"fingerprint"`
+	StatusCode  *int      `db:"status_code"`
+	ContentType string    `db:"content_type"`
+	Body        []byte    `db:"response_body"`
+	LockedUntil time.Time `db:"locked_until"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+func (r IdempotencyRecord) Completed() bool {
+	return r.StatusCode != nil
+}
+
+func ValidateIdempotencyKey(key string) error {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return fmt.Errorf("%w: must be 1 to %d characters", ErrInvalidIdempotencyKey, maxIdempotencyKeyLength)
+	}
+	return nil
+}
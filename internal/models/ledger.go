@@ -0,0 +1,7 @@
+package models
+
+type LedgerCheck struct {
+	Balanced          bool  `json:"balanced"`
+	UnbalancedEntries []int `json:"unbalanced_entries"`
+	MismatchedWallets []int `json:"mismatched_wallets"`
+}
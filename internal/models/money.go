@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+const (
+	minorUnits     = 100
+	fractionDigits = 2
+)
+
+// Money is an amount in minor currency units (kopecks, cents).
+type Money int64
+
+func NewMoney(major, minor int64) Money {
+	return Money(major*minorUnits + minor)
+}
+
+func ParseMoney(s string) (Money, error) {
+	v, err := parseDecimal(s, fractionDigits)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+	}
+	return Money(v), nil
+}
+
+func (m Money) Validate() error {
+	if m <= 0 {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidAmount)
+	}
+	return nil
+}
+
+func (m Money) String() string {
+	return formatDecimal(int64(m), fractionDigits)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	parsed, err := ParseMoney(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	v, err := scanDecimal(src, fractionDigits)
+	if err != nil {
+		return err
+	}
+	*m = Money(v)
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
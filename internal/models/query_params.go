@@ -1,12 +1,64 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type TransactionsQueryParams struct {
-	From       time.Time
-	To         time.Time
-	Limit      int
-	Offset     int
-	Sorting    string
-	Descending string
+	Currency     string
+	From         time.Time
+	To           time.Time
+	Limit        int
+	Offset       int
+	Sorting      string
+	Descending   string
+	Cursor       string
+	Type         string
+	Counterparty int
+	ServiceID    int
+	MinAmount    *Money
+	MaxAmount    *Money
+	Comment      string
+}
+
+func (p TransactionsQueryParams) Validate() error {
+	if p.Currency != "" {
+		if err := ValidateCurrency(p.Currency); err != nil {
+			return err
+		}
+	}
+	if p.Sorting != "" {
+		if err := ValidateSorting(p.Sorting); err != nil {
+			return err
+		}
+	}
+	if p.Type != "" {
+		if err := ValidateTransactionType(p.Type); err != nil {
+			return err
+		}
+	}
+	switch {
+	case p.Limit < 0 || p.Offset < 0:
+		return fmt.Errorf("%w: limit and offset must not be negative", ErrInvalidFilter)
+	case p.Counterparty < 0 || p.ServiceID < 0:
+		return fmt.Errorf("%w: ids must be positive", ErrInvalidFilter)
+	case p.MinAmount != nil && *p.MinAmount < 0, p.MaxAmount != nil && *p.MaxAmount < 0:
+		return fmt.Errorf("%w: amount bounds must not be negative", ErrInvalidFilter)
+	case p.MinAmount != nil && p.MaxAmount != nil && *p.MinAmount > *p.MaxAmount:
+		return fmt.Errorf("%w: min_amount is greater than max_amount", ErrInvalidFilter)
+	case p.Cursor != "" && p.Offset > 0:
+		return fmt.Errorf("%w: cursor and offset are mutually exclusive", ErrInvalidCursor)
+	}
+	return nil
+}
+
+type ReservationsQueryParams struct {
+	AccountID int
+	Status    string
+	ServiceID int
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
 }
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const rateDigits = 8
+
+// Rate is an exchange rate scaled by 10^8: the number of target minor units for 10^8 source minor units.
+type Rate int64
+
+var IdentityRate = Rate(pow10(rateDigits))
+
+func ParseRate(s string) (Rate, error) {
+	v, err := parseDecimal(s, rateDigits)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidRate, err)
+	}
+	if v <= 0 {
+		return 0, fmt.Errorf("%w: rate must be positive", ErrInvalidRate)
+	}
+	return Rate(v), nil
+}
+
+// Convert applies the rate to amount, rounding half away from zero to the nearest minor unit.
+func (r Rate) Convert(amount Money) Money {
+	product := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(int64(r)))
+	return Money(roundDiv(product, big.NewInt(pow10(rateDigits))).Int64())
+}
+
+func (r Rate) Inverse() Rate {
+	scale := big.NewInt(pow10(rateDigits))
+	numerator := new(big.Int).Mul(scale, scale)
+	return Rate(roundDiv(numerator, big.NewInt(int64(r))).Int64())
+}
+
+func roundDiv(numerator, denominator *big.Int) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(new(big.Int).Abs(denominator)) >= 0 {
+		if numerator.Sign()*denominator.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return quotient
+}
+
+func (r Rate) String() string {
+	return formatDecimal(int64(r), rateDigits)
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	parsed, err := ParseRate(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+func (r *Rate) Scan(src interface{}) error {
+	v, err := scanDecimal(src, rateDigits)
+	if err != nil {
+		return err
+	}
+	*r = Rate(v)
+	return nil
+}
+
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
@@ -0,0 +1,7 @@
+package models
+
+type ReportRow struct {
+	ServiceTitle string `db:"title"`
+	Currency     string `db:"currency"`
+	Amount       Money  `db:"amount"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	ReservationActive    = "Active"
+	ReservationCompleted = "Completed"
+	ReservationCancelled = "Cancelled"
+	ReservationExpired   = "Expired"
+)
+
+type Reservation struct {
+	OrderID         int        `json:"order_id" db:"order_id"`
+	AccountID       int        `json:"account_id" db:"owner_id"`
+	ServiceID       int        `json:"service_id" db:"service_id"`
+	Currency        string     `json:"currency" db:"currency"`
+	Amount          Money      `json:"amount" db:"amount"`
+	CapturedAmount  Money      `json:"captured_amount" db:"captured_amount"`
+	RemainingAmount Money      `json:"remaining_amount" db:"remaining_amount"`
+	Status          string     `json:"status" db:"status"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func ValidateReservationStatus(status string) error {
+	switch status {
+	case ReservationActive, ReservationCompleted, ReservationCancelled, ReservationExpired:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+}
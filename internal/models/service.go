@@ -1,6 +1,34 @@
 package models
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 type Service struct {
-	Title  string  `db:"title"`
-	Amount float64 `db:"amount"`
+	ID           int       `json:"id" db:"id"`
+	Title        string    `json:"title" db:"title"`
+	Description  string    `json:"description" db:"description"`
+	Active       bool      `json:"active" db:"active"`
+	DefaultPrice *Money    `json:"default_price,omitempty" db:"default_price"`
+	Currency     *string   `json:"currency,omitempty" db:"currency"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (s Service) Validate() error {
+	if strings.TrimSpace(s.Title) == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidService)
+	}
+	if (s.DefaultPrice == nil) != (s.Currency == nil) {
+		return fmt.Errorf("%w: default_price and currency must be set together", ErrInvalidService)
+	}
+	if s.DefaultPrice == nil {
+		return nil
+	}
+	if err := s.DefaultPrice.Validate(); err != nil {
+		return err
+	}
+	return ValidateCurrency(*s.Currency)
 }
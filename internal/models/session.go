@@ -1,6 +1,139 @@
 package models
 
+import "fmt"
+
+const (
+	RoleAdmin   = "admin"
+	RoleFinance = "finance"
+	RoleService = "service"
+)
+
+const (
+	ScopeWalletRead     = "wallet:read"
+	ScopeWalletDeposit  = "wallet:deposit"
+	ScopeWalletWithdraw = "wallet:withdraw"
+	ScopeWalletTransfer = "wallet:transfer"
+	ScopeReserveRead    = "reserve:read"
+	ScopeReserveWrite   = "reserve:write"
+	ScopeReserveAny     = "reserve:any"
+	ScopeReserveRefund  = "reserve:refund"
+	ScopeReportRead     = "report:read"
+	ScopeLedgerRead     = "ledger:read"
+	ScopeServiceRead    = "service:read"
+	ScopeServiceWrite   = "service:write"
+	ScopeWebhookRead    = "webhook:read"
+	ScopeWebhookWrite   = "webhook:write"
+	ScopeAdminRead      = "admin:read"
+	ScopeAdminWrite     = "admin:write"
+	ScopeAdminAdjust    = "admin:adjust"
+	ScopeAuditRead      = "audit:read"
+	ScopeAdminKeys      = "admin:keys"
+)
+
+var allScopes = []string{
+	ScopeWalletRead, ScopeWalletDeposit, ScopeWalletWithdraw, ScopeWalletTransfer,
+	ScopeReserveRead, ScopeReserveWrite, ScopeReserveAny, ScopeReserveRefund,
+	ScopeReportRead, ScopeLedgerRead, ScopeServiceRead, ScopeServiceWrite, ScopeWebhookRead, ScopeWebhookWrite,
+	ScopeAdminRead, ScopeAdminWrite, ScopeAdminAdjust, ScopeAuditRead, ScopeAdminKeys,
+}
+
+var userScopes = []string{
+	ScopeWalletRead,
+	ScopeWalletDeposit,
+	ScopeWalletWithdraw,
+	ScopeWalletTransfer,
+	ScopeReserveRead,
+	ScopeReserveWrite,
+}
+
+// apiKeyScopes are the service-facing scopes an API key may carry. Keys act for no account of their own,
+// so wallet and admin scopes are left to JWT sessions.
+var apiKeyScopes = []string{
+	ScopeReserveRead,
+	ScopeReserveWrite,
+	ScopeReserveAny,
+	ScopeReserveRefund,
+	ScopeReportRead,
+	ScopeServiceRead,
+}
+
+// roleScopes are granted to tokens that carry a role but no scope claim. Any other role gets userScopes.
+var roleScopes = map[string][]string{
+	RoleAdmin:   allScopes,
+	RoleFinance: {ScopeWalletRead, ScopeReportRead, ScopeLedgerRead},
+}
+
+func RoleScopes(role string) []string {
+	if scopes, ok := roleScopes[role]; ok {
+		return scopes
+	}
+	return userScopes
+}
+
+func ValidateScope(scope string) error {
+	for _, item := range allScopes {
+		if item == scope {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKey, scope)
+}
+
+func validateAPIKeyScope(scope string) error {
+	if err := ValidateScope(scope); err != nil {
+		return err
+	}
+	if !isAPIKeyScope(scope) {
+		return fmt.Errorf("%w: scope %q is not available to api keys", ErrInvalidAPIKey, scope)
+	}
+	return nil
+}
+
+func isAPIKeyScope(scope string) bool {
+	for _, item := range apiKeyScopes {
+		if item == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// SessionInfo of an API key has APIKeyID and Client set and is limited to ServiceIDs, even when the list is empty.
+// JWT sessions have nil ServiceIDs, which allow every service.
 type SessionInfo struct {
-	AccountID int
-	Role      string
+	AccountID  int
+	APIKeyID   int
+	Role       string
+	Scopes     []string
+	Client     string
+	ServiceIDs []int
+}
+
+func (s SessionInfo) HasScope(scope string) bool {
+	for _, item := range s.Scopes {
+		if item == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (s SessionInfo) AllowsService(serviceID int) bool {
+	if s.ServiceIDs == nil {
+		return true
+	}
+	for _, item := range s.ServiceIDs {
+		if item == serviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal is the role recorded in the audit log, API keys are recorded with their client name.
+func (s SessionInfo) Principal() string {
+	if s.Client != "" {
+		return s.Role + ":" + s.Client
+	}
+	return s.Role
 }
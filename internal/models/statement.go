@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+type StatementLine struct {
+	EntryID       int       `json:"entry_id" db:"entry_id"`
+	Kind          string    `json:"kind" db:"kind"`
+	Reference     string    `json:"reference" db:"reference"`
+	TransactionID *int      `json:"transaction_id,omitempty" db:"transaction_id"`
+	Comment       string    `json:"comment" db:"comment"`
+	Amount        Money     `json:"amount" db:"amount"`
+	Balance       Money     `json:"balance" db:"-"`
+	Timestamp     time.Time `json:"timestamp" db:"created_at"`
+}
+
+type Statement struct {
+	AccountID      int             `json:"account_id"`
+	Currency       string          `json:"currency"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance Money           `json:"opening_balance"`
+	TotalCredit    Money           `json:"total_credit"`
+	TotalDebit     Money           `json:"total_debit"`
+	ClosingBalance Money           `json:"closing_balance"`
+	WalletBalance  Money           `json:"-"`
+	Lines          []StatementLine `json:"lines"`
+}
+
+// Close fills running balances and totals from the opening balance and the signed line amounts.
+func (s *Statement) Close() {
+	balance := s.OpeningBalance
+	for i := range s.Lines {
+		balance += s.Lines[i].Amount
+		s.Lines[i].Balance = balance
+		if s.Lines[i].Amount > 0 {
+			s.TotalCredit += s.Lines[i].Amount
+		} else {
+			s.TotalDebit -= s.Lines[i].Amount
+		}
+	}
+	s.ClosingBalance = balance
+}
@@ -1,33 +1,149 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	TransactionKindDeposit        = "deposit"
+	TransactionKindWithdrawal     = "withdrawal"
+	TransactionKindTransfer       = "transfer"
+	TransactionKindReserve        = "reserve"
+	TransactionKindReserveRelease = "reserve_release"
+	TransactionKindServiceCharge  = "service_charge"
+	TransactionKindRefund         = "refund"
+	TransactionKindAdjustment     = "adjustment"
+)
+
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
 
 type Transaction struct {
-	IdempotenceKey int     `json:"idempotence_key"`
-	Amount         float64 `json:"amount"`
-	Comment        string  `json:"comment"`
+	IdempotenceKey int    `json:"idempotence_key"`
+	Amount         Money  `json:"amount"`
+	Currency       string `json:"currency"`
+	Comment        string `json:"comment"`
+	Actor          *Actor `json:"-"`
 }
 
 type TransferTransaction struct {
-	IdempotenceKey int     `json:"idempotence_key"`
-	Target         int     `json:"target"`
-	Amount         float64 `json:"amount"`
-	Comment        string  `json:"comment"`
+	IdempotenceKey int    `json:"idempotence_key"`
+	Target         int    `json:"target"`
+	Amount         Money  `json:"amount"`
+	Currency       string `json:"currency"`
+	TargetCurrency string `json:"target_currency"`
+	QuoteID        string `json:"quote_id"`
+	Comment        string `json:"comment"`
 }
 
 type ReserveTransaction struct {
-	AccountID int     `json:"account_id"`
-	ServiceID int     `json:"service_id"`
-	OrderID   int     `json:"order_id"`
-	Amount    float64 `json:"amount"`
+	IdempotenceKey int        `json:"idempotence_key,omitempty"`
+	AccountID      int        `json:"account_id"`
+	ServiceID      int        `json:"service_id"`
+	OrderID        int        `json:"order_id"`
+	Amount         Money      `json:"amount"`
+	Currency       string     `json:"currency"`
+	Final          bool       `json:"final,omitempty"`
+	TTL            int        `json:"ttl,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+type RefundTransaction struct {
+	IdempotenceKey int    `json:"idempotence_key"`
+	AccountID      int    `json:"account_id"`
+	OrderID        int    `json:"order_id"`
+	Amount         Money  `json:"amount"`
+	Comment        string `json:"comment"`
 }
 
+// TransactionFullInfo is a history row as seen by the account that requested it: Amount is signed,
+// and for transfers Counterparty is the owner of the other wallet.
 type TransactionFullInfo struct {
-	ID             int       `json:"id" db:"id"`
-	WalletID       int       `json:"wallet_id" db:"wallet_id"`
-	Amount         float64   `json:"amount" db:"amount"`
-	TargetWalletID *int      `json:"target_wallet_id" db:"target_wallet_id"`
-	ServiceID      *int      `json:"service_id" db:"service_id"`
-	Comment        string    `json:"comment" db:"comment"`
-	Timestamp      time.Time `json:"timestamp" db:"timestamp"`
+	ID           int       `json:"id" db:"id"`
+	Kind         string    `json:"kind" db:"kind"`
+	Direction    string    `json:"direction" db:"direction"`
+	Amount       Money     `json:"amount" db:"amount"`
+	Currency     string    `json:"currency" db:"currency"`
+	Counterparty *int      `json:"counterparty,omitempty" db:"counterparty"`
+	TargetAmount *Money    `json:"target_amount,omitempty" db:"target_amount"`
+	ExchangeRate *Rate     `json:"exchange_rate,omitempty" db:"exchange_rate"`
+	ServiceID    *int      `json:"service_id" db:"service_id"`
+	ReversalOf   *int      `json:"reversal_of,omitempty" db:"reversal_of"`
+	Comment      string    `json:"comment" db:"comment"`
+	Timestamp    time.Time `json:"timestamp" db:"timestamp"`
+}
+
+// validateIdempotenceKey keeps client keys positive, the rest of the key space belongs to postings the service makes.
+func validateIdempotenceKey(key int) error {
+	if key <= 0 {
+		return fmt.Errorf("%w: must be positive", ErrInvalidIdempotenceKey)
+	}
+	return nil
+}
+
+func (t Transaction) Validate() error {
+	if err := validateIdempotenceKey(t.IdempotenceKey); err != nil {
+		return err
+	}
+	if err := t.Amount.Validate(); err != nil {
+		return err
+	}
+	return ValidateCurrency(t.Currency)
+}
+
+func (t TransferTransaction) Validate() error {
+	if err := validateIdempotenceKey(t.IdempotenceKey); err != nil {
+		return err
+	}
+	if err := t.Amount.Validate(); err != nil {
+		return err
+	}
+	if err := ValidateCurrency(t.Currency); err != nil {
+		return err
+	}
+	if t.TargetCurrency != "" {
+		return ValidateCurrency(t.TargetCurrency)
+	}
+	return nil
+}
+
+// Validate allows an empty idempotence key, captures then fall back to the order ID.
+func (t ReserveTransaction) Validate() error {
+	if t.IdempotenceKey != 0 {
+		if err := validateIdempotenceKey(t.IdempotenceKey); err != nil {
+			return err
+		}
+	}
+	if err := t.Amount.Validate(); err != nil {
+		return err
+	}
+	if t.TTL < 0 {
+		return fmt.Errorf("%w: ttl must not be negative", ErrInvalidExpiry)
+	}
+	if t.TTL > 0 && t.ExpiresAt != nil {
+		return fmt.Errorf("%w: ttl and expires_at are mutually exclusive", ErrInvalidExpiry)
+	}
+	return ValidateCurrency(t.Currency)
+}
+
+// Expiry resolves the TTL or explicit expiry time of a reservation relative to now.
+func (t ReserveTransaction) Expiry(now time.Time) (*time.Time, error) {
+	switch {
+	case t.TTL > 0:
+		expiresAt := now.Add(time.Duration(t.TTL) * time.Second)
+		return &expiresAt, nil
+	case t.ExpiresAt != nil && !t.ExpiresAt.After(now):
+		return nil, fmt.Errorf("%w: expires_at is in the past", ErrInvalidExpiry)
+	}
+	return t.ExpiresAt, nil
+}
+
+func (t RefundTransaction) Validate() error {
+	if err := validateIdempotenceKey(t.IdempotenceKey); err != nil {
+		return err
+	}
+	return t.Amount.Validate()
 }
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	SortingDate   = "date"
+	SortingAmount = "amount"
+)
+
+// The type filter accepts any transaction kind, plus transfer_in and transfer_out
+// to select one side of transfers.
+const (
+	TransactionTypeTransferIn  = "transfer_in"
+	TransactionTypeTransferOut = "transfer_out"
+)
+
+type TransactionsPage struct {
+	Transactions []TransactionFullInfo `json:"transactions"`
+	NextCursor   string                `json:"next_cursor,omitempty"`
+}
+
+// TransactionsCursor points right after the last row of a page, so that rows inserted
+// in the meantime do not shift the next page the way an offset does.
+type TransactionsCursor struct {
+	Sorting    string    `json:"sorting"`
+	Descending bool      `json:"descending"`
+	Timestamp  time.Time `json:"timestamp"`
+	Amount     Money     `json:"amount"`
+	ID         int       `json:"id"`
+}
+
+func NewTransactionsCursor(sorting string, descending bool, last TransactionFullInfo) TransactionsCursor {
+	return TransactionsCursor{
+		Sorting:    sorting,
+		Descending: descending,
+		Timestamp:  last.Timestamp,
+		Amount:     last.Amount,
+		ID:         last.ID,
+	}
+}
+
+func (c TransactionsCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeTransactionsCursor(s string) (TransactionsCursor, error) {
+	var cursor TransactionsCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err = ValidateSorting(cursor.Sorting); err != nil || cursor.ID <= 0 {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+func ValidateSorting(sorting string) error {
+	switch sorting {
+	case SortingDate, SortingAmount:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown sorting %q", ErrInvalidFilter, sorting)
+}
+
+func ValidateTransactionType(transactionType string) error {
+	switch transactionType {
+	case TransactionKindDeposit, TransactionKindWithdrawal, TransactionKindTransfer, TransactionKindReserve,
+		TransactionKindReserveRelease, TransactionKindServiceCharge, TransactionKindRefund, TransactionKindAdjustment,
+		TransactionTypeTransferIn, TransactionTypeTransferOut:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown type %q", ErrInvalidFilter, transactionType)
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	DeliveryPending   = "Pending"
+	DeliveryDelivered = "Delivered"
+	DeliveryDead      = "Dead"
+)
+
+const minWebhookSecretLength = 16
+
+// EventTypeList is stored as a jsonb array.
+type EventTypeList []string
+
+func (l EventTypeList) Value() (driver.Value, error) {
+	data, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (l *EventTypeList) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	}
+	return errors.New("unsupported event type list")
+}
+
+type Webhook struct {
+	ID         int           `json:"id" db:"id"`
+	URL        string        `json:"url" db:"url"`
+	Secret     string        `json:"secret,omitempty" db:"secret"`
+	EventTypes EventTypeList `json:"event_types" db:"event_types"`
+	Active     bool          `json:"active" db:"active"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// Validate checks a webhook before it is stored. An empty secret is allowed on update and keeps the old one.
+func (w Webhook) Validate(requireSecret bool) error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) url", ErrInvalidWebhook)
+	}
+	if (requireSecret || w.Secret != "") && len(w.Secret) < minWebhookSecretLength {
+		return fmt.Errorf("%w: secret must be at least %d characters", ErrInvalidWebhook, minWebhookSecretLength)
+	}
+	if len(w.EventTypes) == 0 {
+		return fmt.Errorf("%w: event_types is required", ErrInvalidWebhook)
+	}
+	for _, eventType := range w.EventTypes {
+		if err = ValidateEventType(eventType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type WebhookDelivery struct {
+	ID             int              `json:"id" db:"id"`
+	WebhookID      int              `json:"webhook_id" db:"webhook_id"`
+	EventID        int              `json:"event_id" db:"event_id"`
+	EventType      string           `json:"event_type" db:"event_type"`
+	AccountID      int              `json:"account_id" db:"account_id"`
+	Status         string           `json:"status" db:"status"`
+	AttemptCount   int              `json:"attempt_count" db:"attempt_count"`
+	NextAttemptAt  time.Time        `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError      *string          `json:"last_error,omitempty" db:"last_error"`
+	ResponseStatus *int             `json:"response_status,omitempty" db:"response_status"`
+	DeliveredAt    *time.Time       `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at" db:"updated_at"`
+	Attempts       []WebhookAttempt `json:"attempts,omitempty" db:"-"`
+}
+
+type WebhookAttempt struct {
+	DeliveryID     int       `json:"-" db:"delivery_id"`
+	ResponseStatus *int      `json:"response_status,omitempty" db:"response_status"`
+	Error          *string   `json:"error,omitempty" db:"error"`
+	Duration       int64     `json:"duration_ms" db:"duration_ms"`
+	AttemptedAt    time.Time `json:"attempted_at" db:"attempted_at"`
+}
+
+// WebhookDispatch is a claimed delivery together with what is needed to send it.
+type WebhookDispatch struct {
+	DeliveryID   int
+	AttemptCount int
+	URL          string
+	Secret       string
+	Event        Event
+}
+
+type WebhookDeliveriesQueryParams struct {
+	WebhookID int
+	Status    string
+	Limit     int
+	Offset    int
+}
+
+func ValidateDeliveryStatus(status string) error {
+	switch status {
+	case DeliveryPending, DeliveryDelivered, DeliveryDead:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+}
@@ -0,0 +1,54 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaPublisher keys messages by account so that all events of an account land in one partition
+// and keep their order.
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaPublisher(brokers []string, topic string) *KafkaPublisher {
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Topic:                  topic,
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			BatchTimeout:           10 * time.Millisecond,
+			AllowAutoTopicCreation: true,
+		},
+	}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, event models.Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("unable to encode event: %w", err)
+	}
+	err = p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(strconv.Itoa(event.AccountID)),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "event_type", Value: []byte(event.Type)},
+			{Key: "sequence", Value: []byte(strconv.FormatInt(event.Sequence, 10))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to publish event: %w", err)
+	}
+	return nil
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
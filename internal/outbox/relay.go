@@ -0,0 +1,83 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultBatchSize = 100
+
+type Store interface {
+	RelayEvents(ctx context.Context, limit int,
+		publish func(ctx context.Context, events []models.Event) []int) (int, error)
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, event models.Event) error
+	Close() error
+}
+
+type Relay struct {
+	log       *logrus.Logger
+	store     Store
+	publisher Publisher
+	batchSize int
+}
+
+func NewRelay(log *logrus.Logger, store Store, publisher Publisher) *Relay {
+	return &Relay{
+		log:       log,
+		store:     store,
+		publisher: publisher,
+		batchSize: defaultBatchSize,
+	}
+}
+
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Flush(ctx); err != nil {
+				r.log.Errorf("failed to relay outbox events: %v", err)
+			}
+		}
+	}
+}
+
+// Flush delivers pending events until the outbox is drained or a batch is only partly published.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		published, err := r.store.RelayEvents(ctx, r.batchSize, r.publish)
+		total += published
+		if err != nil || published < r.batchSize {
+			return total, err
+		}
+	}
+}
+
+// publish stops delivering an account's events after its first failure, so the next attempt
+// resumes from the same event and per-account order is kept.
+func (r *Relay) publish(ctx context.Context, events []models.Event) []int {
+	published := make([]int, 0, len(events))
+	failed := make(map[int]bool)
+	for _, event := range events {
+		if failed[event.AccountID] {
+			continue
+		}
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			r.log.Errorf("failed to publish event %d: %v", event.ID, err)
+			failed[event.AccountID] = true
+			continue
+		}
+		published = append(published, event.ID)
+	}
+	return published
+}
@@ -0,0 +1,53 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+// WriterPublisher writes events as JSON lines.
+type WriterPublisher struct {
+	mu      sync.Mutex
+	w       io.Writer
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{
+		w:       w,
+		encoder: json.NewEncoder(w),
+	}
+}
+
+func NewFilePublisher(path string) (*WriterPublisher, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open outbox file: %w", err)
+	}
+	publisher := NewWriterPublisher(file)
+	publisher.closer = file
+	return publisher, nil
+}
+
+func (p *WriterPublisher) Publish(_ context.Context, event models.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.encoder.Encode(event); err != nil {
+		return fmt.Errorf("unable to write event: %w", err)
+	}
+	return nil
+}
+
+func (p *WriterPublisher) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
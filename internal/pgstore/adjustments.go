@@ -0,0 +1,274 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+const adjustmentColumns = `id, account_id, currency, amount, comment, reason, attachment, status, proposed_by,
+	reviewed_by, review_comment, transaction_id, created_at, expires_at, reviewed_at`
+
+func (db *DB) CreateAdjustment(ctx context.Context, adjustment models.Adjustment) (*models.Adjustment, error) {
+	query := `
+	INSERT INTO adjustment_requests (account_id, currency, amount, comment, reason, attachment, status, proposed_by,
+	                                 created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING ` + adjustmentColumns
+	var err error
+	for i := 0; i < retries; i++ {
+		created := models.Adjustment{}
+		err = db.db.GetContext(ctx, &created, query, adjustment.AccountID, adjustment.Currency, adjustment.Amount,
+			adjustment.Comment, adjustment.Reason, adjustment.Attachment, models.AdjustmentPending,
+			adjustment.ProposedBy, adjustment.CreatedAt.UTC().Format(dateTimeLayout),
+			adjustment.ExpiresAt.UTC().Format(dateTimeLayout))
+		if err != nil {
+			err = fmt.Errorf("err executing [CreateAdjustment]: %w", err)
+			continue
+		}
+		return &created, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetAdjustment(ctx context.Context, adjustmentID int) (*models.Adjustment, error) {
+	query := `
+	SELECT ` + adjustmentColumns + `
+	FROM adjustment_requests
+	WHERE id = $1`
+	var err error
+	for i := 0; i < retries; i++ {
+		adjustment := models.Adjustment{}
+		if err = db.db.GetContext(ctx, &adjustment, query, adjustmentID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrAdjustmentNotFound
+			}
+			err = fmt.Errorf("err executing [GetAdjustment]: %w", err)
+			continue
+		}
+		return &adjustment, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetAdjustments(ctx context.Context,
+	queryParams *models.AdjustmentsQueryParams) ([]models.Adjustment, error) {
+	query := `
+	SELECT ` + adjustmentColumns + `
+	FROM adjustment_requests
+	WHERE ($1 = 0 OR account_id = $1) AND
+	      ($2 = '' OR status = $2)
+	ORDER BY id DESC
+	LIMIT $3 OFFSET $4`
+	var err error
+	for i := 0; i < retries; i++ {
+		adjustments := make([]models.Adjustment, 0)
+		err = db.db.SelectContext(ctx, &adjustments, query, queryParams.AccountID, queryParams.Status,
+			queryParams.Limit, queryParams.Offset)
+		if err != nil {
+			err = fmt.Errorf("err executing [GetAdjustments]: %w", err)
+			continue
+		}
+		return adjustments, nil
+	}
+	return nil, err
+}
+
+// ApproveAdjustment applies a pending adjustment to the wallet and marks it approved in one transaction.
+func (db *DB) ApproveAdjustment(ctx context.Context, reviewerID int, review models.AdjustmentReview,
+	now time.Time) (*models.Adjustment, error) {
+	var err error
+	var tx *sql.Tx
+	var adjustment *models.Adjustment
+	var transactionID int
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back approve adjustment transaction")
+				}
+			}()
+			adjustment, err = db.lockPendingAdjustment(ctx, tx, reviewerID, review.ID, now)
+			if err != nil {
+				return fmt.Errorf("err executing [ApproveAdjustment]: %w", err)
+			}
+			transactionID, err = db.adjustBalance(ctx, tx, adjustment.AccountID, adjustment.Transaction(reviewerID))
+			if err != nil {
+				return fmt.Errorf("err executing [ApproveAdjustment]: %w", err)
+			}
+			err = db.reviewAdjustment(ctx, tx, models.AdjustmentApproved, reviewerID, review, &transactionID, now)
+			if err != nil {
+				return fmt.Errorf("err executing [ApproveAdjustment]: %w", err)
+			}
+			err = tx.Commit()
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return db.GetAdjustment(ctx, review.ID)
+	}
+	return nil, err
+}
+
+func (db *DB) RejectAdjustment(ctx context.Context, reviewerID int, review models.AdjustmentReview,
+	now time.Time) (*models.Adjustment, error) {
+	var err error
+	var tx *sql.Tx
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back reject adjustment transaction")
+				}
+			}()
+			if _, err = db.lockPendingAdjustment(ctx, tx, reviewerID, review.ID, now); err != nil {
+				return fmt.Errorf("err executing [RejectAdjustment]: %w", err)
+			}
+			err = db.reviewAdjustment(ctx, tx, models.AdjustmentRejected, reviewerID, review, nil, now)
+			if err != nil {
+				return fmt.Errorf("err executing [RejectAdjustment]: %w", err)
+			}
+			err = tx.Commit()
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return db.GetAdjustment(ctx, review.ID)
+	}
+	return nil, err
+}
+
+func (db *DB) ExpireAdjustments(ctx context.Context, now time.Time) (int, error) {
+	query := `
+	UPDATE adjustment_requests
+	SET status = $1
+	WHERE status = $2 AND expires_at <= $3`
+	var err error
+	var result sql.Result
+	for i := 0; i < retries; i++ {
+		result, err = db.db.ExecContext(ctx, query, models.AdjustmentExpired, models.AdjustmentPending,
+			now.UTC().Format(dateTimeLayout))
+		if err != nil {
+			err = fmt.Errorf("err executing [ExpireAdjustments]: %w", err)
+			continue
+		}
+		count, _ := result.RowsAffected()
+		return int(count), nil
+	}
+	return 0, err
+}
+
+func (db *DB) lockPendingAdjustment(ctx context.Context, tx *sql.Tx, reviewerID, adjustmentID int,
+	now time.Time) (*models.Adjustment, error) {
+	query := `
+	SELECT account_id, currency, amount, comment, reason, status, proposed_by, expires_at
+	FROM adjustment_requests
+	WHERE id = $1
+	FOR UPDATE`
+	adjustment := models.Adjustment{ID: adjustmentID}
+	err := tx.QueryRowContext(ctx, query, adjustmentID).Scan(&adjustment.AccountID, &adjustment.Currency,
+		&adjustment.Amount, &adjustment.Comment, &adjustment.Reason, &adjustment.Status, &adjustment.ProposedBy,
+		&adjustment.ExpiresAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, models.ErrAdjustmentNotFound
+	case err != nil:
+		return nil, fmt.Errorf("err executing [lockPendingAdjustment]: %w", err)
+	case adjustment.Status != models.AdjustmentPending:
+		return nil, fmt.Errorf("%w: adjustment is %s", models.ErrAdjustmentNotPending, adjustment.Status)
+	case !adjustment.ExpiresAt.After(now):
+		return nil, models.ErrAdjustmentExpired
+	case adjustment.ProposedBy == reviewerID:
+		return nil, models.ErrSelfReview
+	}
+	return &adjustment, nil
+}
+
+func (db *DB) reviewAdjustment(ctx context.Context, tx *sql.Tx, status string, reviewerID int,
+	review models.AdjustmentReview, transactionID *int, now time.Time) error {
+	query := `
+	UPDATE adjustment_requests
+	SET status = $1,
+	reviewed_by = $2,
+	review_comment = $3,
+	transaction_id = $4,
+	reviewed_at = $5
+	WHERE id = $6`
+	_, err := tx.ExecContext(ctx, query, status, reviewerID, review.Comment, transactionID,
+		now.UTC().Format(dateTimeLayout), review.ID)
+	if err != nil {
+		return fmt.Errorf("err executing [reviewAdjustment]: %w", err)
+	}
+	return nil
+}
+
+// adjustBalance applies a signed correction to the wallet. Negative adjustments cannot overdraw it.
+func (db *DB) adjustBalance(ctx context.Context, tx *sql.Tx, ownerID int, transaction models.Transaction) (int, error) {
+	var err error
+	var wallet *models.Wallet
+	var walletID int
+	if transaction.Amount < 0 {
+		wallet, err = db.checkBalance(ctx, tx, ownerID, transaction.Currency, -transaction.Amount)
+		if err != nil {
+			return 0, fmt.Errorf("err executing [adjustBalance]: %w", err)
+		}
+		walletID = wallet.ID
+		err = db.withdrawMoney(ctx, tx, walletID, -transaction.Amount)
+	} else {
+		walletID, err = db.depositMoney(ctx, tx, ownerID, transaction.Currency, transaction.Amount)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("err executing [adjustBalance]: %w", err)
+	}
+	transactionID, err := db.insertTransaction(ctx, tx, transactionRecord{
+		idempotenceKey: &transaction.IdempotenceKey,
+		kind:           models.TransactionKindAdjustment,
+		walletID:       walletID,
+		amount:         transaction.Amount,
+		comment:        transaction.Comment,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("err executing [adjustBalance]: %w", err)
+	}
+	err = db.insertJournalEntry(ctx, tx, entryAdjustment, transactionReference(transactionID), &transactionID,
+		adjustments(transaction.Currency, -transaction.Amount),
+		available(ownerID, transaction.Currency, transaction.Amount))
+	if err != nil {
+		return 0, fmt.Errorf("err executing [adjustBalance]: %w", err)
+	}
+	err = db.insertEvent(ctx, tx, models.EventBalanceAdjusted, models.BalanceEvent{
+		AccountID:     ownerID,
+		TransactionID: &transactionID,
+		Currency:      transaction.Currency,
+		Amount:        transaction.Amount,
+		AdminID:       transaction.Actor.ID(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("err executing [adjustBalance]: %w", err)
+	}
+	err = db.insertAdminAction(ctx, tx, models.AdminActionAdjustment, ownerID, transactionID, transaction)
+	if err != nil {
+		return 0, fmt.Errorf("err executing [adjustBalance]: %w", err)
+	}
+	return transactionID, nil
+}
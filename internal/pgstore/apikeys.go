@@ -0,0 +1,184 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+const apiKeyColumns = `id, client_name, key_prefix, key_hash, scopes, service_ids, created_by, rotated_from,
+	created_at, expires_at, revoked_at, last_used_at`
+
+func (db *DB) CreateAPIKey(ctx context.Context, key models.APIKey) (*models.APIKey, error) {
+	query := `
+	INSERT INTO api_keys (client_name, key_prefix, key_hash, scopes, service_ids, created_by, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING ` + apiKeyColumns
+	var err error
+	for i := 0; i < retries; i++ {
+		created := models.APIKey{}
+		err = db.db.GetContext(ctx, &created, query, key.ClientName, key.Prefix, key.Hash, key.Scopes,
+			key.ServiceIDs, key.CreatedBy, key.CreatedAt.UTC().Format(dateTimeLayout), key.ExpiresAt)
+		if err != nil {
+			err = fmt.Errorf("err executing [CreateAPIKey]: %w", err)
+			continue
+		}
+		return &created, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	query := `
+	SELECT ` + apiKeyColumns + `
+	FROM api_keys
+	ORDER BY id`
+	var err error
+	for i := 0; i < retries; i++ {
+		keys := make([]models.APIKey, 0)
+		if err = db.db.SelectContext(ctx, &keys, query); err != nil {
+			err = fmt.Errorf("err executing [GetAPIKeys]: %w", err)
+			continue
+		}
+		return keys, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetAPIKey(ctx context.Context, keyID int) (*models.APIKey, error) {
+	query := `
+	SELECT ` + apiKeyColumns + `
+	FROM api_keys
+	WHERE id = $1`
+	var err error
+	for i := 0; i < retries; i++ {
+		key := models.APIKey{}
+		if err = db.db.GetContext(ctx, &key, query, keyID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrAPIKeyNotFound
+			}
+			err = fmt.Errorf("err executing [GetAPIKey]: %w", err)
+			continue
+		}
+		return &key, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	query := `
+	SELECT ` + apiKeyColumns + `
+	FROM api_keys
+	WHERE key_prefix = $1`
+	var err error
+	for i := 0; i < retries; i++ {
+		key := models.APIKey{}
+		if err = db.db.GetContext(ctx, &key, query, prefix); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrAPIKeyNotFound
+			}
+			err = fmt.Errorf("err executing [GetAPIKeyByPrefix]: %w", err)
+			continue
+		}
+		return &key, nil
+	}
+	return nil, err
+}
+
+// RevokeAPIKey never moves an earlier revocation, e.g. a rotation grace period, to a later time.
+func (db *DB) RevokeAPIKey(ctx context.Context, keyID int, at time.Time) (*models.APIKey, error) {
+	query := `
+	UPDATE api_keys
+	SET revoked_at = LEAST(COALESCE(revoked_at, $2), $2)
+	WHERE id = $1
+	RETURNING ` + apiKeyColumns
+	var err error
+	for i := 0; i < retries; i++ {
+		revoked := models.APIKey{}
+		if err = db.db.GetContext(ctx, &revoked, query, keyID, at.UTC().Format(dateTimeLayout)); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrAPIKeyNotFound
+			}
+			err = fmt.Errorf("err executing [RevokeAPIKey]: %w", err)
+			continue
+		}
+		return &revoked, nil
+	}
+	return nil, err
+}
+
+// RotateAPIKey stores the replacement of an active key and schedules the revocation of the old one.
+func (db *DB) RotateAPIKey(ctx context.Context, key models.APIKey, revokeAt time.Time) (*models.APIKey, error) {
+	revokeQuery := `
+	UPDATE api_keys
+	SET revoked_at = $2
+	WHERE id = $1 AND (revoked_at IS NULL OR revoked_at > $3) AND (expires_at IS NULL OR expires_at > $3)`
+	insertQuery := `
+	INSERT INTO api_keys (client_name, key_prefix, key_hash, scopes, service_ids, created_by, rotated_from, created_at,
+	                      expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	RETURNING id`
+	var err error
+	var tx *sql.Tx
+	var result sql.Result
+	var keyID int
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back rotate api key transaction")
+				}
+			}()
+			now := key.CreatedAt.UTC().Format(dateTimeLayout)
+			result, err = tx.ExecContext(ctx, revokeQuery, *key.RotatedFrom, revokeAt.UTC().Format(dateTimeLayout), now)
+			if err != nil {
+				return fmt.Errorf("err executing [RotateAPIKey]: %w", err)
+			}
+			if count, _ := result.RowsAffected(); count == 0 {
+				return fmt.Errorf("%w: key is revoked or expired", models.ErrAPIKeyNotFound)
+			}
+			err = tx.QueryRowContext(ctx, insertQuery, key.ClientName, key.Prefix, key.Hash, key.Scopes,
+				key.ServiceIDs, key.CreatedBy, key.RotatedFrom, now, key.ExpiresAt).Scan(&keyID)
+			if err != nil {
+				return fmt.Errorf("err executing [RotateAPIKey]: %w", err)
+			}
+			err = tx.Commit()
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			return nil, err
+		}
+		if err != nil {
+			continue
+		}
+		return db.GetAPIKey(ctx, keyID)
+	}
+	return nil, err
+}
+
+func (db *DB) TouchAPIKey(ctx context.Context, keyID int, now time.Time) error {
+	query := `
+	UPDATE api_keys
+	SET last_used_at = $2
+	WHERE id = $1`
+	var err error
+	for i := 0; i < retries; i++ {
+		if _, err = db.db.ExecContext(ctx, query, keyID, now.UTC().Format(dateTimeLayout)); err != nil {
+			err = fmt.Errorf("err executing [TouchAPIKey]: %w", err)
+			continue
+		}
+		return nil
+	}
+	return err
+}
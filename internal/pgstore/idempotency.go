@@ -0,0 +1,129 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+func (db *DB) InsertIdempotencyKey(ctx context.Context,
+	record models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	deleteQuery := `
+	DELETE FROM idempotency_key
+	WHERE account_id = $1 AND api_key_id = $2 AND key = $3 AND endpoint = $4 AND
+	      (expires_at < $5 OR (status_code IS NULL AND locked_until < $5))`
+	insertQuery := `
+	INSERT INTO idempotency_key (account_id, api_key_id, key, endpoint, fingerprint, created_at, locked_until,
+	                             expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (account_id, api_key_id, key, endpoint) DO NOTHING`
+	selectQuery := `
+	SELECT account_id, api_key_id, key, endpoint, fingerprint, status_code, content_type, response_body, expires_at
+	FROM idempotency_key
+	WHERE account_id = $1 AND api_key_id = $2 AND key = $3 AND endpoint = $4`
+	var err error
+	var tx *sqlx.Tx
+	var existing *models.IdempotencyRecord
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTxx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back idempotency transaction")
+				}
+			}()
+			now := time.Now().UTC()
+			_, err = tx.ExecContext(ctx, deleteQuery, record.AccountID, record.APIKeyID, record.Key, record.Endpoint, now)
+			if err != nil {
+				return fmt.Errorf("err executing [InsertIdempotencyKey]: %w", err)
+			}
+			var result sql.Result
+			result, err = tx.ExecContext(ctx, insertQuery, record.AccountID, record.APIKeyID, record.Key, record.Endpoint,
+				record.Fingerprint, now, record.LockedUntil, record.ExpiresAt)
+			if err != nil {
+				return fmt.Errorf("err executing [InsertIdempotencyKey]: %w", err)
+			}
+			existing = nil
+			if count, _ := result.RowsAffected(); count == 0 {
+				existing = &models.IdempotencyRecord{}
+				err = tx.GetContext(ctx, existing, selectQuery, record.AccountID, record.APIKeyID, record.Key,
+					record.Endpoint)
+				if err != nil {
+					return fmt.Errorf("err executing [InsertIdempotencyKey]: %w", err)
+				}
+			}
+			err = tx.Commit()
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return existing, nil
+	}
+	return nil, err
+}
+
+func (db *DB) CompleteIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) error {
+	query := `
+	UPDATE idempotency_key
+	SET status_code = $1,
+	content_type = $2,
+	response_body = $3
+	WHERE account_id = $4 AND api_key_id = $5 AND key = $6 AND endpoint = $7`
+	var err error
+	for i := 0; i < retries; i++ {
+		_, err = db.db.ExecContext(ctx, query, record.StatusCode, record.ContentType, record.Body, record.AccountID,
+			record.APIKeyID, record.Key, record.Endpoint)
+		if err != nil {
+			err = fmt.Errorf("err executing [CompleteIdempotencyKey]: %w", err)
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) DeleteIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) error {
+	query := `
+	DELETE FROM idempotency_key
+	WHERE account_id = $1 AND api_key_id = $2 AND key = $3 AND endpoint = $4`
+	var err error
+	for i := 0; i < retries; i++ {
+		_, err = db.db.ExecContext(ctx, query, record.AccountID, record.APIKeyID, record.Key, record.Endpoint)
+		if err != nil {
+			err = fmt.Errorf("err executing [DeleteIdempotencyKey]: %w", err)
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int, error) {
+	query := `
+	DELETE FROM idempotency_key
+	WHERE expires_at < $1`
+	var err error
+	var result sql.Result
+	for i := 0; i < retries; i++ {
+		result, err = db.db.ExecContext(ctx, query, now)
+		if err != nil {
+			err = fmt.Errorf("err executing [PurgeIdempotencyKeys]: %w", err)
+			continue
+		}
+		count, _ := result.RowsAffected()
+		return int(count), nil
+	}
+	return 0, err
+}
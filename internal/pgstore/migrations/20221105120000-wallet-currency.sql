@@ -0,0 +1,28 @@
+-- +migrate Up
+ALTER TABLE reserved_funds DROP CONSTRAINT reserved_funds_owner_id_fkey;
+ALTER TABLE wallet DROP CONSTRAINT wallet_owner_id_key;
+
+ALTER TABLE wallet ADD COLUMN currency text DEFAULT 'RUB' NOT NULL;
+ALTER TABLE wallet ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE wallet ADD CONSTRAINT wallet_owner_id_currency_key UNIQUE (owner_id, currency);
+
+ALTER TABLE reserved_funds ADD COLUMN currency text DEFAULT 'RUB' NOT NULL;
+ALTER TABLE reserved_funds ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE reserved_funds ADD CONSTRAINT reserved_funds_owner_id_currency_fkey
+    FOREIGN KEY (owner_id, currency) REFERENCES wallet (owner_id, currency);
+
+
+-- +migrate Down
+DELETE FROM reserved_funds WHERE currency <> 'RUB';
+DELETE FROM transaction
+WHERE wallet_id IN (SELECT id FROM wallet WHERE currency <> 'RUB')
+   OR target_wallet_id IN (SELECT id FROM wallet WHERE currency <> 'RUB');
+DELETE FROM wallet WHERE currency <> 'RUB';
+
+ALTER TABLE reserved_funds DROP CONSTRAINT reserved_funds_owner_id_currency_fkey;
+ALTER TABLE reserved_funds DROP COLUMN currency;
+ALTER TABLE wallet DROP CONSTRAINT wallet_owner_id_currency_key;
+ALTER TABLE wallet DROP COLUMN currency;
+ALTER TABLE wallet ADD CONSTRAINT wallet_owner_id_key UNIQUE (owner_id);
+ALTER TABLE reserved_funds ADD CONSTRAINT reserved_funds_owner_id_fkey
+    FOREIGN KEY (owner_id) REFERENCES wallet (owner_id);
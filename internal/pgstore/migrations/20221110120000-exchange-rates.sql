@@ -0,0 +1,24 @@
+-- +migrate Up
+ALTER TABLE transaction ADD COLUMN target_amount numeric(11,2);
+ALTER TABLE transaction ADD COLUMN exchange_rate numeric(20,8);
+ALTER TABLE transaction ADD COLUMN quote_id text;
+
+CREATE TABLE exchange_quote
+(
+    id            text PRIMARY KEY                       NOT NULL,
+    owner_id      int                                    NOT NULL,
+    from_currency text                                   NOT NULL,
+    to_currency   text                                   NOT NULL,
+    rate          numeric(20,8)                          NOT NULL,
+    expires_at    timestamp with time zone               NOT NULL,
+    used_at       timestamp with time zone,
+    created_at    timestamp with time zone DEFAULT NOW() NOT NULL
+);
+
+
+-- +migrate Down
+DROP TABLE exchange_quote;
+
+ALTER TABLE transaction DROP COLUMN quote_id;
+ALTER TABLE transaction DROP COLUMN exchange_rate;
+ALTER TABLE transaction DROP COLUMN target_amount;
@@ -0,0 +1,85 @@
+-- +migrate Up
+CREATE TABLE ledger_account
+(
+    id       bigserial PRIMARY KEY NOT NULL,
+    type     text                  NOT NULL,
+    owner_id int                   NOT NULL,
+    currency text                  NOT NULL,
+    UNIQUE (type, owner_id, currency)
+);
+
+CREATE TABLE journal_entry
+(
+    id             bigserial PRIMARY KEY                  NOT NULL,
+    kind           text                                   NOT NULL,
+    reference      text                                   NOT NULL,
+    transaction_id bigint REFERENCES transaction (id),
+    created_at     timestamp with time zone DEFAULT NOW() NOT NULL
+);
+
+CREATE TABLE posting
+(
+    id         bigserial PRIMARY KEY                      NOT NULL,
+    entry_id   bigint REFERENCES journal_entry (id)       NOT NULL,
+    account_id bigint REFERENCES ledger_account (id)      NOT NULL,
+    amount     numeric(11,2)                              NOT NULL
+);
+
+CREATE INDEX posting_entry_id_idx ON posting (entry_id);
+CREATE INDEX posting_account_id_idx ON posting (account_id);
+
+INSERT INTO ledger_account (type, owner_id, currency)
+SELECT 'user_available', owner_id, currency FROM wallet
+UNION ALL
+SELECT 'user_reserved', owner_id, currency FROM wallet
+UNION ALL
+SELECT DISTINCT 'external_cash', 0, currency FROM wallet;
+
+INSERT INTO journal_entry (kind, reference)
+SELECT 'opening_balance', 'wallet:' || id FROM wallet;
+
+INSERT INTO posting (entry_id, account_id, amount)
+SELECT je.id, la.id, w.balance
+FROM wallet w
+INNER JOIN journal_entry je ON je.kind = 'opening_balance' AND je.reference = 'wallet:' || w.id
+INNER JOIN ledger_account la ON la.type = 'user_available' AND la.owner_id = w.owner_id AND la.currency = w.currency
+UNION ALL
+SELECT je.id, la.id, w.reserved_balance
+FROM wallet w
+INNER JOIN journal_entry je ON je.kind = 'opening_balance' AND je.reference = 'wallet:' || w.id
+INNER JOIN ledger_account la ON la.type = 'user_reserved' AND la.owner_id = w.owner_id AND la.currency = w.currency
+UNION ALL
+SELECT je.id, la.id, -(w.balance + w.reserved_balance)
+FROM wallet w
+INNER JOIN journal_entry je ON je.kind = 'opening_balance' AND je.reference = 'wallet:' || w.id
+INNER JOIN ledger_account la ON la.type = 'external_cash' AND la.owner_id = 0 AND la.currency = w.currency;
+
+-- +migrate StatementBegin
+CREATE FUNCTION check_journal_entry_balanced() RETURNS trigger AS
+$$
+BEGIN
+    IF EXISTS (SELECT 1
+               FROM posting p
+               INNER JOIN ledger_account a ON a.id = p.account_id
+               WHERE p.entry_id = NEW.entry_id
+               GROUP BY a.currency
+               HAVING SUM(p.amount) <> 0) THEN
+        RAISE EXCEPTION 'journal entry % is not balanced', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +migrate StatementEnd
+
+CREATE CONSTRAINT TRIGGER posting_balanced
+    AFTER INSERT ON posting
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_journal_entry_balanced();
+
+
+-- +migrate Down
+DROP TRIGGER posting_balanced ON posting;
+DROP FUNCTION check_journal_entry_balanced();
+DROP TABLE posting;
+DROP TABLE journal_entry;
+DROP TABLE ledger_account;
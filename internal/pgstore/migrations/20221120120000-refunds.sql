@@ -0,0 +1,22 @@
+-- +migrate Up
+ALTER TABLE transaction ADD COLUMN reversal_of bigint REFERENCES transaction (id);
+
+CREATE TABLE refund
+(
+    id             bigserial PRIMARY KEY                  NOT NULL,
+    order_id       int REFERENCES reserved_funds (order_id) NOT NULL,
+    owner_id       int                                    NOT NULL,
+    service_id     int REFERENCES services (id)           NOT NULL,
+    currency       text                                   NOT NULL,
+    amount         numeric(11,2)                          NOT NULL,
+    transaction_id bigint REFERENCES transaction (id)     NOT NULL,
+    created_at     timestamp with time zone DEFAULT NOW() NOT NULL
+);
+
+CREATE INDEX refund_order_id_idx ON refund (order_id);
+
+
+-- +migrate Down
+DROP TABLE refund;
+
+ALTER TABLE transaction DROP COLUMN reversal_of;
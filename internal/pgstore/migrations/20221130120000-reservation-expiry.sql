@@ -0,0 +1,20 @@
+-- +migrate Up
+ALTER TABLE reserved_funds ADD COLUMN expires_at timestamp with time zone;
+
+CREATE INDEX reserved_funds_expires_at_idx ON reserved_funds (expires_at) WHERE status = 'Active';
+
+ALTER TABLE transaction ALTER COLUMN idempotence_key DROP NOT NULL;
+
+
+-- +migrate Down
+UPDATE journal_entry
+SET transaction_id = NULL
+WHERE transaction_id IN (SELECT id FROM transaction WHERE idempotence_key IS NULL);
+
+DELETE FROM transaction WHERE idempotence_key IS NULL;
+
+ALTER TABLE transaction ALTER COLUMN idempotence_key SET NOT NULL;
+
+DROP INDEX reserved_funds_expires_at_idx;
+
+ALTER TABLE reserved_funds DROP COLUMN expires_at;
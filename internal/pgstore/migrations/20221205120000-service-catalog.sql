@@ -0,0 +1,31 @@
+-- +migrate Up
+ALTER TABLE services ADD COLUMN description text DEFAULT '' NOT NULL;
+ALTER TABLE services ADD COLUMN active boolean DEFAULT true NOT NULL;
+ALTER TABLE services ADD COLUMN default_price numeric(11,2);
+ALTER TABLE services ADD COLUMN currency text;
+ALTER TABLE services ADD COLUMN created_at timestamp with time zone DEFAULT NOW() NOT NULL;
+ALTER TABLE services ADD COLUMN updated_at timestamp with time zone DEFAULT NOW() NOT NULL;
+ALTER TABLE services ADD CONSTRAINT services_default_price_check
+    CHECK ((default_price IS NULL) = (currency IS NULL));
+
+ALTER TABLE capture ADD COLUMN service_title text;
+UPDATE capture c SET service_title = s.title FROM services s WHERE s.id = c.service_id;
+ALTER TABLE capture ALTER COLUMN service_title SET NOT NULL;
+
+ALTER TABLE refund ADD COLUMN service_title text;
+UPDATE refund r SET service_title = s.title FROM services s WHERE s.id = r.service_id;
+ALTER TABLE refund ALTER COLUMN service_title SET NOT NULL;
+
+
+-- +migrate Down
+ALTER TABLE refund DROP COLUMN service_title;
+
+ALTER TABLE capture DROP COLUMN service_title;
+
+ALTER TABLE services DROP CONSTRAINT services_default_price_check;
+ALTER TABLE services DROP COLUMN updated_at;
+ALTER TABLE services DROP COLUMN created_at;
+ALTER TABLE services DROP COLUMN currency;
+ALTER TABLE services DROP COLUMN default_price;
+ALTER TABLE services DROP COLUMN active;
+ALTER TABLE services DROP COLUMN description;
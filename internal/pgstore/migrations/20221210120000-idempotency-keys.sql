@@ -0,0 +1,20 @@
+-- +migrate Up
+CREATE TABLE idempotency_key
+(
+    account_id    int                                    NOT NULL,
+    key           text                                   NOT NULL,
+    endpoint      text                                   NOT NULL,
+    fingerprint   text                                   NOT NULL,
+    status_code   int,
+    content_type  text DEFAULT ''                        NOT NULL,
+    response_body bytea,
+    created_at    timestamp with time zone DEFAULT NOW() NOT NULL,
+    expires_at    timestamp with time zone               NOT NULL,
+    PRIMARY KEY (account_id, key, endpoint)
+);
+
+CREATE INDEX idempotency_key_expires_at_idx ON idempotency_key (expires_at);
+
+
+-- +migrate Down
+DROP TABLE idempotency_key;
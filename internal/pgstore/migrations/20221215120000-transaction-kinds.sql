@@ -0,0 +1,27 @@
+-- +migrate Up
+ALTER TABLE transaction ADD COLUMN kind text;
+
+UPDATE transaction
+SET kind = CASE WHEN target_wallet_id IS NOT NULL THEN 'transfer'
+                WHEN reversal_of IS NOT NULL THEN 'refund'
+                WHEN service_id IS NOT NULL AND idempotence_key IS NULL THEN 'reserve_release'
+                WHEN service_id IS NOT NULL THEN 'service_charge'
+                WHEN amount < 0 THEN 'withdrawal'
+                ELSE 'deposit' END;
+
+-- amounts become signed from the point of view of the wallet the row belongs to
+UPDATE transaction
+SET amount = -amount
+WHERE kind IN ('transfer', 'service_charge');
+
+ALTER TABLE transaction ALTER COLUMN kind SET NOT NULL;
+ALTER TABLE transaction ADD CONSTRAINT transaction_kind_check
+    CHECK (kind IN ('deposit', 'withdrawal', 'transfer', 'reserve', 'reserve_release', 'service_charge', 'refund',
+                    'adjustment'));
+
+-- +migrate Down
+UPDATE transaction
+SET amount = -amount
+WHERE kind IN ('transfer', 'service_charge');
+
+ALTER TABLE transaction DROP COLUMN kind;
@@ -0,0 +1,24 @@
+-- +migrate Up
+CREATE TABLE outbox_sequence
+(
+    account_id    int    PRIMARY KEY NOT NULL,
+    last_sequence bigint             NOT NULL
+);
+
+CREATE TABLE outbox
+(
+    id           bigserial PRIMARY KEY                  NOT NULL,
+    account_id   int                                    NOT NULL,
+    sequence     bigint                                 NOT NULL,
+    event_type   text                                   NOT NULL,
+    payload      jsonb                                  NOT NULL,
+    created_at   timestamp with time zone DEFAULT NOW() NOT NULL,
+    published_at timestamp with time zone,
+    UNIQUE (account_id, sequence)
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (id) WHERE published_at IS NULL;
+
+-- +migrate Down
+DROP TABLE outbox;
+DROP TABLE outbox_sequence;
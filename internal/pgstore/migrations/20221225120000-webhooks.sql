@@ -0,0 +1,47 @@
+-- +migrate Up
+CREATE TABLE webhooks
+(
+    id          serial PRIMARY KEY                     NOT NULL,
+    url         text                                   NOT NULL,
+    secret      text                                   NOT NULL,
+    event_types jsonb                                  NOT NULL,
+    active      boolean                  DEFAULT TRUE  NOT NULL,
+    created_at  timestamp with time zone DEFAULT NOW() NOT NULL,
+    updated_at  timestamp with time zone DEFAULT NOW() NOT NULL
+);
+
+CREATE TABLE webhook_deliveries
+(
+    id              bigserial PRIMARY KEY                                   NOT NULL,
+    webhook_id      int REFERENCES webhooks (id) ON DELETE CASCADE          NOT NULL,
+    event_id        bigint REFERENCES outbox (id)                           NOT NULL,
+    status          text                                                    NOT NULL,
+    attempt_count   int                      DEFAULT 0                      NOT NULL,
+    next_attempt_at timestamp with time zone                                NOT NULL,
+    last_error      text,
+    response_status int,
+    delivered_at    timestamp with time zone,
+    created_at      timestamp with time zone DEFAULT NOW()                  NOT NULL,
+    updated_at      timestamp with time zone DEFAULT NOW()                  NOT NULL,
+    CHECK (status IN ('Pending', 'Delivered', 'Dead'))
+);
+
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'Pending';
+CREATE INDEX webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, id);
+
+CREATE TABLE webhook_attempts
+(
+    id              bigserial PRIMARY KEY                                         NOT NULL,
+    delivery_id     bigint REFERENCES webhook_deliveries (id) ON DELETE CASCADE   NOT NULL,
+    response_status int,
+    error           text,
+    duration_ms     bigint                                                        NOT NULL,
+    attempted_at    timestamp with time zone                                      NOT NULL
+);
+
+CREATE INDEX webhook_attempts_delivery_idx ON webhook_attempts (delivery_id, id);
+
+-- +migrate Down
+DROP TABLE webhook_attempts;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
@@ -0,0 +1,20 @@
+-- +migrate Up
+CREATE TABLE admin_actions
+(
+    id             bigserial PRIMARY KEY                  NOT NULL,
+    admin_id       int                                    NOT NULL,
+    account_id     int                                    NOT NULL,
+    action         text                                   NOT NULL,
+    transaction_id bigint REFERENCES transaction (id),
+    currency       text                                   NOT NULL,
+    amount         numeric(11,2)                          NOT NULL,
+    reason         text                                   NOT NULL,
+    created_at     timestamp with time zone DEFAULT NOW() NOT NULL,
+    CHECK (action IN ('deposit', 'withdrawal', 'adjustment'))
+);
+
+CREATE INDEX admin_actions_account_idx ON admin_actions (account_id, id);
+CREATE INDEX admin_actions_admin_idx ON admin_actions (admin_id, id);
+
+-- +migrate Down
+DROP TABLE admin_actions;
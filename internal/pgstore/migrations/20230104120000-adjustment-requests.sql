@@ -0,0 +1,27 @@
+-- +migrate Up
+CREATE TABLE adjustment_requests
+(
+    id             bigserial PRIMARY KEY                  NOT NULL,
+    account_id     int                                    NOT NULL,
+    currency       text                                   NOT NULL,
+    amount         numeric(11,2)                          NOT NULL,
+    comment        text                                   NOT NULL,
+    reason         text                                   NOT NULL,
+    attachment     text                                   NOT NULL,
+    status         text                                   NOT NULL,
+    proposed_by    int                                    NOT NULL,
+    reviewed_by    int,
+    review_comment text    DEFAULT ''                     NOT NULL,
+    transaction_id bigint REFERENCES transaction (id),
+    created_at     timestamp with time zone DEFAULT NOW() NOT NULL,
+    expires_at     timestamp with time zone               NOT NULL,
+    reviewed_at    timestamp with time zone,
+    CHECK (status IN ('pending', 'approved', 'rejected', 'expired')),
+    CHECK (reviewed_by IS NULL OR reviewed_by <> proposed_by)
+);
+
+CREATE INDEX adjustment_requests_pending_idx ON adjustment_requests (expires_at) WHERE status = 'pending';
+CREATE INDEX adjustment_requests_account_idx ON adjustment_requests (account_id, id);
+
+-- +migrate Down
+DROP TABLE adjustment_requests;
@@ -0,0 +1,41 @@
+-- +migrate Up
+CREATE TABLE audit_log
+(
+    id              bigserial PRIMARY KEY    NOT NULL,
+    account_id      int                      NOT NULL,
+    role            text                     NOT NULL,
+    subject_id      int                      NOT NULL,
+    method          text                     NOT NULL,
+    endpoint        text                     NOT NULL,
+    payload         text                     NOT NULL,
+    status_code     int                      NOT NULL,
+    outcome         text                     NOT NULL,
+    balances_before jsonb,
+    balances_after  jsonb,
+    created_at      timestamp with time zone NOT NULL,
+    prev_hash       text                     NOT NULL,
+    hash            text                     NOT NULL
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+
+-- +migrate StatementBegin
+CREATE FUNCTION reject_audit_log_change() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +migrate StatementEnd
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION reject_audit_log_change();
+
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_log_change();
+
+-- +migrate Down
+DROP TABLE audit_log;
+DROP FUNCTION reject_audit_log_change();
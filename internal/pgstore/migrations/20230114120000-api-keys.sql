@@ -0,0 +1,19 @@
+-- +migrate Up
+CREATE TABLE api_keys
+(
+    id           serial PRIMARY KEY                     NOT NULL,
+    client_name  text                                   NOT NULL,
+    key_prefix   text UNIQUE                            NOT NULL,
+    key_hash     text                                   NOT NULL,
+    scopes       jsonb                                  NOT NULL,
+    service_ids  jsonb   DEFAULT '[]'                   NOT NULL,
+    created_by   int                                    NOT NULL,
+    rotated_from int REFERENCES api_keys (id),
+    created_at   timestamp with time zone DEFAULT NOW() NOT NULL,
+    expires_at   timestamp with time zone,
+    revoked_at   timestamp with time zone,
+    last_used_at timestamp with time zone
+);
+
+-- +migrate Down
+DROP TABLE api_keys;
@@ -0,0 +1,8 @@
+-- +migrate Up
+ALTER TABLE outbox ADD COLUMN claimed_until timestamp with time zone;
+
+CREATE INDEX outbox_pending_account_idx ON outbox (account_id) WHERE published_at IS NULL;
+
+-- +migrate Down
+DROP INDEX outbox_pending_account_idx;
+ALTER TABLE outbox DROP COLUMN claimed_until;
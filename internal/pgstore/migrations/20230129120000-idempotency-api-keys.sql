@@ -0,0 +1,10 @@
+-- +migrate Up
+ALTER TABLE idempotency_key ADD COLUMN api_key_id int DEFAULT 0 NOT NULL;
+ALTER TABLE idempotency_key DROP CONSTRAINT idempotency_key_pkey;
+ALTER TABLE idempotency_key ADD PRIMARY KEY (account_id, api_key_id, key, endpoint);
+
+-- +migrate Down
+DELETE FROM idempotency_key WHERE api_key_id <> 0;
+ALTER TABLE idempotency_key DROP CONSTRAINT idempotency_key_pkey;
+ALTER TABLE idempotency_key ADD PRIMARY KEY (account_id, key, endpoint);
+ALTER TABLE idempotency_key DROP COLUMN api_key_id;
@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE idempotency_key ADD COLUMN locked_until timestamp with time zone DEFAULT NOW() NOT NULL;
+
+-- +migrate Down
+ALTER TABLE idempotency_key DROP COLUMN locked_until;
@@ -0,0 +1,169 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// outboxLockID keys the advisory lock that lets a single relay claim events at a time.
+const outboxLockID = 7243001
+
+// outboxClaimTTL bounds how long a relay may publish a claimed batch before another relay takes it over.
+const outboxClaimTTL = time.Minute
+
+func (db *DB) insertEvent(ctx context.Context, tx *sql.Tx, eventType string, event models.BalanceEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("err executing [insertEvent]: %w", err)
+	}
+	query := `
+	INSERT INTO outbox_sequence (account_id, last_sequence)
+	VALUES ($1, 1)
+	ON CONFLICT (account_id) DO UPDATE SET last_sequence = outbox_sequence.last_sequence + 1
+	RETURNING last_sequence`
+	var sequence int64
+	if err = tx.QueryRowContext(ctx, query, event.AccountID).Scan(&sequence); err != nil {
+		return fmt.Errorf("err executing [insertEvent]: %w", err)
+	}
+	query = `
+	INSERT INTO outbox (account_id, sequence, event_type, payload, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id`
+	var eventID int
+	err = tx.QueryRowContext(ctx, query, event.AccountID, sequence, eventType, string(payload),
+		time.Now().UTC().Format(dateTimeLayout)).Scan(&eventID)
+	if err != nil {
+		return fmt.Errorf("err executing [insertEvent]: %w", err)
+	}
+	if err = db.insertWebhookDeliveries(ctx, tx, eventID, eventType); err != nil {
+		return fmt.Errorf("err executing [insertEvent]: %w", err)
+	}
+	if err = db.notifyEvent(ctx, tx, event.AccountID); err != nil {
+		return fmt.Errorf("err executing [insertEvent]: %w", err)
+	}
+	return nil
+}
+
+// RelayEvents hands up to limit pending events to publish in outbox order and marks the ones
+// it reports as delivered. The batch is claimed and committed before publishing, so a slow broker
+// holds neither a transaction nor row locks. Events stay pending if the process dies before they
+// are marked and are claimed again once the claim expires, which makes delivery at-least-once.
+func (db *DB) RelayEvents(ctx context.Context, limit int,
+	publish func(ctx context.Context, events []models.Event) []int) (int, error) {
+	now := time.Now().UTC()
+	events, err := db.claimEvents(ctx, limit, now)
+	if err != nil {
+		return 0, fmt.Errorf("err executing [RelayEvents]: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+	publishCtx, cancel := context.WithDeadline(ctx, now.Add(outboxClaimTTL))
+	published := publish(publishCtx, events)
+	cancel()
+	claimed := make([]int, 0, len(events))
+	for _, event := range events {
+		claimed = append(claimed, event.ID)
+	}
+	if err = db.releaseEvents(ctx, claimed, published); err != nil {
+		return 0, fmt.Errorf("err executing [RelayEvents]: %w", err)
+	}
+	return len(published), nil
+}
+
+// claimEvents claims the next batch. Accounts with events under a live claim are skipped, otherwise two relays
+// could deliver events of one account out of order, so a crashed relay holds back only its own accounts until
+// the claim expires. The advisory lock keeps relays from claiming at the same moment.
+func (db *DB) claimEvents(ctx context.Context, limit int, now time.Time) ([]models.Event, error) {
+	lockQuery := `SELECT pg_try_advisory_xact_lock($1)`
+	claimQuery := `
+	UPDATE outbox
+	SET claimed_until = $1
+	WHERE id IN (SELECT o.id
+	             FROM outbox o
+	             WHERE o.published_at IS NULL AND (o.claimed_until IS NULL OR o.claimed_until <= $2) AND
+	                   NOT EXISTS (SELECT 1
+	                               FROM outbox c
+	                               WHERE c.account_id = o.account_id AND c.published_at IS NULL AND
+	                                     c.claimed_until > $2)
+	             ORDER BY o.id
+	             LIMIT $3
+	             FOR UPDATE SKIP LOCKED)
+	RETURNING id, account_id, sequence, event_type, payload, created_at`
+	var tx *sqlx.Tx
+	var err error
+	tx, err = db.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("err executing [claimEvents]: %w", err)
+	}
+	defer func() {
+		if err = tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			db.log.Error("err rolling back claim events transaction")
+		}
+	}()
+	var locked bool
+	if err = tx.QueryRowContext(ctx, lockQuery, outboxLockID).Scan(&locked); err != nil {
+		return nil, fmt.Errorf("err executing [claimEvents]: %w", err)
+	}
+	if !locked {
+		return nil, nil
+	}
+	events := make([]models.Event, 0)
+	err = tx.SelectContext(ctx, &events, claimQuery, now.Add(outboxClaimTTL).Format(dateTimeLayout),
+		now.Format(dateTimeLayout), limit)
+	if err != nil {
+		return nil, fmt.Errorf("err executing [claimEvents]: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("err committing the transaction: %w", err)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+// releaseEvents marks the published events and drops the claim on the rest, so the next relay retries them.
+func (db *DB) releaseEvents(ctx context.Context, claimed, published []int) error {
+	query := `
+	UPDATE outbox
+	SET published_at = CASE WHEN id = ANY($2) THEN $1::timestamptz END,
+	    claimed_until = NULL
+	WHERE id = ANY($3)`
+	_, err := db.db.ExecContext(ctx, query, time.Now().UTC().Format(dateTimeLayout), published, claimed)
+	if err != nil {
+		return fmt.Errorf("err executing [releaseEvents]: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) insertTransferEvents(ctx context.Context, tx *sql.Tx, accountID, transactionID int,
+	transaction models.TransferTransaction, conversion models.Conversion) error {
+	err := db.insertEvent(ctx, tx, models.EventTransferCompleted, models.BalanceEvent{
+		AccountID:      accountID,
+		TransactionID:  &transactionID,
+		Counterparty:   transaction.Target,
+		Currency:       transaction.Currency,
+		Amount:         transaction.Amount,
+		TargetCurrency: conversion.TargetCurrency,
+		TargetAmount:   &conversion.TargetAmount,
+	})
+	if err != nil {
+		return err
+	}
+	return db.insertEvent(ctx, tx, models.EventTransferReceived, models.BalanceEvent{
+		AccountID:      transaction.Target,
+		TransactionID:  &transactionID,
+		Counterparty:   accountID,
+		Currency:       transaction.Currency,
+		Amount:         transaction.Amount,
+		TargetCurrency: conversion.TargetCurrency,
+		TargetAmount:   &conversion.TargetAmount,
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/DANDA322/balance-service/internal/models"
@@ -75,15 +76,15 @@ func (db *DB) Migrate(direction migrate.MigrationDirection) error {
 	return err
 }
 
-func (db *DB) GetWallet(ctx context.Context, accountID int) (*models.Wallet, error) {
+func (db *DB) GetWallet(ctx context.Context, accountID int, currency string) (*models.Wallet, error) {
 	query := `
-	SELECT id, balance, reserved_balance, created_at, updated_at
+	SELECT id, owner_id, currency, balance, reserved_balance, created_at, updated_at
 	FROM wallet
-	WHERE owner_id = $1`
+	WHERE owner_id = $1 AND currency = $2`
 	var wallet models.Wallet
 	var err error
 	for i := 0; i < retries; i++ {
-		if err = db.db.GetContext(ctx, &wallet, query, accountID); err != nil {
+		if err = db.db.GetContext(ctx, &wallet, query, accountID, currency); err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				return nil, models.ErrWalletNotFound
 			}
@@ -98,6 +99,7 @@ func (db *DB) UpsertDepositToWallet(ctx context.Context, ownerID int, transactio
 	var err error
 	var tx *sql.Tx
 	var wallet *models.Wallet
+	var transactionID int
 	for i := 0; i < retries; i++ {
 		err = func() error {
 			tx, err = db.db.BeginTx(ctx, nil)
@@ -110,21 +112,50 @@ func (db *DB) UpsertDepositToWallet(ctx context.Context, ownerID int, transactio
 				}
 			}()
 			query := `
-	INSERT INTO wallet (owner_id, balance, reserved_balance, created_at, updated_at)
-	VALUES ($1, $2, 0, $3, $3)
-	ON CONFLICT (owner_id) DO UPDATE SET balance = wallet.balance + excluded.balance,
+	INSERT INTO wallet (owner_id, currency, balance, reserved_balance, created_at, updated_at)
+	VALUES ($1, $2, $3, 0, $4, $4)
+	ON CONFLICT (owner_id, currency) DO UPDATE SET balance = wallet.balance + excluded.balance,
 										updated_at = excluded.updated_at`
-			if _, err = tx.ExecContext(ctx, query, ownerID, transaction.Amount, time.Now().UTC().Format(dateTimeLayout)); err != nil {
+			if _, err = tx.ExecContext(ctx, query, ownerID, transaction.Currency, transaction.Amount,
+				time.Now().UTC().Format(dateTimeLayout)); err != nil {
 				return fmt.Errorf("err executing [UpsertDepositToWallet]: %w", err)
 			}
-			wallet, err = db.checkBalance(ctx, tx, ownerID, 0)
+			wallet, err = db.checkBalance(ctx, tx, ownerID, transaction.Currency, 0)
 			if err != nil {
 				return fmt.Errorf("err executing [UpsertDepositToWallet]: %w", err)
 			}
-			if err = db.insertTransaction(ctx, tx, transaction.IdempotenceKey, wallet.ID, nil, transaction.Amount,
-				nil, transaction.Comment); err != nil {
+			transactionID, err = db.insertTransaction(ctx, tx, transactionRecord{
+				idempotenceKey: &transaction.IdempotenceKey,
+				kind:           models.TransactionKindDeposit,
+				walletID:       wallet.ID,
+				amount:         transaction.Amount,
+				comment:        transaction.Comment,
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [UpsertDepositToWallet]: %w", err)
+			}
+			err = db.insertJournalEntry(ctx, tx, entryDeposit, transactionReference(transactionID), &transactionID,
+				externalCash(transaction.Currency, -transaction.Amount),
+				available(ownerID, transaction.Currency, transaction.Amount))
+			if err != nil {
+				return fmt.Errorf("err executing [UpsertDepositToWallet]: %w", err)
+			}
+			err = db.insertEvent(ctx, tx, models.EventFundsDeposited, models.BalanceEvent{
+				AccountID:     ownerID,
+				TransactionID: &transactionID,
+				Currency:      transaction.Currency,
+				Amount:        transaction.Amount,
+				AdminID:       transaction.Actor.ID(),
+			})
+			if err != nil {
 				return fmt.Errorf("err executing [UpsertDepositToWallet]: %w", err)
 			}
+			if transaction.Actor != nil {
+				err = db.insertAdminAction(ctx, tx, models.AdminActionDeposit, ownerID, transactionID, transaction)
+				if err != nil {
+					return fmt.Errorf("err executing [UpsertDepositToWallet]: %w", err)
+				}
+			}
 			err = tx.Commit()
 			if err != nil {
 				return fmt.Errorf("err committing the transaction: %w", err)
@@ -143,6 +174,7 @@ func (db *DB) WithdrawMoneyFromWallet(ctx context.Context, ownerID int, transact
 	var err error
 	var tx *sql.Tx
 	var wallet *models.Wallet
+	var transactionID int
 	for i := 0; i < retries; i++ {
 		err = func() error {
 			tx, err = db.db.BeginTx(ctx, nil)
@@ -154,28 +186,46 @@ func (db *DB) WithdrawMoneyFromWallet(ctx context.Context, ownerID int, transact
 					db.log.Error("err rolling back deposit transaction")
 				}
 			}()
-			wallet, err = db.checkBalance(ctx, tx, ownerID, transaction.Amount)
+			wallet, err = db.checkBalance(ctx, tx, ownerID, transaction.Currency, transaction.Amount)
 			if err != nil {
 				return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
 			}
-			query := `
-	UPDATE wallet 
-	SET balance = balance - $1,
-	updated_at = $3
-	WHERE owner_id = $2`
-			var result sql.Result
-			result, err = tx.ExecContext(ctx, query, transaction.Amount, ownerID, time.Now().UTC().Format(dateTimeLayout))
+			err = db.withdrawMoney(ctx, tx, wallet.ID, transaction.Amount)
+			if err != nil {
+				return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
+			}
+			transactionID, err = db.insertTransaction(ctx, tx, transactionRecord{
+				idempotenceKey: &transaction.IdempotenceKey,
+				kind:           models.TransactionKindWithdrawal,
+				walletID:       wallet.ID,
+				amount:         -transaction.Amount,
+				comment:        transaction.Comment,
+			})
 			if err != nil {
 				return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
 			}
-			if count, _ := result.RowsAffected(); count == 0 {
-				return models.ErrWalletNotFound
+			err = db.insertJournalEntry(ctx, tx, entryWithdrawal, transactionReference(transactionID), &transactionID,
+				available(ownerID, transaction.Currency, -transaction.Amount),
+				externalCash(transaction.Currency, transaction.Amount))
+			if err != nil {
+				return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
 			}
-			transaction.Amount *= -1
-			if err = db.insertTransaction(ctx, tx, transaction.IdempotenceKey, wallet.ID, nil, transaction.Amount,
-				nil, transaction.Comment); err != nil {
+			err = db.insertEvent(ctx, tx, models.EventFundsWithdrawn, models.BalanceEvent{
+				AccountID:     ownerID,
+				TransactionID: &transactionID,
+				Currency:      transaction.Currency,
+				Amount:        transaction.Amount,
+				AdminID:       transaction.Actor.ID(),
+			})
+			if err != nil {
 				return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
 			}
+			if transaction.Actor != nil {
+				err = db.insertAdminAction(ctx, tx, models.AdminActionWithdrawal, ownerID, transactionID, transaction)
+				if err != nil {
+					return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
+				}
+			}
 			err = tx.Commit()
 			if err != nil {
 				return fmt.Errorf("err committing the transaction: %w", err)
@@ -190,10 +240,12 @@ func (db *DB) WithdrawMoneyFromWallet(ctx context.Context, ownerID int, transact
 	return err
 }
 
-func (db *DB) TransferMoney(ctx context.Context, accountID int, transaction models.TransferTransaction) error {
+func (db *DB) TransferMoney(ctx context.Context, accountID int, transaction models.TransferTransaction,
+	conversion models.Conversion) error {
 	var err error
 	var tx *sql.Tx
 	var wallet *models.Wallet
+	var targetWalletID, transactionID int
 	for i := 0; i < retries; i++ {
 		err = func() error {
 			tx, err = db.db.BeginTx(ctx, nil)
@@ -205,7 +257,12 @@ func (db *DB) TransferMoney(ctx context.Context, accountID int, transaction mode
 					db.log.Error("err rolling back transfer transaction")
 				}
 			}()
-			wallet, err = db.checkBalance(ctx, tx, accountID, transaction.Amount)
+			if conversion.QuoteID != "" {
+				if err = db.useExchangeQuote(ctx, tx, accountID, conversion.QuoteID); err != nil {
+					return fmt.Errorf("err executing [TransferMoney]: %w", err)
+				}
+			}
+			wallet, err = db.checkBalance(ctx, tx, accountID, transaction.Currency, transaction.Amount)
 			if err != nil {
 				return fmt.Errorf("err executing [TransferMoney]: %w", err)
 			}
@@ -213,12 +270,33 @@ func (db *DB) TransferMoney(ctx context.Context, accountID int, transaction mode
 			if err != nil {
 				return fmt.Errorf("err executing [TransferMoney]: %w", err)
 			}
-			err = db.depositMoney(ctx, tx, transaction.Target, transaction.Amount)
+			targetWalletID, err = db.depositMoney(ctx, tx, transaction.Target, conversion.TargetCurrency, conversion.TargetAmount)
+			if err != nil {
+				return fmt.Errorf("err executing [TransferMoney]: %w", err)
+			}
+			record := transactionRecord{
+				idempotenceKey: &transaction.IdempotenceKey,
+				kind:           models.TransactionKindTransfer,
+				walletID:       wallet.ID,
+				targetWalletID: &targetWalletID,
+				amount:         -transaction.Amount,
+				comment:        transaction.Comment,
+				targetAmount:   &conversion.TargetAmount,
+				exchangeRate:   &conversion.Rate,
+			}
+			if conversion.QuoteID != "" {
+				record.quoteID = &conversion.QuoteID
+			}
+			transactionID, err = db.insertTransaction(ctx, tx, record)
+			if err != nil {
+				return fmt.Errorf("err executing [TransferMoney]: %w", err)
+			}
+			err = db.insertJournalEntry(ctx, tx, entryTransfer, transactionReference(transactionID), &transactionID,
+				transferPostings(accountID, transaction, conversion)...)
 			if err != nil {
 				return fmt.Errorf("err executing [TransferMoney]: %w", err)
 			}
-			err = db.insertTransaction(ctx, tx, transaction.IdempotenceKey, wallet.ID, &transaction.Target, transaction.Amount,
-				nil, transaction.Comment)
+			err = db.insertTransferEvents(ctx, tx, accountID, transactionID, transaction, conversion)
 			if err != nil {
 				return fmt.Errorf("err executing [TransferMoney]: %w", err)
 			}
@@ -240,6 +318,7 @@ func (db *DB) ReserveMoneyFromWallet(ctx context.Context, transaction models.Res
 	var err error
 	var tx *sql.Tx
 	var wallet *models.Wallet
+	var transactionID int
 	for i := 0; i < retries; i++ {
 		err = func() error {
 			tx, err = db.db.BeginTx(ctx, nil)
@@ -251,7 +330,7 @@ func (db *DB) ReserveMoneyFromWallet(ctx context.Context, transaction models.Res
 					db.log.Error("err rolling back reserve transaction")
 				}
 			}()
-			wallet, err = db.checkBalance(ctx, tx, transaction.AccountID, transaction.Amount)
+			wallet, err = db.checkBalance(ctx, tx, transaction.AccountID, transaction.Currency, transaction.Amount)
 			if err != nil {
 				return fmt.Errorf("err executing [ReserveMoneyFromWallet]: %w", err)
 			}
@@ -267,6 +346,33 @@ func (db *DB) ReserveMoneyFromWallet(ctx context.Context, transaction models.Res
 			if err != nil {
 				return fmt.Errorf("err executing [ReserveMoneyFromWallet]: %w", err)
 			}
+			transactionID, err = db.insertTransaction(ctx, tx, transactionRecord{
+				kind:      models.TransactionKindReserve,
+				walletID:  wallet.ID,
+				amount:    -transaction.Amount,
+				serviceID: &transaction.ServiceID,
+				comment:   "Резервирование средств",
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [ReserveMoneyFromWallet]: %w", err)
+			}
+			err = db.insertJournalEntry(ctx, tx, entryReserve, orderReference(transaction.OrderID), &transactionID,
+				available(transaction.AccountID, transaction.Currency, -transaction.Amount),
+				reserved(transaction.AccountID, transaction.Currency, transaction.Amount))
+			if err != nil {
+				return fmt.Errorf("err executing [ReserveMoneyFromWallet]: %w", err)
+			}
+			err = db.insertEvent(ctx, tx, models.EventFundsReserved, models.BalanceEvent{
+				AccountID:     transaction.AccountID,
+				TransactionID: &transactionID,
+				OrderID:       transaction.OrderID,
+				ServiceID:     transaction.ServiceID,
+				Currency:      transaction.Currency,
+				Amount:        transaction.Amount,
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [ReserveMoneyFromWallet]: %w", err)
+			}
 			err = tx.Commit()
 			if err != nil {
 				return fmt.Errorf("err committing the transaction: %w", err)
@@ -285,7 +391,9 @@ func (db *DB) ApplyReservedMoney(ctx context.Context, transaction models.Reserve
 	var err error
 	var tx *sql.Tx
 	var wallet *models.Wallet
+	var order *reservedOrder
 	var serviceTitle string
+	var transactionID int
 	for i := 0; i < retries; i++ {
 		err = func() error {
 			tx, err = db.db.BeginTx(ctx, nil)
@@ -297,24 +405,78 @@ func (db *DB) ApplyReservedMoney(ctx context.Context, transaction models.Reserve
 					db.log.Error("err rolling back apply transaction")
 				}
 			}()
-			wallet, err = db.checkReservedBalance(ctx, tx, transaction.AccountID, transaction.Amount)
+			wallet, err = db.checkReservedBalance(ctx, tx, transaction.AccountID, transaction.Currency, transaction.Amount)
 			if err != nil {
 				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
 			}
+			order, err = db.lockReservedOrder(ctx, tx, transaction)
+			if err != nil {
+				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+			}
+			remaining := order.amount - order.captured
+			if transaction.Amount > remaining {
+				return models.ErrNotEnoughReservedMoney
+			}
 			err = db.withdrawReservedMoney(ctx, tx, wallet.ID, transaction.Amount)
 			if err != nil {
 				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
 			}
-			err = db.updateOrderStatus(ctx, tx, transaction.AccountID, "Completed", transaction)
+			serviceTitle, err = db.getActiveServiceTitle(ctx, tx, transaction.ServiceID)
+			if err != nil {
+				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+			}
+			// Without a key only the first capture can be made, keyed by the order as a single full capture was.
+			idempotenceKey := transaction.IdempotenceKey
+			if idempotenceKey == 0 {
+				if order.captured > 0 {
+					return models.ErrIdempotenceKeyRequired
+				}
+				idempotenceKey = transaction.OrderID
+			}
+			transactionID, err = db.insertTransaction(ctx, tx, transactionRecord{
+				idempotenceKey: &idempotenceKey,
+				kind:           models.TransactionKindServiceCharge,
+				walletID:       wallet.ID,
+				amount:         -transaction.Amount,
+				serviceID:      &transaction.ServiceID,
+				comment:        serviceTitle,
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+			}
+			err = db.insertCapture(ctx, tx, order, serviceTitle, transaction.Amount, transactionID)
+			if err != nil {
+				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+			}
+			err = db.insertJournalEntry(ctx, tx, entryReserveApply, orderReference(transaction.OrderID), &transactionID,
+				reserved(transaction.AccountID, transaction.Currency, -transaction.Amount),
+				revenue(transaction.Currency, transaction.Amount))
 			if err != nil {
 				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
 			}
-			serviceTitle, err = db.getServiceTitle(ctx, tx, transaction.ServiceID)
+			order.captured += transaction.Amount
+			status := models.ReservationActive
+			if transaction.Final || order.captured == order.amount {
+				status = models.ReservationCompleted
+				err = db.releaseReserve(ctx, tx, wallet.ID, order, order.amount-order.captured, entryReserveCancel,
+					"Возврат остатка резервирования")
+				if err != nil {
+					return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+				}
+			}
+			err = db.updateOrder(ctx, tx, order, status)
 			if err != nil {
 				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
 			}
-			err = db.insertTransaction(ctx, tx, transaction.OrderID, wallet.ID, nil, transaction.Amount,
-				&transaction.ServiceID, serviceTitle)
+			err = db.insertEvent(ctx, tx, models.EventReserveApplied, models.BalanceEvent{
+				AccountID:     transaction.AccountID,
+				TransactionID: &transactionID,
+				OrderID:       transaction.OrderID,
+				ServiceID:     transaction.ServiceID,
+				Currency:      transaction.Currency,
+				Amount:        transaction.Amount,
+				Final:         status == models.ReservationCompleted,
+			})
 			if err != nil {
 				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
 			}
@@ -336,6 +498,7 @@ func (db *DB) CancelReserve(ctx context.Context, transaction models.ReserveTrans
 	var err error
 	var tx *sql.Tx
 	var wallet *models.Wallet
+	var order *reservedOrder
 	for i := 0; i < retries; i++ {
 		err = func() error {
 			tx, err = db.db.BeginTx(ctx, nil)
@@ -347,19 +510,18 @@ func (db *DB) CancelReserve(ctx context.Context, transaction models.ReserveTrans
 					db.log.Error("err rolling back cancel transaction")
 				}
 			}()
-			wallet, err = db.checkReservedBalance(ctx, tx, transaction.AccountID, transaction.Amount)
+			wallet, err = db.checkReservedBalance(ctx, tx, transaction.AccountID, transaction.Currency, 0)
 			if err != nil {
 				return fmt.Errorf("err executing [CancelReserve]: %w", err)
 			}
-			err = db.withdrawReservedMoney(ctx, tx, wallet.ID, transaction.Amount)
+			order, err = db.lockReservedOrder(ctx, tx, transaction)
 			if err != nil {
 				return fmt.Errorf("err executing [CancelReserve]: %w", err)
 			}
-			err = db.depositMoney(ctx, tx, transaction.AccountID, transaction.Amount)
-			if err != nil {
-				return fmt.Errorf("err executing [CancelReserve]: %w", err)
+			if transaction.Amount != order.amount-order.captured {
+				return models.ErrOrderNotFound
 			}
-			err = db.updateOrderStatus(ctx, tx, transaction.AccountID, "Cancelled", transaction)
+			err = db.cancelOrder(ctx, tx, wallet, order, models.ReservationCancelled)
 			if err != nil {
 				return fmt.Errorf("err executing [CancelReserve]: %w", err)
 			}
@@ -377,61 +539,258 @@ func (db *DB) CancelReserve(ctx context.Context, transaction models.ReserveTrans
 	return err
 }
 
-func (db *DB) GetWalletTransactions(ctx context.Context, accountID int,
-	queryParams *models.TransactionsQueryParams) ([]models.TransactionFullInfo, error) {
+func (db *DB) GetExpiredReservations(ctx context.Context, now time.Time,
+	limit int) ([]models.ReserveTransaction, error) {
+	query := `
+	SELECT order_id, owner_id, service_id, currency, amount
+	FROM reserved_funds
+	WHERE status = $1 AND expires_at <= $2
+	ORDER BY expires_at
+	LIMIT $3`
+	var err error
+	var rows *sql.Rows
+	for i := 0; i < retries; i++ {
+		reservations := make([]models.ReserveTransaction, 0)
+		rows, err = db.db.QueryContext(ctx, query, models.ReservationActive, now.UTC().Format(dateTimeLayout), limit)
+		if err != nil {
+			err = fmt.Errorf("err executing [GetExpiredReservations]: %w", err)
+			continue
+		}
+		for rows.Next() {
+			reservation := models.ReserveTransaction{}
+			err = rows.Scan(&reservation.OrderID, &reservation.AccountID, &reservation.ServiceID,
+				&reservation.Currency, &reservation.Amount)
+			if err != nil {
+				break
+			}
+			reservations = append(reservations, reservation)
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		_ = rows.Close()
+		if err != nil {
+			err = fmt.Errorf("err executing [GetExpiredReservations]: %w", err)
+			continue
+		}
+		return reservations, nil
+	}
+	return nil, err
+}
+
+func (db *DB) ExpireReserve(ctx context.Context, transaction models.ReserveTransaction) error {
 	var err error
+	var tx *sql.Tx
 	var wallet *models.Wallet
-	var transactions []models.TransactionFullInfo
-	var otherTransaction models.TransactionFullInfo
+	var order *reservedOrder
 	for i := 0; i < retries; i++ {
 		err = func() error {
-			wallet, err = db.GetWallet(ctx, accountID)
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back expire transaction")
+				}
+			}()
+			wallet, err = db.checkReservedBalance(ctx, tx, transaction.AccountID, transaction.Currency, 0)
+			if err != nil {
+				return fmt.Errorf("err executing [ExpireReserve]: %w", err)
+			}
+			order, err = db.lockReservedOrder(ctx, tx, transaction)
 			if err != nil {
-				return fmt.Errorf("err executing [GetWalletTransactions]: %w", err)
+				return fmt.Errorf("err executing [ExpireReserve]: %w", err)
 			}
-			query := db.queryBuilder(queryParams.Sorting, queryParams.Descending)
-			var rows *sqlx.Rows
-			rows, err = db.db.QueryxContext(ctx, query, wallet.ID, queryParams.From, queryParams.To,
-				queryParams.Limit, queryParams.Offset)
+			err = db.cancelOrder(ctx, tx, wallet, order, models.ReservationExpired)
+			if err != nil {
+				return fmt.Errorf("err executing [ExpireReserve]: %w", err)
+			}
+			err = tx.Commit()
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) RefundOrder(ctx context.Context, refund models.RefundTransaction) error {
+	var err error
+	var tx *sql.Tx
+	var order *capturedOrder
+	var walletID, transactionID int
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
 			if err != nil {
-				return fmt.Errorf("err executing [GetWalletTransactions]: %w", err)
+				return err
 			}
 			defer func() {
-				if err = rows.Close(); err != nil {
-					db.log.Warnf("err closing rows: %v", err)
+				if err = tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back refund transaction")
 				}
 			}()
-			for rows.Next() {
-				if err = rows.StructScan(&otherTransaction); err != nil {
-					continue
-				}
-				transactions = append(transactions, otherTransaction)
+			order, err = db.lockCapturedOrder(ctx, tx, refund.AccountID, refund.OrderID)
+			if err != nil {
+				return fmt.Errorf("err executing [RefundOrder]: %w", err)
+			}
+			if order.refunded+refund.Amount > order.captured {
+				return models.ErrRefundExceedsCaptured
+			}
+			walletID, err = db.depositMoney(ctx, tx, refund.AccountID, order.currency, refund.Amount)
+			if err != nil {
+				return fmt.Errorf("err executing [RefundOrder]: %w", err)
+			}
+			comment := refund.Comment
+			if comment == "" {
+				comment = "Возврат: " + order.serviceTitle
+			}
+			reversed, reversalOf := order.reverseCaptures(refund.Amount)
+			transactionID, err = db.insertTransaction(ctx, tx, transactionRecord{
+				idempotenceKey: &refund.IdempotenceKey,
+				kind:           models.TransactionKindRefund,
+				walletID:       walletID,
+				amount:         refund.Amount,
+				serviceID:      &order.serviceID,
+				comment:        comment,
+				reversalOf:     reversalOf,
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [RefundOrder]: %w", err)
+			}
+			err = db.insertRefund(ctx, tx, order, refund.Amount, transactionID, reversed)
+			if err != nil {
+				return fmt.Errorf("err executing [RefundOrder]: %w", err)
+			}
+			err = db.insertJournalEntry(ctx, tx, entryRefund, orderReference(refund.OrderID), &transactionID,
+				revenue(order.currency, -refund.Amount),
+				available(refund.AccountID, order.currency, refund.Amount))
+			if err != nil {
+				return fmt.Errorf("err executing [RefundOrder]: %w", err)
+			}
+			err = db.insertEvent(ctx, tx, models.EventOrderRefunded, models.BalanceEvent{
+				AccountID:     refund.AccountID,
+				TransactionID: &transactionID,
+				OrderID:       refund.OrderID,
+				ServiceID:     order.serviceID,
+				Currency:      order.currency,
+				Amount:        refund.Amount,
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [RefundOrder]: %w", err)
+			}
+			err = tx.Commit()
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
 			}
 			return nil
 		}()
 		if err != nil {
 			continue
 		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) GetWalletTransactions(ctx context.Context, accountID int, queryParams *models.TransactionsQueryParams,
+	cursor *models.TransactionsCursor) ([]models.TransactionFullInfo, error) {
+	query, args := db.queryBuilder(accountID, queryParams, cursor)
+	var err error
+	for i := 0; i < retries; i++ {
+		if err = db.checkWalletExists(ctx, accountID, queryParams.Currency); err != nil {
+			err = fmt.Errorf("err executing [GetWalletTransactions]: %w", err)
+			if errors.Is(err, models.ErrWalletNotFound) {
+				return nil, err
+			}
+			continue
+		}
+		transactions := make([]models.TransactionFullInfo, 0)
+		if err = db.db.SelectContext(ctx, &transactions, query, args...); err != nil {
+			err = fmt.Errorf("err executing [GetWalletTransactions]: %w", err)
+			continue
+		}
 		return transactions, nil
 	}
 	return nil, err
 }
 
-func (db *DB) GetReport(ctx context.Context, month time.Time) (map[string]float64, error) {
+func (db *DB) GetReservation(ctx context.Context, orderID int) (*models.Reservation, error) {
 	query := `
-	SELECT title, amount
+	SELECT order_id, owner_id, service_id, currency, amount, captured_amount,
+	       CASE WHEN status = $2 THEN amount - captured_amount ELSE 0 END AS remaining_amount,
+	       status, expires_at, created_at, updated_at
 	FROM reserved_funds
-	INNER JOIN services s on s.id = reserved_funds.service_id
-	WHERE status = $1 AND 
-	    updated_at BETWEEN $2 AND $3`
-	status := "Completed"
+	WHERE order_id = $1`
+	var err error
+	for i := 0; i < retries; i++ {
+		reservation := models.Reservation{}
+		err = db.db.GetContext(ctx, &reservation, query, orderID, models.ReservationActive)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrOrderNotFound
+			}
+			err = fmt.Errorf("err executing [GetReservation]: %w", err)
+			continue
+		}
+		return &reservation, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetReservations(ctx context.Context,
+	queryParams *models.ReservationsQueryParams) ([]models.Reservation, error) {
+	query := `
+	SELECT order_id, owner_id, service_id, currency, amount, captured_amount,
+	       CASE WHEN status = $8 THEN amount - captured_amount ELSE 0 END AS remaining_amount,
+	       status, expires_at, created_at, updated_at
+	FROM reserved_funds
+	WHERE ($1 = 0 OR owner_id = $1) AND
+	      ($2 = '' OR status = $2) AND
+	      ($3 = 0 OR service_id = $3) AND
+	      created_at BETWEEN $4 AND $5
+	ORDER BY created_at DESC, order_id DESC
+	LIMIT $6 OFFSET $7`
+	var err error
+	for i := 0; i < retries; i++ {
+		reservations := make([]models.Reservation, 0)
+		err = db.db.SelectContext(ctx, &reservations, query, queryParams.AccountID, queryParams.Status,
+			queryParams.ServiceID, queryParams.From, queryParams.To, queryParams.Limit, queryParams.Offset,
+			models.ReservationActive)
+		if err != nil {
+			err = fmt.Errorf("err executing [GetReservations]: %w", err)
+			continue
+		}
+		return reservations, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetReport(ctx context.Context, month time.Time) ([]models.ReportRow, error) {
+	query := `
+	SELECT service_title AS title, currency, SUM(amount) AS amount
+	FROM (SELECT service_title, currency, amount
+	      FROM capture
+	      WHERE created_at BETWEEN $1 AND $2
+	      UNION ALL
+	      SELECT service_title, currency, -amount
+	      FROM refund
+	      WHERE created_at BETWEEN $1 AND $2) revenue
+	GROUP BY service_title, currency
+	ORDER BY service_title, currency`
 	var err error
 	var rows *sqlx.Rows
-	services := make(map[string]float64)
-	var service models.Service
+	var report []models.ReportRow
+	var row models.ReportRow
 	for i := 0; i < retries; i++ {
 		err = func() error {
-			rows, err = db.db.QueryxContext(ctx, query, status, month, month.AddDate(0, 1, 0))
+			rows, err = db.db.QueryxContext(ctx, query, month, month.AddDate(0, 1, 0))
 			if err != nil {
 				return fmt.Errorf("err executing [GetReport]: %w", err)
 			}
@@ -440,23 +799,210 @@ func (db *DB) GetReport(ctx context.Context, month time.Time) (map[string]float6
 					db.log.Warnf("err closing rows: %v", err)
 				}
 			}()
+			report = report[:0]
 			for rows.Next() {
-				if err = rows.StructScan(&service); err != nil {
+				if err = rows.StructScan(&row); err != nil {
 					return err
 				}
-				services[service.Title] += service.Amount
+				report = append(report, row)
 			}
 			return nil
 		}()
 		if err != nil {
 			continue
 		}
-		return services, nil
+		return report, nil
+	}
+	return nil, err
+}
+
+func (db *DB) InsertExchangeQuote(ctx context.Context, quote models.ExchangeQuote) error {
+	query := `
+	INSERT INTO exchange_quote (id, owner_id, from_currency, to_currency, rate, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	var err error
+	for i := 0; i < retries; i++ {
+		_, err = db.db.ExecContext(ctx, query, quote.ID, quote.AccountID, quote.From, quote.To, quote.Rate,
+			quote.ExpiresAt.UTC().Format(dateTimeLayout), time.Now().UTC().Format(dateTimeLayout))
+		if err != nil {
+			err = fmt.Errorf("err executing [InsertExchangeQuote]: %w", err)
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) GetExchangeQuote(ctx context.Context, accountID int, quoteID string) (*models.ExchangeQuote, error) {
+	query := `
+	SELECT id, owner_id, from_currency, to_currency, rate, expires_at
+	FROM exchange_quote
+	WHERE id = $1 AND 
+	      owner_id = $2 AND
+	      used_at IS NULL AND
+	      expires_at > $3`
+	var quote models.ExchangeQuote
+	var err error
+	for i := 0; i < retries; i++ {
+		if err = db.db.GetContext(ctx, &quote, query, quoteID, accountID,
+			time.Now().UTC().Format(dateTimeLayout)); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrQuoteNotFound
+			}
+			continue
+		}
+		return &quote, nil
 	}
 	return nil, err
 }
 
-func (db *DB) reserveMoney(ctx context.Context, tx *sql.Tx, walletID int, amount float64) error {
+func (db *DB) useExchangeQuote(ctx context.Context, tx *sql.Tx, accountID int, quoteID string) error {
+	query := `
+	UPDATE exchange_quote
+	SET used_at = $3
+	WHERE id = $1 AND
+	      owner_id = $2 AND
+	      used_at IS NULL AND
+	      expires_at > $3`
+	result, err := tx.ExecContext(ctx, query, quoteID, accountID, time.Now().UTC().Format(dateTimeLayout))
+	if err != nil {
+		return fmt.Errorf("err executing [useExchangeQuote]: %w", err)
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return models.ErrQuoteNotFound
+	}
+	return nil
+}
+
+type capturedOrder struct {
+	orderID      int
+	ownerID      int
+	serviceID    int
+	currency     string
+	serviceTitle string
+	captured     models.Money
+	refunded     models.Money
+	captures     []orderCapture
+}
+
+// orderCapture is a capture of the order with the part of it that is not refunded yet.
+type orderCapture struct {
+	id            int
+	transactionID *int
+	refundable    models.Money
+}
+
+type captureRefund struct {
+	captureID int
+	amount    models.Money
+}
+
+// reverseCaptures spreads a refund over the captures it reverses, latest capture first,
+// and returns the transaction of the first of them.
+func (order *capturedOrder) reverseCaptures(amount models.Money) ([]captureRefund, *int) {
+	reversed := make([]captureRefund, 0, 1)
+	var reversalOf *int
+	for _, capture := range order.captures {
+		if amount == 0 {
+			break
+		}
+		if capture.refundable <= 0 {
+			continue
+		}
+		part := capture.refundable
+		if part > amount {
+			part = amount
+		}
+		if len(reversed) == 0 {
+			reversalOf = capture.transactionID
+		}
+		reversed = append(reversed, captureRefund{captureID: capture.id, amount: part})
+		amount -= part
+	}
+	return reversed, reversalOf
+}
+
+func (db *DB) lockCapturedOrder(ctx context.Context, tx *sql.Tx, ownerID, orderID int) (*capturedOrder, error) {
+	query := `
+	SELECT order_id, owner_id, service_id, currency, captured_amount, status
+	FROM reserved_funds
+	WHERE owner_id = $1 AND order_id = $2
+	FOR UPDATE`
+	order := capturedOrder{}
+	var status string
+	err := tx.QueryRowContext(ctx, query, ownerID, orderID).Scan(&order.orderID, &order.ownerID, &order.serviceID,
+		&order.currency, &order.captured, &status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("err executing [lockCapturedOrder]: %w", err)
+	}
+	if status != models.ReservationCompleted || order.captured == 0 {
+		return nil, models.ErrOrderNotCompleted
+	}
+	query = `
+	SELECT COALESCE(SUM(amount), 0)
+	FROM refund
+	WHERE order_id = $1`
+	if err = tx.QueryRowContext(ctx, query, orderID).Scan(&order.refunded); err != nil {
+		return nil, fmt.Errorf("err executing [lockCapturedOrder]: %w", err)
+	}
+	query = `
+	SELECT c.id, c.transaction_id, c.service_title,
+	       c.amount - COALESCE((SELECT SUM(rc.amount) FROM refund_capture rc WHERE rc.capture_id = c.id), 0)
+	FROM capture c
+	WHERE c.order_id = $1
+	ORDER BY c.created_at DESC, c.id DESC`
+	rows, err := tx.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("err executing [lockCapturedOrder]: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		capture := orderCapture{}
+		var serviceTitle string
+		if err = rows.Scan(&capture.id, &capture.transactionID, &serviceTitle, &capture.refundable); err != nil {
+			return nil, fmt.Errorf("err executing [lockCapturedOrder]: %w", err)
+		}
+		if len(order.captures) == 0 {
+			order.serviceTitle = serviceTitle
+		}
+		order.captures = append(order.captures, capture)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("err executing [lockCapturedOrder]: %w", err)
+	}
+	if len(order.captures) == 0 {
+		return nil, models.ErrOrderNotCompleted
+	}
+	return &order, nil
+}
+
+func (db *DB) insertRefund(ctx context.Context, tx *sql.Tx, order *capturedOrder, amount models.Money,
+	transactionID int, reversed []captureRefund) error {
+	query := `
+	INSERT INTO refund (order_id, owner_id, service_id, service_title, currency, amount, transaction_id, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id`
+	var refundID int
+	err := tx.QueryRowContext(ctx, query, order.orderID, order.ownerID, order.serviceID, order.serviceTitle,
+		order.currency, amount, transactionID, time.Now().UTC().Format(dateTimeLayout)).Scan(&refundID)
+	if err != nil {
+		return fmt.Errorf("err executing [insertRefund]: %w", err)
+	}
+	query = `
+	INSERT INTO refund_capture (refund_id, capture_id, amount)
+	VALUES ($1, $2, $3)`
+	for _, capture := range reversed {
+		if _, err = tx.ExecContext(ctx, query, refundID, capture.captureID, capture.amount); err != nil {
+			return fmt.Errorf("err executing [insertRefund]: %w", err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) reserveMoney(ctx context.Context, tx *sql.Tx, walletID int, amount models.Money) error {
 	query := `
 	UPDATE wallet 
 	SET reserved_balance = reserved_balance + $1,
@@ -474,26 +1020,28 @@ func (db *DB) reserveMoney(ctx context.Context, tx *sql.Tx, walletID int, amount
 
 func (db *DB) insertReservedFunds(ctx context.Context, tx *sql.Tx, accountID int, transaction models.ReserveTransaction) error {
 	query := `
-	INSERT INTO reserved_funds (order_id, owner_id, service_id, amount, status, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $6)`
-	status := "Active"
-	_, err := tx.ExecContext(ctx, query, transaction.OrderID, accountID, transaction.ServiceID, transaction.Amount,
-		status, time.Now().UTC().Format(dateTimeLayout))
+	INSERT INTO reserved_funds (order_id, owner_id, currency, service_id, amount, status, created_at, updated_at,
+	                            expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $7, $8)`
+	status := models.ReservationActive
+	_, err := tx.ExecContext(ctx, query, transaction.OrderID, accountID, transaction.Currency, transaction.ServiceID,
+		transaction.Amount, status, time.Now().UTC().Format(dateTimeLayout), transaction.ExpiresAt)
 	if err != nil {
 		return fmt.Errorf("err executing [insertReservedFunds]: %w", err)
 	}
 	return nil
 }
 
-func (db *DB) checkBalance(ctx context.Context, tx *sql.Tx, ownerID int, amount float64) (*models.Wallet, error) {
+func (db *DB) checkBalance(ctx context.Context, tx *sql.Tx, ownerID int, currency string,
+	amount models.Money) (*models.Wallet, error) {
 	query := `
-	SELECT id, balance
+	SELECT id, currency, balance
 	FROM wallet
-	WHERE owner_id = $1
+	WHERE owner_id = $1 AND currency = $2
 	FOR UPDATE`
-	row := tx.QueryRowContext(ctx, query, ownerID)
+	row := tx.QueryRowContext(ctx, query, ownerID, currency)
 	var wallet models.Wallet
-	if err := row.Scan(&wallet.ID, &wallet.Balance); err != nil {
+	if err := row.Scan(&wallet.ID, &wallet.Currency, &wallet.Balance); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, models.ErrWalletNotFound
 		}
@@ -508,21 +1056,50 @@ func (db *DB) checkBalance(ctx context.Context, tx *sql.Tx, ownerID int, amount
 	return &wallet, nil
 }
 
-func (db *DB) updateOrderStatus(ctx context.Context, tx *sql.Tx, ownerID int, status string,
-	transaction models.ReserveTransaction) error {
+type reservedOrder struct {
+	orderID   int
+	ownerID   int
+	serviceID int
+	currency  string
+	amount    models.Money
+	captured  models.Money
+}
+
+func (db *DB) lockReservedOrder(ctx context.Context, tx *sql.Tx, transaction models.ReserveTransaction) (*reservedOrder, error) {
+	query := `
+	SELECT order_id, owner_id, service_id, currency, amount, captured_amount
+	FROM reserved_funds
+	WHERE owner_id = $1 AND 
+	      service_id = $2 AND
+	      order_id = $3 AND 
+	      status = $4
+	FOR UPDATE`
+	order := reservedOrder{}
+	err := tx.QueryRowContext(ctx, query, transaction.AccountID, transaction.ServiceID, transaction.OrderID,
+		models.ReservationActive).Scan(&order.orderID, &order.ownerID, &order.serviceID, &order.currency, &order.amount,
+		&order.captured)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("err executing [lockReservedOrder]: %w", err)
+	}
+	if order.currency != transaction.Currency {
+		return nil, models.ErrCurrencyMismatch
+	}
+	return &order, nil
+}
+
+func (db *DB) updateOrder(ctx context.Context, tx *sql.Tx, order *reservedOrder, status string) error {
 	query := `
 	UPDATE reserved_funds 
 	SET status = $1,
-	updated_at = $2
-	WHERE owner_id = $3 AND 
-	      service_id = $4 AND
-	      order_id = $5 AND 
-	      amount = $6 AND
-	      status = $7`
-	result, err := tx.ExecContext(ctx, query, status, time.Now().UTC().Format(dateTimeLayout), ownerID, transaction.ServiceID,
-		transaction.OrderID, transaction.Amount, "Active")
+	captured_amount = $2,
+	updated_at = $3
+	WHERE order_id = $4`
+	result, err := tx.ExecContext(ctx, query, status, order.captured, time.Now().UTC().Format(dateTimeLayout), order.orderID)
 	if err != nil {
-		return fmt.Errorf("err executing [updateOrderStatus]: %w", err)
+		return fmt.Errorf("err executing [updateOrder]: %w", err)
 	}
 	if count, _ := result.RowsAffected(); count == 0 {
 		return models.ErrOrderNotFound
@@ -530,13 +1107,79 @@ func (db *DB) updateOrderStatus(ctx context.Context, tx *sql.Tx, ownerID int, st
 	return nil
 }
 
-func (db *DB) checkReservedBalance(ctx context.Context, tx *sql.Tx, ownerID int, amount float64) (*models.Wallet, error) {
+func (db *DB) releaseReserve(ctx context.Context, tx *sql.Tx, walletID int, order *reservedOrder,
+	amount models.Money, kind, comment string) error {
+	if amount == 0 {
+		return nil
+	}
+	if err := db.withdrawReservedMoney(ctx, tx, walletID, amount); err != nil {
+		return err
+	}
+	if err := db.depositToWallet(ctx, tx, walletID, amount); err != nil {
+		return err
+	}
+	transactionID, err := db.insertTransaction(ctx, tx, transactionRecord{
+		kind:      models.TransactionKindReserveRelease,
+		walletID:  walletID,
+		amount:    amount,
+		serviceID: &order.serviceID,
+		comment:   comment,
+	})
+	if err != nil {
+		return err
+	}
+	return db.insertJournalEntry(ctx, tx, kind, orderReference(order.orderID), &transactionID,
+		reserved(order.ownerID, order.currency, -amount),
+		available(order.ownerID, order.currency, amount))
+}
+
+func (db *DB) cancelOrder(ctx context.Context, tx *sql.Tx, wallet *models.Wallet, order *reservedOrder,
+	status string) error {
+	remaining := order.amount - order.captured
+	if wallet.ReservedBalance < remaining {
+		return models.ErrNotEnoughReservedMoney
+	}
+	kind, comment, eventType := entryReserveCancel, "Отмена резервирования", models.EventReserveCancelled
+	if status == models.ReservationExpired {
+		kind, comment, eventType = entryReserveExpire, "Истек срок резервирования", models.EventReserveExpired
+	}
+	if err := db.releaseReserve(ctx, tx, wallet.ID, order, remaining, kind, comment); err != nil {
+		return err
+	}
+	err := db.insertEvent(ctx, tx, eventType, models.BalanceEvent{
+		AccountID: order.ownerID,
+		OrderID:   order.orderID,
+		ServiceID: order.serviceID,
+		Currency:  order.currency,
+		Amount:    remaining,
+	})
+	if err != nil {
+		return err
+	}
+	return db.updateOrder(ctx, tx, order, status)
+}
+
+func (db *DB) insertCapture(ctx context.Context, tx *sql.Tx, order *reservedOrder, serviceTitle string,
+	amount models.Money, transactionID int) error {
+	query := `
+	INSERT INTO capture (order_id, service_id, service_title, currency, amount, transaction_id, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := tx.ExecContext(ctx, query, order.orderID, order.serviceID, serviceTitle, order.currency, amount,
+		transactionID, time.Now().UTC().Format(dateTimeLayout))
+	if err != nil {
+		return fmt.Errorf("err executing [insertCapture]: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) checkReservedBalance(ctx context.Context, tx *sql.Tx, ownerID int, currency string,
+	amount models.Money) (*models.Wallet, error) {
 	query := `
 	SELECT id, reserved_balance
 	FROM wallet
-	WHERE owner_id = $1
+	WHERE owner_id = $1 AND currency = $2
 	FOR UPDATE`
-	row := tx.QueryRowContext(ctx, query, ownerID)
+	row := tx.QueryRowContext(ctx, query, ownerID, currency)
 	var wallet models.Wallet
 	if err := row.Scan(&wallet.ID, &wallet.ReservedBalance); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -550,28 +1193,37 @@ func (db *DB) checkReservedBalance(ctx context.Context, tx *sql.Tx, ownerID int,
 	return &wallet, nil
 }
 
-func (db *DB) insertTransaction(ctx context.Context, tx *sql.Tx, idempotenceKey, walletID int, targetOwnerID *int, amount float64,
-	serviceID *int, comment string) error {
-	var query string
-	var err error
-	if targetOwnerID == nil {
-		query = `
-		INSERT INTO transaction (idempotence_key, wallet_id, amount, target_wallet_id, service_id, comment, timestamp)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	} else {
-		query = `
-	INSERT INTO transaction (idempotence_key, wallet_id, amount, target_wallet_id, service_id, comment, timestamp)
-	VALUES ($1, $2, $3, (SELECT id FROM wallet where owner_id = $4), $5, $6, $7)`
-	}
-	_, err = tx.ExecContext(ctx, query, idempotenceKey, walletID, amount, targetOwnerID, serviceID, comment,
-		time.Now().UTC().Format(dateTimeLayout))
+type transactionRecord struct {
+	idempotenceKey *int
+	kind           string
+	walletID       int
+	targetWalletID *int
+	amount         models.Money
+	serviceID      *int
+	comment        string
+	targetAmount   *models.Money
+	exchangeRate   *models.Rate
+	quoteID        *string
+	reversalOf     *int
+}
+
+func (db *DB) insertTransaction(ctx context.Context, tx *sql.Tx, record transactionRecord) (int, error) {
+	query := `
+	INSERT INTO transaction (idempotence_key, kind, wallet_id, amount, target_wallet_id, service_id, comment, timestamp,
+	                         target_amount, exchange_rate, quote_id, reversal_of)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	RETURNING id`
+	var id int
+	err := tx.QueryRowContext(ctx, query, record.idempotenceKey, record.kind, record.walletID, record.amount,
+		record.targetWalletID, record.serviceID, record.comment, time.Now().UTC().Format(dateTimeLayout),
+		record.targetAmount, record.exchangeRate, record.quoteID, record.reversalOf).Scan(&id)
 	if err != nil {
-		return fmt.Errorf("err executing [insertTransaction]: %w", err)
+		return 0, fmt.Errorf("err executing [insertTransaction]: %w", err)
 	}
-	return nil
+	return id, nil
 }
 
-func (db *DB) withdrawMoney(ctx context.Context, tx *sql.Tx, walletID int, amount float64) error {
+func (db *DB) withdrawMoney(ctx context.Context, tx *sql.Tx, walletID int, amount models.Money) error {
 	query := `
 	UPDATE wallet 
 	SET balance = balance - $1,
@@ -587,7 +1239,7 @@ func (db *DB) withdrawMoney(ctx context.Context, tx *sql.Tx, walletID int, amoun
 	return nil
 }
 
-func (db *DB) withdrawReservedMoney(ctx context.Context, tx *sql.Tx, walletID int, amount float64) error {
+func (db *DB) withdrawReservedMoney(ctx context.Context, tx *sql.Tx, walletID int, amount models.Money) error {
 	query := `
 	UPDATE wallet 
 	SET reserved_balance = reserved_balance - $1,
@@ -603,15 +1255,33 @@ func (db *DB) withdrawReservedMoney(ctx context.Context, tx *sql.Tx, walletID in
 	return nil
 }
 
-func (db *DB) depositMoney(ctx context.Context, tx *sql.Tx, ownerID int, amount float64) error {
+func (db *DB) depositMoney(ctx context.Context, tx *sql.Tx, ownerID int, currency string, amount models.Money) (int, error) {
+	query := `
+	UPDATE wallet 
+	SET balance = balance + $1,
+	updated_at = $4
+	WHERE owner_id = $2 AND currency = $3
+	RETURNING id`
+	var walletID int
+	err := tx.QueryRowContext(ctx, query, amount, ownerID, currency, time.Now().UTC().Format(dateTimeLayout)).Scan(&walletID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, db.walletNotFoundReason(ctx, tx, ownerID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("err executing [depositMoney]: %w", err)
+	}
+	return walletID, nil
+}
+
+func (db *DB) depositToWallet(ctx context.Context, tx *sql.Tx, walletID int, amount models.Money) error {
 	query := `
 	UPDATE wallet 
 	SET balance = balance + $1,
 	updated_at = $3
-	WHERE wallet.id in (SELECT id from wallet WHERE wallet.owner_id = $2)`
-	result, err := tx.ExecContext(ctx, query, amount, ownerID, time.Now().UTC().Format(dateTimeLayout))
+	WHERE id = $2`
+	result, err := tx.ExecContext(ctx, query, amount, walletID, time.Now().UTC().Format(dateTimeLayout))
 	if err != nil {
-		return fmt.Errorf("err executing [depositMoney]: %w", err)
+		return fmt.Errorf("err executing [depositToWallet]: %w", err)
 	}
 	if count, _ := result.RowsAffected(); count == 0 {
 		return models.ErrWalletNotFound
@@ -619,44 +1289,127 @@ func (db *DB) depositMoney(ctx context.Context, tx *sql.Tx, ownerID int, amount
 	return nil
 }
 
-func (db *DB) getServiceTitle(ctx context.Context, tx *sql.Tx, serviceID int) (string, error) {
+func (db *DB) walletNotFoundReason(ctx context.Context, tx *sql.Tx, ownerID int) error {
+	query := `
+	SELECT EXISTS (SELECT 1 FROM wallet WHERE owner_id = $1)`
+	var exists bool
+	if err := tx.QueryRowContext(ctx, query, ownerID).Scan(&exists); err != nil {
+		return fmt.Errorf("err executing [walletNotFoundReason]: %w", err)
+	}
+	if exists {
+		return models.ErrCurrencyMismatch
+	}
+	return models.ErrWalletNotFound
+}
+
+func (db *DB) checkWalletExists(ctx context.Context, ownerID int, currency string) error {
 	query := `
-	SELECT title
+	SELECT EXISTS (SELECT 1 FROM wallet WHERE owner_id = $1 AND ($2 = '' OR currency = $2))`
+	var exists bool
+	if err := db.db.QueryRowContext(ctx, query, ownerID, currency).Scan(&exists); err != nil {
+		return fmt.Errorf("err executing [checkWalletExists]: %w", err)
+	}
+	if !exists {
+		return models.ErrWalletNotFound
+	}
+	return nil
+}
+
+func (db *DB) getActiveServiceTitle(ctx context.Context, tx *sql.Tx, serviceID int) (string, error) {
+	query := `
+	SELECT title, active
 	FROM services
-	WHERE id = $1`
+	WHERE id = $1
+	FOR SHARE`
 	row := tx.QueryRowContext(ctx, query, serviceID)
 	var title string
-	if err := row.Scan(&title); err != nil {
+	var active bool
+	if err := row.Scan(&title, &active); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return "", models.ErrServiceNotFound
 		}
 		return "", fmt.Errorf("err getting service title: %w", err)
 	}
+	if !active {
+		return "", models.ErrServiceInactive
+	}
 	return title, nil
 }
 
-func (db *DB) queryBuilder(sorting, descending string) string {
-	query := `SELECT id, wallet_id, amount, target_wallet_id, service_id, comment, timestamp
-	FROM transaction
-	WHERE (wallet_id = $1 OR target_wallet_id = $1)
-	AND timestamp BETWEEN $2 AND $3`
-
-	switch sorting {
-	case "date":
-		query += " ORDER BY timestamp"
-	case "amount":
-		query += " ORDER BY amount"
-	default:
-		query += " ORDER BY timestamp"
+func (db *DB) queryBuilder(accountID int, queryParams *models.TransactionsQueryParams,
+	cursor *models.TransactionsCursor) (string, []interface{}) {
+	query := `SELECT h.id, h.kind, CASE WHEN h.amount < 0 THEN $5 ELSE $6 END AS direction, h.amount, h.currency,
+	       h.counterparty, h.target_amount, h.exchange_rate, h.service_id, h.reversal_of, h.comment, h.timestamp
+	FROM (SELECT t.id, t.kind, t.amount, w.currency, tw.owner_id AS counterparty,
+	             t.target_amount, t.exchange_rate, t.service_id, t.reversal_of, t.comment, t.timestamp
+	      FROM transaction t
+	      INNER JOIN wallet w ON w.id = t.wallet_id
+	      LEFT JOIN wallet tw ON tw.id = t.target_wallet_id
+	      WHERE w.owner_id = $1 AND ($2 = '' OR w.currency = $2)
+	      UNION ALL
+	      SELECT t.id, t.kind, COALESCE(t.target_amount, -t.amount), tw.currency, w.owner_id,
+	             t.target_amount, t.exchange_rate, t.service_id, t.reversal_of, t.comment, t.timestamp
+	      FROM transaction t
+	      INNER JOIN wallet w ON w.id = t.wallet_id
+	      INNER JOIN wallet tw ON tw.id = t.target_wallet_id
+	      WHERE tw.owner_id = $1 AND ($2 = '' OR tw.currency = $2)) h
+	WHERE h.timestamp BETWEEN $3 AND $4`
+	args := []interface{}{accountID, queryParams.Currency, queryParams.From, queryParams.To,
+		models.DirectionOut, models.DirectionIn}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
 	}
-	switch descending {
-	case "true":
-		query += " DESC"
-	case "false":
-		query += " ASC"
+	switch queryParams.Type {
+	case "":
+	case models.TransactionTypeTransferIn:
+		query += " AND h.kind = " + arg(models.TransactionKindTransfer) + " AND h.amount >= 0"
+	case models.TransactionTypeTransferOut:
+		query += " AND h.kind = " + arg(models.TransactionKindTransfer) + " AND h.amount < 0"
 	default:
-		query += " DESC"
+		query += " AND h.kind = " + arg(queryParams.Type)
+	}
+	if queryParams.Counterparty != 0 {
+		query += " AND h.counterparty = " + arg(queryParams.Counterparty)
+	}
+	if queryParams.ServiceID != 0 {
+		query += " AND h.service_id = " + arg(queryParams.ServiceID)
+	}
+	if queryParams.MinAmount != nil {
+		query += " AND ABS(h.amount) >= " + arg(*queryParams.MinAmount)
+	}
+	if queryParams.MaxAmount != nil {
+		query += " AND ABS(h.amount) <= " + arg(*queryParams.MaxAmount)
+	}
+	if queryParams.Comment != "" {
+		query += " AND strpos(lower(h.comment), lower(" + arg(queryParams.Comment) + ")) > 0"
+	}
+
+	sortColumn := "h.timestamp"
+	if queryParams.Sorting == models.SortingAmount {
+		sortColumn = "h.amount"
+	}
+	direction, comparison := " DESC", " < "
+	if queryParams.Descending == "false" {
+		direction, comparison = " ASC", " > "
+	}
+	// A transfer between the caller's own wallets has a row per wallet with the same ID, the amount tells them apart.
+	orderColumns := []string{sortColumn, "h.id"}
+	if sortColumn != "h.amount" {
+		orderColumns = append(orderColumns, "h.amount")
+	}
+	if cursor != nil {
+		var value interface{} = cursor.Timestamp
+		if cursor.Sorting == models.SortingAmount {
+			value = cursor.Amount
+		}
+		values := []string{arg(value), arg(cursor.ID)}
+		if len(orderColumns) > len(values) {
+			values = append(values, arg(cursor.Amount))
+		}
+		query += " AND (" + strings.Join(orderColumns, ", ") + ")" + comparison + "(" + strings.Join(values, ", ") + ")"
 	}
-	query += " LIMIT $4 OFFSET $5"
-	return query
+	query += " ORDER BY " + strings.Join(orderColumns, direction+", ") + direction
+	query += " LIMIT " + arg(queryParams.Limit) + " OFFSET " + arg(queryParams.Offset)
+	return query, args
 }
@@ -0,0 +1,123 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const serviceColumns = `id, title, description, active, default_price, currency, created_at, updated_at`
+
+func (db *DB) GetServices(ctx context.Context) ([]models.Service, error) {
+	query := `
+	SELECT ` + serviceColumns + `
+	FROM services
+	ORDER BY id`
+	var err error
+	for i := 0; i < retries; i++ {
+		services := make([]models.Service, 0)
+		if err = db.db.SelectContext(ctx, &services, query); err != nil {
+			err = fmt.Errorf("err executing [GetServices]: %w", err)
+			continue
+		}
+		return services, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetService(ctx context.Context, serviceID int) (*models.Service, error) {
+	query := `
+	SELECT ` + serviceColumns + `
+	FROM services
+	WHERE id = $1`
+	var err error
+	for i := 0; i < retries; i++ {
+		service := models.Service{}
+		if err = db.db.GetContext(ctx, &service, query, serviceID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrServiceNotFound
+			}
+			err = fmt.Errorf("err executing [GetService]: %w", err)
+			continue
+		}
+		return &service, nil
+	}
+	return nil, err
+}
+
+func (db *DB) CreateService(ctx context.Context, service models.Service) (*models.Service, error) {
+	query := `
+	INSERT INTO services (title, description, active, default_price, currency, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $6)
+	RETURNING ` + serviceColumns
+	var err error
+	for i := 0; i < retries; i++ {
+		created := models.Service{}
+		err = db.db.GetContext(ctx, &created, query, service.Title, service.Description, service.Active,
+			service.DefaultPrice, service.Currency, time.Now().UTC().Format(dateTimeLayout))
+		if err != nil {
+			err = fmt.Errorf("err executing [CreateService]: %w", err)
+			continue
+		}
+		return &created, nil
+	}
+	return nil, err
+}
+
+func (db *DB) UpdateService(ctx context.Context, service models.Service) (*models.Service, error) {
+	query := `
+	UPDATE services
+	SET title = $1,
+	description = $2,
+	active = $3,
+	default_price = $4,
+	currency = $5,
+	updated_at = $6
+	WHERE id = $7
+	RETURNING ` + serviceColumns
+	var err error
+	for i := 0; i < retries; i++ {
+		updated := models.Service{}
+		err = db.db.GetContext(ctx, &updated, query, service.Title, service.Description, service.Active,
+			service.DefaultPrice, service.Currency, time.Now().UTC().Format(dateTimeLayout), service.ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrServiceNotFound
+			}
+			err = fmt.Errorf("err executing [UpdateService]: %w", err)
+			continue
+		}
+		return &updated, nil
+	}
+	return nil, err
+}
+
+func (db *DB) DeleteService(ctx context.Context, serviceID int) error {
+	query := `
+	DELETE FROM services
+	WHERE id = $1`
+	var err error
+	var result sql.Result
+	for i := 0; i < retries; i++ {
+		result, err = db.db.ExecContext(ctx, query, serviceID)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.SQLState() == pgerrcode.ForeignKeyViolation {
+				return models.ErrServiceInUse
+			}
+			err = fmt.Errorf("err executing [DeleteService]: %w", err)
+			continue
+		}
+		if count, _ := result.RowsAffected(); count == 0 {
+			return models.ErrServiceNotFound
+		}
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// eventsChannel is notified with the account id of every outbox event. Notifications are sent on commit,
+// so listeners on any replica only learn about committed changes.
+const eventsChannel = "balance_events"
+
+func (db *DB) GetWallets(ctx context.Context, accountID int) ([]models.Wallet, error) {
+	query := `
+	SELECT id, owner_id, currency, balance, reserved_balance, created_at, updated_at
+	FROM wallet
+	WHERE owner_id = $1
+	ORDER BY currency`
+	var err error
+	for i := 0; i < retries; i++ {
+		wallets := make([]models.Wallet, 0)
+		if err = db.db.SelectContext(ctx, &wallets, query, accountID); err != nil {
+			err = fmt.Errorf("err executing [GetWallets]: %w", err)
+			continue
+		}
+		return wallets, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetEvents(ctx context.Context, accountID int, afterSequence int64, limit int) ([]models.Event, error) {
+	query := `
+	SELECT id, account_id, sequence, event_type, payload, created_at
+	FROM outbox
+	WHERE account_id = $1 AND sequence > $2
+	ORDER BY sequence
+	LIMIT $3`
+	var err error
+	for i := 0; i < retries; i++ {
+		events := make([]models.Event, 0)
+		if err = db.db.SelectContext(ctx, &events, query, accountID, afterSequence, limit); err != nil {
+			err = fmt.Errorf("err executing [GetEvents]: %w", err)
+			continue
+		}
+		return events, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetLastEventSequence(ctx context.Context, accountID int) (int64, error) {
+	query := `
+	SELECT last_sequence
+	FROM outbox_sequence
+	WHERE account_id = $1`
+	var err error
+	for i := 0; i < retries; i++ {
+		var sequence int64
+		if err = db.db.GetContext(ctx, &sequence, query, accountID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return 0, nil
+			}
+			err = fmt.Errorf("err executing [GetLastEventSequence]: %w", err)
+			continue
+		}
+		return sequence, nil
+	}
+	return 0, err
+}
+
+// ListenEvents blocks on a dedicated connection and calls notify with the account id of each committed event.
+// It returns when ctx is done or the connection breaks.
+func (db *DB) ListenEvents(ctx context.Context, notify func(accountID int)) error {
+	conn, err := pgx.Connect(ctx, db.dsn)
+	if err != nil {
+		return fmt.Errorf("err executing [ListenEvents]: %w", err)
+	}
+	defer func() {
+		if err := conn.Close(context.Background()); err != nil {
+			db.log.Errorf("err closing listen connection")
+		}
+	}()
+	if _, err = conn.Exec(ctx, "LISTEN "+eventsChannel); err != nil {
+		return fmt.Errorf("err executing [ListenEvents]: %w", err)
+	}
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("err executing [ListenEvents]: %w", err)
+		}
+		accountID, err := strconv.Atoi(notification.Payload)
+		if err != nil {
+			db.log.Errorf("unexpected %s payload %q", eventsChannel, notification.Payload)
+			continue
+		}
+		notify(accountID)
+	}
+}
+
+func (db *DB) notifyEvent(ctx context.Context, tx *sql.Tx, accountID int) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, eventsChannel, strconv.Itoa(accountID)); err != nil {
+		return fmt.Errorf("err executing [notifyEvent]: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (h *handler) AdminGetBalances(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.Atoi(r.URL.Query().Get("account_id"))
+	if err != nil {
+		h.writeErrResponse(w, http.StatusBadRequest, "Can't parse account_id")
+		return
+	}
+	balances, err := h.balance.GetWalletBalances(r.Context(), accountID)
+	if err != nil {
+		h.log.Errorf("Error get balances: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, balances)
+}
+
+func (h *handler) AdminGetTransactions(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.Atoi(r.URL.Query().Get("account_id"))
+	if err != nil {
+		h.writeErrResponse(w, http.StatusBadRequest, "Can't parse account_id")
+		return
+	}
+	queryParams, ok := h.parseTransactionsQuery(w, r)
+	if !ok {
+		return
+	}
+	page, err := h.balance.GetWalletTransaction(r.Context(), accountID, &queryParams)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidCurrency), errors.Is(err, models.ErrInvalidFilter),
+		errors.Is(err, models.ErrInvalidCursor):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrWalletNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrWalletNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error get wallet transactions: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, page)
+}
+
+func (h *handler) AdminGetActions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	queryParams := models.AdminActionsQueryParams{}
+	var err error
+	for key, dest := range map[string]*int{
+		"account_id": &queryParams.AccountID,
+		"admin_id":   &queryParams.AdminID,
+		"limit":      &queryParams.Limit,
+		"offset":     &queryParams.Offset,
+	} {
+		if query.Get(key) == "" {
+			continue
+		}
+		if *dest, err = strconv.Atoi(query.Get(key)); err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse "+key)
+			return
+		}
+	}
+	actions, err := h.balance.GetAdminActions(r.Context(), &queryParams)
+	if err != nil {
+		h.log.Errorf("Error get admin actions: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, actions)
+}
+
+func (h *handler) AdminDeposit(w http.ResponseWriter, r *http.Request) {
+	h.adminOperation(w, r, "deposit money", h.balance.AdminDeposit)
+}
+
+func (h *handler) AdminWithdraw(w http.ResponseWriter, r *http.Request) {
+	h.adminOperation(w, r, "withdraw money", h.balance.AdminWithdraw)
+}
+
+func (h *handler) AdminAdjustBalance(w http.ResponseWriter, r *http.Request) {
+	h.adminOperation(w, r, "adjust balance", h.balance.AdjustBalance)
+}
+
+func (h *handler) adminOperation(w http.ResponseWriter, r *http.Request, operation string,
+	apply func(ctx context.Context, adminID int, transaction models.AdminTransaction) error) {
+	transaction := models.AdminTransaction{}
+	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	ctx := r.Context()
+	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
+	err := apply(ctx, sessionInfo.AccountID, transaction)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgerrcode.UniqueViolation == pgErr.SQLState() {
+		h.writeErrResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidAmount), errors.Is(err, models.ErrInvalidCurrency),
+		errors.Is(err, models.ErrInvalidAccount), errors.Is(err, models.ErrReasonRequired),
+		errors.Is(err, models.ErrInvalidIdempotenceKey):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrCurrencyMismatch):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrCurrencyMismatch.Error())
+		return
+	case errors.Is(err, models.ErrWalletNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrWalletNotFound.Error())
+		return
+	case errors.Is(err, models.ErrNotEnoughMoney):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrNotEnoughMoney.Error())
+		return
+	default:
+		h.log.Errorf("Error %s: %v", operation, err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, map[string]interface{}{"response": "OK"})
+}
+
+func (h *handler) GetAdjustments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	queryParams := models.AdjustmentsQueryParams{Status: query.Get("status")}
+	var err error
+	for key, dest := range map[string]*int{
+		"account_id": &queryParams.AccountID,
+		"limit":      &queryParams.Limit,
+		"offset":     &queryParams.Offset,
+	} {
+		if query.Get(key) == "" {
+			continue
+		}
+		if *dest, err = strconv.Atoi(query.Get(key)); err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse "+key)
+			return
+		}
+	}
+	adjustments, err := h.balance.GetAdjustments(r.Context(), &queryParams)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidStatus):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	default:
+		h.log.Errorf("Error get adjustments: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, adjustments)
+}
+
+func (h *handler) GetAdjustment(w http.ResponseWriter, r *http.Request) {
+	adjustmentID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		h.writeErrResponse(w, http.StatusBadRequest, "Can't parse id")
+		return
+	}
+	adjustment, err := h.balance.GetAdjustment(r.Context(), adjustmentID)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrAdjustmentNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrAdjustmentNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error get adjustment: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, adjustment)
+}
+
+func (h *handler) ProposeAdjustment(w http.ResponseWriter, r *http.Request) {
+	adjustment := models.Adjustment{}
+	if err := json.NewDecoder(r.Body).Decode(&adjustment); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	ctx := r.Context()
+	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
+	created, err := h.balance.ProposeAdjustment(ctx, sessionInfo.AccountID, adjustment)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidAmount), errors.Is(err, models.ErrInvalidCurrency),
+		errors.Is(err, models.ErrInvalidAccount), errors.Is(err, models.ErrReasonRequired),
+		errors.Is(err, models.ErrInvalidAdjustment):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	default:
+		h.log.Errorf("Error propose adjustment: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, created)
+}
+
+func (h *handler) ApproveAdjustment(w http.ResponseWriter, r *http.Request) {
+	h.reviewAdjustment(w, r, "approve adjustment", h.balance.ApproveAdjustment)
+}
+
+func (h *handler) RejectAdjustment(w http.ResponseWriter, r *http.Request) {
+	h.reviewAdjustment(w, r, "reject adjustment", h.balance.RejectAdjustment)
+}
+
+func (h *handler) reviewAdjustment(w http.ResponseWriter, r *http.Request, operation string,
+	review func(ctx context.Context, adminID int, review models.AdjustmentReview) (*models.Adjustment, error)) {
+	adjustmentReview := models.AdjustmentReview{}
+	if err := json.NewDecoder(r.Body).Decode(&adjustmentReview); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	ctx := r.Context()
+	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
+	adjustment, err := review(ctx, sessionInfo.AccountID, adjustmentReview)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrAdjustmentNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrAdjustmentNotFound.Error())
+		return
+	case errors.Is(err, models.ErrSelfReview):
+		h.writeErrResponse(w, http.StatusForbidden, models.ErrSelfReview.Error())
+		return
+	case errors.Is(err, models.ErrAdjustmentNotPending):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrAdjustmentNotPending.Error())
+		return
+	case errors.Is(err, models.ErrAdjustmentExpired):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrAdjustmentExpired.Error())
+		return
+	case errors.Is(err, models.ErrWalletNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrWalletNotFound.Error())
+		return
+	case errors.Is(err, models.ErrNotEnoughMoney):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrNotEnoughMoney.Error())
+		return
+	default:
+		h.log.Errorf("Error %s: %v", operation, err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, adjustment)
+}
@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+func (h *handler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.balance.GetAPIKeys(r.Context())
+	if err != nil {
+		h.log.Errorf("Error get api keys: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, keys)
+}
+
+func (h *handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	key := models.APIKey{}
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	ctx := r.Context()
+	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
+	created, err := h.balance.CreateAPIKey(ctx, sessionInfo.AccountID, key)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidAPIKey):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	default:
+		h.log.Errorf("Error create api key: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, created)
+}
+
+func (h *handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	revocation := models.APIKeyRevocation{}
+	if err := json.NewDecoder(r.Body).Decode(&revocation); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	revoked, err := h.balance.RevokeAPIKey(r.Context(), revocation.ID)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrAPIKeyNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrAPIKeyNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error revoke api key: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, revoked)
+}
+
+func (h *handler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	rotation := models.APIKeyRotation{}
+	if err := json.NewDecoder(r.Body).Decode(&rotation); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	ctx := r.Context()
+	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
+	rotated, err := h.balance.RotateAPIKey(ctx, sessionInfo.AccountID, rotation)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidAPIKey):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrAPIKeyNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrAPIKeyNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error rotate api key: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, rotated)
+}
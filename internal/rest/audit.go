@@ -0,0 +1,223 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/DANDA322/balance-service/pkg/jsonl"
+)
+
+const (
+	auditMaxPayloadSize = 1 << 20
+	redactedValue       = "[redacted]"
+)
+
+var redactedFields = []string{"secret"}
+
+type auditRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *auditRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *auditRecorder) Write(b []byte) (int, error) {
+	if r.statusCode >= http.StatusBadRequest {
+		r.body.Write(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *auditRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// audit records every authenticated call. Calls that may change state also get the balances of the
+// affected account, which is the account_id of the request if it has one and the caller otherwise,
+// before and after the call.
+func (h *handler) audit(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
+		record := models.AuditRecord{
+			AccountID: sessionInfo.AccountID,
+			Role:      sessionInfo.Principal(),
+			SubjectID: sessionInfo.AccountID,
+			Method:    r.Method,
+			Endpoint:  r.URL.Path,
+			Payload:   r.URL.RawQuery,
+		}
+		if accountID, err := strconv.Atoi(r.URL.Query().Get("account_id")); err == nil {
+			record.SubjectID = accountID
+		}
+		mutating := r.Method != http.MethodGet
+		if mutating {
+			body, err := readBody(r, auditMaxPayloadSize)
+			if err != nil {
+				h.writeReadErrResponse(w, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			record.Payload = redactPayload(body)
+			payload := struct {
+				AccountID int `json:"account_id"`
+			}{}
+			if json.Unmarshal(body, &payload) == nil && payload.AccountID > 0 {
+				record.SubjectID = payload.AccountID
+			}
+			record.BalancesBefore = h.auditBalances(ctx, record.SubjectID)
+		}
+		recorder := &auditRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		record.StatusCode = recorder.statusCode
+		record.Outcome = auditOutcome(recorder)
+		if mutating {
+			record.BalancesAfter = h.auditBalances(ctx, record.SubjectID)
+		}
+		// The request context is already cancelled when a streaming client disconnects.
+		if err := h.balance.RecordAudit(context.Background(), record); err != nil {
+			h.log.Errorf("Error record audit: %v", err)
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+func (h *handler) auditBalances(ctx context.Context, accountID int) models.AuditBalances {
+	balances, err := h.balance.GetWalletBalances(ctx, accountID)
+	if err != nil {
+		h.log.Errorf("Error get balances for audit: %v", err)
+		return nil
+	}
+	return balances
+}
+
+func auditOutcome(recorder *auditRecorder) string {
+	if recorder.statusCode < http.StatusBadRequest {
+		return models.AuditOutcomeOK
+	}
+	response := struct {
+		Error string `json:"error"`
+	}{}
+	if json.Unmarshal(recorder.body.Bytes(), &response) == nil && response.Error != "" {
+		return response.Error
+	}
+	return http.StatusText(recorder.statusCode)
+}
+
+func redactPayload(body []byte) string {
+	fields := map[string]json.RawMessage{}
+	if json.Unmarshal(body, &fields) != nil {
+		return string(body)
+	}
+	redacted := false
+	for _, field := range redactedFields {
+		if _, ok := fields[field]; ok {
+			fields[field], _ = json.Marshal(redactedValue)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return string(body)
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func (h *handler) VerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	fromID, toID, ok := h.parseAuditRange(w, r)
+	if !ok {
+		return
+	}
+	verification, err := h.balance.VerifyAuditLog(r.Context(), fromID, toID)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidPeriod):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	default:
+		h.log.Errorf("Error verify audit log: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, verification)
+}
+
+func (h *handler) ExportAuditLog(w http.ResponseWriter, r *http.Request) {
+	fromID, toID, ok := h.parseAuditRange(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	var from, to time.Time
+	var err error
+	for key, dest := range map[string]*time.Time{"from": &from, "to": &to} {
+		if query.Get(key) == "" {
+			continue
+		}
+		if *dest, err = h.parseTime(query.Get(key), dateTimeLayout); err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse time")
+			h.log.Info(err)
+			return
+		}
+	}
+	writer := jsonl.WriterJSONL{}
+	written := false
+	start := func() {
+		if !written {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", "attachment; filename=\"audit.jsonl\"")
+			written = true
+		}
+	}
+	err = h.balance.ExportAuditLog(r.Context(), fromID, toID, from, to, func(records []models.AuditRecord) error {
+		start()
+		return writer.WriteAuditRecords(w, records)
+	})
+	switch {
+	case err == nil:
+	case written:
+		h.log.Errorf("Error export audit log: %v", err)
+		return
+	case errors.Is(err, models.ErrInvalidPeriod):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	default:
+		h.log.Errorf("Error export audit log: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	start()
+}
+
+func (h *handler) parseAuditRange(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	query := r.URL.Query()
+	var fromID, toID int64
+	var err error
+	for key, dest := range map[string]*int64{"from_id": &fromID, "to_id": &toID} {
+		if query.Get(key) == "" {
+			continue
+		}
+		if *dest, err = strconv.ParseInt(query.Get(key), 10, 64); err != nil || *dest < 0 {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse "+key)
+			return 0, 0, false
+		}
+	}
+	return fromID, toID, true
+}
@@ -1,8 +1,6 @@
 package rest
 
 import (
-	"crypto/rsa"
-	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +8,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/DANDA322/balance-service/internal/auth"
 	"github.com/DANDA322/balance-service/internal/models"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -19,32 +18,33 @@ import (
 const (
 	dateTimeLayout  = "2006-01-02T15:04:05Z"
 	yearMonthLayout = "2006-01"
-	roleAdmin       = "admin"
+	statementCSV    = "csv"
+	statementJSONL  = "jsonl"
 )
 
-//go:embed public.pub
-var publicSigningKey []byte
-
 type handler struct {
-	log     *logrus.Logger
-	balance Balance
-	pubKey  *rsa.PublicKey
+	log      *logrus.Logger
+	balance  Balance
+	verifier *auth.Verifier
 }
 
-func newHandler(log *logrus.Logger, balance Balance) *handler {
+func newHandler(log *logrus.Logger, balance Balance, verifier *auth.Verifier) *handler {
 	return &handler{
-		log:     log,
-		balance: balance,
-		pubKey:  musGetPublicKey(publicSigningKey),
+		log:      log,
+		balance:  balance,
+		verifier: verifier,
 	}
 }
 
 func (h *handler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
-	balance, err := h.balance.GetBalance(ctx, sessionInfo.AccountID)
+	balance, err := h.balance.GetBalance(ctx, sessionInfo.AccountID, r.URL.Query().Get("currency"))
 	switch {
 	case err == nil:
+	case errors.Is(err, models.ErrInvalidCurrency):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
 	case errors.Is(err, models.ErrWalletNotFound):
 		h.writeErrResponse(w, http.StatusNotFound, models.ErrWalletNotFound.Error())
 		return
@@ -53,17 +53,13 @@ func (h *handler) GetBalance(w http.ResponseWriter, r *http.Request) {
 		h.writeErrResponse(w, http.StatusInternalServerError, err)
 		return
 	}
-	result := models.Balance{
-		Currency: "RUB",
-		Amount:   balance,
-	}
-	h.writeJSONResponse(w, result)
+	h.writeJSONResponse(w, balance)
 }
 
 func (h *handler) DepositMoneyToWallet(w http.ResponseWriter, r *http.Request) {
 	transaction := models.Transaction{}
 	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
-		h.writeErrResponse(w, http.StatusBadRequest, "Can't decode json")
+		h.writeDecodeErrResponse(w, err)
 		return
 	}
 	ctx := r.Context()
@@ -76,6 +72,13 @@ func (h *handler) DepositMoneyToWallet(w http.ResponseWriter, r *http.Request) {
 	}
 	switch {
 	case err == nil:
+	case errors.Is(err, models.ErrInvalidAmount), errors.Is(err, models.ErrInvalidCurrency),
+		errors.Is(err, models.ErrInvalidIdempotenceKey):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrCurrencyMismatch):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrCurrencyMismatch.Error())
+		return
 	default:
 		h.log.Errorf("Error deposit money: %v", err)
 		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
@@ -87,7 +90,7 @@ func (h *handler) DepositMoneyToWallet(w http.ResponseWriter, r *http.Request) {
 func (h *handler) WithdrawMoneyFromWallet(w http.ResponseWriter, r *http.Request) { //nolint:dupl
 	transaction := models.Transaction{}
 	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
-		h.writeErrResponse(w, http.StatusBadRequest, "Can't decode json")
+		h.writeDecodeErrResponse(w, err)
 		h.log.Info(err)
 		return
 	}
@@ -101,6 +104,13 @@ func (h *handler) WithdrawMoneyFromWallet(w http.ResponseWriter, r *http.Request
 	}
 	switch {
 	case err == nil:
+	case errors.Is(err, models.ErrInvalidAmount), errors.Is(err, models.ErrInvalidCurrency),
+		errors.Is(err, models.ErrInvalidIdempotenceKey):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrCurrencyMismatch):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrCurrencyMismatch.Error())
+		return
 	case errors.Is(err, models.ErrWalletNotFound):
 		h.writeErrResponse(w, http.StatusNotFound, models.ErrWalletNotFound.Error())
 		return
@@ -118,7 +128,7 @@ func (h *handler) WithdrawMoneyFromWallet(w http.ResponseWriter, r *http.Request
 func (h *handler) TransferMoney(w http.ResponseWriter, r *http.Request) { //nolint:dupl
 	transaction := models.TransferTransaction{}
 	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
-		h.writeErrResponse(w, http.StatusBadRequest, "Can't decode json")
+		h.writeDecodeErrResponse(w, err)
 		h.log.Info(err)
 		return
 	}
@@ -132,12 +142,25 @@ func (h *handler) TransferMoney(w http.ResponseWriter, r *http.Request) { //noli
 	}
 	switch {
 	case err == nil:
+	case errors.Is(err, models.ErrInvalidAmount), errors.Is(err, models.ErrInvalidCurrency),
+		errors.Is(err, models.ErrInvalidIdempotenceKey):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrCurrencyMismatch):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrCurrencyMismatch.Error())
+		return
 	case errors.Is(err, models.ErrWalletNotFound):
 		h.writeErrResponse(w, http.StatusNotFound, models.ErrWalletNotFound.Error())
 		return
 	case errors.Is(err, models.ErrNotEnoughMoney):
 		h.writeErrResponse(w, http.StatusConflict, models.ErrNotEnoughMoney.Error())
 		return
+	case errors.Is(err, models.ErrRateNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrRateNotFound.Error())
+		return
+	case errors.Is(err, models.ErrQuoteNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrQuoteNotFound.Error())
+		return
 	default:
 		h.log.Errorf("Error transfer money: %v", err)
 		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
@@ -149,13 +172,14 @@ func (h *handler) TransferMoney(w http.ResponseWriter, r *http.Request) { //noli
 func (h *handler) ReserveMoney(w http.ResponseWriter, r *http.Request) {
 	transaction := models.ReserveTransaction{}
 	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
-		h.writeErrResponse(w, http.StatusBadRequest, "Can't decode json")
+		h.writeDecodeErrResponse(w, err)
 		h.log.Info(err)
 		return
 	}
 	ctx := r.Context()
 	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
-	if sessionInfo.Role != roleAdmin && transaction.AccountID != sessionInfo.AccountID {
+	if (!sessionInfo.HasScope(models.ScopeReserveAny) && transaction.AccountID != sessionInfo.AccountID) ||
+		!sessionInfo.AllowsService(transaction.ServiceID) {
 		h.writeErrResponse(w, http.StatusForbidden, "")
 		return
 	}
@@ -167,6 +191,13 @@ func (h *handler) ReserveMoney(w http.ResponseWriter, r *http.Request) {
 	}
 	switch {
 	case err == nil:
+	case errors.Is(err, models.ErrInvalidAmount), errors.Is(err, models.ErrInvalidCurrency),
+		errors.Is(err, models.ErrInvalidExpiry), errors.Is(err, models.ErrInvalidIdempotenceKey):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrCurrencyMismatch):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrCurrencyMismatch.Error())
+		return
 	case errors.Is(err, models.ErrWalletNotFound):
 		h.writeErrResponse(w, http.StatusNotFound, models.ErrWalletNotFound.Error())
 		return
@@ -184,19 +215,32 @@ func (h *handler) ReserveMoney(w http.ResponseWriter, r *http.Request) {
 func (h *handler) ApplyReservedMoney(w http.ResponseWriter, r *http.Request) {
 	transaction := models.ReserveTransaction{}
 	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
-		h.writeErrResponse(w, http.StatusBadRequest, "Can't decode json")
+		h.writeDecodeErrResponse(w, err)
 		h.log.Info(err)
 		return
 	}
 	ctx := r.Context()
 	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
-	if sessionInfo.Role != roleAdmin && transaction.AccountID != sessionInfo.AccountID {
+	if (!sessionInfo.HasScope(models.ScopeReserveAny) && transaction.AccountID != sessionInfo.AccountID) ||
+		!sessionInfo.AllowsService(transaction.ServiceID) {
 		h.writeErrResponse(w, http.StatusForbidden, "")
 		return
 	}
 	err := h.balance.ApplyReservedMoney(ctx, transaction)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgerrcode.UniqueViolation == pgErr.SQLState() {
+		h.writeErrResponse(w, http.StatusConflict, err.Error())
+		return
+	}
 	switch {
 	case err == nil:
+	case errors.Is(err, models.ErrInvalidAmount), errors.Is(err, models.ErrInvalidCurrency),
+		errors.Is(err, models.ErrIdempotenceKeyRequired), errors.Is(err, models.ErrInvalidIdempotenceKey):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrCurrencyMismatch):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrCurrencyMismatch.Error())
+		return
 	case errors.Is(err, models.ErrWalletNotFound):
 		h.writeErrResponse(w, http.StatusNotFound, models.ErrWalletNotFound.Error())
 		return
@@ -209,6 +253,9 @@ func (h *handler) ApplyReservedMoney(w http.ResponseWriter, r *http.Request) {
 	case errors.Is(err, models.ErrServiceNotFound):
 		h.writeErrResponse(w, http.StatusNotFound, models.ErrServiceNotFound.Error())
 		return
+	case errors.Is(err, models.ErrServiceInactive):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrServiceInactive.Error())
+		return
 	default:
 		h.log.Errorf("Error recognize money: %v", err)
 		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
@@ -220,43 +267,17 @@ func (h *handler) ApplyReservedMoney(w http.ResponseWriter, r *http.Request) {
 func (h *handler) GetWalletTransactions(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
-	from, err := h.parseTime(r.URL.Query().Get("from"), dateTimeLayout)
-	if err != nil {
-		h.writeErrResponse(w, http.StatusBadRequest, "Can't parse time")
-		h.log.Info(err)
-		return
-	}
-	to, err := h.parseTime(r.URL.Query().Get("to"), dateTimeLayout)
-	if err != nil {
-		h.writeErrResponse(w, http.StatusBadRequest, "Can't parse time")
-		h.log.Info(err)
-		return
-	}
-	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
-	if err != nil {
-		h.writeErrResponse(w, http.StatusInternalServerError, err)
-		return
-	}
-	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
-	if err != nil {
-		h.writeErrResponse(w, http.StatusBadRequest, err)
+	queryParams, ok := h.parseTransactionsQuery(w, r)
+	if !ok {
 		return
 	}
-	sorting := r.URL.Query().Get("sorting")
-	descending := r.URL.Query().Get("descending")
-	var transactions []models.TransactionFullInfo
-	queryParams := models.TransactionsQueryParams{
-		From:       from,
-		To:         to,
-		Limit:      limit,
-		Offset:     offset,
-		Sorting:    sorting,
-		Descending: descending,
-	}
-	h.log.Info(queryParams)
-	transactions, err = h.balance.GetWalletTransaction(ctx, sessionInfo.AccountID, &queryParams)
+	page, err := h.balance.GetWalletTransaction(ctx, sessionInfo.AccountID, &queryParams)
 	switch {
 	case err == nil:
+	case errors.Is(err, models.ErrInvalidCurrency), errors.Is(err, models.ErrInvalidFilter),
+		errors.Is(err, models.ErrInvalidCursor):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
 	case errors.Is(err, models.ErrWalletNotFound):
 		h.writeErrResponse(w, http.StatusNotFound, models.ErrWalletNotFound.Error())
 		return
@@ -265,25 +286,85 @@ func (h *handler) GetWalletTransactions(w http.ResponseWriter, r *http.Request)
 		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
 		return
 	}
-	h.writeJSONResponse(w, transactions)
+	h.writeJSONResponse(w, page)
+}
+
+func (h *handler) parseTransactionsQuery(w http.ResponseWriter, r *http.Request) (models.TransactionsQueryParams, bool) {
+	query := r.URL.Query()
+	queryParams := models.TransactionsQueryParams{
+		Currency:   query.Get("currency"),
+		Sorting:    query.Get("sorting"),
+		Descending: query.Get("descending"),
+		Cursor:     query.Get("cursor"),
+		Type:       query.Get("type"),
+		Comment:    query.Get("comment"),
+	}
+	var err error
+	for name, dst := range map[string]*time.Time{"from": &queryParams.From, "to": &queryParams.To} {
+		if query.Get(name) == "" {
+			continue
+		}
+		if *dst, err = h.parseTime(query.Get(name), dateTimeLayout); err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse time")
+			h.log.Info(err)
+			return queryParams, false
+		}
+	}
+	for name, dst := range map[string]*int{
+		"limit":        &queryParams.Limit,
+		"offset":       &queryParams.Offset,
+		"counterparty": &queryParams.Counterparty,
+		"service_id":   &queryParams.ServiceID,
+	} {
+		if query.Get(name) == "" {
+			continue
+		}
+		if *dst, err = strconv.Atoi(query.Get(name)); err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse "+name)
+			return queryParams, false
+		}
+	}
+	for name, dst := range map[string]**models.Money{
+		"min_amount": &queryParams.MinAmount,
+		"max_amount": &queryParams.MaxAmount,
+	} {
+		if query.Get(name) == "" {
+			continue
+		}
+		amount, err := models.ParseMoney(query.Get(name))
+		if err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse "+name)
+			return queryParams, false
+		}
+		*dst = &amount
+	}
+	return queryParams, true
 }
 
 func (h *handler) CancelReserve(w http.ResponseWriter, r *http.Request) {
 	transaction := models.ReserveTransaction{}
 	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
-		h.writeErrResponse(w, http.StatusBadRequest, "Can't decode json")
+		h.writeDecodeErrResponse(w, err)
 		h.log.Info(err)
 		return
 	}
 	ctx := r.Context()
 	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
-	if sessionInfo.Role != roleAdmin && transaction.AccountID != sessionInfo.AccountID {
+	if (!sessionInfo.HasScope(models.ScopeReserveAny) && transaction.AccountID != sessionInfo.AccountID) ||
+		!sessionInfo.AllowsService(transaction.ServiceID) {
 		h.writeErrResponse(w, http.StatusForbidden, "")
 		return
 	}
 	err := h.balance.CancelReserve(ctx, transaction)
 	switch {
 	case err == nil:
+	case errors.Is(err, models.ErrInvalidAmount), errors.Is(err, models.ErrInvalidCurrency),
+		errors.Is(err, models.ErrInvalidIdempotenceKey):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrCurrencyMismatch):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrCurrencyMismatch.Error())
+		return
 	case errors.Is(err, models.ErrWalletNotFound):
 		h.writeErrResponse(w, http.StatusNotFound, models.ErrWalletNotFound.Error())
 		return
@@ -301,6 +382,53 @@ func (h *handler) CancelReserve(w http.ResponseWriter, r *http.Request) {
 	h.writeJSONResponse(w, map[string]interface{}{"response": "OK"})
 }
 
+func (h *handler) RefundOrder(w http.ResponseWriter, r *http.Request) {
+	refund := models.RefundTransaction{}
+	if err := json.NewDecoder(r.Body).Decode(&refund); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	ctx := r.Context()
+	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
+	if sessionInfo.ServiceIDs != nil {
+		reservation, err := h.balance.GetReservation(ctx, refund.OrderID)
+		if err == nil && !sessionInfo.AllowsService(reservation.ServiceID) {
+			h.writeErrResponse(w, http.StatusForbidden, "")
+			return
+		}
+	}
+	err := h.balance.RefundOrder(ctx, refund)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgerrcode.UniqueViolation == pgErr.SQLState() {
+		h.writeErrResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidAmount), errors.Is(err, models.ErrInvalidIdempotenceKey):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrWalletNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrWalletNotFound.Error())
+		return
+	case errors.Is(err, models.ErrOrderNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrOrderNotFound.Error())
+		return
+	case errors.Is(err, models.ErrOrderNotCompleted):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrOrderNotCompleted.Error())
+		return
+	case errors.Is(err, models.ErrRefundExceedsCaptured):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrRefundExceedsCaptured.Error())
+		return
+	default:
+		h.log.Errorf("Error refund order: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, map[string]interface{}{"response": "OK"})
+}
+
 func (h *handler) GetReport(w http.ResponseWriter, r *http.Request) {
 	month, err := h.parseTime(r.URL.Query().Get("month"), yearMonthLayout)
 	if err != nil {
@@ -316,6 +444,178 @@ func (h *handler) GetReport(w http.ResponseWriter, r *http.Request) {
 	h.writeCSVResponse(w, file)
 }
 
+func (h *handler) GetStatement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = statementCSV
+	}
+	if format != statementCSV && format != statementJSONL {
+		h.writeErrResponse(w, http.StatusBadRequest, "Unsupported format")
+		return
+	}
+	from, err := h.parseTime(query.Get("from"), dateTimeLayout)
+	if err != nil {
+		h.writeErrResponse(w, http.StatusBadRequest, "Can't parse time")
+		h.log.Info(err)
+		return
+	}
+	var to time.Time
+	if query.Get("to") != "" {
+		if to, err = h.parseTime(query.Get("to"), dateTimeLayout); err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse time")
+			h.log.Info(err)
+			return
+		}
+	}
+	statement, err := h.balance.GetStatement(ctx, sessionInfo.AccountID, query.Get("currency"), from, to)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidCurrency), errors.Is(err, models.ErrInvalidPeriod):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrWalletNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrWalletNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error get statement: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeStatementResponse(w, format, statement)
+}
+
+func (h *handler) GetExchangeQuote(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
+	quote, err := h.balance.GetExchangeQuote(ctx, sessionInfo.AccountID, r.URL.Query().Get("from"),
+		r.URL.Query().Get("to"))
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidCurrency):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrRateNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrRateNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error get exchange quote: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, quote)
+}
+
+func (h *handler) CheckLedger(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	check, err := h.balance.CheckLedger(ctx)
+	if err != nil {
+		h.log.Errorf("Error check ledger: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, check)
+}
+
+func (h *handler) GetReservation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
+	orderID, err := strconv.Atoi(r.URL.Query().Get("order_id"))
+	if err != nil {
+		h.writeErrResponse(w, http.StatusBadRequest, "Can't parse order_id")
+		return
+	}
+	reservation, err := h.balance.GetReservation(ctx, orderID)
+	if err == nil && ((!sessionInfo.HasScope(models.ScopeReserveAny) && reservation.AccountID != sessionInfo.AccountID) ||
+		!sessionInfo.AllowsService(reservation.ServiceID)) {
+		err = models.ErrOrderNotFound
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrOrderNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrOrderNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error get reservation: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, reservation)
+}
+
+func (h *handler) GetReservations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
+	query := r.URL.Query()
+	queryParams := models.ReservationsQueryParams{
+		AccountID: sessionInfo.AccountID,
+		Status:    query.Get("status"),
+	}
+	var err error
+	if query.Get("account_id") != "" {
+		if queryParams.AccountID, err = strconv.Atoi(query.Get("account_id")); err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse account_id")
+			return
+		}
+	} else if sessionInfo.HasScope(models.ScopeReserveAny) {
+		queryParams.AccountID = 0
+	}
+	if !sessionInfo.HasScope(models.ScopeReserveAny) && queryParams.AccountID != sessionInfo.AccountID {
+		h.writeErrResponse(w, http.StatusForbidden, "")
+		return
+	}
+	if query.Get("service_id") != "" {
+		if queryParams.ServiceID, err = strconv.Atoi(query.Get("service_id")); err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse service_id")
+			return
+		}
+	}
+	if !sessionInfo.AllowsService(queryParams.ServiceID) {
+		h.writeErrResponse(w, http.StatusForbidden, "")
+		return
+	}
+	if query.Get("from") != "" {
+		if queryParams.From, err = h.parseTime(query.Get("from"), dateTimeLayout); err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse time")
+			h.log.Info(err)
+			return
+		}
+	}
+	if query.Get("to") != "" {
+		if queryParams.To, err = h.parseTime(query.Get("to"), dateTimeLayout); err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse time")
+			h.log.Info(err)
+			return
+		}
+	}
+	if query.Get("limit") != "" {
+		if queryParams.Limit, err = strconv.Atoi(query.Get("limit")); err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse limit")
+			return
+		}
+	}
+	if query.Get("offset") != "" {
+		if queryParams.Offset, err = strconv.Atoi(query.Get("offset")); err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse offset")
+			return
+		}
+	}
+	reservations, err := h.balance.GetReservations(ctx, &queryParams)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidStatus):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	default:
+		h.log.Errorf("Error get reservations: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, reservations)
+}
+
 func (h *handler) parseTime(s, layout string) (time.Time, error) {
 	t, err := time.Parse(layout, s)
 	if err != nil {
@@ -3,11 +3,16 @@ package rest
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"time"
 
+	"github.com/DANDA322/balance-service/internal/auth"
 	"github.com/DANDA322/balance-service/internal/models"
 	"github.com/DANDA322/balance-service/pkg/csv"
+	"github.com/DANDA322/balance-service/pkg/jsonl"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -16,35 +21,151 @@ import (
 
 type Balance interface {
 	AddDeposit(ctx context.Context, accountID int, transaction models.Transaction) error
-	GetBalance(ctx context.Context, accountID int) (float64, error)
+	GetBalance(ctx context.Context, accountID int, currency string) (*models.Balance, error)
 	WithdrawMoney(ctx context.Context, accountID int, transaction models.Transaction) error
 	TransferMoney(ctx context.Context, accountID int, transaction models.TransferTransaction) error
 	ReserveMoney(ctx context.Context, transaction models.ReserveTransaction) error
 	ApplyReservedMoney(ctx context.Context, transaction models.ReserveTransaction) error
 	GetWalletTransaction(ctx context.Context, accountID int,
-		queryParams *models.TransactionsQueryParams) ([]models.TransactionFullInfo, error)
+		queryParams *models.TransactionsQueryParams) (*models.TransactionsPage, error)
 	CancelReserve(ctx context.Context, transaction models.ReserveTransaction) error
-	GetReport(ctx context.Context, month time.Time) (map[string]float64, error)
+	RefundOrder(ctx context.Context, refund models.RefundTransaction) error
+	GetReport(ctx context.Context, month time.Time) ([]models.ReportRow, error)
+	GetExchangeQuote(ctx context.Context, accountID int, from, to string) (*models.ExchangeQuote, error)
+	CheckLedger(ctx context.Context) (*models.LedgerCheck, error)
+	GetStatement(ctx context.Context, accountID int, currency string, from, to time.Time) (*models.Statement, error)
+	GetReservation(ctx context.Context, orderID int) (*models.Reservation, error)
+	GetReservations(ctx context.Context, queryParams *models.ReservationsQueryParams) ([]models.Reservation, error)
+	GetServices(ctx context.Context) ([]models.Service, error)
+	GetService(ctx context.Context, serviceID int) (*models.Service, error)
+	CreateService(ctx context.Context, service models.Service) (*models.Service, error)
+	UpdateService(ctx context.Context, service models.Service) (*models.Service, error)
+	DeleteService(ctx context.Context, serviceID int) error
+	GetWebhooks(ctx context.Context) ([]models.Webhook, error)
+	GetWebhook(ctx context.Context, webhookID int) (*models.Webhook, error)
+	CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID int) error
+	GetWebhookDeliveries(ctx context.Context,
+		queryParams *models.WebhookDeliveriesQueryParams) ([]models.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, deliveryID int) (*models.WebhookDelivery, error)
+	ReplayWebhookDelivery(ctx context.Context, deliveryID int) (*models.WebhookDelivery, error)
+	SubscribeBalance(accountID int) (<-chan struct{}, func())
+	GetWalletBalances(ctx context.Context, accountID int) ([]models.WalletBalance, error)
+	GetBalanceEvents(ctx context.Context, accountID int, afterSequence int64) ([]models.Event, error)
+	GetLastEventSequence(ctx context.Context, accountID int) (int64, error)
+	AdminDeposit(ctx context.Context, adminID int, transaction models.AdminTransaction) error
+	AdminWithdraw(ctx context.Context, adminID int, transaction models.AdminTransaction) error
+	AdjustBalance(ctx context.Context, adminID int, transaction models.AdminTransaction) error
+	ProposeAdjustment(ctx context.Context, adminID int, adjustment models.Adjustment) (*models.Adjustment, error)
+	GetAdjustment(ctx context.Context, adjustmentID int) (*models.Adjustment, error)
+	GetAdjustments(ctx context.Context, queryParams *models.AdjustmentsQueryParams) ([]models.Adjustment, error)
+	ApproveAdjustment(ctx context.Context, adminID int, review models.AdjustmentReview) (*models.Adjustment, error)
+	RejectAdjustment(ctx context.Context, adminID int, review models.AdjustmentReview) (*models.Adjustment, error)
+	RecordAudit(ctx context.Context, record models.AuditRecord) error
+	VerifyAuditLog(ctx context.Context, fromID, toID int64) (*models.AuditVerification, error)
+	ExportAuditLog(ctx context.Context, fromID, toID int64, from, to time.Time,
+		write func(records []models.AuditRecord) error) error
+	GetAdminActions(ctx context.Context, queryParams *models.AdminActionsQueryParams) ([]models.AdminAction, error)
+	AuthenticateAPIKey(ctx context.Context, plain string) (models.SessionInfo, error)
+	CreateAPIKey(ctx context.Context, adminID int, key models.APIKey) (*models.APIKey, error)
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID int) (*models.APIKey, error)
+	RotateAPIKey(ctx context.Context, adminID int, rotation models.APIKeyRotation) (*models.APIKey, error)
+	StartIdempotentRequest(ctx context.Context,
+		record models.IdempotencyRecord) (models.IdempotencyRecord, bool, error)
+	FinishIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) error
+	AbortIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) error
 }
 
-func NewRouter(log *logrus.Logger, balance Balance) chi.Router {
-	handler := newHandler(log, balance)
+func NewRouter(log *logrus.Logger, balance Balance, verifier *auth.Verifier) chi.Router {
+	handler := newHandler(log, balance, verifier)
 	r := chi.NewRouter()
 	r.Use(middleware.Recoverer)
 	r.Use(cors.AllowAll().Handler)
 	r.NotFound(notFoundHandler)
 	r.Route("/wallet", func(r chi.Router) {
 		r.Use(handler.auth)
+		r.Use(handler.audit)
+		r.Use(handler.authorize)
 		r.Get("/getBalance", handler.GetBalance)
 		r.Get("/getTransactions", handler.GetWalletTransactions)
 		r.Get("/getReport", handler.GetReport)
-		r.Post("/addDeposit", handler.DepositMoneyToWallet)
-		r.Post("/withdrawMoney", handler.WithdrawMoneyFromWallet)
-		r.Post("/transferMoney", handler.TransferMoney)
-		r.Post("/reserveMoney", handler.ReserveMoney)
-		r.Post("/applyReserve", handler.ApplyReservedMoney)
-		r.Post("/cancelReserve", handler.CancelReserve)
+		r.Get("/getStatement", handler.GetStatement)
+		r.Get("/getExchangeQuote", handler.GetExchangeQuote)
+		r.Get("/checkLedger", handler.CheckLedger)
+		r.Get("/getReservation", handler.GetReservation)
+		r.Get("/getReservations", handler.GetReservations)
+		r.Get("/stream", handler.StreamBalance)
+		r.Group(func(r chi.Router) {
+			r.Use(handler.idempotency)
+			r.Post("/addDeposit", handler.DepositMoneyToWallet)
+			r.Post("/withdrawMoney", handler.WithdrawMoneyFromWallet)
+			r.Post("/transferMoney", handler.TransferMoney)
+			r.Post("/reserveMoney", handler.ReserveMoney)
+			r.Post("/applyReserve", handler.ApplyReservedMoney)
+			r.Post("/cancelReserve", handler.CancelReserve)
+			r.Post("/refund", handler.RefundOrder)
+		})
 	})
+	r.Route("/services", func(r chi.Router) {
+		r.Use(handler.auth)
+		r.Use(handler.audit)
+		r.Use(handler.authorize)
+		r.Get("/getServices", handler.GetServices)
+		r.Get("/getService", handler.GetService)
+		r.Group(func(r chi.Router) {
+			r.Use(handler.idempotency)
+			r.Post("/createService", handler.CreateService)
+			r.Post("/updateService", handler.UpdateService)
+			r.Post("/deleteService", handler.DeleteService)
+		})
+	})
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(handler.auth)
+		r.Use(handler.audit)
+		r.Use(handler.authorize)
+		r.Get("/getBalances", handler.AdminGetBalances)
+		r.Get("/getTransactions", handler.AdminGetTransactions)
+		r.Get("/getActions", handler.AdminGetActions)
+		r.Get("/getAdjustments", handler.GetAdjustments)
+		r.Get("/getAdjustment", handler.GetAdjustment)
+		r.Get("/verifyAuditLog", handler.VerifyAuditLog)
+		r.Get("/exportAuditLog", handler.ExportAuditLog)
+		r.Get("/getApiKeys", handler.GetAPIKeys)
+		// Responses with a plain key are not stored for replays, so key routes stay out of the idempotency group.
+		r.Post("/createApiKey", handler.CreateAPIKey)
+		r.Post("/rotateApiKey", handler.RotateAPIKey)
+		r.Group(func(r chi.Router) {
+			r.Use(handler.idempotency)
+			r.Post("/addDeposit", handler.AdminDeposit)
+			r.Post("/withdrawMoney", handler.AdminWithdraw)
+			r.Post("/adjustBalance", handler.AdminAdjustBalance)
+			r.Post("/proposeAdjustment", handler.ProposeAdjustment)
+			r.Post("/approveAdjustment", handler.ApproveAdjustment)
+			r.Post("/rejectAdjustment", handler.RejectAdjustment)
+			r.Post("/revokeApiKey", handler.RevokeAPIKey)
+		})
+	})
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Use(handler.auth)
+		r.Use(handler.audit)
+		r.Use(handler.authorize)
+		r.Get("/getWebhooks", handler.GetWebhooks)
+		r.Get("/getWebhook", handler.GetWebhook)
+		r.Get("/getDeliveries", handler.GetWebhookDeliveries)
+		r.Get("/getDelivery", handler.GetWebhookDelivery)
+		r.Group(func(r chi.Router) {
+			r.Use(handler.idempotency)
+			r.Post("/createWebhook", handler.CreateWebhook)
+			r.Post("/updateWebhook", handler.UpdateWebhook)
+			r.Post("/deleteWebhook", handler.DeleteWebhook)
+			r.Post("/replayDelivery", handler.ReplayWebhookDelivery)
+		})
+	})
+	if err := checkPermissions(r); err != nil {
+		log.Panic(err)
+	}
 
 	return r
 }
@@ -60,7 +181,7 @@ func (h *handler) writeJSONResponse(w http.ResponseWriter, data interface{}) {
 	}
 }
 
-func (h *handler) writeCSVResponse(w http.ResponseWriter, data map[string]float64) {
+func (h *handler) writeCSVResponse(w http.ResponseWriter, data []models.ReportRow) {
 	w.Header().Set("Content-Type", "text/csv")
 	w.Header().Set("Content-Disposition", "attachment; filename=\"report.csv\"")
 	writer := csv.WriterCSV{}
@@ -70,6 +191,56 @@ func (h *handler) writeCSVResponse(w http.ResponseWriter, data map[string]float6
 	}
 }
 
+func (h *handler) writeStatementResponse(w http.ResponseWriter, format string, statement *models.Statement) {
+	var err error
+	switch format {
+	case statementJSONL:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"statement.jsonl\"")
+		writer := jsonl.WriterJSONL{}
+		err = writer.WriteStatement(w, statement)
+	default:
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"statement.csv\"")
+		writer := csv.WriterCSV{}
+		err = writer.WriteStatement(w, statement)
+	}
+	if err != nil {
+		h.log.Errorf("err to write statement: %v", err)
+	}
+}
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// readBody reads the whole request body. A body over limit is rejected rather than cut off,
+// so middlewares never see a different payload than the handler would.
+func readBody(r *http.Request, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("%w: limit is %d bytes", errBodyTooLarge, limit)
+	}
+	return body, nil
+}
+
+func (h *handler) writeReadErrResponse(w http.ResponseWriter, err error) {
+	if errors.Is(err, errBodyTooLarge) {
+		h.writeErrResponse(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	h.writeErrResponse(w, http.StatusBadRequest, "Can't read body")
+}
+
+func (h *handler) writeDecodeErrResponse(w http.ResponseWriter, err error) {
+	if errors.Is(err, models.ErrInvalidAmount) {
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.writeErrResponse(w, http.StatusBadRequest, "Can't decode json")
+}
+
 func (h *handler) writeErrResponse(w http.ResponseWriter, code int, err interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	idempotencyMaxRequestSize = 1 << 20
+	idempotencyStoreTimeout   = 5 * time.Second
+)
+
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (h *handler) idempotency(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := readBody(r, idempotencyMaxRequestSize)
+		if err != nil {
+			h.writeReadErrResponse(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		ctx := r.Context()
+		sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
+		fingerprint := sha256.Sum256(body)
+		record, replay, err := h.balance.StartIdempotentRequest(ctx, models.IdempotencyRecord{
+			AccountID:   sessionInfo.AccountID,
+			APIKeyID:    sessionInfo.APIKeyID,
+			Key:         key,
+			Endpoint:    r.URL.Path,
+			Fingerprint: hex.EncodeToString(fingerprint[:]),
+		})
+		switch {
+		case err == nil:
+		case errors.Is(err, models.ErrInvalidIdempotencyKey):
+			h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+			return
+		case errors.Is(err, models.ErrIdempotencyKeyReused):
+			h.writeErrResponse(w, http.StatusUnprocessableEntity, models.ErrIdempotencyKeyReused.Error())
+			return
+		case errors.Is(err, models.ErrIdempotencyInProgress):
+			h.writeErrResponse(w, http.StatusConflict, models.ErrIdempotencyInProgress.Error())
+			return
+		default:
+			h.log.Errorf("Error start idempotent request: %v", err)
+			h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+			return
+		}
+		if replay {
+			w.Header().Set("Content-Type", record.ContentType)
+			w.Header().Set(idempotentReplayedHeader, "true")
+			w.WriteHeader(*record.StatusCode)
+			if _, err = w.Write(record.Body); err != nil {
+				h.log.Errorf("unable to write stored response: %v", err)
+			}
+			return
+		}
+		// The outcome is stored with a context of its own, so a client that disconnects mid-request does not
+		// leave the key in progress. The deferred abort releases the key when next panics as well.
+		finished := false
+		defer func() {
+			if finished {
+				return
+			}
+			storeCtx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+			defer cancel()
+			if err := h.balance.AbortIdempotentRequest(storeCtx, record); err != nil {
+				h.log.Errorf("Error abort idempotent request: %v", err)
+			}
+		}()
+		recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		if recorder.statusCode >= http.StatusInternalServerError {
+			return
+		}
+		record.StatusCode = &recorder.statusCode
+		record.ContentType = recorder.Header().Get("Content-Type")
+		record.Body = recorder.body.Bytes()
+		storeCtx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+		defer cancel()
+		if err = h.balance.FinishIdempotentRequest(storeCtx, record); err != nil {
+			h.log.Errorf("Error finish idempotent request: %v", err)
+			return
+		}
+		finished = true
+	}
+	return http.HandlerFunc(fn)
+}
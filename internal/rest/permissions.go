@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// permissions maps every route to the scope it requires. NewRouter refuses to start with a route missing here.
+var permissions = map[string]string{
+	"GET /wallet/getBalance":        models.ScopeWalletRead,
+	"GET /wallet/getTransactions":   models.ScopeWalletRead,
+	"GET /wallet/getStatement":      models.ScopeWalletRead,
+	"GET /wallet/getExchangeQuote":  models.ScopeWalletRead,
+	"GET /wallet/stream":            models.ScopeWalletRead,
+	"GET /wallet/getReport":         models.ScopeReportRead,
+	"GET /wallet/checkLedger":       models.ScopeLedgerRead,
+	"GET /wallet/getReservation":    models.ScopeReserveRead,
+	"GET /wallet/getReservations":   models.ScopeReserveRead,
+	"POST /wallet/addDeposit":       models.ScopeWalletDeposit,
+	"POST /wallet/withdrawMoney":    models.ScopeWalletWithdraw,
+	"POST /wallet/transferMoney":    models.ScopeWalletTransfer,
+	"POST /wallet/reserveMoney":     models.ScopeReserveWrite,
+	"POST /wallet/applyReserve":     models.ScopeReserveWrite,
+	"POST /wallet/cancelReserve":    models.ScopeReserveWrite,
+	"POST /wallet/refund":           models.ScopeReserveRefund,
+	"GET /services/getServices":     models.ScopeServiceRead,
+	"GET /services/getService":      models.ScopeServiceRead,
+	"POST /services/createService":  models.ScopeServiceWrite,
+	"POST /services/updateService":  models.ScopeServiceWrite,
+	"POST /services/deleteService":  models.ScopeServiceWrite,
+	"GET /admin/getBalances":        models.ScopeAdminRead,
+	"GET /admin/getTransactions":    models.ScopeAdminRead,
+	"GET /admin/getActions":         models.ScopeAdminRead,
+	"GET /admin/getAdjustments":     models.ScopeAdminRead,
+	"GET /admin/getAdjustment":      models.ScopeAdminRead,
+	"GET /admin/verifyAuditLog":     models.ScopeAuditRead,
+	"GET /admin/exportAuditLog":     models.ScopeAuditRead,
+	"GET /admin/getApiKeys":         models.ScopeAdminKeys,
+	"POST /admin/addDeposit":        models.ScopeAdminWrite,
+	"POST /admin/withdrawMoney":     models.ScopeAdminWrite,
+	"POST /admin/adjustBalance":     models.ScopeAdminAdjust,
+	"POST /admin/proposeAdjustment": models.ScopeAdminAdjust,
+	"POST /admin/approveAdjustment": models.ScopeAdminAdjust,
+	"POST /admin/rejectAdjustment":  models.ScopeAdminAdjust,
+	"POST /admin/createApiKey":      models.ScopeAdminKeys,
+	"POST /admin/revokeApiKey":      models.ScopeAdminKeys,
+	"POST /admin/rotateApiKey":      models.ScopeAdminKeys,
+	"GET /webhooks/getWebhooks":     models.ScopeWebhookRead,
+	"GET /webhooks/getWebhook":      models.ScopeWebhookRead,
+	"GET /webhooks/getDeliveries":   models.ScopeWebhookRead,
+	"GET /webhooks/getDelivery":     models.ScopeWebhookRead,
+	"POST /webhooks/createWebhook":  models.ScopeWebhookWrite,
+	"POST /webhooks/updateWebhook":  models.ScopeWebhookWrite,
+	"POST /webhooks/deleteWebhook":  models.ScopeWebhookWrite,
+	"POST /webhooks/replayDelivery": models.ScopeWebhookWrite,
+}
+
+func checkPermissions(r chi.Routes) error {
+	return chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if _, ok := permissions[method+" "+route]; !ok {
+			return fmt.Errorf("no permission for route %s %s", method, route)
+		}
+		return nil
+	})
+}
+
+// authorize lets unknown routes through to the router, which answers 404 or 405 for them.
+func (h *handler) authorize(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.RawPath
+		if path == "" {
+			path = r.URL.Path
+		}
+		scope, ok := permissions[r.Method+" "+path]
+		if ok && !r.Context().Value(SessionKey).(models.SessionInfo).HasScope(scope) {
+			h.writeErrResponse(w, http.StatusForbidden, fmt.Sprintf("scope %s is required", scope))
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
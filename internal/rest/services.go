@@ -0,0 +1,117 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+func (h *handler) GetServices(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	services, err := h.balance.GetServices(ctx)
+	if err != nil {
+		h.log.Errorf("Error get services: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, services)
+}
+
+func (h *handler) GetService(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	serviceID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		h.writeErrResponse(w, http.StatusBadRequest, "Can't parse id")
+		return
+	}
+	service, err := h.balance.GetService(ctx, serviceID)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrServiceNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrServiceNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error get service: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, service)
+}
+
+func (h *handler) CreateService(w http.ResponseWriter, r *http.Request) {
+	service := models.Service{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&service); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	ctx := r.Context()
+	created, err := h.balance.CreateService(ctx, service)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidService), errors.Is(err, models.ErrInvalidAmount),
+		errors.Is(err, models.ErrInvalidCurrency):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	default:
+		h.log.Errorf("Error create service: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, created)
+}
+
+func (h *handler) UpdateService(w http.ResponseWriter, r *http.Request) {
+	service := models.Service{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&service); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	ctx := r.Context()
+	updated, err := h.balance.UpdateService(ctx, service)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidService), errors.Is(err, models.ErrInvalidAmount),
+		errors.Is(err, models.ErrInvalidCurrency):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrServiceNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrServiceNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error update service: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, updated)
+}
+
+func (h *handler) DeleteService(w http.ResponseWriter, r *http.Request) {
+	service := models.Service{}
+	if err := json.NewDecoder(r.Body).Decode(&service); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	ctx := r.Context()
+	err := h.balance.DeleteService(ctx, service.ID)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrServiceNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrServiceNotFound.Error())
+		return
+	case errors.Is(err, models.ErrServiceInUse):
+		h.writeErrResponse(w, http.StatusConflict, models.ErrServiceInUse.Error())
+		return
+	default:
+		h.log.Errorf("Error delete service: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, map[string]interface{}{"response": "OK"})
+}
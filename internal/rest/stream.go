@@ -0,0 +1,124 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+const (
+	lastEventIDHeader = "Last-Event-ID"
+	streamPingPeriod  = 15 * time.Second
+	sseBalanceEvent   = "balance"
+	sseTxEvent        = "transaction"
+)
+
+// StreamBalance pushes the account's balances and events as Server-Sent Events. Events carry the per-account
+// sequence as their id, so a reconnecting client resumes after Last-Event-ID. Without it the stream starts
+// from the current state.
+func (h *handler) StreamBalance(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.writeErrResponse(w, http.StatusInternalServerError, "Streaming unsupported")
+		return
+	}
+	ctx := r.Context()
+	sessionInfo := ctx.Value(SessionKey).(models.SessionInfo)
+	lastEventID := r.Header.Get(lastEventIDHeader)
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	updates, unsubscribe := h.balance.SubscribeBalance(sessionInfo.AccountID)
+	defer unsubscribe()
+	var sequence int64
+	var err error
+	if lastEventID != "" {
+		if sequence, err = strconv.ParseInt(lastEventID, 10, 64); err != nil || sequence < 0 {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse last_event_id")
+			return
+		}
+	} else if sequence, err = h.balance.GetLastEventSequence(ctx, sessionInfo.AccountID); err != nil {
+		h.log.Errorf("Error stream balance: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err = h.writeBalanceSnapshot(w, r, sessionInfo.AccountID); err != nil {
+		h.log.Errorf("Error stream balance: %v", err)
+		return
+	}
+	ticker := time.NewTicker(streamPingPeriod)
+	defer ticker.Stop()
+	for {
+		if sequence, err = h.writeBalanceEvents(w, r, sessionInfo.AccountID, sequence); err != nil {
+			h.log.Errorf("Error stream balance: %v", err)
+			return
+		}
+		flusher.Flush()
+		select {
+		case <-ctx.Done():
+			return
+		case <-updates:
+		case <-ticker.C:
+			if _, err = fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeBalanceEvents sends every event after sequence followed by a fresh balance snapshot
+// and returns the last sent sequence.
+func (h *handler) writeBalanceEvents(w http.ResponseWriter, r *http.Request, accountID int,
+	sequence int64) (int64, error) {
+	sent := false
+	for {
+		events, err := h.balance.GetBalanceEvents(r.Context(), accountID, sequence)
+		if err != nil {
+			return sequence, err
+		}
+		for _, event := range events {
+			if err = writeSSE(w, strconv.FormatInt(event.Sequence, 10), sseTxEvent, event); err != nil {
+				return sequence, err
+			}
+			sequence = event.Sequence
+			sent = true
+		}
+		if len(events) == 0 {
+			break
+		}
+	}
+	if !sent {
+		return sequence, nil
+	}
+	return sequence, h.writeBalanceSnapshot(w, r, accountID)
+}
+
+func (h *handler) writeBalanceSnapshot(w http.ResponseWriter, r *http.Request, accountID int) error {
+	balances, err := h.balance.GetWalletBalances(r.Context(), accountID)
+	if err != nil {
+		return err
+	}
+	return writeSSE(w, "", sseBalanceEvent, balances)
+}
+
+func writeSSE(w http.ResponseWriter, id, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err = fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
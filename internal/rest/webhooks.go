@@ -0,0 +1,187 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+func (h *handler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	webhooks, err := h.balance.GetWebhooks(ctx)
+	if err != nil {
+		h.log.Errorf("Error get webhooks: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, webhooks)
+}
+
+func (h *handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	webhookID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		h.writeErrResponse(w, http.StatusBadRequest, "Can't parse id")
+		return
+	}
+	webhook, err := h.balance.GetWebhook(ctx, webhookID)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrWebhookNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrWebhookNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error get webhook: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, webhook)
+}
+
+func (h *handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook := models.Webhook{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	ctx := r.Context()
+	created, err := h.balance.CreateWebhook(ctx, webhook)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidWebhook):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	default:
+		h.log.Errorf("Error create webhook: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, created)
+}
+
+func (h *handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook := models.Webhook{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	ctx := r.Context()
+	updated, err := h.balance.UpdateWebhook(ctx, webhook)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidWebhook):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, models.ErrWebhookNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrWebhookNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error update webhook: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, updated)
+}
+
+func (h *handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook := models.Webhook{}
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	ctx := r.Context()
+	err := h.balance.DeleteWebhook(ctx, webhook.ID)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrWebhookNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrWebhookNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error delete webhook: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, map[string]interface{}{"response": "OK"})
+}
+
+func (h *handler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+	queryParams := models.WebhookDeliveriesQueryParams{Status: query.Get("status")}
+	var err error
+	for key, dest := range map[string]*int{
+		"webhook_id": &queryParams.WebhookID,
+		"limit":      &queryParams.Limit,
+		"offset":     &queryParams.Offset,
+	} {
+		if query.Get(key) == "" {
+			continue
+		}
+		if *dest, err = strconv.Atoi(query.Get(key)); err != nil {
+			h.writeErrResponse(w, http.StatusBadRequest, "Can't parse "+key)
+			return
+		}
+	}
+	deliveries, err := h.balance.GetWebhookDeliveries(ctx, &queryParams)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrInvalidStatus):
+		h.writeErrResponse(w, http.StatusBadRequest, err.Error())
+		return
+	default:
+		h.log.Errorf("Error get webhook deliveries: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, deliveries)
+}
+
+func (h *handler) GetWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	deliveryID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		h.writeErrResponse(w, http.StatusBadRequest, "Can't parse id")
+		return
+	}
+	delivery, err := h.balance.GetWebhookDelivery(ctx, deliveryID)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrDeliveryNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrDeliveryNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error get webhook delivery: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, delivery)
+}
+
+func (h *handler) ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery := models.WebhookDelivery{}
+	if err := json.NewDecoder(r.Body).Decode(&delivery); err != nil {
+		h.writeDecodeErrResponse(w, err)
+		h.log.Info(err)
+		return
+	}
+	ctx := r.Context()
+	replayed, err := h.balance.ReplayWebhookDelivery(ctx, delivery.ID)
+	switch {
+	case err == nil:
+	case errors.Is(err, models.ErrDeliveryNotFound):
+		h.writeErrResponse(w, http.StatusNotFound, models.ErrDeliveryNotFound.Error())
+		return
+	default:
+		h.log.Errorf("Error replay webhook delivery: %v", err)
+		h.writeErrResponse(w, http.StatusInternalServerError, fmt.Sprintf("Internal server error: %v", err))
+		return
+	}
+	h.writeJSONResponse(w, replayed)
+}
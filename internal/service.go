@@ -2,39 +2,142 @@ package internal
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/DANDA322/balance-service/internal/stream"
 	"github.com/sirupsen/logrus"
 )
 
 type Database interface {
-	GetWallet(ctx context.Context, accountID int) (*models.Wallet, error)
+	GetWallet(ctx context.Context, accountID int, currency string) (*models.Wallet, error)
 	UpsertDepositToWallet(ctx context.Context, accountID int, transaction models.Transaction) error
 	WithdrawMoneyFromWallet(ctx context.Context, ownerID int, transaction models.Transaction) error
-	TransferMoney(ctx context.Context, accountID int, transaction models.TransferTransaction) error
+	TransferMoney(ctx context.Context, accountID int, transaction models.TransferTransaction,
+		conversion models.Conversion) error
 	ReserveMoneyFromWallet(ctx context.Context, transaction models.ReserveTransaction) error
 	ApplyReservedMoney(ctx context.Context, transaction models.ReserveTransaction) error
-	GetWalletTransactions(ctx context.Context, accountID int,
-		queryParams *models.TransactionsQueryParams) ([]models.TransactionFullInfo, error)
+	GetWalletTransactions(ctx context.Context, accountID int, queryParams *models.TransactionsQueryParams,
+		cursor *models.TransactionsCursor) ([]models.TransactionFullInfo, error)
 	CancelReserve(ctx context.Context, transaction models.ReserveTransaction) error
-	GetReport(ctx context.Context, month time.Time) (map[string]float64, error)
+	GetExpiredReservations(ctx context.Context, now time.Time, limit int) ([]models.ReserveTransaction, error)
+	ExpireReserve(ctx context.Context, transaction models.ReserveTransaction) error
+	GetReservation(ctx context.Context, orderID int) (*models.Reservation, error)
+	GetReservations(ctx context.Context, queryParams *models.ReservationsQueryParams) ([]models.Reservation, error)
+	GetServices(ctx context.Context) ([]models.Service, error)
+	GetService(ctx context.Context, serviceID int) (*models.Service, error)
+	CreateService(ctx context.Context, service models.Service) (*models.Service, error)
+	UpdateService(ctx context.Context, service models.Service) (*models.Service, error)
+	DeleteService(ctx context.Context, serviceID int) error
+	InsertIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) error
+	DeleteIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) error
+	PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int, error)
+	RefundOrder(ctx context.Context, refund models.RefundTransaction) error
+	GetReport(ctx context.Context, month time.Time) ([]models.ReportRow, error)
+	InsertExchangeQuote(ctx context.Context, quote models.ExchangeQuote) error
+	GetExchangeQuote(ctx context.Context, accountID int, quoteID string) (*models.ExchangeQuote, error)
+	CheckLedger(ctx context.Context) (*models.LedgerCheck, error)
+	GetStatement(ctx context.Context, accountID int, currency string, from, to time.Time) (*models.Statement, error)
+	GetWebhooks(ctx context.Context) ([]models.Webhook, error)
+	GetWebhook(ctx context.Context, webhookID int) (*models.Webhook, error)
+	CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID int) error
+	GetWebhookDeliveries(ctx context.Context,
+		queryParams *models.WebhookDeliveriesQueryParams) ([]models.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, deliveryID int) (*models.WebhookDelivery, error)
+	ReplayWebhookDelivery(ctx context.Context, deliveryID int, now time.Time) error
+	GetWallets(ctx context.Context, accountID int) ([]models.Wallet, error)
+	GetEvents(ctx context.Context, accountID int, afterSequence int64, limit int) ([]models.Event, error)
+	GetLastEventSequence(ctx context.Context, accountID int) (int64, error)
+	ListenEvents(ctx context.Context, notify func(accountID int)) error
+	AdjustBalance(ctx context.Context, ownerID int, transaction models.Transaction) error
+	CreateAdjustment(ctx context.Context, adjustment models.Adjustment) (*models.Adjustment, error)
+	GetAdjustment(ctx context.Context, adjustmentID int) (*models.Adjustment, error)
+	GetAdjustments(ctx context.Context, queryParams *models.AdjustmentsQueryParams) ([]models.Adjustment, error)
+	ApproveAdjustment(ctx context.Context, reviewerID int, review models.AdjustmentReview,
+		now time.Time) (*models.Adjustment, error)
+	RejectAdjustment(ctx context.Context, reviewerID int, review models.AdjustmentReview,
+		now time.Time) (*models.Adjustment, error)
+	ExpireAdjustments(ctx context.Context, now time.Time) (int, error)
+	InsertAuditRecord(ctx context.Context, record models.AuditRecord) error
+	GetAuditRecords(ctx context.Context, queryParams *models.AuditQueryParams) ([]models.AuditRecord, error)
+	GetAuditHash(ctx context.Context, auditID int64) (string, error)
+	GetAdminActions(ctx context.Context, queryParams *models.AdminActionsQueryParams) ([]models.AdminAction, error)
+	CreateAPIKey(ctx context.Context, key models.APIKey) (*models.APIKey, error)
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	GetAPIKey(ctx context.Context, keyID int) (*models.APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID int, at time.Time) (*models.APIKey, error)
+	RotateAPIKey(ctx context.Context, key models.APIKey, revokeAt time.Time) (*models.APIKey, error)
+	TouchAPIKey(ctx context.Context, keyID int, now time.Time) error
 }
 
+type ExchangeRateProvider interface {
+	GetRate(ctx context.Context, from, to string) (models.Rate, error)
+}
+
+const (
+	quoteTTL                    = time.Minute
+	expiredBatchLimit           = 100
+	defaultReservations         = 100
+	defaultDeliveries           = 100
+	defaultAdminActions         = 100
+	defaultAdjustments          = 100
+	defaultAdjustmentTTL        = 72 * time.Hour
+	auditBatch                  = 1000
+	adjustmentComment           = "Корректировка баланса"
+	streamEventsBatch           = 100
+	listenRetryDelay            = time.Second
+	defaultTransactions         = 100
+	maxTransactions             = 1000
+	defaultIdempotencyRetention = 24 * time.Hour
+	idempotencyLease            = time.Minute
+	apiKeyTouchInterval         = time.Minute
+)
+
 type App struct {
-	log *logrus.Logger
-	db  Database
+	log                  *logrus.Logger
+	db                   Database
+	rates                ExchangeRateProvider
+	idempotencyRetention time.Duration
+	adjustmentTTL        time.Duration
+	hub                  *stream.Hub
 }
 
-func NewApp(log *logrus.Logger, db Database) *App {
+func NewApp(log *logrus.Logger, db Database, rates ExchangeRateProvider) *App {
 	return &App{
-		log: log,
-		db:  db,
+		log:                  log,
+		db:                   db,
+		rates:                rates,
+		idempotencyRetention: defaultIdempotencyRetention,
+		adjustmentTTL:        defaultAdjustmentTTL,
+		hub:                  stream.NewHub(),
 	}
 }
 
+func (a *App) SetIdempotencyRetention(retention time.Duration) {
+	a.idempotencyRetention = retention
+}
+
+func (a *App) SetAdjustmentTTL(ttl time.Duration) {
+	a.adjustmentTTL = ttl
+}
+
 func (a *App) AddDeposit(ctx context.Context, accountID int, transaction models.Transaction) error {
+	if err := transaction.Validate(); err != nil {
+		return err
+	}
 	if err := a.db.UpsertDepositToWallet(ctx, accountID, transaction); err != nil {
 		return fmt.Errorf("unable to upsert deposit: %w", err)
 	}
@@ -42,6 +145,9 @@ func (a *App) AddDeposit(ctx context.Context, accountID int, transaction models.
 }
 
 func (a *App) WithdrawMoney(ctx context.Context, accountID int, transaction models.Transaction) error {
+	if err := transaction.Validate(); err != nil {
+		return err
+	}
 	if err := a.db.WithdrawMoneyFromWallet(ctx, accountID, transaction); err != nil {
 		return fmt.Errorf("unable to withdraw money: %w", err)
 	}
@@ -49,28 +155,118 @@ func (a *App) WithdrawMoney(ctx context.Context, accountID int, transaction mode
 }
 
 func (a *App) TransferMoney(ctx context.Context, accountID int, transaction models.TransferTransaction) error {
-	if err := a.db.TransferMoney(ctx, accountID, transaction); err != nil {
+	if err := transaction.Validate(); err != nil {
+		return err
+	}
+	conversion, err := a.conversion(ctx, accountID, transaction)
+	if err != nil {
+		return fmt.Errorf("unable to transfer money: %w", err)
+	}
+	if err = a.db.TransferMoney(ctx, accountID, transaction, *conversion); err != nil {
 		return fmt.Errorf("unable to transfer money: %w", err)
 	}
 	return nil
 }
 
-func (a *App) GetBalance(ctx context.Context, accountID int) (float64, error) {
-	wallet, err := a.db.GetWallet(ctx, accountID)
+func (a *App) conversion(ctx context.Context, accountID int, transaction models.TransferTransaction) (*models.Conversion, error) {
+	conversion := models.Conversion{
+		TargetCurrency: transaction.TargetCurrency,
+		Rate:           models.IdentityRate,
+		QuoteID:        transaction.QuoteID,
+	}
+	if conversion.TargetCurrency == "" {
+		conversion.TargetCurrency = transaction.Currency
+	}
+	switch {
+	case transaction.QuoteID != "":
+		quote, err := a.db.GetExchangeQuote(ctx, accountID, transaction.QuoteID)
+		if err != nil {
+			return nil, err
+		}
+		if quote.From != transaction.Currency || quote.To != conversion.TargetCurrency {
+			return nil, models.ErrCurrencyMismatch
+		}
+		conversion.Rate = quote.Rate
+	case conversion.TargetCurrency != transaction.Currency:
+		rate, err := a.rates.GetRate(ctx, transaction.Currency, conversion.TargetCurrency)
+		if err != nil {
+			return nil, err
+		}
+		conversion.Rate = rate
+	}
+	conversion.TargetAmount = conversion.Rate.Convert(transaction.Amount)
+	if err := conversion.TargetAmount.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: converted amount is too small", models.ErrInvalidAmount)
+	}
+	return &conversion, nil
+}
+
+func (a *App) GetExchangeQuote(ctx context.Context, accountID int, from, to string) (*models.ExchangeQuote, error) {
+	if err := models.ValidateCurrency(from); err != nil {
+		return nil, err
+	}
+	if err := models.ValidateCurrency(to); err != nil {
+		return nil, err
+	}
+	rate, err := a.rates.GetRate(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get exchange rate: %w", err)
+	}
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		return nil, fmt.Errorf("unable to generate quote id: %w", err)
+	}
+	quote := models.ExchangeQuote{
+		ID:        hex.EncodeToString(id),
+		AccountID: accountID,
+		From:      from,
+		To:        to,
+		Rate:      rate,
+		ExpiresAt: time.Now().UTC().Add(quoteTTL).Truncate(time.Second),
+	}
+	if err = a.db.InsertExchangeQuote(ctx, quote); err != nil {
+		return nil, fmt.Errorf("unable to save exchange quote: %w", err)
+	}
+	return &quote, nil
+}
+
+// GetBalance falls back to the default currency for callers that predate per-currency wallets.
+func (a *App) GetBalance(ctx context.Context, accountID int, currency string) (*models.Balance, error) {
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+	if err := models.ValidateCurrency(currency); err != nil {
+		return nil, err
+	}
+	wallet, err := a.db.GetWallet(ctx, accountID, currency)
 	if err != nil {
-		return 0, fmt.Errorf("unable to get balance: %w", err)
+		return nil, fmt.Errorf("unable to get balance: %w", err)
 	}
-	return wallet.Balance, nil
+	return &models.Balance{
+		Currency: wallet.Currency,
+		Amount:   wallet.Balance,
+	}, nil
 }
 
 func (a *App) ReserveMoney(ctx context.Context, transaction models.ReserveTransaction) error {
-	if err := a.db.ReserveMoneyFromWallet(ctx, transaction); err != nil {
+	if err := transaction.Validate(); err != nil {
+		return err
+	}
+	expiresAt, err := transaction.Expiry(time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	transaction.ExpiresAt = expiresAt
+	if err = a.db.ReserveMoneyFromWallet(ctx, transaction); err != nil {
 		return fmt.Errorf("unable to reserve money: %w", err)
 	}
 	return nil
 }
 
 func (a *App) ApplyReservedMoney(ctx context.Context, transaction models.ReserveTransaction) error {
+	if err := transaction.Validate(); err != nil {
+		return err
+	}
 	if err := a.db.ApplyReservedMoney(ctx, transaction); err != nil {
 		return fmt.Errorf("unable to recognize money: %w", err)
 	}
@@ -78,25 +274,729 @@ func (a *App) ApplyReservedMoney(ctx context.Context, transaction models.Reserve
 }
 
 func (a *App) GetWalletTransaction(ctx context.Context, accountID int,
-	queryParams *models.TransactionsQueryParams) ([]models.TransactionFullInfo, error) {
-	transactions, err := a.db.GetWalletTransactions(ctx, accountID, queryParams)
+	queryParams *models.TransactionsQueryParams) (*models.TransactionsPage, error) {
+	if err := queryParams.Validate(); err != nil {
+		return nil, err
+	}
+	if queryParams.Sorting == "" {
+		queryParams.Sorting = models.SortingDate
+	}
+	if queryParams.Descending != "false" {
+		queryParams.Descending = "true"
+	}
+	var cursor *models.TransactionsCursor
+	if queryParams.Cursor != "" {
+		decoded, err := models.DecodeTransactionsCursor(queryParams.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = &decoded
+		queryParams.Sorting = decoded.Sorting
+		queryParams.Descending = strconv.FormatBool(decoded.Descending)
+		queryParams.Offset = 0
+	}
+	if queryParams.To.IsZero() {
+		queryParams.To = time.Now().UTC()
+	}
+	limit := queryParams.Limit
+	switch {
+	case limit == 0:
+		limit = defaultTransactions
+	case limit > maxTransactions:
+		limit = maxTransactions
+	}
+	queryParams.Limit = limit + 1
+	transactions, err := a.db.GetWalletTransactions(ctx, accountID, queryParams, cursor)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get transactions: %w", err)
 	}
-	return transactions, nil
+	page := &models.TransactionsPage{Transactions: transactions}
+	if len(transactions) > limit {
+		page.Transactions = transactions[:limit]
+		page.NextCursor = models.NewTransactionsCursor(queryParams.Sorting, queryParams.Descending == "true",
+			transactions[limit-1]).Encode()
+	}
+	return page, nil
 }
 
 func (a *App) CancelReserve(ctx context.Context, transaction models.ReserveTransaction) error {
+	if err := transaction.Validate(); err != nil {
+		return err
+	}
 	if err := a.db.CancelReserve(ctx, transaction); err != nil {
-		return fmt.Errorf("unable to cancel reserve")
+		return fmt.Errorf("unable to cancel reserve: %w", err)
 	}
 	return nil
 }
 
-func (a *App) GetReport(ctx context.Context, month time.Time) (map[string]float64, error) {
-	services, err := a.db.GetReport(ctx, month)
+func (a *App) GetReservation(ctx context.Context, orderID int) (*models.Reservation, error) {
+	reservation, err := a.db.GetReservation(ctx, orderID)
 	if err != nil {
-		return nil, fmt.Errorf("unable get data for report: %w", err)
+		return nil, fmt.Errorf("unable to get reservation: %w", err)
+	}
+	return reservation, nil
+}
+
+func (a *App) GetReservations(ctx context.Context,
+	queryParams *models.ReservationsQueryParams) ([]models.Reservation, error) {
+	if queryParams.Status != "" {
+		if err := models.ValidateReservationStatus(queryParams.Status); err != nil {
+			return nil, err
+		}
+	}
+	if queryParams.Limit <= 0 {
+		queryParams.Limit = defaultReservations
+	}
+	if queryParams.To.IsZero() {
+		queryParams.To = time.Now().UTC()
+	}
+	reservations, err := a.db.GetReservations(ctx, queryParams)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get reservations: %w", err)
+	}
+	return reservations, nil
+}
+
+func (a *App) GetStatement(ctx context.Context, accountID int, currency string,
+	from, to time.Time) (*models.Statement, error) {
+	if err := models.ValidateCurrency(currency); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if to.IsZero() {
+		to = now
+	}
+	if from.After(to) {
+		return nil, fmt.Errorf("%w: from is after to", models.ErrInvalidPeriod)
+	}
+	statement, err := a.db.GetStatement(ctx, accountID, currency, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get statement: %w", err)
+	}
+	if !to.Before(now) && statement.ClosingBalance != statement.WalletBalance {
+		a.log.Errorf("statement for account %d in %s closes at %s, wallet balance is %s", accountID, currency,
+			statement.ClosingBalance, statement.WalletBalance)
+		return nil, models.ErrLedgerMismatch
+	}
+	return statement, nil
+}
+
+func (a *App) GetServices(ctx context.Context) ([]models.Service, error) {
+	services, err := a.db.GetServices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get services: %w", err)
 	}
 	return services, nil
 }
+
+func (a *App) GetService(ctx context.Context, serviceID int) (*models.Service, error) {
+	service, err := a.db.GetService(ctx, serviceID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get service: %w", err)
+	}
+	return service, nil
+}
+
+func (a *App) CreateService(ctx context.Context, service models.Service) (*models.Service, error) {
+	if err := service.Validate(); err != nil {
+		return nil, err
+	}
+	created, err := a.db.CreateService(ctx, service)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create service: %w", err)
+	}
+	return created, nil
+}
+
+func (a *App) UpdateService(ctx context.Context, service models.Service) (*models.Service, error) {
+	if err := service.Validate(); err != nil {
+		return nil, err
+	}
+	updated, err := a.db.UpdateService(ctx, service)
+	if err != nil {
+		return nil, fmt.Errorf("unable to update service: %w", err)
+	}
+	return updated, nil
+}
+
+func (a *App) DeleteService(ctx context.Context, serviceID int) error {
+	if err := a.db.DeleteService(ctx, serviceID); err != nil {
+		return fmt.Errorf("unable to delete service: %w", err)
+	}
+	return nil
+}
+
+func (a *App) AdminDeposit(ctx context.Context, adminID int, transaction models.AdminTransaction) error {
+	if err := transaction.Validate(false); err != nil {
+		return err
+	}
+	if err := a.db.UpsertDepositToWallet(ctx, transaction.AccountID, transaction.Transaction(adminID)); err != nil {
+		return fmt.Errorf("unable to upsert deposit: %w", err)
+	}
+	return nil
+}
+
+func (a *App) AdminWithdraw(ctx context.Context, adminID int, transaction models.AdminTransaction) error {
+	if err := transaction.Validate(false); err != nil {
+		return err
+	}
+	if err := a.db.WithdrawMoneyFromWallet(ctx, transaction.AccountID, transaction.Transaction(adminID)); err != nil {
+		return fmt.Errorf("unable to withdraw money: %w", err)
+	}
+	return nil
+}
+
+func (a *App) AdjustBalance(ctx context.Context, adminID int, transaction models.AdminTransaction) error {
+	if err := transaction.Validate(true); err != nil {
+		return err
+	}
+	if transaction.Comment == "" {
+		transaction.Comment = adjustmentComment
+	}
+	if err := a.db.AdjustBalance(ctx, transaction.AccountID, transaction.Transaction(adminID)); err != nil {
+		return fmt.Errorf("unable to adjust balance: %w", err)
+	}
+	return nil
+}
+
+// ProposeAdjustment records a balance correction that takes effect only after another admin approves it.
+func (a *App) ProposeAdjustment(ctx context.Context, adminID int,
+	adjustment models.Adjustment) (*models.Adjustment, error) {
+	if err := adjustment.Validate(); err != nil {
+		return nil, err
+	}
+	if adjustment.Comment == "" {
+		adjustment.Comment = adjustmentComment
+	}
+	adjustment.ProposedBy = adminID
+	adjustment.CreatedAt = time.Now().UTC()
+	adjustment.ExpiresAt = adjustment.CreatedAt.Add(a.adjustmentTTL)
+	created, err := a.db.CreateAdjustment(ctx, adjustment)
+	if err != nil {
+		return nil, fmt.Errorf("unable to propose adjustment: %w", err)
+	}
+	return created, nil
+}
+
+func (a *App) GetAdjustment(ctx context.Context, adjustmentID int) (*models.Adjustment, error) {
+	adjustment, err := a.db.GetAdjustment(ctx, adjustmentID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get adjustment: %w", err)
+	}
+	return adjustment, nil
+}
+
+func (a *App) GetAdjustments(ctx context.Context,
+	queryParams *models.AdjustmentsQueryParams) ([]models.Adjustment, error) {
+	if queryParams.Status != "" {
+		if err := models.ValidateAdjustmentStatus(queryParams.Status); err != nil {
+			return nil, err
+		}
+	}
+	if queryParams.Limit <= 0 {
+		queryParams.Limit = defaultAdjustments
+	}
+	adjustments, err := a.db.GetAdjustments(ctx, queryParams)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get adjustments: %w", err)
+	}
+	return adjustments, nil
+}
+
+func (a *App) ApproveAdjustment(ctx context.Context, adminID int,
+	review models.AdjustmentReview) (*models.Adjustment, error) {
+	adjustment, err := a.db.ApproveAdjustment(ctx, adminID, review, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("unable to approve adjustment: %w", err)
+	}
+	return adjustment, nil
+}
+
+func (a *App) RejectAdjustment(ctx context.Context, adminID int,
+	review models.AdjustmentReview) (*models.Adjustment, error) {
+	adjustment, err := a.db.RejectAdjustment(ctx, adminID, review, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("unable to reject adjustment: %w", err)
+	}
+	return adjustment, nil
+}
+
+func (a *App) ExpireAdjustments(ctx context.Context) (int, error) {
+	expired, err := a.db.ExpireAdjustments(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("unable to expire adjustments: %w", err)
+	}
+	if expired > 0 {
+		record := a.systemAuditRecord("expireAdjustments", 0, map[string]int{"expired": expired})
+		if err = a.RecordAudit(ctx, record); err != nil {
+			a.log.Errorf("failed to audit expired adjustments: %v", err)
+		}
+	}
+	return expired, nil
+}
+
+// RecordAudit appends a record to the audit log. Timestamps are truncated to what the database stores,
+// so the hash can be recomputed from the stored record.
+func (a *App) RecordAudit(ctx context.Context, record models.AuditRecord) error {
+	record.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	if err := a.db.InsertAuditRecord(ctx, record); err != nil {
+		return fmt.Errorf("unable to record audit: %w", err)
+	}
+	return nil
+}
+
+// ExportAuditLog passes audit records between fromID and toID created in [from, to) to write in chain order.
+// Zero bounds are open.
+func (a *App) ExportAuditLog(ctx context.Context, fromID, toID int64, from, to time.Time,
+	write func(records []models.AuditRecord) error) error {
+	if to.IsZero() {
+		to = time.Now().UTC().Add(time.Second)
+	}
+	if from.After(to) || (toID > 0 && fromID > toID) {
+		return fmt.Errorf("%w: from is after to", models.ErrInvalidPeriod)
+	}
+	queryParams := models.AuditQueryParams{AfterID: fromID - 1, ToID: toID, From: from, To: to, Limit: auditBatch}
+	if queryParams.AfterID < 0 {
+		queryParams.AfterID = 0
+	}
+	for {
+		records, err := a.db.GetAuditRecords(ctx, &queryParams)
+		if err != nil {
+			return fmt.Errorf("unable to get audit records: %w", err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err = write(records); err != nil {
+			return fmt.Errorf("unable to write audit records: %w", err)
+		}
+		queryParams.AfterID = records[len(records)-1].ID
+	}
+}
+
+// VerifyAuditLog recomputes the hash chain between fromID and toID. A record whose hash does not match
+// its contents was edited, a record that does not link to the previous one follows a deleted record.
+func (a *App) VerifyAuditLog(ctx context.Context, fromID, toID int64) (*models.AuditVerification, error) {
+	if toID > 0 && fromID > toID {
+		return nil, fmt.Errorf("%w: from_id is after to_id", models.ErrInvalidPeriod)
+	}
+	prevHash, err := a.db.GetAuditHash(ctx, fromID-1)
+	if err != nil {
+		return nil, fmt.Errorf("unable to verify audit log: %w", err)
+	}
+	verification := &models.AuditVerification{Valid: true}
+	err = a.ExportAuditLog(ctx, fromID, toID, time.Time{}, time.Time{}, func(records []models.AuditRecord) error {
+		for _, record := range records {
+			if !verification.Valid {
+				return nil
+			}
+			switch {
+			case record.PrevHash != prevHash:
+				verification.Error = "record does not link to the previous record"
+			case record.Digest() != record.Hash:
+				verification.Error = "record hash does not match its contents"
+			default:
+				prevHash = record.Hash
+				verification.Checked++
+				continue
+			}
+			id := record.ID
+			verification.Valid = false
+			verification.InvalidID = &id
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to verify audit log: %w", err)
+	}
+	return verification, nil
+}
+
+func (a *App) systemAuditRecord(endpoint string, subjectID int, payload interface{}) models.AuditRecord {
+	data, _ := json.Marshal(payload)
+	return models.AuditRecord{
+		Role:      models.RoleSystem,
+		SubjectID: subjectID,
+		Endpoint:  endpoint,
+		Payload:   string(data),
+		Outcome:   models.AuditOutcomeOK,
+	}
+}
+
+func (a *App) auditBalances(ctx context.Context, accountID int) models.AuditBalances {
+	balances, err := a.GetWalletBalances(ctx, accountID)
+	if err != nil {
+		a.log.Errorf("failed to snapshot balances of %d for audit: %v", accountID, err)
+		return nil
+	}
+	return balances
+}
+
+func (a *App) GetAdminActions(ctx context.Context,
+	queryParams *models.AdminActionsQueryParams) ([]models.AdminAction, error) {
+	if queryParams.Limit <= 0 {
+		queryParams.Limit = defaultAdminActions
+	}
+	actions, err := a.db.GetAdminActions(ctx, queryParams)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get admin actions: %w", err)
+	}
+	return actions, nil
+}
+
+// CreateAPIKey returns the only copy of the plain key, the database keeps its hash.
+func (a *App) CreateAPIKey(ctx context.Context, adminID int, key models.APIKey) (*models.APIKey, error) {
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+	if key.ServiceIDs == nil {
+		key.ServiceIDs = models.ServiceIDList{}
+	}
+	plain, err := generateAPIKey(&key)
+	if err != nil {
+		return nil, err
+	}
+	key.CreatedBy = adminID
+	key.CreatedAt = time.Now().UTC()
+	created, err := a.db.CreateAPIKey(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create api key: %w", err)
+	}
+	created.Key = plain
+	return created, nil
+}
+
+func (a *App) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	keys, err := a.db.GetAPIKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (a *App) RevokeAPIKey(ctx context.Context, keyID int) (*models.APIKey, error) {
+	key, err := a.db.RevokeAPIKey(ctx, keyID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("unable to revoke api key: %w", err)
+	}
+	return key, nil
+}
+
+func (a *App) RotateAPIKey(ctx context.Context, adminID int, rotation models.APIKeyRotation) (*models.APIKey, error) {
+	if rotation.GracePeriod < 0 {
+		return nil, fmt.Errorf("%w: grace_period must not be negative", models.ErrInvalidAPIKey)
+	}
+	old, err := a.db.GetAPIKey(ctx, rotation.ID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get api key: %w", err)
+	}
+	key := models.APIKey{
+		ClientName:  old.ClientName,
+		Scopes:      old.Scopes,
+		ServiceIDs:  old.ServiceIDs,
+		CreatedBy:   adminID,
+		RotatedFrom: &old.ID,
+		CreatedAt:   time.Now().UTC(),
+		ExpiresAt:   old.ExpiresAt,
+	}
+	plain, err := generateAPIKey(&key)
+	if err != nil {
+		return nil, err
+	}
+	rotated, err := a.db.RotateAPIKey(ctx, key, key.CreatedAt.Add(time.Duration(rotation.GracePeriod)*time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("unable to rotate api key: %w", err)
+	}
+	rotated.Key = plain
+	return rotated, nil
+}
+
+// AuthenticateAPIKey resolves a plain key to its session. Last use is recorded at most once per apiKeyTouchInterval.
+func (a *App) AuthenticateAPIKey(ctx context.Context, plain string) (models.SessionInfo, error) {
+	prefix, _, ok := strings.Cut(strings.TrimPrefix(plain, models.APIKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(plain, models.APIKeyPrefix) {
+		return models.SessionInfo{}, models.ErrAPIKeyNotFound
+	}
+	key, err := a.db.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		return models.SessionInfo{}, fmt.Errorf("unable to get api key: %w", err)
+	}
+	now := time.Now().UTC()
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(plain)), []byte(key.Hash)) != 1 || !key.Active(now) {
+		return models.SessionInfo{}, models.ErrAPIKeyNotFound
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err = a.db.TouchAPIKey(ctx, key.ID, now); err != nil {
+			a.log.Errorf("unable to record api key use: %v", err)
+		}
+	}
+	return key.Session(), nil
+}
+
+// generateAPIKey fills the prefix and hash of key and returns the plain key "bsk_<prefix>_<secret>".
+func generateAPIKey(key *models.APIKey) (string, error) {
+	random := make([]byte, 38)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("unable to generate api key: %w", err)
+	}
+	key.Prefix = hex.EncodeToString(random[:6])
+	plain := models.APIKeyPrefix + key.Prefix + "_" + hex.EncodeToString(random[6:])
+	key.Hash = hashAPIKey(plain)
+	return plain, nil
+}
+
+func hashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// RunBalanceNotifications forwards committed balance events to stream subscribers until ctx is done.
+// After the listener reconnects every subscriber is woken up, since notifications may have been lost in between.
+func (a *App) RunBalanceNotifications(ctx context.Context) {
+	for {
+		err := a.db.ListenEvents(ctx, a.hub.Notify)
+		if ctx.Err() != nil {
+			return
+		}
+		a.log.Errorf("balance notifications interrupted: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+		a.hub.NotifyAll()
+	}
+}
+
+func (a *App) SubscribeBalance(accountID int) (<-chan struct{}, func()) {
+	return a.hub.Subscribe(accountID)
+}
+
+func (a *App) GetWalletBalances(ctx context.Context, accountID int) ([]models.WalletBalance, error) {
+	wallets, err := a.db.GetWallets(ctx, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get wallets: %w", err)
+	}
+	balances := make([]models.WalletBalance, 0, len(wallets))
+	for _, wallet := range wallets {
+		balances = append(balances, models.WalletBalance{
+			Currency:        wallet.Currency,
+			Balance:         wallet.Balance,
+			ReservedBalance: wallet.ReservedBalance,
+		})
+	}
+	return balances, nil
+}
+
+func (a *App) GetBalanceEvents(ctx context.Context, accountID int, afterSequence int64) ([]models.Event, error) {
+	events, err := a.db.GetEvents(ctx, accountID, afterSequence, streamEventsBatch)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get balance events: %w", err)
+	}
+	return events, nil
+}
+
+func (a *App) GetLastEventSequence(ctx context.Context, accountID int) (int64, error) {
+	sequence, err := a.db.GetLastEventSequence(ctx, accountID)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get last event sequence: %w", err)
+	}
+	return sequence, nil
+}
+
+// Webhook secrets are write-only and never returned by the API.
+func (a *App) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	webhooks, err := a.db.GetWebhooks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get webhooks: %w", err)
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+func (a *App) GetWebhook(ctx context.Context, webhookID int) (*models.Webhook, error) {
+	webhook, err := a.db.GetWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get webhook: %w", err)
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (a *App) CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	if err := webhook.Validate(true); err != nil {
+		return nil, err
+	}
+	created, err := a.db.CreateWebhook(ctx, webhook)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create webhook: %w", err)
+	}
+	created.Secret = ""
+	return created, nil
+}
+
+func (a *App) UpdateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	if err := webhook.Validate(false); err != nil {
+		return nil, err
+	}
+	updated, err := a.db.UpdateWebhook(ctx, webhook)
+	if err != nil {
+		return nil, fmt.Errorf("unable to update webhook: %w", err)
+	}
+	updated.Secret = ""
+	return updated, nil
+}
+
+func (a *App) DeleteWebhook(ctx context.Context, webhookID int) error {
+	if err := a.db.DeleteWebhook(ctx, webhookID); err != nil {
+		return fmt.Errorf("unable to delete webhook: %w", err)
+	}
+	return nil
+}
+
+func (a *App) GetWebhookDeliveries(ctx context.Context,
+	queryParams *models.WebhookDeliveriesQueryParams) ([]models.WebhookDelivery, error) {
+	if queryParams.Status != "" {
+		if err := models.ValidateDeliveryStatus(queryParams.Status); err != nil {
+			return nil, err
+		}
+	}
+	if queryParams.Limit <= 0 {
+		queryParams.Limit = defaultDeliveries
+	}
+	deliveries, err := a.db.GetWebhookDeliveries(ctx, queryParams)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (a *App) GetWebhookDelivery(ctx context.Context, deliveryID int) (*models.WebhookDelivery, error) {
+	delivery, err := a.db.GetWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+func (a *App) ReplayWebhookDelivery(ctx context.Context, deliveryID int) (*models.WebhookDelivery, error) {
+	if err := a.db.ReplayWebhookDelivery(ctx, deliveryID, time.Now()); err != nil {
+		return nil, fmt.Errorf("unable to replay webhook delivery: %w", err)
+	}
+	return a.GetWebhookDelivery(ctx, deliveryID)
+}
+
+// StartIdempotentRequest claims the key for a new request. When the key was already used for the same payload
+// it reports a replay together with the stored response. A claim left unfinished for idempotencyLease,
+// e.g. by a crashed replica, is taken over by the next request with the key.
+func (a *App) StartIdempotentRequest(ctx context.Context,
+	record models.IdempotencyRecord) (models.IdempotencyRecord, bool, error) {
+	if err := models.ValidateIdempotencyKey(record.Key); err != nil {
+		return record, false, err
+	}
+	now := time.Now().UTC()
+	record.LockedUntil = now.Add(idempotencyLease)
+	record.ExpiresAt = now.Add(a.idempotencyRetention)
+	existing, err := a.db.InsertIdempotencyKey(ctx, record)
+	if err != nil {
+		return record, false, fmt.Errorf("unable to store idempotency key: %w", err)
+	}
+	switch {
+	case existing == nil:
+		return record, false, nil
+	case existing.Fingerprint != record.Fingerprint:
+		return record, false, models.ErrIdempotencyKeyReused
+	case !existing.Completed():
+		return record, false, models.ErrIdempotencyInProgress
+	}
+	return *existing, true, nil
+}
+
+func (a *App) FinishIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) error {
+	if err := a.db.CompleteIdempotencyKey(ctx, record); err != nil {
+		return fmt.Errorf("unable to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (a *App) AbortIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) error {
+	if err := a.db.DeleteIdempotencyKey(ctx, record); err != nil {
+		return fmt.Errorf("unable to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (a *App) PurgeIdempotencyKeys(ctx context.Context) (int, error) {
+	purged, err := a.db.PurgeIdempotencyKeys(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("unable to purge idempotency keys: %w", err)
+	}
+	return purged, nil
+}
+
+func (a *App) ExpireReservations(ctx context.Context) (int, error) {
+	reservations, err := a.db.GetExpiredReservations(ctx, time.Now().UTC(), expiredBatchLimit)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get expired reservations: %w", err)
+	}
+	expired := 0
+	// A reservation that fails to expire must not hold up the rest of the batch, so failures are collected.
+	var failed []string
+	var failure error
+	for _, reservation := range reservations {
+		record := a.systemAuditRecord("expireReserve", reservation.AccountID,
+			map[string]int{"order_id": reservation.OrderID})
+		record.BalancesBefore = a.auditBalances(ctx, reservation.AccountID)
+		if err = a.db.ExpireReserve(ctx, reservation); err != nil {
+			if errors.Is(err, models.ErrOrderNotFound) {
+				continue
+			}
+			a.log.Errorf("failed to expire reserve %d: %v", reservation.OrderID, err)
+			failed = append(failed, strconv.Itoa(reservation.OrderID))
+			if failure == nil {
+				failure = err
+			}
+			continue
+		}
+		record.BalancesAfter = a.auditBalances(ctx, reservation.AccountID)
+		if err = a.RecordAudit(ctx, record); err != nil {
+			a.log.Errorf("failed to audit expired reserve %d: %v", reservation.OrderID, err)
+		}
+		expired++
+	}
+	if len(failed) > 0 {
+		return expired, fmt.Errorf("unable to expire reserves %s: %w", strings.Join(failed, ", "), failure)
+	}
+	return expired, nil
+}
+
+func (a *App) RefundOrder(ctx context.Context, refund models.RefundTransaction) error {
+	if err := refund.Validate(); err != nil {
+		return err
+	}
+	if err := a.db.RefundOrder(ctx, refund); err != nil {
+		return fmt.Errorf("unable to refund order: %w", err)
+	}
+	return nil
+}
+
+func (a *App) GetReport(ctx context.Context, month time.Time) ([]models.ReportRow, error) {
+	report, err := a.db.GetReport(ctx, month)
+	if err != nil {
+		return nil, fmt.Errorf("unable get data for report: %w", err)
+	}
+	return report, nil
+}
+
+func (a *App) CheckLedger(ctx context.Context) (*models.LedgerCheck, error) {
+	check, err := a.db.CheckLedger(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to check ledger: %w", err)
+	}
+	if !check.Balanced {
+		a.log.Warnf("ledger is out of balance: entries %v, wallets %v", check.UnbalancedEntries, check.MismatchedWallets)
+	}
+	return check, nil
+}
@@ -0,0 +1,84 @@
+package internal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal"
+	"github.com/DANDA322/balance-service/internal/memstore"
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/DANDA322/balance-service/pkg/logging"
+	"github.com/stretchr/testify/require"
+)
+
+type failingExpiryStore struct {
+	*memstore.DB
+	orderID int
+}
+
+func (db failingExpiryStore) ExpireReserve(ctx context.Context, transaction models.ReserveTransaction) error {
+	if transaction.OrderID == db.orderID {
+		return errors.New("deadlock detected")
+	}
+	return db.DB.ExpireReserve(ctx, transaction)
+}
+
+func TestExpireReservationsContinuesAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	db := memstore.NewMemStore()
+	require.NoError(t, db.UpsertDepositToWallet(ctx, 1, models.Transaction{IdempotenceKey: 1,
+		Amount: models.NewMoney(100, 0), Currency: "RUB"}))
+	expiresAt := time.Now().UTC().Add(-time.Minute)
+	for orderID := 1; orderID <= 3; orderID++ {
+		require.NoError(t, db.ReserveMoneyFromWallet(ctx, models.ReserveTransaction{IdempotenceKey: orderID + 1,
+			AccountID: 1, ServiceID: 1, OrderID: orderID, Amount: models.NewMoney(10, 0), Currency: "RUB",
+			ExpiresAt: &expiresAt}))
+	}
+	app := internal.NewApp(logging.GetLogger("false"), failingExpiryStore{DB: db, orderID: 1}, nil)
+	expired, err := app.ExpireReservations(ctx)
+	require.ErrorContains(t, err, "unable to expire reserves 1")
+	require.Equal(t, 2, expired)
+	for orderID, status := range map[int]string{
+		1: models.ReservationActive,
+		2: models.ReservationExpired,
+		3: models.ReservationExpired,
+	} {
+		reservation, err := db.GetReservation(ctx, orderID)
+		require.NoError(t, err)
+		require.Equal(t, status, reservation.Status)
+	}
+}
+
+func TestAPIKeySessionIsScopedToKey(t *testing.T) {
+	ctx := context.Background()
+	app := internal.NewApp(logging.GetLogger("false"), memstore.NewMemStore(), nil)
+	created, err := app.CreateAPIKey(ctx, 1, models.APIKey{ClientName: "orders",
+		Scopes: models.ScopeList{models.ScopeReserveWrite}, ServiceIDs: models.ServiceIDList{1}})
+	require.NoError(t, err)
+	session, err := app.AuthenticateAPIKey(ctx, created.Key)
+	require.NoError(t, err)
+	require.Equal(t, created.ID, session.APIKeyID)
+	require.Zero(t, session.AccountID)
+	require.Equal(t, []string{models.ScopeReserveWrite}, session.Scopes)
+	_, err = app.CreateAPIKey(ctx, 1, models.APIKey{ClientName: "orders",
+		Scopes: models.ScopeList{models.ScopeAdminAdjust}})
+	require.ErrorIs(t, err, models.ErrInvalidAPIKey)
+}
+
+func TestAdjustmentKeyNotTakenByClients(t *testing.T) {
+	ctx := context.Background()
+	app := internal.NewApp(logging.GetLogger("false"), memstore.NewMemStore(), nil)
+	require.NoError(t, app.AddDeposit(ctx, 1, models.Transaction{IdempotenceKey: 1,
+		Amount: models.NewMoney(100, 0), Currency: "RUB"}))
+	err := app.AddDeposit(ctx, 1, models.Transaction{IdempotenceKey: -1, Amount: models.NewMoney(1, 0),
+		Currency: "RUB"})
+	require.ErrorIs(t, err, models.ErrInvalidIdempotenceKey)
+	proposed, err := app.ProposeAdjustment(ctx, 10, models.Adjustment{AccountID: 1, Amount: -models.NewMoney(50, 0),
+		Currency: "RUB", Reason: "Двойное зачисление платежа", Attachment: "TICKET-1024"})
+	require.NoError(t, err)
+	approved, err := app.ApproveAdjustment(ctx, 20, models.AdjustmentReview{ID: proposed.ID})
+	require.NoError(t, err)
+	require.Equal(t, models.AdjustmentApproved, approved.Status)
+}
@@ -0,0 +1,79 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+// AdjustBalance applies a signed correction to the wallet right away, without a second admin's approval.
+func (db *DB) AdjustBalance(ctx context.Context, ownerID int, transaction models.Transaction) error {
+	var err error
+	var tx *sql.Tx
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = db.rollback(tx); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back adjustment transaction")
+				}
+			}()
+			if _, err = db.adjustBalance(ctx, tx, ownerID, transaction); err != nil {
+				return fmt.Errorf("err executing [AdjustBalance]: %w", err)
+			}
+			err = db.commit(tx)
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) GetAdminActions(ctx context.Context,
+	queryParams *models.AdminActionsQueryParams) ([]models.AdminAction, error) {
+	query := `
+	SELECT id, admin_id, account_id, action, transaction_id, currency, money(amount) AS amount, reason, created_at
+	FROM admin_actions
+	WHERE ($1 = 0 OR account_id = $1) AND
+	      ($2 = 0 OR admin_id = $2)
+	ORDER BY id DESC
+	LIMIT $3 OFFSET $4`
+	var err error
+	for i := 0; i < retries; i++ {
+		actions := make([]models.AdminAction, 0)
+		err = db.db.SelectContext(ctx, &actions, query, queryParams.AccountID, queryParams.AdminID,
+			queryParams.Limit, queryParams.Offset)
+		if err != nil {
+			err = fmt.Errorf("err executing [GetAdminActions]: %w", err)
+			continue
+		}
+		return actions, nil
+	}
+	return nil, err
+}
+
+func (db *DB) insertAdminAction(ctx context.Context, tx *sql.Tx, action string, accountID, transactionID int,
+	transaction models.Transaction) error {
+	query := `
+	INSERT INTO admin_actions (admin_id, account_id, action, transaction_id, currency, amount, reason, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := tx.ExecContext(ctx, query, transaction.Actor.AdminID, accountID, action, transactionID,
+		transaction.Currency, int64(transaction.Amount), transaction.Actor.Reason, time.Now().UTC().Format(dateTimeLayout))
+	if err != nil {
+		return fmt.Errorf("err executing [insertAdminAction]: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+const auditColumns = `id, account_id, role, subject_id, method, endpoint, payload, status_code, outcome,
+	balances_before, balances_after, created_at, prev_hash, hash`
+
+// InsertAuditRecord appends a record to the audit log and chains it to the last one.
+// The write lock taken by the transaction keeps appends in order.
+func (db *DB) InsertAuditRecord(ctx context.Context, record models.AuditRecord) error {
+	lastQuery := `
+	SELECT hash
+	FROM audit_log
+	ORDER BY id DESC
+	LIMIT 1`
+	insertQuery := `
+	INSERT INTO audit_log (account_id, role, subject_id, method, endpoint, payload, status_code, outcome,
+	                       balances_before, balances_after, created_at, prev_hash, hash)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	var err error
+	var tx *sql.Tx
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back audit transaction")
+				}
+			}()
+			record.PrevHash = ""
+			err = tx.QueryRowContext(ctx, lastQuery).Scan(&record.PrevHash)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("err executing [InsertAuditRecord]: %w", err)
+			}
+			record.Hash = record.Digest()
+			_, err = tx.ExecContext(ctx, insertQuery, record.AccountID, record.Role, record.SubjectID, record.Method,
+				record.Endpoint, record.Payload, record.StatusCode, record.Outcome, record.BalancesBefore,
+				record.BalancesAfter, record.CreatedAt.UTC().Format(dateTimeLayout), record.PrevHash, record.Hash)
+			if err != nil {
+				return fmt.Errorf("err executing [InsertAuditRecord]: %w", err)
+			}
+			err = tx.Commit()
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+// GetAuditRecords returns up to limit records with ids after AfterID in chain order.
+func (db *DB) GetAuditRecords(ctx context.Context, queryParams *models.AuditQueryParams) ([]models.AuditRecord, error) {
+	query := `
+	SELECT ` + auditColumns + `
+	FROM audit_log
+	WHERE id > $1 AND
+	      ($2 = 0 OR id <= $2) AND
+	      created_at >= $3 AND
+	      created_at < $4
+	ORDER BY id
+	LIMIT $5`
+	var err error
+	for i := 0; i < retries; i++ {
+		records := make([]models.AuditRecord, 0)
+		err = db.db.SelectContext(ctx, &records, query, queryParams.AfterID, queryParams.ToID,
+			queryParams.From.UTC().Format(dateTimeLayout), queryParams.To.UTC().Format(dateTimeLayout), queryParams.Limit)
+		if err != nil {
+			err = fmt.Errorf("err executing [GetAuditRecords]: %w", err)
+			continue
+		}
+		return records, nil
+	}
+	return nil, err
+}
+
+// GetAuditHash returns the hash of the last record with an id not greater than auditID,
+// or an empty string at the start of the chain.
+func (db *DB) GetAuditHash(ctx context.Context, auditID int64) (string, error) {
+	query := `
+	SELECT hash
+	FROM audit_log
+	WHERE id <= $1
+	ORDER BY id DESC
+	LIMIT 1`
+	var err error
+	for i := 0; i < retries; i++ {
+		var hash string
+		if err = db.db.GetContext(ctx, &hash, query, auditID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return "", nil
+			}
+			err = fmt.Errorf("err executing [GetAuditHash]: %w", err)
+			continue
+		}
+		return hash, nil
+	}
+	return "", err
+}
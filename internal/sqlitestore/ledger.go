@@ -0,0 +1,247 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// Ledger accounts hold signed postings: a user account balance is the sum of its postings,
+// so money entering the system from outside shows up as a negative external cash balance.
+const (
+	accountUserAvailable = "user_available"
+	accountUserReserved  = "user_reserved"
+	accountRevenue       = "company_revenue"
+	accountExternalCash  = "external_cash"
+	accountFXClearing    = "fx_clearing"
+	accountAdjustments   = "balance_adjustments"
+
+	systemOwnerID = 0
+)
+
+const (
+	entryDeposit       = "deposit"
+	entryWithdrawal    = "withdrawal"
+	entryTransfer      = "transfer"
+	entryReserve       = "reserve"
+	entryReserveApply  = "reserve_apply"
+	entryReserveCancel = "reserve_cancel"
+	entryReserveExpire = "reserve_expire"
+	entryRefund        = "refund"
+	entryAdjustment    = "adjustment"
+)
+
+type posting struct {
+	accountType string
+	ownerID     int
+	currency    string
+	amount      models.Money
+}
+
+func available(ownerID int, currency string, amount models.Money) posting {
+	return posting{accountType: accountUserAvailable, ownerID: ownerID, currency: currency, amount: amount}
+}
+
+func reserved(ownerID int, currency string, amount models.Money) posting {
+	return posting{accountType: accountUserReserved, ownerID: ownerID, currency: currency, amount: amount}
+}
+
+func revenue(currency string, amount models.Money) posting {
+	return posting{accountType: accountRevenue, ownerID: systemOwnerID, currency: currency, amount: amount}
+}
+
+func externalCash(currency string, amount models.Money) posting {
+	return posting{accountType: accountExternalCash, ownerID: systemOwnerID, currency: currency, amount: amount}
+}
+
+func fxClearing(currency string, amount models.Money) posting {
+	return posting{accountType: accountFXClearing, ownerID: systemOwnerID, currency: currency, amount: amount}
+}
+
+func adjustments(currency string, amount models.Money) posting {
+	return posting{accountType: accountAdjustments, ownerID: systemOwnerID, currency: currency, amount: amount}
+}
+
+func transferPostings(accountID int, transaction models.TransferTransaction, conversion models.Conversion) []posting {
+	if conversion.TargetCurrency == transaction.Currency {
+		return []posting{
+			available(accountID, transaction.Currency, -transaction.Amount),
+			available(transaction.Target, conversion.TargetCurrency, conversion.TargetAmount),
+		}
+	}
+	return []posting{
+		available(accountID, transaction.Currency, -transaction.Amount),
+		fxClearing(transaction.Currency, transaction.Amount),
+		fxClearing(conversion.TargetCurrency, -conversion.TargetAmount),
+		available(transaction.Target, conversion.TargetCurrency, conversion.TargetAmount),
+	}
+}
+
+func transactionReference(transactionID int) string {
+	return fmt.Sprintf("transaction:%d", transactionID)
+}
+
+func orderReference(orderID int) string {
+	return fmt.Sprintf("order:%d", orderID)
+}
+
+func (db *DB) insertJournalEntry(ctx context.Context, tx *sql.Tx, kind, reference string, transactionID *int,
+	postings ...posting) error {
+	query := `
+	INSERT INTO journal_entry (kind, reference, transaction_id, created_at)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id`
+	var entryID int
+	err := tx.QueryRowContext(ctx, query, kind, reference, transactionID,
+		time.Now().UTC().Format(dateTimeLayout)).Scan(&entryID)
+	if err != nil {
+		return fmt.Errorf("err executing [insertJournalEntry]: %w", err)
+	}
+	query = `
+	INSERT INTO posting (entry_id, account_id, amount)
+	VALUES ($1, $2, $3)`
+	for _, p := range postings {
+		var accountID int
+		accountID, err = db.ledgerAccountID(ctx, tx, p.accountType, p.ownerID, p.currency)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, query, entryID, accountID, int64(p.amount)); err != nil {
+			return fmt.Errorf("err executing [insertJournalEntry]: %w", err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) ledgerAccountID(ctx context.Context, tx *sql.Tx, accountType string, ownerID int,
+	currency string) (int, error) {
+	query := `
+	INSERT INTO ledger_account (type, owner_id, currency)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (type, owner_id, currency) DO UPDATE SET type = excluded.type
+	RETURNING id`
+	var id int
+	if err := tx.QueryRowContext(ctx, query, accountType, ownerID, currency).Scan(&id); err != nil {
+		return 0, fmt.Errorf("err executing [ledgerAccountID]: %w", err)
+	}
+	return id, nil
+}
+
+func (db *DB) CheckLedger(ctx context.Context) (*models.LedgerCheck, error) {
+	entriesQuery := `
+	SELECT DISTINCT p.entry_id
+	FROM posting p
+	INNER JOIN ledger_account a ON a.id = p.account_id
+	GROUP BY p.entry_id, a.currency
+	HAVING SUM(p.amount) <> 0
+	ORDER BY p.entry_id`
+	walletsQuery := `
+	SELECT w.id
+	FROM wallet w
+	LEFT JOIN (SELECT a.owner_id, a.currency,
+	                  SUM(CASE WHEN a.type = $1 THEN p.amount ELSE 0 END) AS available,
+	                  SUM(CASE WHEN a.type = $2 THEN p.amount ELSE 0 END) AS reserved
+	           FROM ledger_account a
+	           INNER JOIN posting p ON p.account_id = a.id
+	           WHERE a.type IN ($1, $2)
+	           GROUP BY a.owner_id, a.currency) l ON l.owner_id = w.owner_id AND l.currency = w.currency
+	WHERE w.balance <> COALESCE(l.available, 0) OR
+	      w.reserved_balance <> COALESCE(l.reserved, 0)
+	ORDER BY w.id`
+	var err error
+	for i := 0; i < retries; i++ {
+		check := models.LedgerCheck{
+			UnbalancedEntries: make([]int, 0),
+			MismatchedWallets: make([]int, 0),
+		}
+		if err = db.db.SelectContext(ctx, &check.UnbalancedEntries, entriesQuery); err != nil {
+			err = fmt.Errorf("err executing [CheckLedger]: %w", err)
+			continue
+		}
+		if err = db.db.SelectContext(ctx, &check.MismatchedWallets, walletsQuery, accountUserAvailable,
+			accountUserReserved); err != nil {
+			err = fmt.Errorf("err executing [CheckLedger]: %w", err)
+			continue
+		}
+		check.Balanced = len(check.UnbalancedEntries) == 0 && len(check.MismatchedWallets) == 0
+		return &check, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetStatement(ctx context.Context, accountID int, currency string,
+	from, to time.Time) (*models.Statement, error) {
+	walletQuery := `
+	SELECT money(balance)
+	FROM wallet
+	WHERE owner_id = $1 AND currency = $2`
+	openingQuery := `
+	SELECT money(COALESCE(SUM(p.amount), 0))
+	FROM posting p
+	INNER JOIN ledger_account a ON a.id = p.account_id
+	INNER JOIN journal_entry je ON je.id = p.entry_id
+	WHERE a.type = $1 AND a.owner_id = $2 AND a.currency = $3 AND je.created_at < $4`
+	linesQuery := `
+	SELECT je.id AS entry_id, je.kind, je.reference, je.transaction_id, COALESCE(t.comment, '') AS comment,
+	       money(SUM(p.amount)) AS amount, je.created_at
+	FROM posting p
+	INNER JOIN ledger_account a ON a.id = p.account_id
+	INNER JOIN journal_entry je ON je.id = p.entry_id
+	LEFT JOIN "transaction" t ON t.id = je.transaction_id
+	WHERE a.type = $1 AND a.owner_id = $2 AND a.currency = $3 AND je.created_at >= $4 AND je.created_at < $5
+	GROUP BY je.id, je.kind, je.reference, je.transaction_id, t.comment, je.created_at
+	ORDER BY je.created_at, je.id`
+	var err error
+	var tx *sqlx.Tx
+	var statement *models.Statement
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back statement transaction")
+				}
+			}()
+			statement = &models.Statement{
+				AccountID: accountID,
+				Currency:  currency,
+				From:      from,
+				To:        to,
+				Lines:     make([]models.StatementLine, 0),
+			}
+			err = tx.QueryRowContext(ctx, walletQuery, accountID, currency).Scan(&statement.WalletBalance)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return models.ErrWalletNotFound
+				}
+				return fmt.Errorf("err executing [GetStatement]: %w", err)
+			}
+			err = tx.QueryRowContext(ctx, openingQuery, accountUserAvailable, accountID, currency,
+				from.UTC().Format(dateTimeLayout)).
+				Scan(&statement.OpeningBalance)
+			if err != nil {
+				return fmt.Errorf("err executing [GetStatement]: %w", err)
+			}
+			err = tx.SelectContext(ctx, &statement.Lines, linesQuery, accountUserAvailable, accountID, currency,
+				from.UTC().Format(dateTimeLayout), to.UTC().Format(dateTimeLayout))
+			if err != nil {
+				return fmt.Errorf("err executing [GetStatement]: %w", err)
+			}
+			return tx.Commit()
+		}()
+		if err != nil {
+			continue
+		}
+		statement.Close()
+		return statement, nil
+	}
+	return nil, err
+}
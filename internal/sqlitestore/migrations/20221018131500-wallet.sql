@@ -0,0 +1,51 @@
+-- +migrate Up
+-- Amounts are stored as integers in minor units and timestamps as UTC text that sorts in time order.
+CREATE TABLE IF NOT EXISTS wallet
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    owner_id         INTEGER UNIQUE                      NOT NULL,
+    balance          INTEGER                             NOT NULL,
+    reserved_balance INTEGER                             NOT NULL,
+    created_at       TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at       TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS services
+(
+    id    INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    title TEXT                              NOT NULL
+);
+
+CREATE TABLE "transaction"
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    idempotence_key  INTEGER UNIQUE                      NOT NULL,
+    wallet_id        INTEGER REFERENCES wallet (id)      NOT NULL,
+    amount           INTEGER                             NOT NULL,
+    target_wallet_id INTEGER REFERENCES wallet (id),
+    service_id       INTEGER REFERENCES services (id),
+    comment          TEXT                                NOT NULL,
+    timestamp        TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE reserved_funds
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT     NOT NULL,
+    order_id   INTEGER UNIQUE                        NOT NULL,
+    owner_id   INTEGER REFERENCES wallet (owner_id)  NOT NULL,
+    service_id INTEGER REFERENCES services (id)      NOT NULL,
+    amount     INTEGER                               NOT NULL,
+    status     TEXT                                  NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP   NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP   NOT NULL
+);
+
+INSERT INTO services (title)
+VALUES ('Услуга');
+
+
+-- +migrate Down
+DROP TABLE reserved_funds;
+DROP TABLE "transaction";
+DROP TABLE services;
+DROP TABLE wallet;
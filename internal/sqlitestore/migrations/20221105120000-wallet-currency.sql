@@ -0,0 +1,81 @@
+-- +migrate Up
+-- SQLite cannot change constraints in place, the tables are rebuilt. Migrations run with foreign keys off.
+CREATE TABLE wallet_new
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    owner_id         INTEGER                             NOT NULL,
+    balance          INTEGER                             NOT NULL,
+    reserved_balance INTEGER                             NOT NULL,
+    created_at       TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at       TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    currency         TEXT                                NOT NULL,
+    UNIQUE (owner_id, currency)
+);
+
+INSERT INTO wallet_new (id, owner_id, balance, reserved_balance, created_at, updated_at, currency)
+SELECT id, owner_id, balance, reserved_balance, created_at, updated_at, 'RUB' FROM wallet;
+
+DROP TABLE wallet;
+ALTER TABLE wallet_new RENAME TO wallet;
+
+CREATE TABLE reserved_funds_new
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    order_id   INTEGER UNIQUE                      NOT NULL,
+    owner_id   INTEGER                             NOT NULL,
+    service_id INTEGER REFERENCES services (id)    NOT NULL,
+    amount     INTEGER                             NOT NULL,
+    status     TEXT                                NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    currency   TEXT                                NOT NULL,
+    FOREIGN KEY (owner_id, currency) REFERENCES wallet (owner_id, currency)
+);
+
+INSERT INTO reserved_funds_new (id, order_id, owner_id, service_id, amount, status, created_at, updated_at, currency)
+SELECT id, order_id, owner_id, service_id, amount, status, created_at, updated_at, 'RUB' FROM reserved_funds;
+
+DROP TABLE reserved_funds;
+ALTER TABLE reserved_funds_new RENAME TO reserved_funds;
+
+
+-- +migrate Down
+DELETE FROM reserved_funds WHERE currency <> 'RUB';
+DELETE FROM "transaction"
+WHERE wallet_id IN (SELECT id FROM wallet WHERE currency <> 'RUB')
+   OR target_wallet_id IN (SELECT id FROM wallet WHERE currency <> 'RUB');
+DELETE FROM wallet WHERE currency <> 'RUB';
+
+CREATE TABLE reserved_funds_old
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT     NOT NULL,
+    order_id   INTEGER UNIQUE                        NOT NULL,
+    owner_id   INTEGER REFERENCES wallet (owner_id)  NOT NULL,
+    service_id INTEGER REFERENCES services (id)      NOT NULL,
+    amount     INTEGER                               NOT NULL,
+    status     TEXT                                  NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP   NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP   NOT NULL
+);
+
+INSERT INTO reserved_funds_old (id, order_id, owner_id, service_id, amount, status, created_at, updated_at)
+SELECT id, order_id, owner_id, service_id, amount, status, created_at, updated_at FROM reserved_funds;
+
+DROP TABLE reserved_funds;
+ALTER TABLE reserved_funds_old RENAME TO reserved_funds;
+
+CREATE TABLE wallet_old
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    owner_id         INTEGER UNIQUE                      NOT NULL,
+    balance          INTEGER                             NOT NULL,
+    reserved_balance INTEGER                             NOT NULL,
+    created_at       TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at       TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+INSERT INTO wallet_old (id, owner_id, balance, reserved_balance, created_at, updated_at)
+SELECT id, owner_id, balance, reserved_balance, created_at, updated_at FROM wallet;
+
+DROP TABLE wallet;
+ALTER TABLE wallet_old RENAME TO wallet;
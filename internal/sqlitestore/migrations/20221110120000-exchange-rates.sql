@@ -0,0 +1,25 @@
+-- +migrate Up
+-- Exchange rates are stored as integers with 8 fractional digits.
+ALTER TABLE "transaction" ADD COLUMN target_amount INTEGER;
+ALTER TABLE "transaction" ADD COLUMN exchange_rate INTEGER;
+ALTER TABLE "transaction" ADD COLUMN quote_id TEXT;
+
+CREATE TABLE exchange_quote
+(
+    id            TEXT PRIMARY KEY                    NOT NULL,
+    owner_id      INTEGER                             NOT NULL,
+    from_currency TEXT                                NOT NULL,
+    to_currency   TEXT                                NOT NULL,
+    rate          INTEGER                             NOT NULL,
+    expires_at    TIMESTAMP                           NOT NULL,
+    used_at       TIMESTAMP,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+-- +migrate Down
+DROP TABLE exchange_quote;
+
+ALTER TABLE "transaction" DROP COLUMN quote_id;
+ALTER TABLE "transaction" DROP COLUMN exchange_rate;
+ALTER TABLE "transaction" DROP COLUMN target_amount;
@@ -0,0 +1,64 @@
+-- +migrate Up
+CREATE TABLE ledger_account
+(
+    id       INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    type     TEXT                              NOT NULL,
+    owner_id INTEGER                           NOT NULL,
+    currency TEXT                              NOT NULL,
+    UNIQUE (type, owner_id, currency)
+);
+
+CREATE TABLE journal_entry
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    kind           TEXT                                NOT NULL,
+    reference      TEXT                                NOT NULL,
+    transaction_id INTEGER REFERENCES "transaction" (id),
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE posting
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT      NOT NULL,
+    entry_id   INTEGER REFERENCES journal_entry (id)  NOT NULL,
+    account_id INTEGER REFERENCES ledger_account (id) NOT NULL,
+    amount     INTEGER                                NOT NULL
+);
+
+CREATE INDEX posting_entry_id_idx ON posting (entry_id);
+CREATE INDEX posting_account_id_idx ON posting (account_id);
+
+INSERT INTO ledger_account (type, owner_id, currency)
+SELECT 'user_available', owner_id, currency FROM wallet
+UNION ALL
+SELECT 'user_reserved', owner_id, currency FROM wallet
+UNION ALL
+SELECT DISTINCT 'external_cash', 0, currency FROM wallet;
+
+INSERT INTO journal_entry (kind, reference)
+SELECT 'opening_balance', 'wallet:' || id FROM wallet;
+
+INSERT INTO posting (entry_id, account_id, amount)
+SELECT je.id, la.id, w.balance
+FROM wallet w
+INNER JOIN journal_entry je ON je.kind = 'opening_balance' AND je.reference = 'wallet:' || w.id
+INNER JOIN ledger_account la ON la.type = 'user_available' AND la.owner_id = w.owner_id AND la.currency = w.currency
+UNION ALL
+SELECT je.id, la.id, w.reserved_balance
+FROM wallet w
+INNER JOIN journal_entry je ON je.kind = 'opening_balance' AND je.reference = 'wallet:' || w.id
+INNER JOIN ledger_account la ON la.type = 'user_reserved' AND la.owner_id = w.owner_id AND la.currency = w.currency
+UNION ALL
+SELECT je.id, la.id, -(w.balance + w.reserved_balance)
+FROM wallet w
+INNER JOIN journal_entry je ON je.kind = 'opening_balance' AND je.reference = 'wallet:' || w.id
+INNER JOIN ledger_account la ON la.type = 'external_cash' AND la.owner_id = 0 AND la.currency = w.currency;
+
+-- SQLite has no deferred triggers to check that an entry balances once all of its postings are in,
+-- CheckLedger reports unbalanced entries instead.
+
+
+-- +migrate Down
+DROP TABLE posting;
+DROP TABLE journal_entry;
+DROP TABLE ledger_account;
@@ -0,0 +1,22 @@
+-- +migrate Up
+ALTER TABLE "transaction" ADD COLUMN reversal_of INTEGER REFERENCES "transaction" (id);
+
+CREATE TABLE refund
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT            NOT NULL,
+    order_id       INTEGER REFERENCES reserved_funds (order_id) NOT NULL,
+    owner_id       INTEGER                                      NOT NULL,
+    service_id     INTEGER REFERENCES services (id)             NOT NULL,
+    currency       TEXT                                         NOT NULL,
+    amount         INTEGER                                      NOT NULL,
+    transaction_id INTEGER REFERENCES "transaction" (id)        NOT NULL,
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP          NOT NULL
+);
+
+CREATE INDEX refund_order_id_idx ON refund (order_id);
+
+
+-- +migrate Down
+DROP TABLE refund;
+
+ALTER TABLE "transaction" DROP COLUMN reversal_of;
@@ -0,0 +1,32 @@
+-- +migrate Up
+ALTER TABLE reserved_funds ADD COLUMN captured_amount INTEGER DEFAULT 0 NOT NULL;
+
+UPDATE reserved_funds SET captured_amount = amount WHERE status = 'Completed';
+
+CREATE TABLE capture
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT            NOT NULL,
+    order_id       INTEGER REFERENCES reserved_funds (order_id) NOT NULL,
+    service_id     INTEGER REFERENCES services (id)             NOT NULL,
+    currency       TEXT                                         NOT NULL,
+    amount         INTEGER                                      NOT NULL,
+    transaction_id INTEGER REFERENCES "transaction" (id),
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP          NOT NULL
+);
+
+CREATE INDEX capture_order_id_idx ON capture (order_id);
+
+INSERT INTO capture (order_id, service_id, currency, amount, transaction_id, created_at)
+SELECT r.order_id, r.service_id, r.currency, r.amount,
+       (SELECT MIN(t.id)
+        FROM "transaction" t
+        WHERE t.idempotence_key = r.order_id AND t.service_id IS NOT NULL AND t.reversal_of IS NULL),
+       r.updated_at
+FROM reserved_funds r
+WHERE r.status = 'Completed';
+
+
+-- +migrate Down
+DROP TABLE capture;
+
+ALTER TABLE reserved_funds DROP COLUMN captured_amount;
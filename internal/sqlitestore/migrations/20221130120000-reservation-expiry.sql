@@ -0,0 +1,66 @@
+-- +migrate Up
+ALTER TABLE reserved_funds ADD COLUMN expires_at TIMESTAMP;
+
+CREATE INDEX reserved_funds_expires_at_idx ON reserved_funds (expires_at) WHERE status = 'Active';
+
+CREATE TABLE transaction_new
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    idempotence_key  INTEGER UNIQUE,
+    wallet_id        INTEGER REFERENCES wallet (id)      NOT NULL,
+    amount           INTEGER                             NOT NULL,
+    target_wallet_id INTEGER REFERENCES wallet (id),
+    service_id       INTEGER REFERENCES services (id),
+    comment          TEXT                                NOT NULL,
+    timestamp        TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    target_amount    INTEGER,
+    exchange_rate    INTEGER,
+    quote_id         TEXT,
+    reversal_of      INTEGER REFERENCES "transaction" (id)
+);
+
+INSERT INTO transaction_new (id, idempotence_key, wallet_id, amount, target_wallet_id, service_id, comment, timestamp,
+                             target_amount, exchange_rate, quote_id, reversal_of)
+SELECT id, idempotence_key, wallet_id, amount, target_wallet_id, service_id, comment, timestamp,
+       target_amount, exchange_rate, quote_id, reversal_of
+FROM "transaction";
+
+DROP TABLE "transaction";
+ALTER TABLE transaction_new RENAME TO "transaction";
+
+
+-- +migrate Down
+UPDATE journal_entry
+SET transaction_id = NULL
+WHERE transaction_id IN (SELECT id FROM "transaction" WHERE idempotence_key IS NULL);
+
+DELETE FROM "transaction" WHERE idempotence_key IS NULL;
+
+CREATE TABLE transaction_old
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    idempotence_key  INTEGER UNIQUE                      NOT NULL,
+    wallet_id        INTEGER REFERENCES wallet (id)      NOT NULL,
+    amount           INTEGER                             NOT NULL,
+    target_wallet_id INTEGER REFERENCES wallet (id),
+    service_id       INTEGER REFERENCES services (id),
+    comment          TEXT                                NOT NULL,
+    timestamp        TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    target_amount    INTEGER,
+    exchange_rate    INTEGER,
+    quote_id         TEXT,
+    reversal_of      INTEGER REFERENCES "transaction" (id)
+);
+
+INSERT INTO transaction_old (id, idempotence_key, wallet_id, amount, target_wallet_id, service_id, comment, timestamp,
+                             target_amount, exchange_rate, quote_id, reversal_of)
+SELECT id, idempotence_key, wallet_id, amount, target_wallet_id, service_id, comment, timestamp,
+       target_amount, exchange_rate, quote_id, reversal_of
+FROM "transaction";
+
+DROP TABLE "transaction";
+ALTER TABLE transaction_old RENAME TO "transaction";
+
+DROP INDEX reserved_funds_expires_at_idx;
+
+ALTER TABLE reserved_funds DROP COLUMN expires_at;
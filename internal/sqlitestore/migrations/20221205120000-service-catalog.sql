@@ -0,0 +1,43 @@
+-- +migrate Up
+CREATE TABLE services_new
+(
+    id            INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    title         TEXT                                NOT NULL,
+    description   TEXT      DEFAULT ''                NOT NULL,
+    active        BOOLEAN   DEFAULT TRUE              NOT NULL,
+    default_price INTEGER,
+    currency      TEXT,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT services_default_price_check CHECK ((default_price IS NULL) = (currency IS NULL))
+);
+
+INSERT INTO services_new (id, title)
+SELECT id, title FROM services;
+
+DROP TABLE services;
+ALTER TABLE services_new RENAME TO services;
+
+ALTER TABLE capture ADD COLUMN service_title TEXT DEFAULT '' NOT NULL;
+UPDATE capture SET service_title = (SELECT s.title FROM services s WHERE s.id = capture.service_id);
+
+ALTER TABLE refund ADD COLUMN service_title TEXT DEFAULT '' NOT NULL;
+UPDATE refund SET service_title = (SELECT s.title FROM services s WHERE s.id = refund.service_id);
+
+
+-- +migrate Down
+ALTER TABLE refund DROP COLUMN service_title;
+
+ALTER TABLE capture DROP COLUMN service_title;
+
+CREATE TABLE services_old
+(
+    id    INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    title TEXT                              NOT NULL
+);
+
+INSERT INTO services_old (id, title)
+SELECT id, title FROM services;
+
+DROP TABLE services;
+ALTER TABLE services_old RENAME TO services;
@@ -0,0 +1,20 @@
+-- +migrate Up
+CREATE TABLE idempotency_key
+(
+    account_id    INTEGER                             NOT NULL,
+    key           TEXT                                NOT NULL,
+    endpoint      TEXT                                NOT NULL,
+    fingerprint   TEXT                                NOT NULL,
+    status_code   INTEGER,
+    content_type  TEXT DEFAULT ''                     NOT NULL,
+    response_body BLOB,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at    TIMESTAMP                           NOT NULL,
+    PRIMARY KEY (account_id, key, endpoint)
+);
+
+CREATE INDEX idempotency_key_expires_at_idx ON idempotency_key (expires_at);
+
+
+-- +migrate Down
+DROP TABLE idempotency_key;
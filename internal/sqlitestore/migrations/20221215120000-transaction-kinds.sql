@@ -0,0 +1,67 @@
+-- +migrate Up
+-- amounts become signed from the point of view of the wallet the row belongs to
+CREATE TABLE transaction_new
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    idempotence_key  INTEGER UNIQUE,
+    wallet_id        INTEGER REFERENCES wallet (id)      NOT NULL,
+    amount           INTEGER                             NOT NULL,
+    target_wallet_id INTEGER REFERENCES wallet (id),
+    service_id       INTEGER REFERENCES services (id),
+    comment          TEXT                                NOT NULL,
+    timestamp        TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    target_amount    INTEGER,
+    exchange_rate    INTEGER,
+    quote_id         TEXT,
+    reversal_of      INTEGER REFERENCES "transaction" (id),
+    kind             TEXT                                NOT NULL,
+    CONSTRAINT transaction_kind_check
+        CHECK (kind IN ('deposit', 'withdrawal', 'transfer', 'reserve', 'reserve_release', 'service_charge', 'refund',
+                        'adjustment'))
+);
+
+INSERT INTO transaction_new (id, idempotence_key, wallet_id, amount, target_wallet_id, service_id, comment, timestamp,
+                             target_amount, exchange_rate, quote_id, reversal_of, kind)
+SELECT id, idempotence_key, wallet_id, CASE WHEN kind IN ('transfer', 'service_charge') THEN -amount ELSE amount END,
+       target_wallet_id, service_id, comment, timestamp, target_amount, exchange_rate, quote_id, reversal_of, kind
+FROM (SELECT *,
+             CASE WHEN target_wallet_id IS NOT NULL THEN 'transfer'
+                  WHEN reversal_of IS NOT NULL THEN 'refund'
+                  WHEN service_id IS NOT NULL AND idempotence_key IS NULL THEN 'reserve_release'
+                  WHEN service_id IS NOT NULL THEN 'service_charge'
+                  WHEN amount < 0 THEN 'withdrawal'
+                  ELSE 'deposit' END AS kind
+      FROM "transaction");
+
+DROP TABLE "transaction";
+ALTER TABLE transaction_new RENAME TO "transaction";
+
+-- +migrate Down
+UPDATE "transaction"
+SET amount = -amount
+WHERE kind IN ('transfer', 'service_charge');
+
+CREATE TABLE transaction_old
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    idempotence_key  INTEGER UNIQUE,
+    wallet_id        INTEGER REFERENCES wallet (id)      NOT NULL,
+    amount           INTEGER                             NOT NULL,
+    target_wallet_id INTEGER REFERENCES wallet (id),
+    service_id       INTEGER REFERENCES services (id),
+    comment          TEXT                                NOT NULL,
+    timestamp        TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    target_amount    INTEGER,
+    exchange_rate    INTEGER,
+    quote_id         TEXT,
+    reversal_of      INTEGER REFERENCES "transaction" (id)
+);
+
+INSERT INTO transaction_old (id, idempotence_key, wallet_id, amount, target_wallet_id, service_id, comment, timestamp,
+                             target_amount, exchange_rate, quote_id, reversal_of)
+SELECT id, idempotence_key, wallet_id, amount, target_wallet_id, service_id, comment, timestamp,
+       target_amount, exchange_rate, quote_id, reversal_of
+FROM "transaction";
+
+DROP TABLE "transaction";
+ALTER TABLE transaction_old RENAME TO "transaction";
@@ -0,0 +1,24 @@
+-- +migrate Up
+CREATE TABLE outbox_sequence
+(
+    account_id    INTEGER PRIMARY KEY NOT NULL,
+    last_sequence INTEGER             NOT NULL
+);
+
+CREATE TABLE outbox
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    account_id   INTEGER                             NOT NULL,
+    sequence     INTEGER                             NOT NULL,
+    event_type   TEXT                                NOT NULL,
+    payload      BLOB                                NOT NULL,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    published_at TIMESTAMP,
+    UNIQUE (account_id, sequence)
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (id) WHERE published_at IS NULL;
+
+-- +migrate Down
+DROP TABLE outbox;
+DROP TABLE outbox_sequence;
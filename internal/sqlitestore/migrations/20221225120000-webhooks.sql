@@ -0,0 +1,47 @@
+-- +migrate Up
+CREATE TABLE webhooks
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    url         TEXT                                NOT NULL,
+    secret      TEXT                                NOT NULL,
+    event_types TEXT                                NOT NULL,
+    active      BOOLEAN   DEFAULT TRUE              NOT NULL,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE webhook_deliveries
+(
+    id              INTEGER PRIMARY KEY AUTOINCREMENT                  NOT NULL,
+    webhook_id      INTEGER REFERENCES webhooks (id) ON DELETE CASCADE NOT NULL,
+    event_id        INTEGER REFERENCES outbox (id)                     NOT NULL,
+    status          TEXT                                               NOT NULL,
+    attempt_count   INTEGER   DEFAULT 0                                NOT NULL,
+    next_attempt_at TIMESTAMP                                          NOT NULL,
+    last_error      TEXT,
+    response_status INTEGER,
+    delivered_at    TIMESTAMP,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP                NOT NULL,
+    updated_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP                NOT NULL,
+    CHECK (status IN ('Pending', 'Delivered', 'Dead'))
+);
+
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'Pending';
+CREATE INDEX webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, id);
+
+CREATE TABLE webhook_attempts
+(
+    id              INTEGER PRIMARY KEY AUTOINCREMENT                            NOT NULL,
+    delivery_id     INTEGER REFERENCES webhook_deliveries (id) ON DELETE CASCADE NOT NULL,
+    response_status INTEGER,
+    error           TEXT,
+    duration_ms     INTEGER                                                      NOT NULL,
+    attempted_at    TIMESTAMP                                                    NOT NULL
+);
+
+CREATE INDEX webhook_attempts_delivery_idx ON webhook_attempts (delivery_id, id);
+
+-- +migrate Down
+DROP TABLE webhook_attempts;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
@@ -0,0 +1,20 @@
+-- +migrate Up
+CREATE TABLE admin_actions
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT     NOT NULL,
+    admin_id       INTEGER                               NOT NULL,
+    account_id     INTEGER                               NOT NULL,
+    action         TEXT                                  NOT NULL,
+    transaction_id INTEGER REFERENCES "transaction" (id),
+    currency       TEXT                                  NOT NULL,
+    amount         INTEGER                               NOT NULL,
+    reason         TEXT                                  NOT NULL,
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP   NOT NULL,
+    CHECK (action IN ('deposit', 'withdrawal', 'adjustment'))
+);
+
+CREATE INDEX admin_actions_account_idx ON admin_actions (account_id, id);
+CREATE INDEX admin_actions_admin_idx ON admin_actions (admin_id, id);
+
+-- +migrate Down
+DROP TABLE admin_actions;
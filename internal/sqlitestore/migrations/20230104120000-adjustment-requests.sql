@@ -0,0 +1,27 @@
+-- +migrate Up
+CREATE TABLE adjustment_requests
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT     NOT NULL,
+    account_id     INTEGER                               NOT NULL,
+    currency       TEXT                                  NOT NULL,
+    amount         INTEGER                               NOT NULL,
+    comment        TEXT                                  NOT NULL,
+    reason         TEXT                                  NOT NULL,
+    attachment     TEXT                                  NOT NULL,
+    status         TEXT                                  NOT NULL,
+    proposed_by    INTEGER                               NOT NULL,
+    reviewed_by    INTEGER,
+    review_comment TEXT      DEFAULT ''                  NOT NULL,
+    transaction_id INTEGER REFERENCES "transaction" (id),
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP   NOT NULL,
+    expires_at     TIMESTAMP                             NOT NULL,
+    reviewed_at    TIMESTAMP,
+    CHECK (status IN ('pending', 'approved', 'rejected', 'expired')),
+    CHECK (reviewed_by IS NULL OR reviewed_by <> proposed_by)
+);
+
+CREATE INDEX adjustment_requests_pending_idx ON adjustment_requests (expires_at) WHERE status = 'pending';
+CREATE INDEX adjustment_requests_account_idx ON adjustment_requests (account_id, id);
+
+-- +migrate Down
+DROP TABLE adjustment_requests;
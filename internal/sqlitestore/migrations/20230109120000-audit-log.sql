@@ -0,0 +1,39 @@
+-- +migrate Up
+CREATE TABLE audit_log
+(
+    id              INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    account_id      INTEGER                           NOT NULL,
+    role            TEXT                              NOT NULL,
+    subject_id      INTEGER                           NOT NULL,
+    method          TEXT                              NOT NULL,
+    endpoint        TEXT                              NOT NULL,
+    payload         TEXT                              NOT NULL,
+    status_code     INTEGER                           NOT NULL,
+    outcome         TEXT                              NOT NULL,
+    balances_before BLOB,
+    balances_after  BLOB,
+    created_at      TIMESTAMP                         NOT NULL,
+    prev_hash       TEXT                              NOT NULL,
+    hash            TEXT                              NOT NULL
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+
+-- +migrate StatementBegin
+CREATE TRIGGER audit_log_no_update
+    BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+CREATE TRIGGER audit_log_no_delete
+    BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +migrate StatementEnd
+
+-- +migrate Down
+DROP TABLE audit_log;
@@ -0,0 +1,19 @@
+-- +migrate Up
+CREATE TABLE api_keys
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT   NOT NULL,
+    client_name  TEXT                                NOT NULL,
+    key_prefix   TEXT UNIQUE                         NOT NULL,
+    key_hash     TEXT                                NOT NULL,
+    scopes       TEXT                                NOT NULL,
+    service_ids  TEXT      DEFAULT '[]'              NOT NULL,
+    created_by   INTEGER                             NOT NULL,
+    rotated_from INTEGER REFERENCES api_keys (id),
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at   TIMESTAMP,
+    revoked_at   TIMESTAMP,
+    last_used_at TIMESTAMP
+);
+
+-- +migrate Down
+DROP TABLE api_keys;
@@ -0,0 +1,20 @@
+-- +migrate Up
+CREATE TABLE refund_capture
+(
+    refund_id  INTEGER REFERENCES refund (id)  NOT NULL,
+    capture_id INTEGER REFERENCES capture (id) NOT NULL,
+    amount     INTEGER                         NOT NULL,
+    PRIMARY KEY (refund_id, capture_id)
+);
+
+CREATE INDEX refund_capture_capture_id_idx ON refund_capture (capture_id);
+
+INSERT INTO refund_capture (refund_id, capture_id, amount)
+SELECT r.id, c.id, r.amount
+FROM refund r
+         JOIN "transaction" t ON t.id = r.transaction_id
+         JOIN capture c ON c.transaction_id = t.reversal_of;
+
+
+-- +migrate Down
+DROP TABLE refund_capture;
@@ -0,0 +1,47 @@
+-- +migrate Up
+CREATE TABLE idempotency_key_new
+(
+    account_id    INTEGER                             NOT NULL,
+    api_key_id    INTEGER DEFAULT 0                   NOT NULL,
+    key           TEXT                                NOT NULL,
+    endpoint      TEXT                                NOT NULL,
+    fingerprint   TEXT                                NOT NULL,
+    status_code   INTEGER,
+    content_type  TEXT DEFAULT ''                     NOT NULL,
+    response_body BLOB,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at    TIMESTAMP                           NOT NULL,
+    PRIMARY KEY (account_id, api_key_id, key, endpoint)
+);
+
+INSERT INTO idempotency_key_new (account_id, key, endpoint, fingerprint, status_code, content_type, response_body, created_at, expires_at)
+SELECT account_id, key, endpoint, fingerprint, status_code, content_type, response_body, created_at, expires_at
+FROM idempotency_key;
+
+DROP TABLE idempotency_key;
+ALTER TABLE idempotency_key_new RENAME TO idempotency_key;
+CREATE INDEX idempotency_key_expires_at_idx ON idempotency_key (expires_at);
+
+-- +migrate Down
+CREATE TABLE idempotency_key_new
+(
+    account_id    INTEGER                             NOT NULL,
+    key           TEXT                                NOT NULL,
+    endpoint      TEXT                                NOT NULL,
+    fingerprint   TEXT                                NOT NULL,
+    status_code   INTEGER,
+    content_type  TEXT DEFAULT ''                     NOT NULL,
+    response_body BLOB,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at    TIMESTAMP                           NOT NULL,
+    PRIMARY KEY (account_id, key, endpoint)
+);
+
+INSERT INTO idempotency_key_new (account_id, key, endpoint, fingerprint, status_code, content_type, response_body, created_at, expires_at)
+SELECT account_id, key, endpoint, fingerprint, status_code, content_type, response_body, created_at, expires_at
+FROM idempotency_key
+WHERE api_key_id = 0;
+
+DROP TABLE idempotency_key;
+ALTER TABLE idempotency_key_new RENAME TO idempotency_key;
+CREATE INDEX idempotency_key_expires_at_idx ON idempotency_key (expires_at);
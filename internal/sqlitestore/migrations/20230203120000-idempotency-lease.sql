@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE idempotency_key ADD COLUMN locked_until TIMESTAMP DEFAULT '1970-01-01 00:00:00' NOT NULL;
+
+-- +migrate Down
+ALTER TABLE idempotency_key DROP COLUMN locked_until;
@@ -0,0 +1,109 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+func (db *DB) insertEvent(ctx context.Context, tx *sql.Tx, eventType string, event models.BalanceEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("err executing [insertEvent]: %w", err)
+	}
+	query := `
+	INSERT INTO outbox_sequence (account_id, last_sequence)
+	VALUES ($1, 1)
+	ON CONFLICT (account_id) DO UPDATE SET last_sequence = outbox_sequence.last_sequence + 1
+	RETURNING last_sequence`
+	var sequence int64
+	if err = tx.QueryRowContext(ctx, query, event.AccountID).Scan(&sequence); err != nil {
+		return fmt.Errorf("err executing [insertEvent]: %w", err)
+	}
+	query = `
+	INSERT INTO outbox (account_id, sequence, event_type, payload, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id`
+	var eventID int
+	err = tx.QueryRowContext(ctx, query, event.AccountID, sequence, eventType, payload,
+		time.Now().UTC().Format(dateTimeLayout)).Scan(&eventID)
+	if err != nil {
+		return fmt.Errorf("err executing [insertEvent]: %w", err)
+	}
+	if err = db.insertWebhookDeliveries(ctx, tx, eventID, eventType); err != nil {
+		return fmt.Errorf("err executing [insertEvent]: %w", err)
+	}
+	db.notifyEvent(tx, event.AccountID)
+	return nil
+}
+
+// RelayEvents hands up to limit pending events to publish in outbox order and marks the ones
+// it reports as delivered. Events stay pending if the process dies before they are marked,
+// which makes delivery at-least-once. Only one relay of the process drains the outbox at a time,
+// otherwise two relays could deliver events of one account out of order.
+func (db *DB) RelayEvents(ctx context.Context, limit int,
+	publish func(ctx context.Context, events []models.Event) []int) (int, error) {
+	selectQuery := `
+	SELECT id, account_id, sequence, event_type, payload, created_at
+	FROM outbox
+	WHERE published_at IS NULL
+	ORDER BY id
+	LIMIT $1`
+	updateQuery := `
+	UPDATE outbox
+	SET published_at = $1
+	WHERE id IN (SELECT value FROM json_each($2))`
+	if !db.relayMu.TryLock() {
+		return 0, nil
+	}
+	defer db.relayMu.Unlock()
+	events := make([]models.Event, 0)
+	if err := db.db.SelectContext(ctx, &events, selectQuery, limit); err != nil {
+		return 0, fmt.Errorf("err executing [RelayEvents]: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+	published := publish(ctx, events)
+	if len(published) == 0 {
+		return 0, nil
+	}
+	ids, err := json.Marshal(published)
+	if err != nil {
+		return 0, fmt.Errorf("err executing [RelayEvents]: %w", err)
+	}
+	_, err = db.db.ExecContext(ctx, updateQuery, time.Now().UTC().Format(dateTimeLayout), string(ids))
+	if err != nil {
+		return 0, fmt.Errorf("err executing [RelayEvents]: %w", err)
+	}
+	return len(published), nil
+}
+
+func (db *DB) insertTransferEvents(ctx context.Context, tx *sql.Tx, accountID, transactionID int,
+	transaction models.TransferTransaction, conversion models.Conversion) error {
+	err := db.insertEvent(ctx, tx, models.EventTransferCompleted, models.BalanceEvent{
+		AccountID:      accountID,
+		TransactionID:  &transactionID,
+		Counterparty:   transaction.Target,
+		Currency:       transaction.Currency,
+		Amount:         transaction.Amount,
+		TargetCurrency: conversion.TargetCurrency,
+		TargetAmount:   &conversion.TargetAmount,
+	})
+	if err != nil {
+		return err
+	}
+	return db.insertEvent(ctx, tx, models.EventTransferReceived, models.BalanceEvent{
+		AccountID:      transaction.Target,
+		TransactionID:  &transactionID,
+		Counterparty:   accountID,
+		Currency:       transaction.Currency,
+		Amount:         transaction.Amount,
+		TargetCurrency: conversion.TargetCurrency,
+		TargetAmount:   &conversion.TargetAmount,
+	})
+}
@@ -0,0 +1,1531 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	migrate "github.com/rubenv/sql-migrate"
+	"github.com/sirupsen/logrus"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed migrations
+var migrations embed.FS
+
+// Timestamps are stored as UTC text with a fixed width, so that comparing them as strings compares them in time.
+const (
+	dateTimeLayout = "2006-01-02 15:04:05.000000"
+	retries        = 3
+)
+
+// Transactions begin immediately and take the database write lock, which serializes them the way
+// SELECT ... FOR UPDATE serializes writers of a wallet in postgres. Concurrent writers wait for the lock.
+const pragmas = "_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)"
+
+// Amounts are stored as integers in minor units and rates with 8 fractional digits.
+// Queries format them with the money and rate functions into the decimals models.Money and models.Rate scan.
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("money", 1, decimalFunc(func(v int64) string {
+		return models.Money(v).String()
+	}))
+	sqlite.MustRegisterDeterministicScalarFunction("rate", 1, decimalFunc(func(v int64) string {
+		return models.Rate(v).String()
+	}))
+	sqlite.MustRegisterDeterministicScalarFunction("utf8_lower", 1,
+		func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			s, ok := args[0].(string)
+			if !ok {
+				return args[0], nil
+			}
+			return strings.ToLower(s), nil
+		})
+}
+
+func decimalFunc(format func(v int64) string) func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+	return func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		switch v := args[0].(type) {
+		case nil:
+			return nil, nil
+		case int64:
+			return format(v), nil
+		default:
+			return nil, fmt.Errorf("unexpected amount %v", v)
+		}
+	}
+}
+
+type DB struct {
+	log  *logrus.Logger
+	db   *sqlx.DB
+	path string
+
+	relayMu        sync.Mutex
+	listenersMu    sync.Mutex
+	listeners      map[int]func(accountID int)
+	lastListenerID int
+	pending        map[*sql.Tx][]int
+}
+
+// GetSQLiteStore opens the database file at path, creating it if it does not exist.
+func GetSQLiteStore(ctx context.Context, log *logrus.Logger, path string) (*DB, error) {
+	db, err := sqlx.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_txlock=immediate&"+pragmas)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database: %w", err)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("unable to open database: %w", err)
+	}
+	return &DB{
+		log:       log,
+		db:        db,
+		path:      path,
+		listeners: make(map[int]func(accountID int)),
+		pending:   make(map[*sql.Tx][]int),
+	}, nil
+}
+
+// Migrate runs the migrations with foreign keys off, SQLite changes constraints by rebuilding tables.
+func (db *DB) Migrate(direction migrate.MigrationDirection) error {
+	conn, err := sql.Open("sqlite", "file:"+db.path+"?"+pragmas)
+	if err != nil {
+		return fmt.Errorf("unable to open database: %w", err)
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			db.log.Errorf("err closing migrations connections")
+		}
+	}()
+	asserDir := func() func(string) ([]string, error) {
+		return func(path string) ([]string, error) {
+			dirEntry, err := migrations.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			entries := make([]string, 0)
+			for _, e := range dirEntry {
+				entries = append(entries, e.Name())
+			}
+			return entries, nil
+		}
+	}()
+	asset := migrate.AssetMigrationSource{
+		Asset:    migrations.ReadFile,
+		AssetDir: asserDir,
+		Dir:      "migrations",
+	}
+	_, err = migrate.Exec(conn, "sqlite3", asset, direction)
+	return err
+}
+
+func (db *DB) Close() error {
+	return db.db.Close()
+}
+
+// commit commits tx and then notifies the listeners about the events inserted in it.
+func (db *DB) commit(tx *sql.Tx) error {
+	err := tx.Commit()
+	db.listenersMu.Lock()
+	accountIDs := db.pending[tx]
+	delete(db.pending, tx)
+	db.listenersMu.Unlock()
+	if err != nil {
+		return err
+	}
+	db.notify(accountIDs)
+	return nil
+}
+
+func (db *DB) rollback(tx *sql.Tx) error {
+	db.listenersMu.Lock()
+	delete(db.pending, tx)
+	db.listenersMu.Unlock()
+	return tx.Rollback()
+}
+
+// constraintError reports constraint violations as the postgres errors the handlers check for.
+func constraintError(err error) error {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return &pgconn.PgError{Severity: "ERROR", Code: pgerrcode.UniqueViolation, Message: sqliteErr.Error()}
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return &pgconn.PgError{Severity: "ERROR", Code: pgerrcode.ForeignKeyViolation, Message: sqliteErr.Error()}
+	default:
+		return err
+	}
+}
+
+func nullMoney(amount *models.Money) interface{} {
+	if amount == nil {
+		return nil
+	}
+	return int64(*amount)
+}
+
+func nullRate(rate *models.Rate) interface{} {
+	if rate == nil {
+		return nil
+	}
+	return int64(*rate)
+}
+
+func nullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(dateTimeLayout)
+}
+
+func (db *DB) GetWallet(ctx context.Context, accountID int, currency string) (*models.Wallet, error) {
+	query := `
+	SELECT id, owner_id, currency, money(balance) AS balance, money(reserved_balance) AS reserved_balance, created_at,
+	       updated_at
+	FROM wallet
+	WHERE owner_id = $1 AND currency = $2`
+	var wallet models.Wallet
+	var err error
+	for i := 0; i < retries; i++ {
+		if err = db.db.GetContext(ctx, &wallet, query, accountID, currency); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrWalletNotFound
+			}
+			continue
+		}
+		return &wallet, nil
+	}
+	return nil, err
+}
+
+func (db *DB) UpsertDepositToWallet(ctx context.Context, ownerID int, transaction models.Transaction) error {
+	var err error
+	var tx *sql.Tx
+	var wallet *models.Wallet
+	var transactionID int
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = db.rollback(tx); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back deposit transaction")
+				}
+			}()
+			query := `
+	INSERT INTO wallet (owner_id, currency, balance, reserved_balance, created_at, updated_at)
+	VALUES ($1, $2, $3, 0, $4, $4)
+	ON CONFLICT (owner_id, currency) DO UPDATE SET balance = wallet.balance + excluded.balance,
+										updated_at = excluded.updated_at`
+			if _, err = tx.ExecContext(ctx, query, ownerID, transaction.Currency, int64(transaction.Amount),
+				time.Now().UTC().Format(dateTimeLayout)); err != nil {
+				return fmt.Errorf("err executing [UpsertDepositToWallet]: %w", err)
+			}
+			wallet, err = db.checkBalance(ctx, tx, ownerID, transaction.Currency, 0)
+			if err != nil {
+				return fmt.Errorf("err executing [UpsertDepositToWallet]: %w", err)
+			}
+			transactionID, err = db.insertTransaction(ctx, tx, transactionRecord{
+				idempotenceKey: &transaction.IdempotenceKey,
+				kind:           models.TransactionKindDeposit,
+				walletID:       wallet.ID,
+				amount:         transaction.Amount,
+				comment:        transaction.Comment,
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [UpsertDepositToWallet]: %w", err)
+			}
+			err = db.insertJournalEntry(ctx, tx, entryDeposit, transactionReference(transactionID), &transactionID,
+				externalCash(transaction.Currency, -transaction.Amount),
+				available(ownerID, transaction.Currency, transaction.Amount))
+			if err != nil {
+				return fmt.Errorf("err executing [UpsertDepositToWallet]: %w", err)
+			}
+			err = db.insertEvent(ctx, tx, models.EventFundsDeposited, models.BalanceEvent{
+				AccountID:     ownerID,
+				TransactionID: &transactionID,
+				Currency:      transaction.Currency,
+				Amount:        transaction.Amount,
+				AdminID:       transaction.Actor.ID(),
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [UpsertDepositToWallet]: %w", err)
+			}
+			if transaction.Actor != nil {
+				err = db.insertAdminAction(ctx, tx, models.AdminActionDeposit, ownerID, transactionID, transaction)
+				if err != nil {
+					return fmt.Errorf("err executing [UpsertDepositToWallet]: %w", err)
+				}
+			}
+			err = db.commit(tx)
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) WithdrawMoneyFromWallet(ctx context.Context, ownerID int, transaction models.Transaction) error {
+	var err error
+	var tx *sql.Tx
+	var wallet *models.Wallet
+	var transactionID int
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = db.rollback(tx); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back deposit transaction")
+				}
+			}()
+			wallet, err = db.checkBalance(ctx, tx, ownerID, transaction.Currency, transaction.Amount)
+			if err != nil {
+				return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
+			}
+			err = db.withdrawMoney(ctx, tx, wallet.ID, transaction.Amount)
+			if err != nil {
+				return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
+			}
+			transactionID, err = db.insertTransaction(ctx, tx, transactionRecord{
+				idempotenceKey: &transaction.IdempotenceKey,
+				kind:           models.TransactionKindWithdrawal,
+				walletID:       wallet.ID,
+				amount:         -transaction.Amount,
+				comment:        transaction.Comment,
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
+			}
+			err = db.insertJournalEntry(ctx, tx, entryWithdrawal, transactionReference(transactionID), &transactionID,
+				available(ownerID, transaction.Currency, -transaction.Amount),
+				externalCash(transaction.Currency, transaction.Amount))
+			if err != nil {
+				return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
+			}
+			err = db.insertEvent(ctx, tx, models.EventFundsWithdrawn, models.BalanceEvent{
+				AccountID:     ownerID,
+				TransactionID: &transactionID,
+				Currency:      transaction.Currency,
+				Amount:        transaction.Amount,
+				AdminID:       transaction.Actor.ID(),
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
+			}
+			if transaction.Actor != nil {
+				err = db.insertAdminAction(ctx, tx, models.AdminActionWithdrawal, ownerID, transactionID, transaction)
+				if err != nil {
+					return fmt.Errorf("err executing [WithdrawMoneyFromWallet]: %w", err)
+				}
+			}
+			err = db.commit(tx)
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) TransferMoney(ctx context.Context, accountID int, transaction models.TransferTransaction,
+	conversion models.Conversion) error {
+	var err error
+	var tx *sql.Tx
+	var wallet *models.Wallet
+	var targetWalletID, transactionID int
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = db.rollback(tx); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back transfer transaction")
+				}
+			}()
+			if conversion.QuoteID != "" {
+				if err = db.useExchangeQuote(ctx, tx, accountID, conversion.QuoteID); err != nil {
+					return fmt.Errorf("err executing [TransferMoney]: %w", err)
+				}
+			}
+			wallet, err = db.checkBalance(ctx, tx, accountID, transaction.Currency, transaction.Amount)
+			if err != nil {
+				return fmt.Errorf("err executing [TransferMoney]: %w", err)
+			}
+			err = db.withdrawMoney(ctx, tx, wallet.ID, transaction.Amount)
+			if err != nil {
+				return fmt.Errorf("err executing [TransferMoney]: %w", err)
+			}
+			targetWalletID, err = db.depositMoney(ctx, tx, transaction.Target, conversion.TargetCurrency, conversion.TargetAmount)
+			if err != nil {
+				return fmt.Errorf("err executing [TransferMoney]: %w", err)
+			}
+			record := transactionRecord{
+				idempotenceKey: &transaction.IdempotenceKey,
+				kind:           models.TransactionKindTransfer,
+				walletID:       wallet.ID,
+				targetWalletID: &targetWalletID,
+				amount:         -transaction.Amount,
+				comment:        transaction.Comment,
+				targetAmount:   &conversion.TargetAmount,
+				exchangeRate:   &conversion.Rate,
+			}
+			if conversion.QuoteID != "" {
+				record.quoteID = &conversion.QuoteID
+			}
+			transactionID, err = db.insertTransaction(ctx, tx, record)
+			if err != nil {
+				return fmt.Errorf("err executing [TransferMoney]: %w", err)
+			}
+			err = db.insertJournalEntry(ctx, tx, entryTransfer, transactionReference(transactionID), &transactionID,
+				transferPostings(accountID, transaction, conversion)...)
+			if err != nil {
+				return fmt.Errorf("err executing [TransferMoney]: %w", err)
+			}
+			err = db.insertTransferEvents(ctx, tx, accountID, transactionID, transaction, conversion)
+			if err != nil {
+				return fmt.Errorf("err executing [TransferMoney]: %w", err)
+			}
+			err = db.commit(tx)
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) ReserveMoneyFromWallet(ctx context.Context, transaction models.ReserveTransaction) error {
+	var err error
+	var tx *sql.Tx
+	var wallet *models.Wallet
+	var transactionID int
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = db.rollback(tx); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back reserve transaction")
+				}
+			}()
+			wallet, err = db.checkBalance(ctx, tx, transaction.AccountID, transaction.Currency, transaction.Amount)
+			if err != nil {
+				return fmt.Errorf("err executing [ReserveMoneyFromWallet]: %w", err)
+			}
+			err = db.withdrawMoney(ctx, tx, wallet.ID, transaction.Amount)
+			if err != nil {
+				return fmt.Errorf("err executing [ReserveMoneyFromWallet]: %w", err)
+			}
+			err = db.reserveMoney(ctx, tx, wallet.ID, transaction.Amount)
+			if err != nil {
+				return fmt.Errorf("err executing [ReserveMoneyFromWallet]: %w", err)
+			}
+			err = db.insertReservedFunds(ctx, tx, transaction.AccountID, transaction)
+			if err != nil {
+				return fmt.Errorf("err executing [ReserveMoneyFromWallet]: %w", err)
+			}
+			transactionID, err = db.insertTransaction(ctx, tx, transactionRecord{
+				kind:      models.TransactionKindReserve,
+				walletID:  wallet.ID,
+				amount:    -transaction.Amount,
+				serviceID: &transaction.ServiceID,
+				comment:   "Резервирование средств",
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [ReserveMoneyFromWallet]: %w", err)
+			}
+			err = db.insertJournalEntry(ctx, tx, entryReserve, orderReference(transaction.OrderID), &transactionID,
+				available(transaction.AccountID, transaction.Currency, -transaction.Amount),
+				reserved(transaction.AccountID, transaction.Currency, transaction.Amount))
+			if err != nil {
+				return fmt.Errorf("err executing [ReserveMoneyFromWallet]: %w", err)
+			}
+			err = db.insertEvent(ctx, tx, models.EventFundsReserved, models.BalanceEvent{
+				AccountID:     transaction.AccountID,
+				TransactionID: &transactionID,
+				OrderID:       transaction.OrderID,
+				ServiceID:     transaction.ServiceID,
+				Currency:      transaction.Currency,
+				Amount:        transaction.Amount,
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [ReserveMoneyFromWallet]: %w", err)
+			}
+			err = db.commit(tx)
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) ApplyReservedMoney(ctx context.Context, transaction models.ReserveTransaction) error {
+	var err error
+	var tx *sql.Tx
+	var wallet *models.Wallet
+	var order *reservedOrder
+	var serviceTitle string
+	var transactionID int
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = db.rollback(tx); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back apply transaction")
+				}
+			}()
+			wallet, err = db.checkReservedBalance(ctx, tx, transaction.AccountID, transaction.Currency, transaction.Amount)
+			if err != nil {
+				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+			}
+			order, err = db.lockReservedOrder(ctx, tx, transaction)
+			if err != nil {
+				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+			}
+			remaining := order.amount - order.captured
+			if transaction.Amount > remaining {
+				return models.ErrNotEnoughReservedMoney
+			}
+			err = db.withdrawReservedMoney(ctx, tx, wallet.ID, transaction.Amount)
+			if err != nil {
+				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+			}
+			serviceTitle, err = db.getActiveServiceTitle(ctx, tx, transaction.ServiceID)
+			if err != nil {
+				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+			}
+			// Without a key only the first capture can be made, keyed by the order as a single full capture was.
+			idempotenceKey := transaction.IdempotenceKey
+			if idempotenceKey == 0 {
+				if order.captured > 0 {
+					return models.ErrIdempotenceKeyRequired
+				}
+				idempotenceKey = transaction.OrderID
+			}
+			transactionID, err = db.insertTransaction(ctx, tx, transactionRecord{
+				idempotenceKey: &idempotenceKey,
+				kind:           models.TransactionKindServiceCharge,
+				walletID:       wallet.ID,
+				amount:         -transaction.Amount,
+				serviceID:      &transaction.ServiceID,
+				comment:        serviceTitle,
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+			}
+			err = db.insertCapture(ctx, tx, order, serviceTitle, transaction.Amount, transactionID)
+			if err != nil {
+				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+			}
+			err = db.insertJournalEntry(ctx, tx, entryReserveApply, orderReference(transaction.OrderID), &transactionID,
+				reserved(transaction.AccountID, transaction.Currency, -transaction.Amount),
+				revenue(transaction.Currency, transaction.Amount))
+			if err != nil {
+				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+			}
+			order.captured += transaction.Amount
+			status := models.ReservationActive
+			if transaction.Final || order.captured == order.amount {
+				status = models.ReservationCompleted
+				err = db.releaseReserve(ctx, tx, wallet.ID, order, order.amount-order.captured, entryReserveCancel,
+					"Возврат остатка резервирования")
+				if err != nil {
+					return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+				}
+			}
+			err = db.updateOrder(ctx, tx, order, status)
+			if err != nil {
+				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+			}
+			err = db.insertEvent(ctx, tx, models.EventReserveApplied, models.BalanceEvent{
+				AccountID:     transaction.AccountID,
+				TransactionID: &transactionID,
+				OrderID:       transaction.OrderID,
+				ServiceID:     transaction.ServiceID,
+				Currency:      transaction.Currency,
+				Amount:        transaction.Amount,
+				Final:         status == models.ReservationCompleted,
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [ApplyReservedMoney]: %w", err)
+			}
+			err = db.commit(tx)
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) CancelReserve(ctx context.Context, transaction models.ReserveTransaction) error {
+	var err error
+	var tx *sql.Tx
+	var wallet *models.Wallet
+	var order *reservedOrder
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = db.rollback(tx); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back cancel transaction")
+				}
+			}()
+			wallet, err = db.checkReservedBalance(ctx, tx, transaction.AccountID, transaction.Currency, 0)
+			if err != nil {
+				return fmt.Errorf("err executing [CancelReserve]: %w", err)
+			}
+			order, err = db.lockReservedOrder(ctx, tx, transaction)
+			if err != nil {
+				return fmt.Errorf("err executing [CancelReserve]: %w", err)
+			}
+			if transaction.Amount != order.amount-order.captured {
+				return models.ErrOrderNotFound
+			}
+			err = db.cancelOrder(ctx, tx, wallet, order, models.ReservationCancelled)
+			if err != nil {
+				return fmt.Errorf("err executing [CancelReserve]: %w", err)
+			}
+			err = db.commit(tx)
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) GetExpiredReservations(ctx context.Context, now time.Time,
+	limit int) ([]models.ReserveTransaction, error) {
+	query := `
+	SELECT order_id, owner_id, service_id, currency, money(amount)
+	FROM reserved_funds
+	WHERE status = $1 AND expires_at <= $2
+	ORDER BY expires_at
+	LIMIT $3`
+	var err error
+	var rows *sql.Rows
+	for i := 0; i < retries; i++ {
+		reservations := make([]models.ReserveTransaction, 0)
+		rows, err = db.db.QueryContext(ctx, query, models.ReservationActive, now.UTC().Format(dateTimeLayout), limit)
+		if err != nil {
+			err = fmt.Errorf("err executing [GetExpiredReservations]: %w", err)
+			continue
+		}
+		for rows.Next() {
+			reservation := models.ReserveTransaction{}
+			err = rows.Scan(&reservation.OrderID, &reservation.AccountID, &reservation.ServiceID,
+				&reservation.Currency, &reservation.Amount)
+			if err != nil {
+				break
+			}
+			reservations = append(reservations, reservation)
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		_ = rows.Close()
+		if err != nil {
+			err = fmt.Errorf("err executing [GetExpiredReservations]: %w", err)
+			continue
+		}
+		return reservations, nil
+	}
+	return nil, err
+}
+
+func (db *DB) ExpireReserve(ctx context.Context, transaction models.ReserveTransaction) error {
+	var err error
+	var tx *sql.Tx
+	var wallet *models.Wallet
+	var order *reservedOrder
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = db.rollback(tx); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back expire transaction")
+				}
+			}()
+			wallet, err = db.checkReservedBalance(ctx, tx, transaction.AccountID, transaction.Currency, 0)
+			if err != nil {
+				return fmt.Errorf("err executing [ExpireReserve]: %w", err)
+			}
+			order, err = db.lockReservedOrder(ctx, tx, transaction)
+			if err != nil {
+				return fmt.Errorf("err executing [ExpireReserve]: %w", err)
+			}
+			err = db.cancelOrder(ctx, tx, wallet, order, models.ReservationExpired)
+			if err != nil {
+				return fmt.Errorf("err executing [ExpireReserve]: %w", err)
+			}
+			err = db.commit(tx)
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) RefundOrder(ctx context.Context, refund models.RefundTransaction) error {
+	var err error
+	var tx *sql.Tx
+	var order *capturedOrder
+	var walletID, transactionID int
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = db.rollback(tx); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back refund transaction")
+				}
+			}()
+			order, err = db.lockCapturedOrder(ctx, tx, refund.AccountID, refund.OrderID)
+			if err != nil {
+				return fmt.Errorf("err executing [RefundOrder]: %w", err)
+			}
+			if order.refunded+refund.Amount > order.captured {
+				return models.ErrRefundExceedsCaptured
+			}
+			walletID, err = db.depositMoney(ctx, tx, refund.AccountID, order.currency, refund.Amount)
+			if err != nil {
+				return fmt.Errorf("err executing [RefundOrder]: %w", err)
+			}
+			comment := refund.Comment
+			if comment == "" {
+				comment = "Возврат: " + order.serviceTitle
+			}
+			reversed, reversalOf := order.reverseCaptures(refund.Amount)
+			transactionID, err = db.insertTransaction(ctx, tx, transactionRecord{
+				idempotenceKey: &refund.IdempotenceKey,
+				kind:           models.TransactionKindRefund,
+				walletID:       walletID,
+				amount:         refund.Amount,
+				serviceID:      &order.serviceID,
+				comment:        comment,
+				reversalOf:     reversalOf,
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [RefundOrder]: %w", err)
+			}
+			err = db.insertRefund(ctx, tx, order, refund.Amount, transactionID, reversed)
+			if err != nil {
+				return fmt.Errorf("err executing [RefundOrder]: %w", err)
+			}
+			err = db.insertJournalEntry(ctx, tx, entryRefund, orderReference(refund.OrderID), &transactionID,
+				revenue(order.currency, -refund.Amount),
+				available(refund.AccountID, order.currency, refund.Amount))
+			if err != nil {
+				return fmt.Errorf("err executing [RefundOrder]: %w", err)
+			}
+			err = db.insertEvent(ctx, tx, models.EventOrderRefunded, models.BalanceEvent{
+				AccountID:     refund.AccountID,
+				TransactionID: &transactionID,
+				OrderID:       refund.OrderID,
+				ServiceID:     order.serviceID,
+				Currency:      order.currency,
+				Amount:        refund.Amount,
+			})
+			if err != nil {
+				return fmt.Errorf("err executing [RefundOrder]: %w", err)
+			}
+			err = db.commit(tx)
+			if err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) GetWalletTransactions(ctx context.Context, accountID int, queryParams *models.TransactionsQueryParams,
+	cursor *models.TransactionsCursor) ([]models.TransactionFullInfo, error) {
+	query, args := db.queryBuilder(accountID, queryParams, cursor)
+	var err error
+	for i := 0; i < retries; i++ {
+		if err = db.checkWalletExists(ctx, accountID, queryParams.Currency); err != nil {
+			err = fmt.Errorf("err executing [GetWalletTransactions]: %w", err)
+			if errors.Is(err, models.ErrWalletNotFound) {
+				return nil, err
+			}
+			continue
+		}
+		transactions := make([]models.TransactionFullInfo, 0)
+		if err = db.db.SelectContext(ctx, &transactions, query, args...); err != nil {
+			err = fmt.Errorf("err executing [GetWalletTransactions]: %w", err)
+			continue
+		}
+		return transactions, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetReservation(ctx context.Context, orderID int) (*models.Reservation, error) {
+	query := `
+	SELECT order_id, owner_id, service_id, currency, money(amount) AS amount, money(captured_amount) AS captured_amount,
+	       money(CASE WHEN status = $2 THEN amount - captured_amount ELSE 0 END) AS remaining_amount,
+	       status, expires_at, created_at, updated_at
+	FROM reserved_funds
+	WHERE order_id = $1`
+	var err error
+	for i := 0; i < retries; i++ {
+		reservation := models.Reservation{}
+		err = db.db.GetContext(ctx, &reservation, query, orderID, models.ReservationActive)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrOrderNotFound
+			}
+			err = fmt.Errorf("err executing [GetReservation]: %w", err)
+			continue
+		}
+		return &reservation, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetReservations(ctx context.Context,
+	queryParams *models.ReservationsQueryParams) ([]models.Reservation, error) {
+	query := `
+	SELECT order_id, owner_id, service_id, currency, money(amount) AS amount, money(captured_amount) AS captured_amount,
+	       money(CASE WHEN status = $8 THEN amount - captured_amount ELSE 0 END) AS remaining_amount,
+	       status, expires_at, created_at, updated_at
+	FROM reserved_funds
+	WHERE ($1 = 0 OR owner_id = $1) AND
+	      ($2 = '' OR status = $2) AND
+	      ($3 = 0 OR service_id = $3) AND
+	      created_at BETWEEN $4 AND $5
+	ORDER BY created_at DESC, order_id DESC
+	LIMIT $6 OFFSET $7`
+	var err error
+	for i := 0; i < retries; i++ {
+		reservations := make([]models.Reservation, 0)
+		err = db.db.SelectContext(ctx, &reservations, query, queryParams.AccountID, queryParams.Status,
+			queryParams.ServiceID, queryParams.From.UTC().Format(dateTimeLayout),
+			queryParams.To.UTC().Format(dateTimeLayout), queryParams.Limit, queryParams.Offset,
+			models.ReservationActive)
+		if err != nil {
+			err = fmt.Errorf("err executing [GetReservations]: %w", err)
+			continue
+		}
+		return reservations, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetReport(ctx context.Context, month time.Time) ([]models.ReportRow, error) {
+	query := `
+	SELECT service_title AS title, currency, money(SUM(amount)) AS amount
+	FROM (SELECT service_title, currency, amount
+	      FROM capture
+	      WHERE created_at BETWEEN $1 AND $2
+	      UNION ALL
+	      SELECT service_title, currency, -amount
+	      FROM refund
+	      WHERE created_at BETWEEN $1 AND $2) revenue
+	GROUP BY service_title, currency
+	ORDER BY service_title, currency`
+	var err error
+	var rows *sqlx.Rows
+	var report []models.ReportRow
+	var row models.ReportRow
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			rows, err = db.db.QueryxContext(ctx, query, month.UTC().Format(dateTimeLayout),
+				month.AddDate(0, 1, 0).UTC().Format(dateTimeLayout))
+			if err != nil {
+				return fmt.Errorf("err executing [GetReport]: %w", err)
+			}
+			defer func() {
+				if err = rows.Close(); err != nil {
+					db.log.Warnf("err closing rows: %v", err)
+				}
+			}()
+			report = report[:0]
+			for rows.Next() {
+				if err = rows.StructScan(&row); err != nil {
+					return err
+				}
+				report = append(report, row)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return report, nil
+	}
+	return nil, err
+}
+
+func (db *DB) InsertExchangeQuote(ctx context.Context, quote models.ExchangeQuote) error {
+	query := `
+	INSERT INTO exchange_quote (id, owner_id, from_currency, to_currency, rate, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	var err error
+	for i := 0; i < retries; i++ {
+		_, err = db.db.ExecContext(ctx, query, quote.ID, quote.AccountID, quote.From, quote.To, int64(quote.Rate),
+			quote.ExpiresAt.UTC().Format(dateTimeLayout), time.Now().UTC().Format(dateTimeLayout))
+		if err != nil {
+			err = fmt.Errorf("err executing [InsertExchangeQuote]: %w", constraintError(err))
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) GetExchangeQuote(ctx context.Context, accountID int, quoteID string) (*models.ExchangeQuote, error) {
+	query := `
+	SELECT id, owner_id, from_currency, to_currency, rate(rate) AS rate, expires_at
+	FROM exchange_quote
+	WHERE id = $1 AND
+	      owner_id = $2 AND
+	      used_at IS NULL AND
+	      expires_at > $3`
+	var quote models.ExchangeQuote
+	var err error
+	for i := 0; i < retries; i++ {
+		if err = db.db.GetContext(ctx, &quote, query, quoteID, accountID,
+			time.Now().UTC().Format(dateTimeLayout)); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrQuoteNotFound
+			}
+			continue
+		}
+		return &quote, nil
+	}
+	return nil, err
+}
+
+func (db *DB) useExchangeQuote(ctx context.Context, tx *sql.Tx, accountID int, quoteID string) error {
+	query := `
+	UPDATE exchange_quote
+	SET used_at = $3
+	WHERE id = $1 AND
+	      owner_id = $2 AND
+	      used_at IS NULL AND
+	      expires_at > $3`
+	result, err := tx.ExecContext(ctx, query, quoteID, accountID, time.Now().UTC().Format(dateTimeLayout))
+	if err != nil {
+		return fmt.Errorf("err executing [useExchangeQuote]: %w", err)
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return models.ErrQuoteNotFound
+	}
+	return nil
+}
+
+type capturedOrder struct {
+	orderID      int
+	ownerID      int
+	serviceID    int
+	currency     string
+	serviceTitle string
+	captured     models.Money
+	refunded     models.Money
+	captures     []orderCapture
+}
+
+// orderCapture is a capture of the order with the part of it that is not refunded yet.
+type orderCapture struct {
+	id            int
+	transactionID *int
+	refundable    models.Money
+}
+
+type captureRefund struct {
+	captureID int
+	amount    models.Money
+}
+
+// reverseCaptures spreads a refund over the captures it reverses, latest capture first,
+// and returns the transaction of the first of them.
+func (order *capturedOrder) reverseCaptures(amount models.Money) ([]captureRefund, *int) {
+	reversed := make([]captureRefund, 0, 1)
+	var reversalOf *int
+	for _, capture := range order.captures {
+		if amount == 0 {
+			break
+		}
+		if capture.refundable <= 0 {
+			continue
+		}
+		part := capture.refundable
+		if part > amount {
+			part = amount
+		}
+		if len(reversed) == 0 {
+			reversalOf = capture.transactionID
+		}
+		reversed = append(reversed, captureRefund{captureID: capture.id, amount: part})
+		amount -= part
+	}
+	return reversed, reversalOf
+}
+
+func (db *DB) lockCapturedOrder(ctx context.Context, tx *sql.Tx, ownerID, orderID int) (*capturedOrder, error) {
+	query := `
+	SELECT order_id, owner_id, service_id, currency, money(captured_amount), status
+	FROM reserved_funds
+	WHERE owner_id = $1 AND order_id = $2`
+	order := capturedOrder{}
+	var status string
+	err := tx.QueryRowContext(ctx, query, ownerID, orderID).Scan(&order.orderID, &order.ownerID, &order.serviceID,
+		&order.currency, &order.captured, &status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("err executing [lockCapturedOrder]: %w", err)
+	}
+	if status != models.ReservationCompleted || order.captured == 0 {
+		return nil, models.ErrOrderNotCompleted
+	}
+	query = `
+	SELECT money(COALESCE(SUM(amount), 0))
+	FROM refund
+	WHERE order_id = $1`
+	if err = tx.QueryRowContext(ctx, query, orderID).Scan(&order.refunded); err != nil {
+		return nil, fmt.Errorf("err executing [lockCapturedOrder]: %w", err)
+	}
+	query = `
+	SELECT c.id, c.transaction_id, c.service_title,
+	       money(c.amount - COALESCE((SELECT SUM(rc.amount) FROM refund_capture rc WHERE rc.capture_id = c.id), 0))
+	FROM capture c
+	WHERE c.order_id = $1
+	ORDER BY c.created_at DESC, c.id DESC`
+	rows, err := tx.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("err executing [lockCapturedOrder]: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		capture := orderCapture{}
+		var serviceTitle string
+		if err = rows.Scan(&capture.id, &capture.transactionID, &serviceTitle, &capture.refundable); err != nil {
+			return nil, fmt.Errorf("err executing [lockCapturedOrder]: %w", err)
+		}
+		if len(order.captures) == 0 {
+			order.serviceTitle = serviceTitle
+		}
+		order.captures = append(order.captures, capture)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("err executing [lockCapturedOrder]: %w", err)
+	}
+	if len(order.captures) == 0 {
+		return nil, models.ErrOrderNotCompleted
+	}
+	return &order, nil
+}
+
+func (db *DB) insertRefund(ctx context.Context, tx *sql.Tx, order *capturedOrder, amount models.Money,
+	transactionID int, reversed []captureRefund) error {
+	query := `
+	INSERT INTO refund (order_id, owner_id, service_id, service_title, currency, amount, transaction_id, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id`
+	var refundID int
+	err := tx.QueryRowContext(ctx, query, order.orderID, order.ownerID, order.serviceID, order.serviceTitle,
+		order.currency, int64(amount), transactionID, time.Now().UTC().Format(dateTimeLayout)).Scan(&refundID)
+	if err != nil {
+		return fmt.Errorf("err executing [insertRefund]: %w", err)
+	}
+	query = `
+	INSERT INTO refund_capture (refund_id, capture_id, amount)
+	VALUES ($1, $2, $3)`
+	for _, capture := range reversed {
+		if _, err = tx.ExecContext(ctx, query, refundID, capture.captureID, int64(capture.amount)); err != nil {
+			return fmt.Errorf("err executing [insertRefund]: %w", err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) reserveMoney(ctx context.Context, tx *sql.Tx, walletID int, amount models.Money) error {
+	query := `
+	UPDATE wallet
+	SET reserved_balance = reserved_balance + $1,
+	updated_at = $3
+	WHERE id = $2`
+	result, err := tx.ExecContext(ctx, query, int64(amount), walletID, time.Now().UTC().Format(dateTimeLayout))
+	if err != nil {
+		return fmt.Errorf("err executing [addReserve]: %w", err)
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return models.ErrWalletNotFound
+	}
+	return nil
+}
+
+func (db *DB) insertReservedFunds(ctx context.Context, tx *sql.Tx, accountID int, transaction models.ReserveTransaction) error {
+	query := `
+	INSERT INTO reserved_funds (order_id, owner_id, currency, service_id, amount, status, created_at, updated_at,
+	                            expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $7, $8)`
+	status := models.ReservationActive
+	_, err := tx.ExecContext(ctx, query, transaction.OrderID, accountID, transaction.Currency, transaction.ServiceID,
+		int64(transaction.Amount), status, time.Now().UTC().Format(dateTimeLayout), nullTime(transaction.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("err executing [insertReservedFunds]: %w", constraintError(err))
+	}
+	return nil
+}
+
+func (db *DB) checkBalance(ctx context.Context, tx *sql.Tx, ownerID int, currency string,
+	amount models.Money) (*models.Wallet, error) {
+	query := `
+	SELECT id, currency, money(balance)
+	FROM wallet
+	WHERE owner_id = $1 AND currency = $2`
+	row := tx.QueryRowContext(ctx, query, ownerID, currency)
+	var wallet models.Wallet
+	if err := row.Scan(&wallet.ID, &wallet.Currency, &wallet.Balance); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrWalletNotFound
+		}
+		return nil, fmt.Errorf("err checking balance: %w", err)
+	}
+	if amount == 0 {
+		return &wallet, nil
+	}
+	if wallet.Balance-amount < 0 {
+		return nil, models.ErrNotEnoughMoney
+	}
+	return &wallet, nil
+}
+
+type reservedOrder struct {
+	orderID   int
+	ownerID   int
+	serviceID int
+	currency  string
+	amount    models.Money
+	captured  models.Money
+}
+
+func (db *DB) lockReservedOrder(ctx context.Context, tx *sql.Tx, transaction models.ReserveTransaction) (*reservedOrder, error) {
+	query := `
+	SELECT order_id, owner_id, service_id, currency, money(amount), money(captured_amount)
+	FROM reserved_funds
+	WHERE owner_id = $1 AND
+	      service_id = $2 AND
+	      order_id = $3 AND
+	      status = $4`
+	order := reservedOrder{}
+	err := tx.QueryRowContext(ctx, query, transaction.AccountID, transaction.ServiceID, transaction.OrderID,
+		models.ReservationActive).Scan(&order.orderID, &order.ownerID, &order.serviceID, &order.currency, &order.amount,
+		&order.captured)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("err executing [lockReservedOrder]: %w", err)
+	}
+	if order.currency != transaction.Currency {
+		return nil, models.ErrCurrencyMismatch
+	}
+	return &order, nil
+}
+
+func (db *DB) updateOrder(ctx context.Context, tx *sql.Tx, order *reservedOrder, status string) error {
+	query := `
+	UPDATE reserved_funds
+	SET status = $1,
+	captured_amount = $2,
+	updated_at = $3
+	WHERE order_id = $4`
+	result, err := tx.ExecContext(ctx, query, status, int64(order.captured), time.Now().UTC().Format(dateTimeLayout),
+		order.orderID)
+	if err != nil {
+		return fmt.Errorf("err executing [updateOrder]: %w", err)
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return models.ErrOrderNotFound
+	}
+	return nil
+}
+
+func (db *DB) releaseReserve(ctx context.Context, tx *sql.Tx, walletID int, order *reservedOrder,
+	amount models.Money, kind, comment string) error {
+	if amount == 0 {
+		return nil
+	}
+	if err := db.withdrawReservedMoney(ctx, tx, walletID, amount); err != nil {
+		return err
+	}
+	if err := db.depositToWallet(ctx, tx, walletID, amount); err != nil {
+		return err
+	}
+	transactionID, err := db.insertTransaction(ctx, tx, transactionRecord{
+		kind:      models.TransactionKindReserveRelease,
+		walletID:  walletID,
+		amount:    amount,
+		serviceID: &order.serviceID,
+		comment:   comment,
+	})
+	if err != nil {
+		return err
+	}
+	return db.insertJournalEntry(ctx, tx, kind, orderReference(order.orderID), &transactionID,
+		reserved(order.ownerID, order.currency, -amount),
+		available(order.ownerID, order.currency, amount))
+}
+
+func (db *DB) cancelOrder(ctx context.Context, tx *sql.Tx, wallet *models.Wallet, order *reservedOrder,
+	status string) error {
+	remaining := order.amount - order.captured
+	if wallet.ReservedBalance < remaining {
+		return models.ErrNotEnoughReservedMoney
+	}
+	kind, comment, eventType := entryReserveCancel, "Отмена резервирования", models.EventReserveCancelled
+	if status == models.ReservationExpired {
+		kind, comment, eventType = entryReserveExpire, "Истек срок резервирования", models.EventReserveExpired
+	}
+	if err := db.releaseReserve(ctx, tx, wallet.ID, order, remaining, kind, comment); err != nil {
+		return err
+	}
+	err := db.insertEvent(ctx, tx, eventType, models.BalanceEvent{
+		AccountID: order.ownerID,
+		OrderID:   order.orderID,
+		ServiceID: order.serviceID,
+		Currency:  order.currency,
+		Amount:    remaining,
+	})
+	if err != nil {
+		return err
+	}
+	return db.updateOrder(ctx, tx, order, status)
+}
+
+func (db *DB) insertCapture(ctx context.Context, tx *sql.Tx, order *reservedOrder, serviceTitle string,
+	amount models.Money, transactionID int) error {
+	query := `
+	INSERT INTO capture (order_id, service_id, service_title, currency, amount, transaction_id, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := tx.ExecContext(ctx, query, order.orderID, order.serviceID, serviceTitle, order.currency, int64(amount),
+		transactionID, time.Now().UTC().Format(dateTimeLayout))
+	if err != nil {
+		return fmt.Errorf("err executing [insertCapture]: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) checkReservedBalance(ctx context.Context, tx *sql.Tx, ownerID int, currency string,
+	amount models.Money) (*models.Wallet, error) {
+	query := `
+	SELECT id, money(reserved_balance)
+	FROM wallet
+	WHERE owner_id = $1 AND currency = $2`
+	row := tx.QueryRowContext(ctx, query, ownerID, currency)
+	var wallet models.Wallet
+	if err := row.Scan(&wallet.ID, &wallet.ReservedBalance); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrWalletNotFound
+		}
+		return nil, fmt.Errorf("err checking reserved balance: %w", err)
+	}
+	if wallet.ReservedBalance-amount < 0 {
+		return nil, models.ErrNotEnoughReservedMoney
+	}
+	return &wallet, nil
+}
+
+type transactionRecord struct {
+	idempotenceKey *int
+	kind           string
+	walletID       int
+	targetWalletID *int
+	amount         models.Money
+	serviceID      *int
+	comment        string
+	targetAmount   *models.Money
+	exchangeRate   *models.Rate
+	quoteID        *string
+	reversalOf     *int
+}
+
+func (db *DB) insertTransaction(ctx context.Context, tx *sql.Tx, record transactionRecord) (int, error) {
+	query := `
+	INSERT INTO "transaction" (idempotence_key, kind, wallet_id, amount, target_wallet_id, service_id, comment, timestamp,
+	                         target_amount, exchange_rate, quote_id, reversal_of)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	RETURNING id`
+	var id int
+	err := tx.QueryRowContext(ctx, query, record.idempotenceKey, record.kind, record.walletID, int64(record.amount),
+		record.targetWalletID, record.serviceID, record.comment, time.Now().UTC().Format(dateTimeLayout),
+		nullMoney(record.targetAmount), nullRate(record.exchangeRate), record.quoteID, record.reversalOf).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("err executing [insertTransaction]: %w", constraintError(err))
+	}
+	return id, nil
+}
+
+func (db *DB) withdrawMoney(ctx context.Context, tx *sql.Tx, walletID int, amount models.Money) error {
+	query := `
+	UPDATE wallet
+	SET balance = balance - $1,
+	updated_at = $3
+	WHERE id = $2`
+	result, err := tx.ExecContext(ctx, query, int64(amount), walletID, time.Now().UTC().Format(dateTimeLayout))
+	if err != nil {
+		return fmt.Errorf("err executing [withdrawMoney]: %w", err)
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return models.ErrWalletNotFound
+	}
+	return nil
+}
+
+func (db *DB) withdrawReservedMoney(ctx context.Context, tx *sql.Tx, walletID int, amount models.Money) error {
+	query := `
+	UPDATE wallet
+	SET reserved_balance = reserved_balance - $1,
+	updated_at = $3
+	WHERE id = $2`
+	result, err := tx.ExecContext(ctx, query, int64(amount), walletID, time.Now().UTC().Format(dateTimeLayout))
+	if err != nil {
+		return fmt.Errorf("err executing [withdrawReservedMoney]: %w", err)
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return models.ErrWalletNotFound
+	}
+	return nil
+}
+
+func (db *DB) depositMoney(ctx context.Context, tx *sql.Tx, ownerID int, currency string, amount models.Money) (int, error) {
+	query := `
+	UPDATE wallet
+	SET balance = balance + $1,
+	updated_at = $4
+	WHERE owner_id = $2 AND currency = $3
+	RETURNING id`
+	var walletID int
+	err := tx.QueryRowContext(ctx, query, int64(amount), ownerID, currency,
+		time.Now().UTC().Format(dateTimeLayout)).Scan(&walletID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, db.walletNotFoundReason(ctx, tx, ownerID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("err executing [depositMoney]: %w", err)
+	}
+	return walletID, nil
+}
+
+func (db *DB) depositToWallet(ctx context.Context, tx *sql.Tx, walletID int, amount models.Money) error {
+	query := `
+	UPDATE wallet
+	SET balance = balance + $1,
+	updated_at = $3
+	WHERE id = $2`
+	result, err := tx.ExecContext(ctx, query, int64(amount), walletID, time.Now().UTC().Format(dateTimeLayout))
+	if err != nil {
+		return fmt.Errorf("err executing [depositToWallet]: %w", err)
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return models.ErrWalletNotFound
+	}
+	return nil
+}
+
+func (db *DB) walletNotFoundReason(ctx context.Context, tx *sql.Tx, ownerID int) error {
+	query := `
+	SELECT EXISTS (SELECT 1 FROM wallet WHERE owner_id = $1)`
+	var exists bool
+	if err := tx.QueryRowContext(ctx, query, ownerID).Scan(&exists); err != nil {
+		return fmt.Errorf("err executing [walletNotFoundReason]: %w", err)
+	}
+	if exists {
+		return models.ErrCurrencyMismatch
+	}
+	return models.ErrWalletNotFound
+}
+
+func (db *DB) checkWalletExists(ctx context.Context, ownerID int, currency string) error {
+	query := `
+	SELECT EXISTS (SELECT 1 FROM wallet WHERE owner_id = $1 AND ($2 = '' OR currency = $2))`
+	var exists bool
+	if err := db.db.QueryRowContext(ctx, query, ownerID, currency).Scan(&exists); err != nil {
+		return fmt.Errorf("err executing [checkWalletExists]: %w", err)
+	}
+	if !exists {
+		return models.ErrWalletNotFound
+	}
+	return nil
+}
+
+func (db *DB) getActiveServiceTitle(ctx context.Context, tx *sql.Tx, serviceID int) (string, error) {
+	query := `
+	SELECT title, active
+	FROM services
+	WHERE id = $1`
+	row := tx.QueryRowContext(ctx, query, serviceID)
+	var title string
+	var active bool
+	if err := row.Scan(&title, &active); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", models.ErrServiceNotFound
+		}
+		return "", fmt.Errorf("err getting service title: %w", err)
+	}
+	if !active {
+		return "", models.ErrServiceInactive
+	}
+	return title, nil
+}
+
+func (db *DB) queryBuilder(accountID int, queryParams *models.TransactionsQueryParams,
+	cursor *models.TransactionsCursor) (string, []interface{}) {
+	query := `SELECT h.id, h.kind, CASE WHEN h.amount < 0 THEN $5 ELSE $6 END AS direction, money(h.amount) AS amount,
+	       h.currency, h.counterparty, money(h.target_amount) AS target_amount, rate(h.exchange_rate) AS exchange_rate,
+	       h.service_id, h.reversal_of, h.comment, h.timestamp
+	FROM (SELECT t.id, t.kind, t.amount, w.currency, tw.owner_id AS counterparty,
+	             t.target_amount, t.exchange_rate, t.service_id, t.reversal_of, t.comment, t.timestamp
+	      FROM "transaction" t
+	      INNER JOIN wallet w ON w.id = t.wallet_id
+	      LEFT JOIN wallet tw ON tw.id = t.target_wallet_id
+	      WHERE w.owner_id = $1 AND ($2 = '' OR w.currency = $2)
+	      UNION ALL
+	      SELECT t.id, t.kind, COALESCE(t.target_amount, -t.amount), tw.currency, w.owner_id,
+	             t.target_amount, t.exchange_rate, t.service_id, t.reversal_of, t.comment, t.timestamp
+	      FROM "transaction" t
+	      INNER JOIN wallet w ON w.id = t.wallet_id
+	      INNER JOIN wallet tw ON tw.id = t.target_wallet_id
+	      WHERE tw.owner_id = $1 AND ($2 = '' OR tw.currency = $2)) h
+	WHERE h.timestamp BETWEEN $3 AND $4`
+	args := []interface{}{accountID, queryParams.Currency, queryParams.From.UTC().Format(dateTimeLayout),
+		queryParams.To.UTC().Format(dateTimeLayout), models.DirectionOut, models.DirectionIn}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	switch queryParams.Type {
+	case "":
+	case models.TransactionTypeTransferIn:
+		query += " AND h.kind = " + arg(models.TransactionKindTransfer) + " AND h.amount >= 0"
+	case models.TransactionTypeTransferOut:
+		query += " AND h.kind = " + arg(models.TransactionKindTransfer) + " AND h.amount < 0"
+	default:
+		query += " AND h.kind = " + arg(queryParams.Type)
+	}
+	if queryParams.Counterparty != 0 {
+		query += " AND h.counterparty = " + arg(queryParams.Counterparty)
+	}
+	if queryParams.ServiceID != 0 {
+		query += " AND h.service_id = " + arg(queryParams.ServiceID)
+	}
+	if queryParams.MinAmount != nil {
+		query += " AND ABS(h.amount) >= " + arg(int64(*queryParams.MinAmount))
+	}
+	if queryParams.MaxAmount != nil {
+		query += " AND ABS(h.amount) <= " + arg(int64(*queryParams.MaxAmount))
+	}
+	if queryParams.Comment != "" {
+		query += " AND instr(utf8_lower(h.comment), utf8_lower(" + arg(queryParams.Comment) + ")) > 0"
+	}
+
+	sortColumn := "h.timestamp"
+	if queryParams.Sorting == models.SortingAmount {
+		sortColumn = "h.amount"
+	}
+	direction, comparison := " DESC", " < "
+	if queryParams.Descending == "false" {
+		direction, comparison = " ASC", " > "
+	}
+	// A transfer between the caller's own wallets has a row per wallet with the same ID, the amount tells them apart.
+	orderColumns := []string{sortColumn, "h.id"}
+	if sortColumn != "h.amount" {
+		orderColumns = append(orderColumns, "h.amount")
+	}
+	if cursor != nil {
+		var value interface{} = cursor.Timestamp.UTC().Format(dateTimeLayout)
+		if cursor.Sorting == models.SortingAmount {
+			value = int64(cursor.Amount)
+		}
+		values := []string{arg(value), arg(cursor.ID)}
+		if len(orderColumns) > len(values) {
+			values = append(values, arg(int64(cursor.Amount)))
+		}
+		query += " AND (" + strings.Join(orderColumns, ", ") + ")" + comparison + "(" + strings.Join(values, ", ") + ")"
+	}
+	query += " ORDER BY " + strings.Join(orderColumns, direction+", ") + direction
+	query += " LIMIT " + arg(queryParams.Limit) + " OFFSET " + arg(queryParams.Offset)
+	return query, args
+}
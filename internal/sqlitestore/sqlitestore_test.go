@@ -0,0 +1,28 @@
+package sqlitestore_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/DANDA322/balance-service/internal/sqlitestore"
+	"github.com/DANDA322/balance-service/internal/storetest"
+	"github.com/DANDA322/balance-service/pkg/logging"
+	migrate "github.com/rubenv/sql-migrate"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	store, err := sqlitestore.GetSQLiteStore(context.Background(), logging.GetLogger("false"),
+		filepath.Join(t.TempDir(), "balance.db"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, store.Close())
+	}()
+	suite.Run(t, &storetest.Suite{Reset: func() storetest.Store {
+		require.NoError(t, store.Migrate(migrate.Down))
+		require.NoError(t, store.Migrate(migrate.Up))
+		return store
+	}})
+}
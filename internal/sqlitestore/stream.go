@@ -0,0 +1,105 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+func (db *DB) GetWallets(ctx context.Context, accountID int) ([]models.Wallet, error) {
+	query := `
+	SELECT id, owner_id, currency, money(balance) AS balance, money(reserved_balance) AS reserved_balance, created_at,
+	       updated_at
+	FROM wallet
+	WHERE owner_id = $1
+	ORDER BY currency`
+	var err error
+	for i := 0; i < retries; i++ {
+		wallets := make([]models.Wallet, 0)
+		if err = db.db.SelectContext(ctx, &wallets, query, accountID); err != nil {
+			err = fmt.Errorf("err executing [GetWallets]: %w", err)
+			continue
+		}
+		return wallets, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetEvents(ctx context.Context, accountID int, afterSequence int64, limit int) ([]models.Event, error) {
+	query := `
+	SELECT id, account_id, sequence, event_type, payload, created_at
+	FROM outbox
+	WHERE account_id = $1 AND sequence > $2
+	ORDER BY sequence
+	LIMIT $3`
+	var err error
+	for i := 0; i < retries; i++ {
+		events := make([]models.Event, 0)
+		if err = db.db.SelectContext(ctx, &events, query, accountID, afterSequence, limit); err != nil {
+			err = fmt.Errorf("err executing [GetEvents]: %w", err)
+			continue
+		}
+		return events, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetLastEventSequence(ctx context.Context, accountID int) (int64, error) {
+	query := `
+	SELECT last_sequence
+	FROM outbox_sequence
+	WHERE account_id = $1`
+	var err error
+	for i := 0; i < retries; i++ {
+		var sequence int64
+		if err = db.db.GetContext(ctx, &sequence, query, accountID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return 0, nil
+			}
+			err = fmt.Errorf("err executing [GetLastEventSequence]: %w", err)
+			continue
+		}
+		return sequence, nil
+	}
+	return 0, err
+}
+
+// ListenEvents calls notify with the account id of each committed event until ctx is done.
+// SQLite has no LISTEN/NOTIFY, only events committed by this process are reported.
+func (db *DB) ListenEvents(ctx context.Context, notify func(accountID int)) error {
+	db.listenersMu.Lock()
+	db.lastListenerID++
+	listenerID := db.lastListenerID
+	db.listeners[listenerID] = notify
+	db.listenersMu.Unlock()
+	defer func() {
+		db.listenersMu.Lock()
+		delete(db.listeners, listenerID)
+		db.listenersMu.Unlock()
+	}()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// notifyEvent remembers the account id until tx commits.
+func (db *DB) notifyEvent(tx *sql.Tx, accountID int) {
+	db.listenersMu.Lock()
+	defer db.listenersMu.Unlock()
+	db.pending[tx] = append(db.pending[tx], accountID)
+}
+
+func (db *DB) notify(accountIDs []int) {
+	if len(accountIDs) == 0 {
+		return
+	}
+	db.listenersMu.Lock()
+	defer db.listenersMu.Unlock()
+	for _, notify := range db.listeners {
+		for _, accountID := range accountIDs {
+			notify(accountID)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	webhookColumns  = `id, url, secret, event_types, active, created_at, updated_at`
+	deliveryColumns = `d.id, d.webhook_id, d.event_id, o.event_type, o.account_id, d.status, d.attempt_count,
+	d.next_attempt_at, d.last_error, d.response_status, d.delivered_at, d.created_at, d.updated_at`
+)
+
+func (db *DB) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	query := `
+	SELECT ` + webhookColumns + `
+	FROM webhooks
+	ORDER BY id`
+	var err error
+	for i := 0; i < retries; i++ {
+		webhooks := make([]models.Webhook, 0)
+		if err = db.db.SelectContext(ctx, &webhooks, query); err != nil {
+			err = fmt.Errorf("err executing [GetWebhooks]: %w", err)
+			continue
+		}
+		return webhooks, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetWebhook(ctx context.Context, webhookID int) (*models.Webhook, error) {
+	query := `
+	SELECT ` + webhookColumns + `
+	FROM webhooks
+	WHERE id = $1`
+	var err error
+	for i := 0; i < retries; i++ {
+		webhook := models.Webhook{}
+		if err = db.db.GetContext(ctx, &webhook, query, webhookID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrWebhookNotFound
+			}
+			err = fmt.Errorf("err executing [GetWebhook]: %w", err)
+			continue
+		}
+		return &webhook, nil
+	}
+	return nil, err
+}
+
+func (db *DB) CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	query := `
+	INSERT INTO webhooks (url, secret, event_types, active, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $5)
+	RETURNING ` + webhookColumns
+	var err error
+	for i := 0; i < retries; i++ {
+		created := models.Webhook{}
+		err = db.db.GetContext(ctx, &created, query, webhook.URL, webhook.Secret, webhook.EventTypes, webhook.Active,
+			time.Now().UTC().Format(dateTimeLayout))
+		if err != nil {
+			err = fmt.Errorf("err executing [CreateWebhook]: %w", err)
+			continue
+		}
+		return &created, nil
+	}
+	return nil, err
+}
+
+func (db *DB) UpdateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	query := `
+	UPDATE webhooks
+	SET url = $1,
+	secret = COALESCE(NULLIF($2, ''), secret),
+	event_types = $3,
+	active = $4,
+	updated_at = $5
+	WHERE id = $6
+	RETURNING ` + webhookColumns
+	var err error
+	for i := 0; i < retries; i++ {
+		updated := models.Webhook{}
+		err = db.db.GetContext(ctx, &updated, query, webhook.URL, webhook.Secret, webhook.EventTypes, webhook.Active,
+			time.Now().UTC().Format(dateTimeLayout), webhook.ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrWebhookNotFound
+			}
+			err = fmt.Errorf("err executing [UpdateWebhook]: %w", err)
+			continue
+		}
+		return &updated, nil
+	}
+	return nil, err
+}
+
+func (db *DB) DeleteWebhook(ctx context.Context, webhookID int) error {
+	query := `
+	DELETE FROM webhooks
+	WHERE id = $1`
+	var err error
+	var result sql.Result
+	for i := 0; i < retries; i++ {
+		result, err = db.db.ExecContext(ctx, query, webhookID)
+		if err != nil {
+			err = fmt.Errorf("err executing [DeleteWebhook]: %w", err)
+			continue
+		}
+		if count, _ := result.RowsAffected(); count == 0 {
+			return models.ErrWebhookNotFound
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) GetWebhookDeliveries(ctx context.Context,
+	queryParams *models.WebhookDeliveriesQueryParams) ([]models.WebhookDelivery, error) {
+	query := `
+	SELECT ` + deliveryColumns + `
+	FROM webhook_deliveries d
+	JOIN outbox o ON o.id = d.event_id
+	WHERE ($1 = 0 OR d.webhook_id = $1) AND
+	      ($2 = '' OR d.status = $2)
+	ORDER BY d.id DESC
+	LIMIT $3 OFFSET $4`
+	var err error
+	for i := 0; i < retries; i++ {
+		deliveries := make([]models.WebhookDelivery, 0)
+		err = db.db.SelectContext(ctx, &deliveries, query, queryParams.WebhookID, queryParams.Status,
+			queryParams.Limit, queryParams.Offset)
+		if err != nil {
+			err = fmt.Errorf("err executing [GetWebhookDeliveries]: %w", err)
+			continue
+		}
+		return deliveries, nil
+	}
+	return nil, err
+}
+
+func (db *DB) GetWebhookDelivery(ctx context.Context, deliveryID int) (*models.WebhookDelivery, error) {
+	query := `
+	SELECT ` + deliveryColumns + `
+	FROM webhook_deliveries d
+	JOIN outbox o ON o.id = d.event_id
+	WHERE d.id = $1`
+	attemptsQuery := `
+	SELECT delivery_id, response_status, error, duration_ms, attempted_at
+	FROM webhook_attempts
+	WHERE delivery_id = $1
+	ORDER BY id`
+	var err error
+	for i := 0; i < retries; i++ {
+		delivery := models.WebhookDelivery{}
+		if err = db.db.GetContext(ctx, &delivery, query, deliveryID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, models.ErrDeliveryNotFound
+			}
+			err = fmt.Errorf("err executing [GetWebhookDelivery]: %w", err)
+			continue
+		}
+		delivery.Attempts = make([]models.WebhookAttempt, 0)
+		if err = db.db.SelectContext(ctx, &delivery.Attempts, attemptsQuery, deliveryID); err != nil {
+			err = fmt.Errorf("err executing [GetWebhookDelivery]: %w", err)
+			continue
+		}
+		return &delivery, nil
+	}
+	return nil, err
+}
+
+// ReplayWebhookDelivery puts a delivery back in the queue with a fresh retry budget.
+func (db *DB) ReplayWebhookDelivery(ctx context.Context, deliveryID int, now time.Time) error {
+	query := `
+	UPDATE webhook_deliveries
+	SET status = $1,
+	attempt_count = 0,
+	next_attempt_at = $2,
+	updated_at = $2
+	WHERE id = $3`
+	var err error
+	var result sql.Result
+	for i := 0; i < retries; i++ {
+		result, err = db.db.ExecContext(ctx, query, models.DeliveryPending, now.UTC().Format(dateTimeLayout), deliveryID)
+		if err != nil {
+			err = fmt.Errorf("err executing [ReplayWebhookDelivery]: %w", err)
+			continue
+		}
+		if count, _ := result.RowsAffected(); count == 0 {
+			return models.ErrDeliveryNotFound
+		}
+		return nil
+	}
+	return err
+}
+
+// ClaimWebhookDeliveries returns due deliveries and pushes their next attempt forward by lease,
+// so that another dispatcher does not pick them up while they are in flight.
+func (db *DB) ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration,
+	limit int) ([]models.WebhookDispatch, error) {
+	selectQuery := `
+	SELECT d.id, d.attempt_count, w.url, w.secret,
+	       o.id AS event_id, o.account_id, o.sequence, o.event_type, o.payload, o.created_at
+	FROM webhook_deliveries d
+	JOIN webhooks w ON w.id = d.webhook_id
+	JOIN outbox o ON o.id = d.event_id
+	WHERE d.status = $1 AND d.next_attempt_at <= $2
+	ORDER BY d.next_attempt_at, d.id
+	LIMIT $3`
+	updateQuery := `
+	UPDATE webhook_deliveries
+	SET next_attempt_at = $1
+	WHERE id IN (SELECT value FROM json_each($2))`
+	var err error
+	var tx *sqlx.Tx
+	for i := 0; i < retries; i++ {
+		dispatches := make([]models.WebhookDispatch, 0)
+		err = func() error {
+			tx, err = db.db.BeginTxx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back claim transaction")
+				}
+			}()
+			rows, err := tx.QueryContext(ctx, selectQuery, models.DeliveryPending, now.UTC().Format(dateTimeLayout), limit)
+			if err != nil {
+				return fmt.Errorf("err executing [ClaimWebhookDeliveries]: %w", err)
+			}
+			ids := make([]int, 0)
+			for rows.Next() {
+				d := models.WebhookDispatch{}
+				err = rows.Scan(&d.DeliveryID, &d.AttemptCount, &d.URL, &d.Secret, &d.Event.ID, &d.Event.AccountID,
+					&d.Event.Sequence, &d.Event.Type, &d.Event.Payload, &d.Event.CreatedAt)
+				if err != nil {
+					_ = rows.Close()
+					return fmt.Errorf("err executing [ClaimWebhookDeliveries]: %w", err)
+				}
+				dispatches = append(dispatches, d)
+				ids = append(ids, d.DeliveryID)
+			}
+			if err = rows.Close(); err != nil {
+				return fmt.Errorf("err executing [ClaimWebhookDeliveries]: %w", err)
+			}
+			if len(ids) == 0 {
+				return nil
+			}
+			idList, err := json.Marshal(ids)
+			if err != nil {
+				return fmt.Errorf("err executing [ClaimWebhookDeliveries]: %w", err)
+			}
+			_, err = tx.ExecContext(ctx, updateQuery, now.Add(lease).UTC().Format(dateTimeLayout), string(idList))
+			if err != nil {
+				return fmt.Errorf("err executing [ClaimWebhookDeliveries]: %w", err)
+			}
+			if err = tx.Commit(); err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return dispatches, nil
+	}
+	return nil, err
+}
+
+// RecordWebhookAttempt logs an attempt and moves the delivery to its next state.
+func (db *DB) RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt, status string,
+	nextAttemptAt time.Time) error {
+	attemptQuery := `
+	INSERT INTO webhook_attempts (delivery_id, response_status, error, duration_ms, attempted_at)
+	VALUES ($1, $2, $3, $4, $5)`
+	deliveryQuery := `
+	UPDATE webhook_deliveries
+	SET status = $1,
+	attempt_count = attempt_count + 1,
+	next_attempt_at = $2,
+	last_error = $3,
+	response_status = $4,
+	delivered_at = CASE WHEN $1 = '` + models.DeliveryDelivered + `' THEN $5 END,
+	updated_at = $5
+	WHERE id = $6`
+	var err error
+	var tx *sql.Tx
+	attemptedAt := attempt.AttemptedAt.UTC().Format(dateTimeLayout)
+	for i := 0; i < retries; i++ {
+		err = func() error {
+			tx, err = db.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err = tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					db.log.Error("err rolling back webhook attempt transaction")
+				}
+			}()
+			_, err = tx.ExecContext(ctx, attemptQuery, attempt.DeliveryID, attempt.ResponseStatus, attempt.Error,
+				attempt.Duration, attemptedAt)
+			if err != nil {
+				return fmt.Errorf("err executing [RecordWebhookAttempt]: %w", constraintError(err))
+			}
+			_, err = tx.ExecContext(ctx, deliveryQuery, status, nextAttemptAt.UTC().Format(dateTimeLayout), attempt.Error,
+				attempt.ResponseStatus, attemptedAt, attempt.DeliveryID)
+			if err != nil {
+				return fmt.Errorf("err executing [RecordWebhookAttempt]: %w", err)
+			}
+			if err = tx.Commit(); err != nil {
+				return fmt.Errorf("err committing the transaction: %w", err)
+			}
+			return nil
+		}()
+		if err != nil {
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (db *DB) insertWebhookDeliveries(ctx context.Context, tx *sql.Tx, eventID int, eventType string) error {
+	query := `
+	INSERT INTO webhook_deliveries (webhook_id, event_id, status, next_attempt_at, created_at, updated_at)
+	SELECT id, $1, $2, $3, $3, $3
+	FROM webhooks
+	WHERE active AND EXISTS (SELECT 1 FROM json_each(event_types) WHERE value = $4)`
+	_, err := tx.ExecContext(ctx, query, eventID, models.DeliveryPending, time.Now().UTC().Format(dateTimeLayout),
+		eventType)
+	if err != nil {
+		return fmt.Errorf("err executing [insertWebhookDeliveries]: %w", err)
+	}
+	return nil
+}
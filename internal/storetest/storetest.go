@@ -0,0 +1,622 @@
+// Package storetest is a conformance suite every internal.Database implementation has to pass.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal"
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/DANDA322/balance-service/internal/outbox"
+	"github.com/DANDA322/balance-service/internal/webhook"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	owner1 = 1
+	owner2 = 2
+	admin1 = 101
+	admin2 = 102
+)
+
+// Store is everything cmd/service needs from a storage backend.
+type Store interface {
+	internal.Database
+	outbox.Store
+	webhook.Store
+}
+
+// Suite runs against the store returned by Reset, which is called before every test and must return an empty one.
+type Suite struct {
+	suite.Suite
+	Reset func() Store
+	db    Store
+	ctx   context.Context
+}
+
+func (s *Suite) SetupTest() {
+	s.ctx = context.Background()
+	s.db = s.Reset()
+}
+
+func (s *Suite) deposit(ownerID, idempotenceKey int, amount models.Money, currency string) {
+	err := s.db.UpsertDepositToWallet(s.ctx, ownerID, models.Transaction{
+		IdempotenceKey: idempotenceKey,
+		Amount:         amount,
+		Currency:       currency,
+		Comment:        "Пополнение",
+	})
+	require.NoError(s.T(), err)
+}
+
+func (s *Suite) balance(ownerID int, currency string) (models.Money, models.Money) {
+	wallet, err := s.db.GetWallet(s.ctx, ownerID, currency)
+	require.NoError(s.T(), err)
+	return wallet.Balance, wallet.ReservedBalance
+}
+
+func (s *Suite) requireUniqueViolation(err error) {
+	var pgErr *pgconn.PgError
+	require.Error(s.T(), err)
+	require.True(s.T(), errors.As(err, &pgErr))
+	require.Equal(s.T(), pgerrcode.UniqueViolation, pgErr.SQLState())
+}
+
+func (s *Suite) requireLedgerBalanced() {
+	check, err := s.db.CheckLedger(s.ctx)
+	require.NoError(s.T(), err)
+	require.True(s.T(), check.Balanced)
+	require.Empty(s.T(), check.UnbalancedEntries)
+	require.Empty(s.T(), check.MismatchedWallets)
+}
+
+func (s *Suite) TestDepositAndWithdraw() {
+	_, err := s.db.GetWallet(s.ctx, owner1, "RUB")
+	require.ErrorIs(s.T(), err, models.ErrWalletNotFound)
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	s.deposit(owner1, 2, models.NewMoney(50, 50), "RUB")
+	balance, reserved := s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.NewMoney(150, 50), balance)
+	require.Equal(s.T(), models.Money(0), reserved)
+	err = s.db.WithdrawMoneyFromWallet(s.ctx, owner1, models.Transaction{
+		IdempotenceKey: 3, Amount: models.NewMoney(200, 0), Currency: "RUB"})
+	require.ErrorIs(s.T(), err, models.ErrNotEnoughMoney)
+	err = s.db.WithdrawMoneyFromWallet(s.ctx, owner2, models.Transaction{
+		IdempotenceKey: 4, Amount: models.NewMoney(1, 0), Currency: "RUB"})
+	require.ErrorIs(s.T(), err, models.ErrWalletNotFound)
+	err = s.db.WithdrawMoneyFromWallet(s.ctx, owner1, models.Transaction{
+		IdempotenceKey: 5, Amount: models.NewMoney(50, 50), Currency: "RUB"})
+	require.NoError(s.T(), err)
+	balance, _ = s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.NewMoney(100, 0), balance)
+	s.requireLedgerBalanced()
+}
+
+func (s *Suite) TestIdempotenceKeyConflict() {
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	err := s.db.UpsertDepositToWallet(s.ctx, owner1, models.Transaction{
+		IdempotenceKey: 1, Amount: models.NewMoney(100, 0), Currency: "RUB"})
+	s.requireUniqueViolation(err)
+	err = s.db.WithdrawMoneyFromWallet(s.ctx, owner1, models.Transaction{
+		IdempotenceKey: 1, Amount: models.NewMoney(10, 0), Currency: "RUB"})
+	s.requireUniqueViolation(err)
+	balance, _ := s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.NewMoney(100, 0), balance)
+	now := time.Now().UTC()
+	transactions, err := s.db.GetWalletTransactions(s.ctx, owner1, &models.TransactionsQueryParams{
+		From: now.Add(-time.Hour), To: now.Add(time.Hour), Limit: 10}, nil)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), transactions, 1)
+	s.requireLedgerBalanced()
+}
+
+func (s *Suite) TestTransfer() {
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	transfer := models.TransferTransaction{IdempotenceKey: 2, Target: owner2, Amount: models.NewMoney(40, 0),
+		Currency: "RUB", TargetCurrency: "RUB"}
+	conversion := models.Conversion{TargetCurrency: "RUB", TargetAmount: transfer.Amount, Rate: models.Rate(1)}
+	err := s.db.TransferMoney(s.ctx, owner1, transfer, conversion)
+	require.ErrorIs(s.T(), err, models.ErrWalletNotFound)
+	s.deposit(owner2, 3, models.NewMoney(10, 0), "USD")
+	err = s.db.TransferMoney(s.ctx, owner1, transfer, conversion)
+	require.ErrorIs(s.T(), err, models.ErrCurrencyMismatch)
+	s.deposit(owner2, 4, models.NewMoney(10, 0), "RUB")
+	err = s.db.TransferMoney(s.ctx, owner1, transfer, conversion)
+	require.NoError(s.T(), err)
+	balance, _ := s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.NewMoney(60, 0), balance)
+	balance, _ = s.balance(owner2, "RUB")
+	require.Equal(s.T(), models.NewMoney(50, 0), balance)
+	transfer.Amount, conversion.TargetAmount = models.NewMoney(100, 0), models.NewMoney(100, 0)
+	transfer.IdempotenceKey = 5
+	err = s.db.TransferMoney(s.ctx, owner1, transfer, conversion)
+	require.ErrorIs(s.T(), err, models.ErrNotEnoughMoney)
+	s.requireLedgerBalanced()
+}
+
+func (s *Suite) TestTransferToOwnWallet() {
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	s.deposit(owner1, 2, models.NewMoney(1, 0), "USD")
+	transfer := models.TransferTransaction{IdempotenceKey: 3, Target: owner1, Amount: models.NewMoney(61, 25),
+		Currency: "RUB", TargetCurrency: "USD"}
+	conversion := models.Conversion{TargetCurrency: "USD", TargetAmount: models.NewMoney(1, 0),
+		Rate: models.Rate(6125000000)}
+	require.NoError(s.T(), s.db.TransferMoney(s.ctx, owner1, transfer, conversion))
+	now := time.Now().UTC()
+	queryParams := &models.TransactionsQueryParams{From: now.Add(-time.Hour), To: now.Add(time.Hour), Limit: 10}
+	transactions, err := s.db.GetWalletTransactions(s.ctx, owner1, queryParams, nil)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), transactions, 4)
+	legs := make(map[string]models.TransactionFullInfo)
+	for _, transaction := range transactions {
+		if transaction.Kind == models.TransactionKindTransfer {
+			legs[transaction.Direction] = transaction
+		}
+	}
+	require.Equal(s.T(), -models.NewMoney(61, 25), legs[models.DirectionOut].Amount)
+	require.Equal(s.T(), "RUB", legs[models.DirectionOut].Currency)
+	require.Equal(s.T(), models.NewMoney(1, 0), legs[models.DirectionIn].Amount)
+	require.Equal(s.T(), "USD", legs[models.DirectionIn].Currency)
+	require.Equal(s.T(), owner1, *legs[models.DirectionIn].Counterparty)
+	require.Equal(s.T(), legs[models.DirectionOut].ID, legs[models.DirectionIn].ID)
+	queryParams.Type = models.TransactionTypeTransferIn
+	transactions, err = s.db.GetWalletTransactions(s.ctx, owner1, queryParams, nil)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), transactions, 1)
+	require.Equal(s.T(), "USD", transactions[0].Currency)
+	queryParams.Type, queryParams.Limit = "", 1
+	var cursor *models.TransactionsCursor
+	seen := 0
+	for {
+		page, err := s.db.GetWalletTransactions(s.ctx, owner1, queryParams, cursor)
+		require.NoError(s.T(), err)
+		if len(page) == 0 {
+			break
+		}
+		seen++
+		next := models.NewTransactionsCursor(queryParams.Sorting, true, page[0])
+		cursor = &next
+	}
+	require.Equal(s.T(), 4, seen, "paging keeps both legs of the transfer")
+	s.requireLedgerBalanced()
+}
+
+func (s *Suite) TestReserveApplyAndCancel() {
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	reserve := models.ReserveTransaction{IdempotenceKey: 2, AccountID: owner1, ServiceID: 1, OrderID: 1,
+		Amount: models.NewMoney(30, 0), Currency: "RUB"}
+	require.NoError(s.T(), s.db.ReserveMoneyFromWallet(s.ctx, reserve))
+	balance, reserved := s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.NewMoney(70, 0), balance)
+	require.Equal(s.T(), models.NewMoney(30, 0), reserved)
+	duplicate := reserve
+	duplicate.IdempotenceKey = 3
+	s.requireUniqueViolation(s.db.ReserveMoneyFromWallet(s.ctx, duplicate))
+	wrongAmount := reserve
+	wrongAmount.Amount = models.NewMoney(10, 0)
+	require.ErrorIs(s.T(), s.db.CancelReserve(s.ctx, wrongAmount), models.ErrOrderNotFound)
+	apply := reserve
+	apply.IdempotenceKey = 4
+	require.NoError(s.T(), s.db.ApplyReservedMoney(s.ctx, apply))
+	balance, reserved = s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.NewMoney(70, 0), balance)
+	require.Equal(s.T(), models.Money(0), reserved)
+	order, err := s.db.GetReservation(s.ctx, 1)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), models.ReservationCompleted, order.Status)
+	require.Equal(s.T(), models.NewMoney(30, 0), order.CapturedAmount)
+	require.ErrorIs(s.T(), s.db.CancelReserve(s.ctx, reserve), models.ErrOrderNotFound)
+
+	reserve.OrderID, reserve.IdempotenceKey = 2, 5
+	require.NoError(s.T(), s.db.ReserveMoneyFromWallet(s.ctx, reserve))
+	require.NoError(s.T(), s.db.CancelReserve(s.ctx, reserve))
+	balance, reserved = s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.NewMoney(70, 0), balance)
+	require.Equal(s.T(), models.Money(0), reserved)
+	reserve.OrderID, reserve.IdempotenceKey, reserve.Amount = 3, 6, models.NewMoney(71, 0)
+	require.ErrorIs(s.T(), s.db.ReserveMoneyFromWallet(s.ctx, reserve), models.ErrNotEnoughMoney)
+	s.requireLedgerBalanced()
+}
+
+func (s *Suite) TestPartialCapturesAndCancel() {
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	reserve := models.ReserveTransaction{IdempotenceKey: 2, AccountID: owner1, ServiceID: 1, OrderID: 10,
+		Amount: models.NewMoney(30, 0), Currency: "RUB"}
+	require.NoError(s.T(), s.db.ReserveMoneyFromWallet(s.ctx, reserve))
+	capture := models.ReserveTransaction{AccountID: owner1, ServiceID: 1, OrderID: 10,
+		Amount: models.NewMoney(10, 0), Currency: "RUB"}
+	require.NoError(s.T(), s.db.ApplyReservedMoney(s.ctx, capture))
+	require.ErrorIs(s.T(), s.db.ApplyReservedMoney(s.ctx, capture), models.ErrIdempotenceKeyRequired)
+	capture.IdempotenceKey = 3
+	require.NoError(s.T(), s.db.ApplyReservedMoney(s.ctx, capture))
+	require.ErrorIs(s.T(), s.db.CancelReserve(s.ctx, reserve), models.ErrOrderNotFound)
+	reserve.Amount = models.NewMoney(10, 0)
+	require.NoError(s.T(), s.db.CancelReserve(s.ctx, reserve))
+	order, err := s.db.GetReservation(s.ctx, 10)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), models.ReservationCancelled, order.Status)
+	require.Equal(s.T(), models.NewMoney(20, 0), order.CapturedAmount)
+	balance, reserved := s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.NewMoney(80, 0), balance)
+	require.Equal(s.T(), models.Money(0), reserved)
+	s.requireLedgerBalanced()
+}
+
+func (s *Suite) TestExpireReservations() {
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	expiresAt := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	reserve := models.ReserveTransaction{IdempotenceKey: 2, AccountID: owner1, ServiceID: 1, OrderID: 1,
+		Amount: models.NewMoney(30, 0), Currency: "RUB", ExpiresAt: &expiresAt}
+	require.NoError(s.T(), s.db.ReserveMoneyFromWallet(s.ctx, reserve))
+	expired, err := s.db.GetExpiredReservations(s.ctx, time.Now().UTC(), 10)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), expired, 1)
+	require.Equal(s.T(), 1, expired[0].OrderID)
+	require.NoError(s.T(), s.db.ExpireReserve(s.ctx, expired[0]))
+	order, err := s.db.GetReservation(s.ctx, 1)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), models.ReservationExpired, order.Status)
+	balance, reserved := s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.NewMoney(100, 0), balance)
+	require.Equal(s.T(), models.Money(0), reserved)
+	expired, err = s.db.GetExpiredReservations(s.ctx, time.Now().UTC(), 10)
+	require.NoError(s.T(), err)
+	require.Empty(s.T(), expired)
+	s.requireLedgerBalanced()
+}
+
+func (s *Suite) TestRefundAndReport() {
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	reserve := models.ReserveTransaction{IdempotenceKey: 2, AccountID: owner1, ServiceID: 1, OrderID: 1,
+		Amount: models.NewMoney(30, 0), Currency: "RUB"}
+	require.NoError(s.T(), s.db.ReserveMoneyFromWallet(s.ctx, reserve))
+	refund := models.RefundTransaction{IdempotenceKey: 3, AccountID: owner1, OrderID: 1, Amount: models.NewMoney(10, 0)}
+	require.ErrorIs(s.T(), s.db.RefundOrder(s.ctx, refund), models.ErrOrderNotCompleted)
+	reserve.IdempotenceKey = 4
+	require.NoError(s.T(), s.db.ApplyReservedMoney(s.ctx, reserve))
+	require.NoError(s.T(), s.db.RefundOrder(s.ctx, refund))
+	refund.IdempotenceKey, refund.Amount = 5, models.NewMoney(25, 0)
+	require.ErrorIs(s.T(), s.db.RefundOrder(s.ctx, refund), models.ErrRefundExceedsCaptured)
+	balance, _ := s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.NewMoney(80, 0), balance)
+	now := time.Now().UTC()
+	report, err := s.db.GetReport(s.ctx, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []models.ReportRow{{ServiceTitle: "Услуга", Currency: "RUB", Amount: models.NewMoney(20, 0)}},
+		report)
+	s.requireLedgerBalanced()
+}
+
+func (s *Suite) TestRefundReversesCaptures() {
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	reserve := models.ReserveTransaction{IdempotenceKey: 2, AccountID: owner1, ServiceID: 1, OrderID: 20,
+		Amount: models.NewMoney(30, 0), Currency: "RUB"}
+	require.NoError(s.T(), s.db.ReserveMoneyFromWallet(s.ctx, reserve))
+	capture := reserve
+	capture.IdempotenceKey, capture.Amount = 3, models.NewMoney(10, 0)
+	require.NoError(s.T(), s.db.ApplyReservedMoney(s.ctx, capture))
+	refund := models.RefundTransaction{IdempotenceKey: 4, AccountID: owner1, OrderID: 20, Amount: models.NewMoney(5, 0)}
+	require.ErrorIs(s.T(), s.db.RefundOrder(s.ctx, refund), models.ErrOrderNotCompleted)
+	capture.IdempotenceKey, capture.Final = 5, true
+	require.NoError(s.T(), s.db.ApplyReservedMoney(s.ctx, capture))
+	refund.Amount = models.NewMoney(15, 0)
+	require.NoError(s.T(), s.db.RefundOrder(s.ctx, refund))
+	refund.IdempotenceKey, refund.Amount = 6, models.NewMoney(5, 0)
+	require.NoError(s.T(), s.db.RefundOrder(s.ctx, refund))
+	refund.IdempotenceKey, refund.Amount = 7, models.NewMoney(1, 0)
+	require.ErrorIs(s.T(), s.db.RefundOrder(s.ctx, refund), models.ErrRefundExceedsCaptured)
+	now := time.Now().UTC()
+	transactions, err := s.db.GetWalletTransactions(s.ctx, owner1, &models.TransactionsQueryParams{
+		From: now.Add(-time.Hour), To: now.Add(time.Hour), Limit: 20, Sorting: models.SortingDate,
+		Descending: "false"}, nil)
+	require.NoError(s.T(), err)
+	charges, reversals := make([]int, 0), make([]int, 0)
+	for _, transaction := range transactions {
+		switch transaction.Kind {
+		case models.TransactionKindServiceCharge:
+			charges = append(charges, transaction.ID)
+		case models.TransactionKindRefund:
+			require.NotNil(s.T(), transaction.ReversalOf)
+			reversals = append(reversals, *transaction.ReversalOf)
+		}
+	}
+	require.Len(s.T(), charges, 2)
+	require.Equal(s.T(), []int{charges[1], charges[0]}, reversals)
+	balance, _ := s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.NewMoney(100, 0), balance)
+	s.requireLedgerBalanced()
+}
+
+func (s *Suite) TestStatement() {
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	err := s.db.WithdrawMoneyFromWallet(s.ctx, owner1, models.Transaction{
+		IdempotenceKey: 2, Amount: models.NewMoney(30, 0), Currency: "RUB", Comment: "Снятие"})
+	require.NoError(s.T(), err)
+	now := time.Now().UTC()
+	statement, err := s.db.GetStatement(s.ctx, owner1, "RUB", now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), models.Money(0), statement.OpeningBalance)
+	require.Equal(s.T(), models.NewMoney(100, 0), statement.TotalCredit)
+	require.Equal(s.T(), models.NewMoney(30, 0), statement.TotalDebit)
+	require.Equal(s.T(), models.NewMoney(70, 0), statement.ClosingBalance)
+	require.Len(s.T(), statement.Lines, 2)
+	require.Equal(s.T(), "Снятие", statement.Lines[1].Comment)
+	require.Equal(s.T(), statement.ClosingBalance, statement.WalletBalance)
+}
+
+func (s *Suite) TestServices() {
+	price := models.NewMoney(250, 0)
+	currency := "RUB"
+	created, err := s.db.CreateService(s.ctx, models.Service{Title: "Доставка", Active: true, DefaultPrice: &price,
+		Currency: &currency})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 2, created.ID)
+	created.Title, created.Active = "Курьер", false
+	updated, err := s.db.UpdateService(s.ctx, *created)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "Курьер", updated.Title)
+	require.False(s.T(), updated.Active)
+	_, err = s.db.UpdateService(s.ctx, models.Service{ID: 42, Title: "Нет"})
+	require.ErrorIs(s.T(), err, models.ErrServiceNotFound)
+	services, err := s.db.GetServices(s.ctx)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), services, 2)
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	err = s.db.ReserveMoneyFromWallet(s.ctx, models.ReserveTransaction{IdempotenceKey: 2, AccountID: owner1,
+		ServiceID: 1, OrderID: 1, Amount: models.NewMoney(10, 0), Currency: "RUB"})
+	require.NoError(s.T(), err)
+	require.ErrorIs(s.T(), s.db.DeleteService(s.ctx, 1), models.ErrServiceInUse)
+	require.NoError(s.T(), s.db.DeleteService(s.ctx, 2))
+	_, err = s.db.GetService(s.ctx, 2)
+	require.ErrorIs(s.T(), err, models.ErrServiceNotFound)
+	require.ErrorIs(s.T(), s.db.DeleteService(s.ctx, 2), models.ErrServiceNotFound)
+}
+
+func (s *Suite) TestIdempotencyKeys() {
+	record := models.IdempotencyRecord{AccountID: owner1, Key: "key", Endpoint: "/wallet/addDeposit",
+		Fingerprint: "fingerprint", LockedUntil: time.Now().UTC().Add(time.Minute), ExpiresAt: time.Now().UTC().Add(time.Hour)}
+	existing, err := s.db.InsertIdempotencyKey(s.ctx, record)
+	require.NoError(s.T(), err)
+	require.Nil(s.T(), existing)
+	existing, err = s.db.InsertIdempotencyKey(s.ctx, record)
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), existing)
+	require.False(s.T(), existing.Completed())
+	keyRecord := record
+	keyRecord.APIKeyID = 1
+	existing, err = s.db.InsertIdempotencyKey(s.ctx, keyRecord)
+	require.NoError(s.T(), err)
+	require.Nil(s.T(), existing)
+	stale := record
+	stale.Endpoint, stale.LockedUntil = "/wallet/withdrawMoney", time.Now().UTC().Add(-time.Minute)
+	existing, err = s.db.InsertIdempotencyKey(s.ctx, stale)
+	require.NoError(s.T(), err)
+	require.Nil(s.T(), existing)
+	existing, err = s.db.InsertIdempotencyKey(s.ctx, stale)
+	require.NoError(s.T(), err)
+	require.Nil(s.T(), existing, "an in-progress key past its lease is taken over")
+	status := 200
+	record.StatusCode, record.ContentType, record.Body = &status, "application/json", []byte("{}")
+	require.NoError(s.T(), s.db.CompleteIdempotencyKey(s.ctx, record))
+	existing, err = s.db.InsertIdempotencyKey(s.ctx, record)
+	require.NoError(s.T(), err)
+	require.True(s.T(), existing.Completed())
+	require.Equal(s.T(), []byte("{}"), existing.Body)
+	purged, err := s.db.PurgeIdempotencyKeys(s.ctx, time.Now().UTC().Add(2*time.Hour))
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 3, purged)
+	existing, err = s.db.InsertIdempotencyKey(s.ctx, record)
+	require.NoError(s.T(), err)
+	require.Nil(s.T(), existing)
+	require.NoError(s.T(), s.db.DeleteIdempotencyKey(s.ctx, record))
+	existing, err = s.db.InsertIdempotencyKey(s.ctx, record)
+	require.NoError(s.T(), err)
+	require.Nil(s.T(), existing)
+}
+
+func (s *Suite) TestEventsAndWebhooks() {
+	webhook, err := s.db.CreateWebhook(s.ctx, models.Webhook{URL: "http://localhost/hook", Secret: "secret",
+		EventTypes: models.EventTypeList{models.EventFundsDeposited}, Active: true})
+	require.NoError(s.T(), err)
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	err = s.db.WithdrawMoneyFromWallet(s.ctx, owner1, models.Transaction{
+		IdempotenceKey: 2, Amount: models.NewMoney(30, 0), Currency: "RUB"})
+	require.NoError(s.T(), err)
+	events, err := s.db.GetEvents(s.ctx, owner1, 0, 10)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), events, 2)
+	require.Equal(s.T(), models.EventFundsDeposited, events[0].Type)
+	require.Equal(s.T(), int64(1), events[0].Sequence)
+	require.Equal(s.T(), models.EventFundsWithdrawn, events[1].Type)
+	sequence, err := s.db.GetLastEventSequence(s.ctx, owner1)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int64(2), sequence)
+	now := time.Now().UTC().Add(time.Second)
+	dispatches, err := s.db.ClaimWebhookDeliveries(s.ctx, now, time.Minute, 10)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), dispatches, 1)
+	require.Equal(s.T(), webhook.URL, dispatches[0].URL)
+	require.Equal(s.T(), models.EventFundsDeposited, dispatches[0].Event.Type)
+	dispatches, err = s.db.ClaimWebhookDeliveries(s.ctx, now, time.Minute, 10)
+	require.NoError(s.T(), err)
+	require.Empty(s.T(), dispatches)
+	deliveries, err := s.db.GetWebhookDeliveries(s.ctx, &models.WebhookDeliveriesQueryParams{Limit: 10})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), deliveries, 1)
+	responseStatus := 200
+	err = s.db.RecordWebhookAttempt(s.ctx, models.WebhookAttempt{DeliveryID: deliveries[0].ID,
+		ResponseStatus: &responseStatus, AttemptedAt: now}, models.DeliveryDelivered, now)
+	require.NoError(s.T(), err)
+	delivery, err := s.db.GetWebhookDelivery(s.ctx, deliveries[0].ID)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), models.DeliveryDelivered, delivery.Status)
+	require.Equal(s.T(), 1, delivery.AttemptCount)
+	require.Len(s.T(), delivery.Attempts, 1)
+	require.NoError(s.T(), s.db.DeleteWebhook(s.ctx, webhook.ID))
+	_, err = s.db.GetWebhookDelivery(s.ctx, deliveries[0].ID)
+	require.ErrorIs(s.T(), err, models.ErrDeliveryNotFound)
+}
+
+func (s *Suite) TestAdjustments() {
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	now := time.Now().UTC()
+	proposal := models.Adjustment{AccountID: owner1, Amount: -models.NewMoney(150, 0), Currency: "RUB",
+		Comment: "Корректировка", Reason: "Ошибка", Attachment: "ticket-1", ProposedBy: admin1, CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour)}
+	adjustment, err := s.db.CreateAdjustment(s.ctx, proposal)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), models.AdjustmentPending, adjustment.Status)
+	review := models.AdjustmentReview{ID: adjustment.ID, Comment: "ok"}
+	_, err = s.db.ApproveAdjustment(s.ctx, admin1, review, now)
+	require.ErrorIs(s.T(), err, models.ErrSelfReview)
+	_, err = s.db.ApproveAdjustment(s.ctx, admin2, review, now)
+	require.ErrorIs(s.T(), err, models.ErrNotEnoughMoney)
+	_, err = s.db.ApproveAdjustment(s.ctx, admin2, models.AdjustmentReview{ID: 42}, now)
+	require.ErrorIs(s.T(), err, models.ErrAdjustmentNotFound)
+
+	proposal.Amount = -models.NewMoney(40, 0)
+	adjustment, err = s.db.CreateAdjustment(s.ctx, proposal)
+	require.NoError(s.T(), err)
+	review.ID = adjustment.ID
+	approved, err := s.db.ApproveAdjustment(s.ctx, admin2, review, now)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), models.AdjustmentApproved, approved.Status)
+	require.NotNil(s.T(), approved.TransactionID)
+	require.Equal(s.T(), admin2, *approved.ReviewedBy)
+	_, err = s.db.RejectAdjustment(s.ctx, admin2, review, now)
+	require.ErrorIs(s.T(), err, models.ErrAdjustmentNotPending)
+	balance, _ := s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.NewMoney(60, 0), balance)
+	actions, err := s.db.GetAdminActions(s.ctx, &models.AdminActionsQueryParams{AccountID: owner1, Limit: 10})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), actions, 1)
+	require.Equal(s.T(), admin2, actions[0].AdminID)
+
+	expired, err := s.db.ExpireAdjustments(s.ctx, now.Add(2*time.Hour))
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 1, expired)
+	adjustments, err := s.db.GetAdjustments(s.ctx, &models.AdjustmentsQueryParams{
+		Status: models.AdjustmentExpired, Limit: 10})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), adjustments, 1)
+	s.requireLedgerBalanced()
+}
+
+func (s *Suite) TestAdjustBalance() {
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	adjustment := models.Transaction{IdempotenceKey: 2, Amount: -models.NewMoney(150, 0), Currency: "RUB",
+		Comment: "Корректировка", Actor: &models.Actor{AdminID: admin1, Reason: "Ошибка"}}
+	require.ErrorIs(s.T(), s.db.AdjustBalance(s.ctx, owner1, adjustment), models.ErrNotEnoughMoney)
+	adjustment.Amount = -models.NewMoney(40, 0)
+	require.NoError(s.T(), s.db.AdjustBalance(s.ctx, owner1, adjustment))
+	s.requireUniqueViolation(s.db.AdjustBalance(s.ctx, owner1, adjustment))
+	balance, _ := s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.NewMoney(60, 0), balance)
+	actions, err := s.db.GetAdminActions(s.ctx, &models.AdminActionsQueryParams{AccountID: owner1, Limit: 10})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), actions, 1)
+	require.Equal(s.T(), models.AdminActionAdjustment, actions[0].Action)
+	s.requireLedgerBalanced()
+}
+
+func (s *Suite) TestAuditChain() {
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		err := s.db.InsertAuditRecord(s.ctx, models.AuditRecord{AccountID: owner1, Role: "user", Method: "POST",
+			Endpoint: "/wallet/addDeposit", Payload: "{}", StatusCode: 200, Outcome: "success",
+			BalancesBefore: models.AuditBalances{}, BalancesAfter: models.AuditBalances{}, CreatedAt: now})
+		require.NoError(s.T(), err)
+	}
+	records, err := s.db.GetAuditRecords(s.ctx, &models.AuditQueryParams{From: now.Add(-time.Hour),
+		To: now.Add(time.Hour), Limit: 10})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), records, 3)
+	require.Equal(s.T(), "", records[0].PrevHash)
+	for i := 1; i < len(records); i++ {
+		require.Equal(s.T(), records[i-1].Hash, records[i].PrevHash)
+		require.Equal(s.T(), records[i].Digest(), records[i].Hash)
+	}
+	hash, err := s.db.GetAuditHash(s.ctx, records[1].ID)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), records[1].Hash, hash)
+	hash, err = s.db.GetAuditHash(s.ctx, 0)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "", hash)
+	records, err = s.db.GetAuditRecords(s.ctx, &models.AuditQueryParams{AfterID: records[0].ID,
+		From: now.Add(-time.Hour), To: now.Add(time.Hour), Limit: 1})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), records, 1)
+}
+
+func (s *Suite) TestAPIKeys() {
+	now := time.Now().UTC()
+	key := models.APIKey{ClientName: "billing", Prefix: "bsk_aaaaaaaa", Hash: "hash",
+		Scopes: models.ScopeList{"wallet:read"}, ServiceIDs: models.ServiceIDList{1}, CreatedBy: admin1, CreatedAt: now}
+	created, err := s.db.CreateAPIKey(s.ctx, key)
+	require.NoError(s.T(), err)
+	_, err = s.db.CreateAPIKey(s.ctx, key)
+	s.requireUniqueViolation(err)
+	found, err := s.db.GetAPIKeyByPrefix(s.ctx, key.Prefix)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), created.ID, found.ID)
+	require.Equal(s.T(), key.Scopes, found.Scopes)
+	_, err = s.db.GetAPIKeyByPrefix(s.ctx, "bsk_missing")
+	require.ErrorIs(s.T(), err, models.ErrAPIKeyNotFound)
+
+	rotation := key
+	rotation.Prefix, rotation.RotatedFrom = "bsk_bbbbbbbb", &created.ID
+	rotated, err := s.db.RotateAPIKey(s.ctx, rotation, now.Add(time.Hour))
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), created.ID, *rotated.RotatedFrom)
+	old, err := s.db.GetAPIKey(s.ctx, created.ID)
+	require.NoError(s.T(), err)
+	require.True(s.T(), old.Active(now))
+	require.False(s.T(), old.Active(now.Add(2*time.Hour)))
+	old, err = s.db.RevokeAPIKey(s.ctx, created.ID, now.Add(3*time.Hour))
+	require.NoError(s.T(), err)
+	require.True(s.T(), old.RevokedAt.Before(now.Add(2*time.Hour)))
+	old, err = s.db.RevokeAPIKey(s.ctx, created.ID, now.Add(-time.Minute))
+	require.NoError(s.T(), err)
+	require.False(s.T(), old.Active(now))
+	rotation.Prefix = "bsk_cccccccc"
+	_, err = s.db.RotateAPIKey(s.ctx, rotation, now.Add(time.Hour))
+	require.ErrorIs(s.T(), err, models.ErrAPIKeyNotFound)
+	require.NoError(s.T(), s.db.TouchAPIKey(s.ctx, rotated.ID, now))
+	keys, err := s.db.GetAPIKeys(s.ctx)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), keys, 2)
+	require.NotNil(s.T(), keys[1].LastUsedAt)
+}
+
+func (s *Suite) TestConcurrentWithdrawals() {
+	s.deposit(owner1, 1, models.NewMoney(100, 0), "RUB")
+	const workers = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(idempotenceKey int) {
+			defer wg.Done()
+			err := s.db.WithdrawMoneyFromWallet(s.ctx, owner1, models.Transaction{
+				IdempotenceKey: idempotenceKey, Amount: models.NewMoney(10, 0), Currency: "RUB"})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+				return
+			}
+			require.ErrorIs(s.T(), err, models.ErrNotEnoughMoney)
+		}(i + 2)
+	}
+	wg.Wait()
+	require.Equal(s.T(), 10, succeeded)
+	balance, _ := s.balance(owner1, "RUB")
+	require.Equal(s.T(), models.Money(0), balance)
+	s.requireLedgerBalanced()
+}
@@ -0,0 +1,59 @@
+package stream
+
+import "sync"
+
+// Hub fans out "something changed" signals to the subscribers of an account.
+// Signals are coalesced: a subscriber that has not consumed the previous one gets no second signal.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[int]map[chan struct{}]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subs: make(map[int]map[chan struct{}]struct{}),
+	}
+}
+
+func (h *Hub) Subscribe(accountID int) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	if h.subs[accountID] == nil {
+		h.subs[accountID] = make(map[chan struct{}]struct{})
+	}
+	h.subs[accountID][ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs[accountID], ch)
+		if len(h.subs[accountID]) == 0 {
+			delete(h.subs, accountID)
+		}
+	}
+}
+
+func (h *Hub) Notify(accountID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[accountID] {
+		signal(ch)
+	}
+}
+
+func (h *Hub) NotifyAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, subs := range h.subs {
+		for ch := range subs {
+			signal(ch)
+		}
+	}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	defaultMaxAttempts = 8
+	defaultBaseDelay   = 10 * time.Second
+	defaultMaxDelay    = time.Hour
+	defaultBatchSize   = 50
+	requestTimeout     = 10 * time.Second
+	claimLease         = time.Minute
+	maxErrorLength     = 512
+)
+
+type Store interface {
+	ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration,
+		limit int) ([]models.WebhookDispatch, error)
+	RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt, status string, nextAttemptAt time.Time) error
+}
+
+type Dispatcher struct {
+	log         *logrus.Logger
+	store       Store
+	client      *http.Client
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+func NewDispatcher(log *logrus.Logger, store Store) *Dispatcher {
+	return &Dispatcher{
+		log:         log,
+		store:       store,
+		client:      &http.Client{Timeout: requestTimeout},
+		maxAttempts: defaultMaxAttempts,
+		baseDelay:   defaultBaseDelay,
+		maxDelay:    defaultMaxDelay,
+	}
+}
+
+// SetRetryPolicy overrides how many attempts a delivery gets before it is dead-lettered
+// and the bounds of the exponential backoff between them.
+func (d *Dispatcher) SetRetryPolicy(maxAttempts int, baseDelay, maxDelay time.Duration) {
+	d.maxAttempts = maxAttempts
+	d.baseDelay = baseDelay
+	d.maxDelay = maxDelay
+}
+
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.Flush(ctx); err != nil {
+				d.log.Errorf("failed to dispatch webhooks: %v", err)
+			}
+		}
+	}
+}
+
+// Flush sends one batch of due deliveries and returns how many were delivered.
+func (d *Dispatcher) Flush(ctx context.Context) (int, error) {
+	dispatches, err := d.store.ClaimWebhookDeliveries(ctx, time.Now(), claimLease, defaultBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, dispatch := range dispatches {
+		attempt := d.send(ctx, dispatch)
+		status, nextAttemptAt := models.DeliveryDelivered, attempt.AttemptedAt
+		if attempt.Error != nil {
+			status, nextAttemptAt = d.retry(dispatch.AttemptCount+1, attempt.AttemptedAt)
+		} else {
+			delivered++
+		}
+		if err = d.store.RecordWebhookAttempt(ctx, attempt, status, nextAttemptAt); err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+func (d *Dispatcher) retry(attempts int, now time.Time) (string, time.Time) {
+	if attempts >= d.maxAttempts {
+		return models.DeliveryDead, now
+	}
+	delay := d.baseDelay << (attempts - 1)
+	if delay > d.maxDelay || delay <= 0 {
+		delay = d.maxDelay
+	}
+	return models.DeliveryPending, now.Add(delay)
+}
+
+func (d *Dispatcher) send(ctx context.Context, dispatch models.WebhookDispatch) models.WebhookAttempt {
+	attempt := models.WebhookAttempt{DeliveryID: dispatch.DeliveryID, AttemptedAt: time.Now()}
+	responseStatus, err := d.post(ctx, dispatch, attempt.AttemptedAt)
+	attempt.Duration = time.Since(attempt.AttemptedAt).Milliseconds()
+	if responseStatus != 0 {
+		attempt.ResponseStatus = &responseStatus
+	}
+	if err != nil {
+		message := err.Error()
+		if len(message) > maxErrorLength {
+			message = message[:maxErrorLength]
+		}
+		attempt.Error = &message
+	}
+	return attempt
+}
+
+func (d *Dispatcher) post(ctx context.Context, dispatch models.WebhookDispatch, timestamp time.Time) (int, error) {
+	body, err := json.Marshal(dispatch.Event)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dispatch.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, dispatch.Event.Type)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(dispatch.DeliveryID))
+	req.Header.Set(SignatureHeader, Sign(dispatch.Secret, timestamp, body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorLength))
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value "t=<unix>,v1=<hex hmac-sha256 of "<unix>.<body>">".
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix + "."))
+	mac.Write(body)
+	return "t=" + unix + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,406 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.24.4
+// source: balance.proto
+
+package balancepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	BalanceService_GetBalance_FullMethodName      = "/balance.v1.BalanceService/GetBalance"
+	BalanceService_AddDeposit_FullMethodName      = "/balance.v1.BalanceService/AddDeposit"
+	BalanceService_WithdrawMoney_FullMethodName   = "/balance.v1.BalanceService/WithdrawMoney"
+	BalanceService_TransferMoney_FullMethodName   = "/balance.v1.BalanceService/TransferMoney"
+	BalanceService_ReserveMoney_FullMethodName    = "/balance.v1.BalanceService/ReserveMoney"
+	BalanceService_ApplyReserve_FullMethodName    = "/balance.v1.BalanceService/ApplyReserve"
+	BalanceService_CancelReserve_FullMethodName   = "/balance.v1.BalanceService/CancelReserve"
+	BalanceService_GetTransactions_FullMethodName = "/balance.v1.BalanceService/GetTransactions"
+	BalanceService_GetReport_FullMethodName       = "/balance.v1.BalanceService/GetReport"
+)
+
+// BalanceServiceClient is the client API for BalanceService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BalanceServiceClient interface {
+	GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error)
+	AddDeposit(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	WithdrawMoney(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	TransferMoney(ctx context.Context, in *TransferTransaction, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ReserveMoney(ctx context.Context, in *ReserveTransaction, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ApplyReserve(ctx context.Context, in *ReserveTransaction, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	CancelReserve(ctx context.Context, in *ReserveTransaction, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetTransactions(ctx context.Context, in *GetTransactionsRequest, opts ...grpc.CallOption) (*GetTransactionsResponse, error)
+	GetReport(ctx context.Context, in *GetReportRequest, opts ...grpc.CallOption) (*GetReportResponse, error)
+}
+
+type balanceServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBalanceServiceClient(cc grpc.ClientConnInterface) BalanceServiceClient {
+	return &balanceServiceClient{cc}
+}
+
+func (c *balanceServiceClient) GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error) {
+	out := new(Balance)
+	err := c.cc.Invoke(ctx, BalanceService_GetBalance_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *balanceServiceClient) AddDeposit(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, BalanceService_AddDeposit_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *balanceServiceClient) WithdrawMoney(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, BalanceService_WithdrawMoney_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *balanceServiceClient) TransferMoney(ctx context.Context, in *TransferTransaction, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, BalanceService_TransferMoney_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *balanceServiceClient) ReserveMoney(ctx context.Context, in *ReserveTransaction, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, BalanceService_ReserveMoney_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *balanceServiceClient) ApplyReserve(ctx context.Context, in *ReserveTransaction, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, BalanceService_ApplyReserve_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *balanceServiceClient) CancelReserve(ctx context.Context, in *ReserveTransaction, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, BalanceService_CancelReserve_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *balanceServiceClient) GetTransactions(ctx context.Context, in *GetTransactionsRequest, opts ...grpc.CallOption) (*GetTransactionsResponse, error) {
+	out := new(GetTransactionsResponse)
+	err := c.cc.Invoke(ctx, BalanceService_GetTransactions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *balanceServiceClient) GetReport(ctx context.Context, in *GetReportRequest, opts ...grpc.CallOption) (*GetReportResponse, error) {
+	out := new(GetReportResponse)
+	err := c.cc.Invoke(ctx, BalanceService_GetReport_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BalanceServiceServer is the server API for BalanceService service.
+// All implementations must embed UnimplementedBalanceServiceServer
+// for forward compatibility
+type BalanceServiceServer interface {
+	GetBalance(context.Context, *GetBalanceRequest) (*Balance, error)
+	AddDeposit(context.Context, *Transaction) (*emptypb.Empty, error)
+	WithdrawMoney(context.Context, *Transaction) (*emptypb.Empty, error)
+	TransferMoney(context.Context, *TransferTransaction) (*emptypb.Empty, error)
+	ReserveMoney(context.Context, *ReserveTransaction) (*emptypb.Empty, error)
+	ApplyReserve(context.Context, *ReserveTransaction) (*emptypb.Empty, error)
+	CancelReserve(context.Context, *ReserveTransaction) (*emptypb.Empty, error)
+	GetTransactions(context.Context, *GetTransactionsRequest) (*GetTransactionsResponse, error)
+	GetReport(context.Context, *GetReportRequest) (*GetReportResponse, error)
+	mustEmbedUnimplementedBalanceServiceServer()
+}
+
+// UnimplementedBalanceServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedBalanceServiceServer struct {
+}
+
+func (UnimplementedBalanceServiceServer) GetBalance(context.Context, *GetBalanceRequest) (*Balance, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBalance not implemented")
+}
+func (UnimplementedBalanceServiceServer) AddDeposit(context.Context, *Transaction) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddDeposit not implemented")
+}
+func (UnimplementedBalanceServiceServer) WithdrawMoney(context.Context, *Transaction) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WithdrawMoney not implemented")
+}
+func (UnimplementedBalanceServiceServer) TransferMoney(context.Context, *TransferTransaction) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TransferMoney not implemented")
+}
+func (UnimplementedBalanceServiceServer) ReserveMoney(context.Context, *ReserveTransaction) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReserveMoney not implemented")
+}
+func (UnimplementedBalanceServiceServer) ApplyReserve(context.Context, *ReserveTransaction) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApplyReserve not implemented")
+}
+func (UnimplementedBalanceServiceServer) CancelReserve(context.Context, *ReserveTransaction) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelReserve not implemented")
+}
+func (UnimplementedBalanceServiceServer) GetTransactions(context.Context, *GetTransactionsRequest) (*GetTransactionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransactions not implemented")
+}
+func (UnimplementedBalanceServiceServer) GetReport(context.Context, *GetReportRequest) (*GetReportResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetReport not implemented")
+}
+func (UnimplementedBalanceServiceServer) mustEmbedUnimplementedBalanceServiceServer() {}
+
+// UnsafeBalanceServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BalanceServiceServer will
+// result in compilation errors.
+type UnsafeBalanceServiceServer interface {
+	mustEmbedUnimplementedBalanceServiceServer()
+}
+
+func RegisterBalanceServiceServer(s grpc.ServiceRegistrar, srv BalanceServiceServer) {
+	s.RegisterService(&BalanceService_ServiceDesc, srv)
+}
+
+func _BalanceService_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BalanceServiceServer).GetBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BalanceService_GetBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BalanceServiceServer).GetBalance(ctx, req.(*GetBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BalanceService_AddDeposit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BalanceServiceServer).AddDeposit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BalanceService_AddDeposit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BalanceServiceServer).AddDeposit(ctx, req.(*Transaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BalanceService_WithdrawMoney_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BalanceServiceServer).WithdrawMoney(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BalanceService_WithdrawMoney_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BalanceServiceServer).WithdrawMoney(ctx, req.(*Transaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BalanceService_TransferMoney_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransferTransaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BalanceServiceServer).TransferMoney(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BalanceService_TransferMoney_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BalanceServiceServer).TransferMoney(ctx, req.(*TransferTransaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BalanceService_ReserveMoney_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveTransaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BalanceServiceServer).ReserveMoney(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BalanceService_ReserveMoney_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BalanceServiceServer).ReserveMoney(ctx, req.(*ReserveTransaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BalanceService_ApplyReserve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveTransaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BalanceServiceServer).ApplyReserve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BalanceService_ApplyReserve_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BalanceServiceServer).ApplyReserve(ctx, req.(*ReserveTransaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BalanceService_CancelReserve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveTransaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BalanceServiceServer).CancelReserve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BalanceService_CancelReserve_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BalanceServiceServer).CancelReserve(ctx, req.(*ReserveTransaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BalanceService_GetTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTransactionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BalanceServiceServer).GetTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BalanceService_GetTransactions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BalanceServiceServer).GetTransactions(ctx, req.(*GetTransactionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BalanceService_GetReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BalanceServiceServer).GetReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BalanceService_GetReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BalanceServiceServer).GetReport(ctx, req.(*GetReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// BalanceService_ServiceDesc is the grpc.ServiceDesc for BalanceService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BalanceService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "balance.v1.BalanceService",
+	HandlerType: (*BalanceServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetBalance",
+			Handler:    _BalanceService_GetBalance_Handler,
+		},
+		{
+			MethodName: "AddDeposit",
+			Handler:    _BalanceService_AddDeposit_Handler,
+		},
+		{
+			MethodName: "WithdrawMoney",
+			Handler:    _BalanceService_WithdrawMoney_Handler,
+		},
+		{
+			MethodName: "TransferMoney",
+			Handler:    _BalanceService_TransferMoney_Handler,
+		},
+		{
+			MethodName: "ReserveMoney",
+			Handler:    _BalanceService_ReserveMoney_Handler,
+		},
+		{
+			MethodName: "ApplyReserve",
+			Handler:    _BalanceService_ApplyReserve_Handler,
+		},
+		{
+			MethodName: "CancelReserve",
+			Handler:    _BalanceService_CancelReserve_Handler,
+		},
+		{
+			MethodName: "GetTransactions",
+			Handler:    _BalanceService_GetTransactions_Handler,
+		},
+		{
+			MethodName: "GetReport",
+			Handler:    _BalanceService_GetReport_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "balance.proto",
+}
@@ -4,26 +4,31 @@ import (
 	"encoding/csv"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 	"unicode/utf8"
+
+	"github.com/DANDA322/balance-service/internal/models"
 )
 
 type WriterCSV struct {
 }
 
-func (c *WriterCSV) WriteReport(w http.ResponseWriter, data map[string]float64) error {
+func (c *WriterCSV) WriteReport(w http.ResponseWriter, data []models.ReportRow) error {
 	writer := csv.NewWriter(w)
 	delimiter, _ := utf8.DecodeRuneInString(";")
 	writer.Comma = delimiter
 
-	row := []string{"ServiceTitle", "Amount"}
+	row := []string{"ServiceTitle", "Currency", "Amount"}
 	err := writer.Write(row)
 	if err != nil {
 		return fmt.Errorf("cannot write to CSV file: %w", err)
 	}
 
-	for key, value := range data {
-		row[0] = key
-		row[1] = fmt.Sprintf("%v", value)
+	for _, value := range data {
+		row[0] = value.ServiceTitle
+		row[1] = value.Currency
+		row[2] = value.Amount.String()
 		err = writer.Write(row)
 		if err != nil {
 			return fmt.Errorf("cannot write to CSV file: %w", err)
@@ -33,3 +38,31 @@ func (c *WriterCSV) WriteReport(w http.ResponseWriter, data map[string]float64)
 
 	return nil
 }
+
+func (c *WriterCSV) WriteStatement(w http.ResponseWriter, statement *models.Statement) error {
+	writer := csv.NewWriter(w)
+	delimiter, _ := utf8.DecodeRuneInString(";")
+	writer.Comma = delimiter
+
+	rows := [][]string{
+		{"Timestamp", "Kind", "TransactionID", "Comment", "Amount", "Balance"},
+		{statement.From.Format(time.RFC3339), "opening_balance", "", "", "", statement.OpeningBalance.String()},
+	}
+	for _, line := range statement.Lines {
+		transactionID := ""
+		if line.TransactionID != nil {
+			transactionID = strconv.Itoa(*line.TransactionID)
+		}
+		rows = append(rows, []string{line.Timestamp.Format(time.RFC3339), line.Kind, transactionID, line.Comment,
+			line.Amount.String(), line.Balance.String()})
+	}
+	rows = append(rows,
+		[]string{statement.To.Format(time.RFC3339), "total_credit", "", "", statement.TotalCredit.String(), ""},
+		[]string{statement.To.Format(time.RFC3339), "total_debit", "", "", statement.TotalDebit.String(), ""},
+		[]string{statement.To.Format(time.RFC3339), "closing_balance", "", "", "", statement.ClosingBalance.String()})
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("cannot write to CSV file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package jsonl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+)
+
+type WriterJSONL struct {
+}
+
+type balanceLine struct {
+	Type      string       `json:"type"`
+	Timestamp time.Time    `json:"timestamp"`
+	Balance   models.Money `json:"balance"`
+}
+
+type movementLine struct {
+	Type string `json:"type"`
+	models.StatementLine
+}
+
+type totalsLine struct {
+	Type        string       `json:"type"`
+	TotalCredit models.Money `json:"total_credit"`
+	TotalDebit  models.Money `json:"total_debit"`
+}
+
+func (c *WriterJSONL) WriteStatement(w http.ResponseWriter, statement *models.Statement) error {
+	encoder := json.NewEncoder(w)
+	lines := make([]interface{}, 0, len(statement.Lines)+3)
+	lines = append(lines, balanceLine{Type: "opening_balance", Timestamp: statement.From, Balance: statement.OpeningBalance})
+	for _, line := range statement.Lines {
+		lines = append(lines, movementLine{Type: "movement", StatementLine: line})
+	}
+	lines = append(lines,
+		totalsLine{Type: "totals", TotalCredit: statement.TotalCredit, TotalDebit: statement.TotalDebit},
+		balanceLine{Type: "closing_balance", Timestamp: statement.To, Balance: statement.ClosingBalance})
+	for _, line := range lines {
+		if err := encoder.Encode(line); err != nil {
+			return fmt.Errorf("cannot write JSON line: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *WriterJSONL) WriteAuditRecords(w http.ResponseWriter, records []models.AuditRecord) error {
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("cannot write JSON line: %w", err)
+		}
+	}
+	return nil
+}
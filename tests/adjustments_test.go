@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+var debitAdjustment = &models.Adjustment{
+	AccountID:  333,
+	Amount:     -models.NewMoney(50, 0),
+	Currency:   "RUB",
+	Reason:     "Двойное зачисление платежа",
+	Attachment: "TICKET-1024",
+}
+
+func (s *IntegrationTestSuite) TestAdjustmentApproval() {
+	depositMoney(s.T(), s, token2, transaction1)
+	proposed := proposeAdjustment(s, token1, debitAdjustment)
+	require.Equal(s.T(), models.AdjustmentPending, proposed.Status)
+	require.Equal(s.T(), 555, proposed.ProposedBy)
+	require.True(s.T(), proposed.ExpiresAt.After(time.Now()))
+	checkBalance(s.T(), s, token2, balance1)
+	resp, code, err := s.processRequest(http.MethodPost, "/admin/approveAdjustment", token1,
+		models.AdjustmentReview{ID: proposed.ID})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusForbidden, code)
+	require.Equal(s.T(), "{\"error\":\"adjustment cannot be reviewed by its proposer\"}\n", string(resp))
+	approved := reviewAdjustment(s, "/admin/approveAdjustment", token2, proposed.ID, http.StatusOK)
+	require.Equal(s.T(), models.AdjustmentApproved, approved.Status)
+	require.Equal(s.T(), 333, *approved.ReviewedBy)
+	require.NotNil(s.T(), approved.TransactionID)
+	checkBalance(s.T(), s, token2, &models.Balance{Currency: "RUB", Amount: models.NewMoney(50, 50)})
+	reviewAdjustment(s, "/admin/approveAdjustment", token2, proposed.ID, http.StatusConflict)
+	page := getTransactions(s.T(), s, token2, "sorting=date&descending=true")
+	require.Equal(s.T(), models.TransactionKindAdjustment, page.Transactions[0].Kind)
+	require.Equal(s.T(), "Корректировка баланса", page.Transactions[0].Comment)
+	actions := getAdminActions(s, "?admin_id=333")
+	require.Len(s.T(), actions, 1)
+	require.Equal(s.T(), models.AdminActionAdjustment, actions[0].Action)
+	require.Equal(s.T(), debitAdjustment.Reason, actions[0].Reason)
+	resp, code, err = s.processRequest(http.MethodGet, "/wallet/checkLedger", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "{\"balanced\":true,\"unbalanced_entries\":[],\"mismatched_wallets\":[]}\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestAdjustmentKeyNotTakenByClients() {
+	depositMoney(s.T(), s, token2, transaction1)
+	deposit := *transaction4
+	deposit.IdempotenceKey = -1
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/addDeposit", token2, deposit)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Equal(s.T(), "{\"error\":\"invalid idempotence_key: must be positive\"}\n", string(resp))
+	proposed := proposeAdjustment(s, token1, debitAdjustment)
+	require.Equal(s.T(), 1, proposed.ID)
+	approved := reviewAdjustment(s, "/admin/approveAdjustment", token2, proposed.ID, http.StatusOK)
+	require.Equal(s.T(), models.AdjustmentApproved, approved.Status)
+}
+
+func (s *IntegrationTestSuite) TestAdjustmentApprovalNotEnoughMoney() {
+	depositMoney(s.T(), s, token2, transaction4)
+	adjustment := *debitAdjustment
+	adjustment.Amount = -models.NewMoney(100, 0)
+	proposed := proposeAdjustment(s, token1, &adjustment)
+	resp, code, err := s.processRequest(http.MethodPost, "/admin/approveAdjustment", token2,
+		models.AdjustmentReview{ID: proposed.ID})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusConflict, code)
+	require.Equal(s.T(), "{\"error\":\"not enough money on the balance\"}\n", string(resp))
+	checkBalance(s.T(), s, token2, &models.Balance{Currency: "RUB", Amount: transaction4.Amount})
+	require.Len(s.T(), getAdjustments(s, "?status=pending"), 1)
+}
+
+func (s *IntegrationTestSuite) TestAdjustmentRejectAndExpire() {
+	credit := *debitAdjustment
+	credit.Amount = models.NewMoney(50, 0)
+	rejected := proposeAdjustment(s, token1, &credit)
+	expiring := proposeAdjustment(s, token1, &credit)
+	require.Len(s.T(), getAdjustments(s, "?status=pending"), 2)
+	reviewed := reviewAdjustment(s, "/admin/rejectAdjustment", token2, rejected.ID, http.StatusOK)
+	require.Equal(s.T(), models.AdjustmentRejected, reviewed.Status)
+	require.Nil(s.T(), reviewed.TransactionID)
+	reviewAdjustment(s, "/admin/approveAdjustment", token2, rejected.ID, http.StatusConflict)
+	expired, err := s.store.ExpireAdjustments(context.Background(), expiring.ExpiresAt)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 1, expired)
+	reviewAdjustment(s, "/admin/approveAdjustment", token2, expiring.ID, http.StatusConflict)
+	require.Empty(s.T(), getAdjustments(s, "?status=pending"))
+	require.Len(s.T(), getAdjustments(s, "?status=expired&account_id=333"), 1)
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getBalance?currency=RUB", token2, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusNotFound, code, string(resp))
+}
+
+func (s *IntegrationTestSuite) TestProposeAdjustmentRequiresAttachment() {
+	adjustment := *debitAdjustment
+	adjustment.Attachment = ""
+	resp, code, err := s.processRequest(http.MethodPost, "/admin/proposeAdjustment", token1, adjustment)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Equal(s.T(), "{\"error\":\"invalid adjustment: attachment is required\"}\n", string(resp))
+}
+
+func proposeAdjustment(s *IntegrationTestSuite, token string, adjustment *models.Adjustment) models.Adjustment {
+	s.T().Helper()
+	resp, code, err := s.processRequest(http.MethodPost, "/admin/proposeAdjustment", token, adjustment)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code, string(resp))
+	proposed := models.Adjustment{}
+	require.NoError(s.T(), json.Unmarshal(resp, &proposed))
+	return proposed
+}
+
+func reviewAdjustment(s *IntegrationTestSuite, path, token string, adjustmentID, expectedCode int) models.Adjustment {
+	s.T().Helper()
+	resp, code, err := s.processRequest(http.MethodPost, path, token, models.AdjustmentReview{ID: adjustmentID})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), expectedCode, code, string(resp))
+	reviewed := models.Adjustment{}
+	if code == http.StatusOK {
+		require.NoError(s.T(), json.Unmarshal(resp, &reviewed))
+	}
+	return reviewed
+}
+
+func getAdjustments(s *IntegrationTestSuite, query string) []models.Adjustment {
+	s.T().Helper()
+	resp, code, err := s.processRequest(http.MethodGet, "/admin/getAdjustments"+query, token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code, string(resp))
+	adjustments := make([]models.Adjustment, 0)
+	require.NoError(s.T(), json.Unmarshal(resp, &adjustments))
+	return adjustments
+}
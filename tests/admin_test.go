@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+var adminDeposit = &models.AdminTransaction{
+	AccountID:      333,
+	IdempotenceKey: 40,
+	Amount:         models.NewMoney(100, 50),
+	Currency:       "RUB",
+	Comment:        "Пополнение баланса",
+	Reason:         "Платеж не зачислен автоматически, тикет 1024",
+}
+
+func (s *IntegrationTestSuite) TestAdminDepositOnBehalf() {
+	resp, code, err := s.processRequest(http.MethodPost, "/admin/addDeposit", token1, adminDeposit)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code, string(resp))
+	checkBalance(s.T(), s, token2, balance1)
+	resp, code, err = s.processRequest(http.MethodGet, "/admin/getTransactions?account_id=333", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	page := models.TransactionsPage{}
+	require.NoError(s.T(), json.Unmarshal(resp, &page))
+	require.Len(s.T(), page.Transactions, 1)
+	require.Equal(s.T(), models.TransactionKindDeposit, page.Transactions[0].Kind)
+	actions := getAdminActions(s, "?account_id=333")
+	require.Len(s.T(), actions, 1)
+	require.Equal(s.T(), 555, actions[0].AdminID)
+	require.Equal(s.T(), models.AdminActionDeposit, actions[0].Action)
+	require.Equal(s.T(), adminDeposit.Reason, actions[0].Reason)
+	require.NotNil(s.T(), actions[0].TransactionID)
+	require.Empty(s.T(), getAdminActions(s, "?account_id=555"))
+}
+
+func (s *IntegrationTestSuite) TestAdminOperationRequiresReason() {
+	deposit := *adminDeposit
+	deposit.Reason = " "
+	resp, code, err := s.processRequest(http.MethodPost, "/admin/addDeposit", token1, deposit)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Equal(s.T(), "{\"error\":\"reason is required\"}\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestAdminWithdrawAndAdjust() {
+	depositMoney(s.T(), s, token2, transaction5)
+	withdraw := *adminDeposit
+	withdraw.IdempotenceKey = 41
+	withdraw.Amount = models.NewMoney(900, 0)
+	resp, code, err := s.processRequest(http.MethodPost, "/admin/withdrawMoney", token1, withdraw)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code, string(resp))
+	adjustment := *adminDeposit
+	adjustment.IdempotenceKey = 42
+	adjustment.Amount = -models.NewMoney(50, 0)
+	adjustment.Comment = ""
+	resp, code, err = s.processRequest(http.MethodPost, "/admin/adjustBalance", token1, adjustment)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code, string(resp))
+	checkBalance(s.T(), s, token2, &models.Balance{Currency: "RUB", Amount: models.NewMoney(50, 50)})
+	adjustment.IdempotenceKey = 43
+	adjustment.Amount = -models.NewMoney(100, 0)
+	resp, code, err = s.processRequest(http.MethodPost, "/admin/adjustBalance", token1, adjustment)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusConflict, code)
+	require.Equal(s.T(), "{\"error\":\"not enough money on the balance\"}\n", string(resp))
+	page := getTransactions(s.T(), s, token2, "sorting=date&descending=true")
+	require.Equal(s.T(), models.TransactionKindAdjustment, page.Transactions[0].Kind)
+	require.Equal(s.T(), "Корректировка баланса", page.Transactions[0].Comment)
+	actions := getAdminActions(s, "?admin_id=555")
+	require.Len(s.T(), actions, 2)
+	require.Equal(s.T(), models.AdminActionAdjustment, actions[0].Action)
+	require.Equal(s.T(), models.AdminActionWithdrawal, actions[1].Action)
+	resp, code, err = s.processRequest(http.MethodGet, "/wallet/checkLedger", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "{\"balanced\":true,\"unbalanced_entries\":[],\"mismatched_wallets\":[]}\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestAdminGetBalances() {
+	depositMoney(s.T(), s, token2, transaction4)
+	depositMoney(s.T(), s, token2, transactionUSD)
+	resp, code, err := s.processRequest(http.MethodGet, "/admin/getBalances?account_id=333", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	requireBalances(s, string(resp),
+		models.WalletBalance{Currency: "RUB", Balance: transaction4.Amount},
+		models.WalletBalance{Currency: "USD", Balance: transactionUSD.Amount})
+}
+
+func getAdminActions(s *IntegrationTestSuite, query string) []models.AdminAction {
+	s.T().Helper()
+	resp, code, err := s.processRequest(http.MethodGet, "/admin/getActions"+query, token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code, string(resp))
+	actions := make([]models.AdminAction, 0)
+	require.NoError(s.T(), json.Unmarshal(resp, &actions))
+	return actions
+}
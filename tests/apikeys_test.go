@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/DANDA322/balance-service/pkg/balancepb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var orderServiceKey = &models.APIKey{
+	ClientName: "order-service",
+	Scopes:     models.ScopeList{models.ScopeReserveRead, models.ScopeReserveWrite, models.ScopeReserveAny},
+	ServiceIDs: models.ServiceIDList{1},
+}
+
+func (s *IntegrationTestSuite) TestAPIKeyReserve() {
+	depositMoney(s.T(), s, token1, transaction1)
+	key := createAPIKey(s, orderServiceKey)
+	require.True(s.T(), strings.HasPrefix(key.Key, models.APIKeyPrefix+key.Prefix+"_"))
+	resp, code, err := s.processAPIKeyRequest(http.MethodPost, "/wallet/reserveMoney", key.Key, reserveTransaction)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code, string(resp))
+	other := *reserveTransaction
+	other.OrderID = 112
+	other.ServiceID = 2
+	_, code, err = s.processAPIKeyRequest(http.MethodPost, "/wallet/reserveMoney", key.Key, other)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusForbidden, code)
+	_, code, err = s.processAPIKeyRequest(http.MethodGet, "/wallet/getReport?month=2022-10", key.Key, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusForbidden, code)
+
+	resp, code, err = s.processRequest(http.MethodGet, "/admin/getApiKeys", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.NotContains(s.T(), string(resp), key.Key)
+	keys := make([]models.APIKey, 0)
+	require.NoError(s.T(), json.Unmarshal(resp, &keys))
+	require.Len(s.T(), keys, 1)
+	require.Equal(s.T(), "order-service", keys[0].ClientName)
+	require.NotNil(s.T(), keys[0].LastUsedAt)
+}
+
+func (s *IntegrationTestSuite) TestAPIKeyGRPC() {
+	depositMoney(s.T(), s, token1, transaction1)
+	key := createAPIKey(s, orderServiceKey)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "ApiKey "+key.Key)
+	_, err := s.client.ReserveMoney(ctx, grpcReserve)
+	require.NoError(s.T(), err)
+	_, err = s.client.GetBalance(ctx, &balancepb.GetBalanceRequest{Currency: "RUB"})
+	require.Equal(s.T(), codes.PermissionDenied, status.Code(err))
+}
+
+func (s *IntegrationTestSuite) TestAPIKeyRotateAndRevoke() {
+	key := createAPIKey(s, orderServiceKey)
+	resp, code, err := s.processRequest(http.MethodPost, "/admin/rotateApiKey", token1,
+		models.APIKeyRotation{ID: key.ID, GracePeriod: 3600})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code, string(resp))
+	rotated := models.APIKey{}
+	require.NoError(s.T(), json.Unmarshal(resp, &rotated))
+	require.Equal(s.T(), key.ID, *rotated.RotatedFrom)
+	require.NotEqual(s.T(), key.Key, rotated.Key)
+	for _, plain := range []string{key.Key, rotated.Key} {
+		_, code, err = s.processAPIKeyRequest(http.MethodGet, "/wallet/getReservations?service_id=1", plain, nil)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), http.StatusOK, code)
+	}
+
+	_, code, err = s.processRequest(http.MethodPost, "/admin/revokeApiKey", token1, models.APIKeyRevocation{ID: key.ID})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	_, code, err = s.processAPIKeyRequest(http.MethodGet, "/wallet/getReservations?service_id=1", key.Key, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusUnauthorized, code)
+	_, code, err = s.processRequest(http.MethodPost, "/admin/rotateApiKey", token1, models.APIKeyRotation{ID: key.ID})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusNotFound, code)
+	_, code, err = s.processAPIKeyRequest(http.MethodGet, "/wallet/getReservations?service_id=1", rotated.Key, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	_, code, err = s.processAPIKeyRequest(http.MethodGet, "/wallet/getReservations", rotated.Key, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusForbidden, code)
+}
+
+func (s *IntegrationTestSuite) TestAPIKeyInvalid() {
+	key := createAPIKey(s, orderServiceKey)
+	for _, plain := range []string{"", "bsk_", key.Key + "0", models.APIKeyPrefix + key.Prefix + "_00"} {
+		_, code, err := s.processAPIKeyRequest(http.MethodGet, "/wallet/getReservations?service_id=1", plain, nil)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), http.StatusUnauthorized, code, plain)
+	}
+	resp, code, err := s.processRequest(http.MethodPost, "/admin/createApiKey", token1,
+		models.APIKey{ClientName: "billing", Scopes: models.ScopeList{"wallet:everything"}})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Equal(s.T(), "{\"error\":\"invalid api key: unknown scope \\\"wallet:everything\\\"\"}\n", string(resp))
+	resp, code, err = s.processRequest(http.MethodPost, "/admin/createApiKey", token1,
+		models.APIKey{ClientName: "billing", Scopes: models.ScopeList{models.ScopeReserveRead}})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Equal(s.T(), "{\"error\":\"invalid api key: service_ids are required for reserve scopes\"}\n", string(resp))
+	resp, code, err = s.processRequest(http.MethodPost, "/admin/createApiKey", token1,
+		models.APIKey{ClientName: "billing", Scopes: models.ScopeList{models.ScopeWalletDeposit}})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Equal(s.T(), "{\"error\":\"invalid api key: scope \\\"wallet:deposit\\\" is not available to api keys\"}\n",
+		string(resp))
+}
+
+func (s *IntegrationTestSuite) TestAPIKeyResponsesAreNotStored() {
+	headers := map[string]string{"Idempotency-Key": "key-1"}
+	keys := make([]string, 0, 3)
+	for _, path := range []string{"/admin/createApiKey", "/admin/createApiKey", "/admin/rotateApiKey"} {
+		body := interface{}(orderServiceKey)
+		if path == "/admin/rotateApiKey" {
+			body = models.APIKeyRotation{ID: 1}
+		}
+		resp, code, err := s.processRequestWithHeaders(http.MethodPost, path, token1, body, headers)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), http.StatusOK, code, string(resp))
+		key := models.APIKey{}
+		require.NoError(s.T(), json.Unmarshal(resp, &key))
+		require.NotContains(s.T(), keys, key.Key)
+		keys = append(keys, key.Key)
+	}
+	for _, path := range []string{"/admin/createApiKey", "/admin/rotateApiKey"} {
+		stored, err := s.store.InsertIdempotencyKey(context.Background(), models.IdempotencyRecord{
+			AccountID: 555, Key: "key-1", Endpoint: path, ExpiresAt: time.Now().UTC().Add(time.Hour)})
+		require.NoError(s.T(), err)
+		require.Nil(s.T(), stored, path)
+	}
+}
+
+func createAPIKey(s *IntegrationTestSuite, key *models.APIKey) *models.APIKey {
+	s.T().Helper()
+	resp, code, err := s.processRequest(http.MethodPost, "/admin/createApiKey", token1, key)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code, string(resp))
+	created := &models.APIKey{}
+	require.NoError(s.T(), json.Unmarshal(resp, created))
+	return created
+}
+
+func (s *IntegrationTestSuite) processAPIKeyRequest(method, path, key string, body interface{}) ([]byte, int, error) {
+	return s.processRequestWithHeaders(method, path, "", body, map[string]string{"Authorization": "ApiKey " + key})
+}
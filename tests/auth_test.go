@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/auth"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *IntegrationTestSuite) TestAuthKeyTypes() {
+	for _, token := range []string{
+		signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "ec-2023", jwt.MapClaims{"account_id": 777}),
+		signToken(s, jwt.SigningMethodEdDSA, "testdata/ed25519.pem", "ed-2023", jwt.MapClaims{"account_id": 777}),
+		signToken(s, jwt.SigningMethodEdDSA, "testdata/ed25519.pem", "", jwt.MapClaims{"account_id": 777}),
+	} {
+		_, code, err := s.processRequest(http.MethodGet, "/wallet/getBalance?currency=RUB", token, nil)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), http.StatusNotFound, code)
+	}
+	for _, token := range []string{
+		signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "rotated-out", jwt.MapClaims{"account_id": 777}),
+		signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "ed-2023", jwt.MapClaims{"account_id": 777}),
+	} {
+		resp, code, err := s.processRequest(http.MethodGet, "/wallet/getBalance?currency=RUB", token, nil)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), http.StatusUnauthorized, code, string(resp))
+	}
+}
+
+func (s *IntegrationTestSuite) TestAuthClockSkew() {
+	skewed := signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "ec-2023",
+		jwt.MapClaims{"account_id": 777, "exp": time.Now().Add(-30 * time.Second).Unix()})
+	_, code, err := s.processRequest(http.MethodGet, "/wallet/getBalance?currency=RUB", skewed, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusNotFound, code)
+	expired := signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "ec-2023",
+		jwt.MapClaims{"account_id": 777, "exp": time.Now().Add(-2 * time.Minute).Unix()})
+	_, code, err = s.processRequest(http.MethodGet, "/wallet/getBalance?currency=RUB", expired, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusUnauthorized, code)
+	early := signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "ec-2023",
+		jwt.MapClaims{"account_id": 777, "nbf": time.Now().Add(2 * time.Minute).Unix()})
+	_, code, err = s.processRequest(http.MethodGet, "/wallet/getBalance?currency=RUB", early, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusUnauthorized, code)
+}
+
+func (s *IntegrationTestSuite) TestAuthIssuerAndAudience() {
+	keys, err := auth.NewFileKeySet(context.Background(), s.log, "testdata/jwks.json")
+	require.NoError(s.T(), err)
+	verifier := auth.NewVerifier(keys, auth.Config{Issuer: "https://auth.example", Audience: "balance",
+		RequireExpiry: true})
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := jwt.MapClaims{"account_id": 777, "role": "user", "iss": "https://auth.example",
+		"aud": []string{"reports", "balance"}, "exp": exp}
+	session, err := verifier.ParseSession(context.Background(),
+		signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "ec-2023", valid))
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 777, session.AccountID)
+	require.Equal(s.T(), "user", session.Role)
+	for _, claims := range []jwt.MapClaims{
+		{"account_id": 777, "iss": "https://other.example", "aud": "balance", "exp": exp},
+		{"account_id": 777, "iss": "https://auth.example", "aud": "reports", "exp": exp},
+		{"account_id": 777, "iss": "https://auth.example", "aud": "balance"},
+	} {
+		_, err = verifier.ParseSession(context.Background(),
+			signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "ec-2023", claims))
+		require.ErrorIs(s.T(), err, auth.ErrInvalidToken)
+	}
+	_, err = verifier.ParseSession(context.Background(), token1)
+	require.ErrorIs(s.T(), err, auth.ErrInvalidToken)
+}
+
+func (s *IntegrationTestSuite) TestAuthKeyRotation() {
+	jwks := struct {
+		Keys []map[string]string `json:"keys"`
+	}{}
+	data, err := os.ReadFile("testdata/jwks.json")
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), json.Unmarshal(data, &jwks))
+	ecKey, edKey := jwks.Keys[1], jwks.Keys[2]
+	path := filepath.Join(s.T().TempDir(), "jwks.json")
+	writeKeys := func(keys ...map[string]string) {
+		data, err := json.Marshal(map[string]interface{}{"keys": keys})
+		require.NoError(s.T(), err)
+		require.NoError(s.T(), os.WriteFile(path, data, 0o600))
+	}
+	writeKeys(ecKey)
+	ctx := context.Background()
+	keys, err := auth.NewFileKeySet(ctx, s.log, path)
+	require.NoError(s.T(), err)
+	verifier := auth.NewVerifier(keys, auth.Config{})
+	edToken := signToken(s, jwt.SigningMethodEdDSA, "testdata/ed25519.pem", "ed-2023", jwt.MapClaims{"account_id": 777})
+	ecToken := signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "ec-2023", jwt.MapClaims{"account_id": 777})
+	_, err = verifier.ParseSession(ctx, edToken)
+	require.ErrorIs(s.T(), err, auth.ErrUnknownKey)
+	writeKeys(ecKey, edKey)
+	require.NoError(s.T(), keys.Refresh(ctx))
+	_, err = verifier.ParseSession(ctx, edToken)
+	require.NoError(s.T(), err)
+	writeKeys(edKey)
+	require.NoError(s.T(), keys.Refresh(ctx))
+	_, err = verifier.ParseSession(ctx, ecToken)
+	require.ErrorIs(s.T(), err, auth.ErrUnknownKey)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, path)
+	}))
+	defer server.Close()
+	remote, err := auth.NewRemoteKeySet(ctx, s.log, server.URL)
+	require.NoError(s.T(), err)
+	_, err = auth.NewVerifier(remote, auth.Config{}).ParseSession(ctx, edToken)
+	require.NoError(s.T(), err)
+}
+
+func signToken(s *IntegrationTestSuite, method jwt.SigningMethod, keyPath, kid string, claims jwt.MapClaims) string {
+	s.T().Helper()
+	data, err := os.ReadFile(keyPath)
+	require.NoError(s.T(), err)
+	block, _ := pem.Decode(data)
+	require.NotNil(s.T(), block)
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	require.NoError(s.T(), err)
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(s.T(), err)
+	return signed
+}
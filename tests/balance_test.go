@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -21,80 +22,101 @@ const (
 
 var transaction1 = &models.Transaction{
 	IdempotenceKey: 1,
-	Amount:         100.50,
+	Amount:         models.NewMoney(100, 50),
+	Currency:       "RUB",
 	Comment:        "Пополнение баланса",
 }
 
 var transaction2 = &models.Transaction{
 	IdempotenceKey: 2,
-	Amount:         100.50,
+	Amount:         models.NewMoney(100, 50),
+	Currency:       "RUB",
 	Comment:        "Снятие средств",
 }
 
 var transaction3 = &models.Transaction{
 	IdempotenceKey: 3,
-	Amount:         10000.0,
+	Amount:         models.NewMoney(10000, 0),
+	Currency:       "RUB",
 	Comment:        "Снятие средств",
 }
 
 var transaction4 = &models.Transaction{
 	IdempotenceKey: 4,
-	Amount:         50,
+	Amount:         models.NewMoney(50, 0),
+	Currency:       "RUB",
 	Comment:        "Пополнение баланса",
 }
 
 var transaction5 = &models.Transaction{
 	IdempotenceKey: 5,
-	Amount:         1000.50,
+	Amount:         models.NewMoney(1000, 50),
+	Currency:       "RUB",
 	Comment:        "Пополнение баланса",
 }
 
 var balance0 = &models.Balance{
 	Currency: "RUB",
-	Amount:   0,
+	Amount:   models.NewMoney(0, 0),
 }
 
 var balance1 = &models.Balance{
 	Currency: "RUB",
-	Amount:   100.5,
+	Amount:   models.NewMoney(100, 50),
 }
 
 var balance2 = &models.Balance{
 	Currency: "RUB",
-	Amount:   150.5,
+	Amount:   models.NewMoney(150, 50),
+}
+
+var transactionUSD = &models.Transaction{
+	IdempotenceKey: 10,
+	Amount:         models.NewMoney(25, 0),
+	Currency:       "USD",
+	Comment:        "Пополнение баланса",
+}
+
+var balanceUSD = &models.Balance{
+	Currency: "USD",
+	Amount:   models.NewMoney(25, 0),
 }
 
 var transferTransaction = &models.TransferTransaction{
 	IdempotenceKey: 6,
 	Target:         333,
-	Amount:         100.5,
+	Amount:         models.NewMoney(100, 50),
+	Currency:       "RUB",
 	Comment:        "Перевод",
 }
 
 var transactions = []models.TransactionFullInfo{
 	{
-		ID:             3,
-		WalletID:       1,
-		Amount:         transaction2.Amount,
-		TargetWalletID: nil,
-		Comment:        transaction2.Comment,
-		Timestamp:      time.Now().UTC(),
+		ID:        3,
+		Kind:      models.TransactionKindWithdrawal,
+		Direction: models.DirectionOut,
+		Amount:    -transaction2.Amount,
+		Currency:  "RUB",
+		Comment:   transaction2.Comment,
+		Timestamp: time.Now().UTC(),
 	},
 	{
-		ID:             2,
-		WalletID:       1,
-		Amount:         transaction5.Amount,
-		TargetWalletID: nil,
-		Comment:        transaction5.Comment,
-		Timestamp:      time.Now().UTC(),
+		ID:        2,
+		Kind:      models.TransactionKindDeposit,
+		Direction: models.DirectionIn,
+		Amount:    transaction5.Amount,
+		Currency:  "RUB",
+		Comment:   transaction5.Comment,
+		Timestamp: time.Now().UTC(),
 	},
 	{
-		ID:             1,
-		WalletID:       1,
-		Amount:         transaction1.Amount,
-		TargetWalletID: nil,
-		Comment:        transaction1.Comment,
-		Timestamp:      time.Now().UTC(),
+		ID:        1,
+		Kind:      models.TransactionKindDeposit,
+		Direction: models.DirectionIn,
+		Amount:    transaction1.Amount,
+		Currency:  "RUB",
+		Comment:   transaction1.Comment,
+		Timestamp: time.Now().UTC(),
 	},
 }
 
@@ -102,24 +124,27 @@ var reserveTransaction = &models.ReserveTransaction{
 	AccountID: 555,
 	ServiceID: 1,
 	OrderID:   111,
-	Amount:    100.5,
+	Amount:    models.NewMoney(100, 50),
+	Currency:  "RUB",
 }
 
 var reserveTransaction2 = &models.ReserveTransaction{
 	AccountID: 555,
 	ServiceID: 1,
 	OrderID:   111,
-	Amount:    5000,
+	Amount:    models.NewMoney(5000, 0),
+	Currency:  "RUB",
 }
 
 var reserveTransaction3 = &models.ReserveTransaction{
 	AccountID: 555,
 	ServiceID: 1,
 	OrderID:   222,
-	Amount:    100.5,
+	Amount:    models.NewMoney(100, 50),
+	Currency:  "RUB",
 }
 
-var csvText = "ServiceTitle;Amount\nУслуга;201\n"
+var csvText = "ServiceTitle;Currency;Amount\nУслуга;RUB;201.00\n"
 
 func (s *IntegrationTestSuite) TestNotFound() {
 	resp, code, err := s.processRequest(http.MethodPost, "/wallet/blabla", token1, "")
@@ -153,9 +178,37 @@ func (s *IntegrationTestSuite) TestAddDepositWrongJSON() {
 	require.Equal(s.T(), "{\"error\":\"Can't decode json\"}\n", string(resp))
 }
 
+func (s *IntegrationTestSuite) TestAddDepositInvalidAmount() {
+	zeroDeposit := &models.Transaction{IdempotenceKey: 7, Currency: "RUB", Comment: "Пополнение баланса"}
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/addDeposit", token1, zeroDeposit)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Equal(s.T(), "{\"error\":\"invalid amount: amount must be positive\"}\n", string(resp))
+	preciseDeposit := map[string]interface{}{"idempotence_key": 8, "amount": 100.505, "currency": "RUB", "comment": "Пополнение баланса"}
+	resp, code, err = s.processRequest(http.MethodPost, "/wallet/addDeposit", token1, preciseDeposit)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Equal(s.T(), "{\"error\":\"invalid amount: more than 2 fractional digits in \\\"100.505\\\"\"}\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestWithdrawMoneyNegativeAmount() {
+	depositMoney(s.T(), s, token1, transaction1)
+	negativeWithdraw := &models.Transaction{
+		IdempotenceKey: 9,
+		Amount:         -transaction1.Amount,
+		Currency:       "RUB",
+		Comment:        "Снятие средств",
+	}
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/withdrawMoney", token1, negativeWithdraw)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Equal(s.T(), "{\"error\":\"invalid amount: amount must be positive\"}\n", string(resp))
+	checkBalance(s.T(), s, token1, balance1)
+}
+
 func (s *IntegrationTestSuite) TestGetBalance() {
 	depositMoney(s.T(), s, token1, transaction1)
-	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getBalance", token1, nil)
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getBalance?currency=RUB", token1, nil)
 	require.NoError(s.T(), err)
 	require.Equal(s.T(), http.StatusOK, code)
 	respStruct := models.Balance{}
@@ -165,9 +218,34 @@ func (s *IntegrationTestSuite) TestGetBalance() {
 	require.Equal(s.T(), balance1.Currency, respStruct.Currency)
 }
 
-func (s *IntegrationTestSuite) TestGetBalanceNotFound() {
+func (s *IntegrationTestSuite) TestGetBalanceDefaultCurrency() {
+	depositMoney(s.T(), s, token1, transaction1)
+	depositMoney(s.T(), s, token1, transactionUSD)
 	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getBalance", token1, nil)
 	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	respStruct := models.Balance{}
+	require.NoError(s.T(), json.Unmarshal(resp, &respStruct))
+	require.Equal(s.T(), *balance1, respStruct)
+}
+
+func (s *IntegrationTestSuite) TestGetBalanceMultiCurrency() {
+	depositMoney(s.T(), s, token1, transaction1)
+	depositMoney(s.T(), s, token1, transactionUSD)
+	checkBalance(s.T(), s, token1, balance1)
+	checkBalance(s.T(), s, token1, balanceUSD)
+}
+
+func (s *IntegrationTestSuite) TestGetBalanceInvalidCurrency() {
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getBalance?currency=rub", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Equal(s.T(), "{\"error\":\"invalid currency: \\\"rub\\\" is not an ISO-4217 code\"}\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestGetBalanceNotFound() {
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getBalance?currency=RUB", token1, nil)
+	require.NoError(s.T(), err)
 	require.Equal(s.T(), http.StatusNotFound, code)
 	require.Equal(s.T(), "{\"error\":\"wallet not found\"}\n", string(resp))
 }
@@ -256,6 +334,66 @@ func (s *IntegrationTestSuite) TestTransferMoneyWalletNotFound() {
 	require.Equal(s.T(), "{\"error\":\"wallet not found\"}\n", string(resp))
 }
 
+func (s *IntegrationTestSuite) TestTransferMoneyCurrencyMismatch() {
+	depositMoney(s.T(), s, token1, transaction1)
+	depositMoney(s.T(), s, token2, transactionUSD)
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/transferMoney", token1, transferTransaction)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusConflict, code)
+	require.Equal(s.T(), "{\"error\":\"currency mismatch\"}\n", string(resp))
+	checkBalance(s.T(), s, token1, balance1)
+}
+
+func (s *IntegrationTestSuite) TestTransferMoneyCrossCurrency() {
+	depositMoney(s.T(), s, token1, transactionUSD)
+	depositMoney(s.T(), s, token2, transaction4)
+	transfer := &models.TransferTransaction{
+		IdempotenceKey: 11,
+		Target:         333,
+		Amount:         models.NewMoney(10, 0),
+		Currency:       "USD",
+		TargetCurrency: "RUB",
+		Comment:        "Перевод",
+	}
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/transferMoney", token1, transfer)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "{\"response\":\"OK\"}\n", string(resp))
+	checkBalance(s.T(), s, token1, &models.Balance{Currency: "USD", Amount: models.NewMoney(15, 0)})
+	checkBalance(s.T(), s, token2, &models.Balance{Currency: "RUB", Amount: models.NewMoney(662, 50)})
+}
+
+func (s *IntegrationTestSuite) TestTransferMoneyWithQuote() {
+	depositMoney(s.T(), s, token1, transaction1)
+	depositMoney(s.T(), s, token2, transactionUSD)
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getExchangeQuote?from=RUB&to=USD", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	quote := models.ExchangeQuote{}
+	err = json.Unmarshal(resp, &quote)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), models.Rate(1632653), quote.Rate)
+	transfer := &models.TransferTransaction{
+		IdempotenceKey: 12,
+		Target:         333,
+		Amount:         models.NewMoney(61, 25),
+		Currency:       "RUB",
+		TargetCurrency: "USD",
+		QuoteID:        quote.ID,
+		Comment:        "Перевод",
+	}
+	resp, code, err = s.processRequest(http.MethodPost, "/wallet/transferMoney", token1, transfer)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "{\"response\":\"OK\"}\n", string(resp))
+	checkBalance(s.T(), s, token2, &models.Balance{Currency: "USD", Amount: models.NewMoney(26, 0)})
+	transfer.IdempotenceKey = 13
+	resp, code, err = s.processRequest(http.MethodPost, "/wallet/transferMoney", token1, transfer)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusNotFound, code)
+	require.Equal(s.T(), "{\"error\":\"exchange quote not found or expired\"}\n", string(resp))
+}
+
 func (s *IntegrationTestSuite) TestTransferMoneyNotEnoughMoney() {
 	depositMoney(s.T(), s, token1, transaction4)
 	depositMoney(s.T(), s, token2, transaction1)
@@ -273,12 +411,122 @@ func (s *IntegrationTestSuite) TestGetWalletTransactionsSortByDateDesc() {
 	to := from.Add(time.Hour * 24)
 	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getTransactions?from="+from.Format(dateTimeFmt)+
 		"&to="+to.Format(dateTimeFmt)+"&limit=10&offset=0&descending=true&sorting=date", token1, nil)
-	var respStruct []models.TransactionFullInfo
+	var respStruct models.TransactionsPage
 	require.NoError(s.T(), err)
 	err = json.Unmarshal(resp, &respStruct)
 	require.NoError(s.T(), err)
 	require.Equal(s.T(), http.StatusOK, code)
-	compareTransactions(s.T(), transactions, respStruct)
+	require.Empty(s.T(), respStruct.NextCursor)
+	compareTransactions(s.T(), transactions, respStruct.Transactions)
+}
+
+func (s *IntegrationTestSuite) TestGetWalletTransactionsCursor() {
+	depositMoney(s.T(), s, token1, transaction1)
+	depositMoney(s.T(), s, token1, transaction5)
+	withdrawMoney(s.T(), s, token1, transaction2)
+	page := getTransactions(s.T(), s, token1, "limit=2")
+	require.Len(s.T(), page.Transactions, 2)
+	require.NotEmpty(s.T(), page.NextCursor)
+	compareTransactions(s.T(), transactions[:2], page.Transactions)
+	depositMoney(s.T(), s, token1, transaction4)
+	page = getTransactions(s.T(), s, token1, "limit=2&cursor="+page.NextCursor)
+	require.Len(s.T(), page.Transactions, 1)
+	require.Empty(s.T(), page.NextCursor)
+	compareTransactions(s.T(), transactions[2:], page.Transactions)
+}
+
+func (s *IntegrationTestSuite) TestGetWalletTransactionsFilters() {
+	depositMoney(s.T(), s, token1, transaction1)
+	depositMoney(s.T(), s, token1, transaction5)
+	withdrawMoney(s.T(), s, token1, transaction2)
+	depositMoney(s.T(), s, token2, transaction4)
+	_, code, err := s.processRequest(http.MethodPost, "/wallet/transferMoney", token1, transferTransaction)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	page := getTransactions(s.T(), s, token1, "type=withdrawal")
+	require.Len(s.T(), page.Transactions, 1)
+	require.Equal(s.T(), -transaction2.Amount, page.Transactions[0].Amount)
+	page = getTransactions(s.T(), s, token1, "type=transfer_out&counterparty=333")
+	require.Len(s.T(), page.Transactions, 1)
+	require.Equal(s.T(), transferTransaction.Comment, page.Transactions[0].Comment)
+	page = getTransactions(s.T(), s, token2, "type=transfer_in&counterparty=555")
+	require.Len(s.T(), page.Transactions, 1)
+	page = getTransactions(s.T(), s, token1, "type=deposit&min_amount=500&max_amount=2000")
+	require.Len(s.T(), page.Transactions, 1)
+	require.Equal(s.T(), transaction5.Amount, page.Transactions[0].Amount)
+	page = getTransactions(s.T(), s, token1, "comment=%D1%81%D0%BD%D1%8F%D1%82%D0%B8%D0%B5")
+	require.Len(s.T(), page.Transactions, 1)
+	require.Equal(s.T(), transaction2.Comment, page.Transactions[0].Comment)
+}
+
+func (s *IntegrationTestSuite) TestGetWalletTransactionsPerspective() {
+	depositMoney(s.T(), s, token1, transaction5)
+	depositMoney(s.T(), s, token2, transaction4)
+	_, code, err := s.processRequest(http.MethodPost, "/wallet/transferMoney", token1, transferTransaction)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	counterparty1, counterparty2 := 333, 555
+	page := getTransactions(s.T(), s, token1, "type=transfer")
+	require.Len(s.T(), page.Transactions, 1)
+	compareTransactions(s.T(), []models.TransactionFullInfo{{
+		ID:           3,
+		Kind:         models.TransactionKindTransfer,
+		Direction:    models.DirectionOut,
+		Amount:       -transferTransaction.Amount,
+		Currency:     "RUB",
+		Counterparty: &counterparty1,
+		Timestamp:    time.Now().UTC(),
+	}}, page.Transactions)
+	page = getTransactions(s.T(), s, token2, "type=transfer")
+	require.Len(s.T(), page.Transactions, 1)
+	compareTransactions(s.T(), []models.TransactionFullInfo{{
+		ID:           3,
+		Kind:         models.TransactionKindTransfer,
+		Direction:    models.DirectionIn,
+		Amount:       transferTransaction.Amount,
+		Currency:     "RUB",
+		Counterparty: &counterparty2,
+		Timestamp:    time.Now().UTC(),
+	}}, page.Transactions)
+}
+
+func (s *IntegrationTestSuite) TestGetWalletTransactionsReservationKinds() {
+	depositMoney(s.T(), s, token1, transaction5)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	reserveMoney(s.T(), s, token1, reserveTransaction3)
+	_, code, err := s.processRequest(http.MethodPost, "/wallet/applyReserve", token1, reserveTransaction)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	_, code, err = s.processRequest(http.MethodPost, "/wallet/cancelReserve", token1, reserveTransaction3)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	page := getTransactions(s.T(), s, token1, "sorting=date&descending=false")
+	require.Len(s.T(), page.Transactions, 5)
+	kinds := make([]string, 0, len(page.Transactions))
+	for _, transaction := range page.Transactions {
+		kinds = append(kinds, transaction.Kind)
+	}
+	require.Equal(s.T(), []string{models.TransactionKindDeposit, models.TransactionKindReserve,
+		models.TransactionKindReserve, models.TransactionKindServiceCharge, models.TransactionKindReserveRelease}, kinds)
+	require.Equal(s.T(), -reserveTransaction.Amount, page.Transactions[3].Amount)
+	require.Equal(s.T(), models.DirectionOut, page.Transactions[3].Direction)
+	require.Equal(s.T(), reserveTransaction3.Amount, page.Transactions[4].Amount)
+	require.Equal(s.T(), models.DirectionIn, page.Transactions[4].Direction)
+}
+
+func (s *IntegrationTestSuite) TestGetWalletTransactionsInvalidFilters() {
+	depositMoney(s.T(), s, token1, transaction1)
+	for query, expected := range map[string]string{
+		"cursor=garbage":             "{\"error\":\"invalid cursor\"}\n",
+		"type=unknown":               "{\"error\":\"invalid filter: unknown type \\\"unknown\\\"\"}\n",
+		"min_amount=10&max_amount=1": "{\"error\":\"invalid filter: min_amount is greater than max_amount\"}\n",
+		"limit=abc":                  "{\"error\":\"Can't parse limit\"}\n",
+	} {
+		resp, code, err := s.processRequest(http.MethodGet, "/wallet/getTransactions?"+query, token1, nil)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), http.StatusBadRequest, code, query)
+		require.Equal(s.T(), expected, string(resp), query)
+	}
 }
 
 func (s *IntegrationTestSuite) TestGetWalletTransactionsWalletNotFound() {
@@ -360,6 +608,22 @@ func (s *IntegrationTestSuite) TestApplyReserveOrderNotFound() {
 	require.Equal(s.T(), "{\"error\":\"reserved order not found\"}\n", string(resp))
 }
 
+func (s *IntegrationTestSuite) TestApplyReserveCurrencyMismatch() {
+	depositMoney(s.T(), s, token1, transaction1)
+	depositMoney(s.T(), s, token1, transactionUSD)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	reserveUSD := *reserveTransaction3
+	reserveUSD.Amount = transactionUSD.Amount
+	reserveUSD.Currency = transactionUSD.Currency
+	reserveMoney(s.T(), s, token1, &reserveUSD)
+	applyUSD := reserveUSD
+	applyUSD.OrderID = reserveTransaction.OrderID
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/applyReserve", token1, &applyUSD)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusConflict, code)
+	require.Equal(s.T(), "{\"error\":\"currency mismatch\"}\n", string(resp))
+}
+
 func (s *IntegrationTestSuite) TestCancelReserve() {
 	depositMoney(s.T(), s, token1, transaction1)
 	reserveMoney(s.T(), s, token1, reserveTransaction)
@@ -369,6 +633,193 @@ func (s *IntegrationTestSuite) TestCancelReserve() {
 	require.Equal(s.T(), "{\"response\":\"OK\"}\n", string(resp))
 }
 
+func (s *IntegrationTestSuite) TestApplyReservePartialCapture() {
+	depositMoney(s.T(), s, token1, transaction1)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	capture := *reserveTransaction
+	capture.IdempotenceKey = 19
+	capture.Amount = models.NewMoney(40, 0)
+	applyMoney(s.T(), s, token1, &capture)
+	capture.IdempotenceKey = 20
+	capture.Amount = models.NewMoney(30, 0)
+	capture.Final = true
+	applyMoney(s.T(), s, token1, &capture)
+	checkBalance(s.T(), s, token1, &models.Balance{Currency: "RUB", Amount: models.NewMoney(30, 50)})
+	capture.IdempotenceKey = 21
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/applyReserve", token1, &capture)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusNotFound, code)
+	require.Equal(s.T(), "{\"error\":\"reserved order not found\"}\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestApplyReserveMultipleCaptures() {
+	depositMoney(s.T(), s, token1, transaction1)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	capture := *reserveTransaction
+	capture.IdempotenceKey = 22
+	capture.Amount = models.NewMoney(50, 0)
+	applyMoney(s.T(), s, token1, &capture)
+	capture.IdempotenceKey = 23
+	capture.Amount = models.NewMoney(60, 0)
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/applyReserve", token1, &capture)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusConflict, code)
+	require.Equal(s.T(), "{\"error\":\"not enough reserved money on the balance\"}\n", string(resp))
+	capture.Amount = models.NewMoney(50, 50)
+	applyMoney(s.T(), s, token1, &capture)
+	checkBalance(s.T(), s, token1, balance0)
+}
+
+func (s *IntegrationTestSuite) TestCancelReserveAfterPartialCapture() {
+	depositMoney(s.T(), s, token1, transaction1)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	capture := *reserveTransaction
+	capture.IdempotenceKey = 24
+	capture.Amount = models.NewMoney(60, 0)
+	applyMoney(s.T(), s, token1, &capture)
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/cancelReserve", token1, reserveTransaction)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "{\"response\":\"OK\"}\n", string(resp))
+	checkBalance(s.T(), s, token1, &models.Balance{Currency: "RUB", Amount: models.NewMoney(40, 50)})
+}
+
+func (s *IntegrationTestSuite) TestExpireReservations() {
+	depositMoney(s.T(), s, token1, transaction1)
+	expiring := *reserveTransaction
+	expiring.TTL = 1
+	reserveMoney(s.T(), s, token1, &expiring)
+	checkBalance(s.T(), s, token1, balance0)
+	time.Sleep(1100 * time.Millisecond)
+	expired, err := s.service.ExpireReservations(context.Background())
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 1, expired)
+	checkBalance(s.T(), s, token1, balance1)
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/cancelReserve", token1, reserveTransaction)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusNotFound, code)
+	require.Equal(s.T(), "{\"error\":\"reserved order not found\"}\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestReserveMoneyInvalidExpiry() {
+	depositMoney(s.T(), s, token1, transaction1)
+	expiresAt := time.Now().Add(-time.Hour)
+	expiring := *reserveTransaction
+	expiring.ExpiresAt = &expiresAt
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/reserveMoney", token1, &expiring)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Equal(s.T(), "{\"error\":\"invalid reservation expiry: expires_at is in the past\"}\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestGetReservation() {
+	depositMoney(s.T(), s, token1, transaction1)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	capture := *reserveTransaction
+	capture.IdempotenceKey = 25
+	capture.Amount = models.NewMoney(40, 0)
+	applyMoney(s.T(), s, token1, &capture)
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getReservation?order_id=111", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	reservation := models.Reservation{}
+	err = json.Unmarshal(resp, &reservation)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), reserveTransaction.OrderID, reservation.OrderID)
+	require.Equal(s.T(), reserveTransaction.AccountID, reservation.AccountID)
+	require.Equal(s.T(), models.ReservationActive, reservation.Status)
+	require.Equal(s.T(), reserveTransaction.Amount, reservation.Amount)
+	require.Equal(s.T(), models.NewMoney(40, 0), reservation.CapturedAmount)
+	require.Equal(s.T(), models.NewMoney(60, 50), reservation.RemainingAmount)
+}
+
+func (s *IntegrationTestSuite) TestGetReservationNotFound() {
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getReservation?order_id=111", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusNotFound, code)
+	require.Equal(s.T(), "{\"error\":\"reserved order not found\"}\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestGetReservations() {
+	depositMoney(s.T(), s, token1, transaction5)
+	depositMoney(s.T(), s, token2, transaction4)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	reserveMoney(s.T(), s, token1, reserveTransaction3)
+	reserveOther := &models.ReserveTransaction{
+		AccountID: 333,
+		ServiceID: 1,
+		OrderID:   333,
+		Amount:    models.NewMoney(10, 0),
+		Currency:  "RUB",
+	}
+	reserveMoney(s.T(), s, token2, reserveOther)
+	_, code, err := s.processRequest(http.MethodPost, "/wallet/cancelReserve", token1, reserveTransaction3)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getReservations?status=Active", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	var reservations []models.Reservation
+	err = json.Unmarshal(resp, &reservations)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), reservations, 2)
+	resp, code, err = s.processRequest(http.MethodGet, "/wallet/getReservations?account_id=555&service_id=1", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	err = json.Unmarshal(resp, &reservations)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), reservations, 2)
+	require.Equal(s.T(), reserveTransaction3.OrderID, reservations[0].OrderID)
+	require.Equal(s.T(), models.ReservationCancelled, reservations[0].Status)
+	resp, code, err = s.processRequest(http.MethodGet, "/wallet/getReservations?status=Pending", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Equal(s.T(), "{\"error\":\"invalid reservation status: \\\"Pending\\\"\"}\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestGetStatement() {
+	depositMoney(s.T(), s, token1, transaction1)
+	depositMoney(s.T(), s, token1, transaction5)
+	withdrawMoney(s.T(), s, token1, transaction2)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	from := time.Now().Add(-time.Hour).UTC().Format(dateTimeFmt)
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getStatement?currency=RUB&format=jsonl&from="+from,
+		token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	lines := strings.Split(strings.TrimSpace(string(resp)), "\n")
+	require.Len(s.T(), lines, 7)
+	require.Contains(s.T(), lines[0], "\"type\":\"opening_balance\"")
+	require.Contains(s.T(), lines[0], "\"balance\":0.00")
+	require.Contains(s.T(), lines[4], "\"kind\":\"reserve\"")
+	require.Contains(s.T(), lines[4], "\"amount\":-100.50,\"balance\":900.00")
+	require.Contains(s.T(), lines[5], "\"total_credit\":1101.00,\"total_debit\":201.00")
+	require.Contains(s.T(), lines[6], "\"type\":\"closing_balance\"")
+	require.Contains(s.T(), lines[6], "\"balance\":900.00")
+	checkBalance(s.T(), s, token1, &models.Balance{Currency: "RUB", Amount: models.NewMoney(900, 0)})
+}
+
+func (s *IntegrationTestSuite) TestGetStatementCSV() {
+	depositMoney(s.T(), s, token1, transaction1)
+	from := time.Now().Add(-time.Hour).UTC().Format(dateTimeFmt)
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getStatement?currency=RUB&from="+from, token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	lines := strings.Split(strings.TrimSpace(string(resp)), "\n")
+	require.Len(s.T(), lines, 6)
+	require.Equal(s.T(), "Timestamp;Kind;TransactionID;Comment;Amount;Balance", lines[0])
+	require.True(s.T(), strings.HasSuffix(lines[2], ";deposit;1;Пополнение баланса;100.50;100.50"))
+	require.True(s.T(), strings.HasSuffix(lines[5], ";closing_balance;;;;100.50"))
+}
+
+func (s *IntegrationTestSuite) TestGetStatementWalletNotFound() {
+	from := time.Now().Add(-time.Hour).UTC().Format(dateTimeFmt)
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getStatement?currency=RUB&from="+from, token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusNotFound, code)
+	require.Equal(s.T(), "{\"error\":\"wallet not found\"}\n", string(resp))
+}
+
 func (s *IntegrationTestSuite) TestGetReport() {
 	depositMoney(s.T(), s, token1, transaction1)
 	depositMoney(s.T(), s, token1, transaction5)
@@ -392,9 +843,101 @@ func (s *IntegrationTestSuite) TestGetReport() {
 	require.NoError(s.T(), err)
 }
 
+func (s *IntegrationTestSuite) TestRefundOrder() {
+	depositMoney(s.T(), s, token1, transaction1)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	applyMoney(s.T(), s, token1, reserveTransaction)
+	refund := &models.RefundTransaction{
+		IdempotenceKey: 15,
+		AccountID:      555,
+		OrderID:        reserveTransaction.OrderID,
+		Amount:         models.NewMoney(50, 0),
+	}
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/refund", token2, refund)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "{\"response\":\"OK\"}\n", string(resp))
+	checkBalance(s.T(), s, token1, &models.Balance{Currency: "RUB", Amount: models.NewMoney(50, 0)})
+	refund.IdempotenceKey = 16
+	refund.Amount = models.NewMoney(50, 51)
+	resp, code, err = s.processRequest(http.MethodPost, "/wallet/refund", token2, refund)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusConflict, code)
+	require.Equal(s.T(), "{\"error\":\"refund exceeds captured amount\"}\n", string(resp))
+	refund.Amount = models.NewMoney(50, 50)
+	resp, code, err = s.processRequest(http.MethodPost, "/wallet/refund", token2, refund)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "{\"response\":\"OK\"}\n", string(resp))
+	checkBalance(s.T(), s, token1, balance1)
+}
+
+func (s *IntegrationTestSuite) TestRefundOrderNotCompleted() {
+	depositMoney(s.T(), s, token1, transaction1)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	refund := &models.RefundTransaction{
+		IdempotenceKey: 17,
+		AccountID:      555,
+		OrderID:        reserveTransaction.OrderID,
+		Amount:         models.NewMoney(50, 0),
+	}
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/refund", token1, refund)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusConflict, code)
+	require.Equal(s.T(), "{\"error\":\"order is not completed\"}\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestGetReportNetOfRefunds() {
+	depositMoney(s.T(), s, token1, transaction5)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	applyMoney(s.T(), s, token1, reserveTransaction)
+	refund := &models.RefundTransaction{
+		IdempotenceKey: 18,
+		AccountID:      555,
+		OrderID:        reserveTransaction.OrderID,
+		Amount:         models.NewMoney(0, 50),
+	}
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/refund", token1, refund)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "{\"response\":\"OK\"}\n", string(resp))
+	resp, code, err = s.processRequest(http.MethodGet, "/wallet/getReport?month="+time.Now().Format("2006-01"), token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "ServiceTitle;Currency;Amount\nУслуга;RUB;100.00\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestCheckLedger() {
+	depositMoney(s.T(), s, token1, transaction5)
+	depositMoney(s.T(), s, token2, transaction4)
+	depositMoney(s.T(), s, token2, transactionUSD)
+	withdrawMoney(s.T(), s, token1, transaction2)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	reserveMoney(s.T(), s, token1, reserveTransaction3)
+	applyMoney(s.T(), s, token1, reserveTransaction)
+	_, code, err := s.processRequest(http.MethodPost, "/wallet/cancelReserve", token1, reserveTransaction3)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	transfer := &models.TransferTransaction{
+		IdempotenceKey: 14,
+		Target:         333,
+		Amount:         models.NewMoney(100, 0),
+		Currency:       "RUB",
+		TargetCurrency: "USD",
+		Comment:        "Перевод",
+	}
+	_, code, err = s.processRequest(http.MethodPost, "/wallet/transferMoney", token1, transfer)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/checkLedger", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "{\"balanced\":true,\"unbalanced_entries\":[],\"mismatched_wallets\":[]}\n", string(resp))
+}
+
 func checkBalance(t *testing.T, s *IntegrationTestSuite, token string, balance *models.Balance) {
 	t.Helper()
-	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getBalance", token, nil)
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getBalance?currency="+balance.Currency, token, nil)
 	require.NoError(s.T(), err)
 	require.Equal(s.T(), http.StatusOK, code)
 	respStruct := models.Balance{}
@@ -436,14 +979,26 @@ func applyMoney(t *testing.T, s *IntegrationTestSuite, token string, transaction
 	require.Equal(s.T(), "{\"response\":\"OK\"}\n", string(resp))
 }
 
+func getTransactions(t *testing.T, s *IntegrationTestSuite, token, query string) models.TransactionsPage {
+	t.Helper()
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getTransactions?"+query, token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code, string(resp))
+	var page models.TransactionsPage
+	require.NoError(t, json.Unmarshal(resp, &page))
+	return page
+}
+
 func compareTransactions(t *testing.T, expected, actual []models.TransactionFullInfo) {
 	t.Helper()
 	for index, element := range actual {
 		require.Equal(t, element.ID, expected[index].ID)
-		require.Equal(t, element.WalletID, expected[index].WalletID)
+		require.Equal(t, element.Kind, expected[index].Kind)
+		require.Equal(t, element.Direction, expected[index].Direction)
 		require.Equal(t, element.Amount, expected[index].Amount)
+		require.Equal(t, element.Currency, expected[index].Currency)
 		require.Equal(t, element.ServiceID, expected[index].ServiceID)
-		require.Equal(t, element.TargetWalletID, expected[index].TargetWalletID)
+		require.Equal(t, element.Counterparty, expected[index].Counterparty)
 		require.Equal(t, element.Timestamp.Truncate(time.Second), expected[index].Timestamp.Truncate(time.Second))
 	}
 }
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"context"
+
+	"github.com/DANDA322/balance-service/pkg/balancepb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var grpcDeposit = &balancepb.Transaction{
+	IdempotenceKey: 30,
+	Amount:         "100.50",
+	Currency:       "RUB",
+	Comment:        "Пополнение баланса",
+}
+
+var grpcReserve = &balancepb.ReserveTransaction{
+	AccountId: 555,
+	ServiceId: 1,
+	OrderId:   111,
+	Amount:    "100.50",
+	Currency:  "RUB",
+}
+
+func grpcContext(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func (s *IntegrationTestSuite) TestGRPCUnauthenticated() {
+	_, err := s.client.GetBalance(context.Background(), &balancepb.GetBalanceRequest{Currency: "RUB"})
+	require.Equal(s.T(), codes.Unauthenticated, status.Code(err))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer invalid")
+	_, err = s.client.GetBalance(ctx, &balancepb.GetBalanceRequest{Currency: "RUB"})
+	require.Equal(s.T(), codes.Unauthenticated, status.Code(err))
+}
+
+func (s *IntegrationTestSuite) TestGRPCDepositAndGetBalance() {
+	ctx := grpcContext(token1)
+	_, err := s.client.AddDeposit(ctx, grpcDeposit)
+	require.NoError(s.T(), err)
+	balance, err := s.client.GetBalance(ctx, &balancepb.GetBalanceRequest{Currency: "RUB"})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "RUB", balance.GetCurrency())
+	require.Equal(s.T(), "100.50", balance.GetAmount())
+	checkBalance(s.T(), s, token1, balance1)
+}
+
+func (s *IntegrationTestSuite) TestGRPCDepositIdempotence() {
+	ctx := grpcContext(token1)
+	_, err := s.client.AddDeposit(ctx, grpcDeposit)
+	require.NoError(s.T(), err)
+	_, err = s.client.AddDeposit(ctx, grpcDeposit)
+	require.Equal(s.T(), codes.AlreadyExists, status.Code(err))
+}
+
+func (s *IntegrationTestSuite) TestGRPCInvalidAmount() {
+	deposit := &balancepb.Transaction{IdempotenceKey: 31, Amount: "-1", Currency: "RUB"}
+	_, err := s.client.AddDeposit(grpcContext(token1), deposit)
+	require.Equal(s.T(), codes.InvalidArgument, status.Code(err))
+}
+
+func (s *IntegrationTestSuite) TestGRPCWalletNotFound() {
+	_, err := s.client.GetBalance(grpcContext(token1), &balancepb.GetBalanceRequest{Currency: "RUB"})
+	require.Equal(s.T(), codes.NotFound, status.Code(err))
+	require.Equal(s.T(), "wallet not found", status.Convert(err).Message())
+}
+
+func (s *IntegrationTestSuite) TestGRPCWithdrawNotEnoughMoney() {
+	ctx := grpcContext(token1)
+	_, err := s.client.AddDeposit(ctx, grpcDeposit)
+	require.NoError(s.T(), err)
+	withdraw := &balancepb.Transaction{IdempotenceKey: 32, Amount: "1000", Currency: "RUB"}
+	_, err = s.client.WithdrawMoney(ctx, withdraw)
+	require.Equal(s.T(), codes.FailedPrecondition, status.Code(err))
+	require.Equal(s.T(), "not enough money on the balance", status.Convert(err).Message())
+}
+
+func (s *IntegrationTestSuite) TestGRPCReserveAndApply() {
+	ctx := grpcContext(token1)
+	_, err := s.client.AddDeposit(ctx, grpcDeposit)
+	require.NoError(s.T(), err)
+	_, err = s.client.ReserveMoney(ctx, grpcReserve)
+	require.NoError(s.T(), err)
+	checkBalance(s.T(), s, token1, balance0)
+	_, err = s.client.ApplyReserve(ctx, grpcReserve)
+	require.NoError(s.T(), err)
+	report, err := s.client.GetReport(ctx, &balancepb.GetReportRequest{Month: "2022-11"})
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), report)
+}
+
+func (s *IntegrationTestSuite) TestGRPCReserveAndCancel() {
+	ctx := grpcContext(token1)
+	_, err := s.client.AddDeposit(ctx, grpcDeposit)
+	require.NoError(s.T(), err)
+	_, err = s.client.ReserveMoney(ctx, grpcReserve)
+	require.NoError(s.T(), err)
+	_, err = s.client.CancelReserve(ctx, grpcReserve)
+	require.NoError(s.T(), err)
+	checkBalance(s.T(), s, token1, balance1)
+}
+
+func (s *IntegrationTestSuite) TestGRPCGetTransactions() {
+	ctx := grpcContext(token1)
+	_, err := s.client.AddDeposit(ctx, grpcDeposit)
+	require.NoError(s.T(), err)
+	resp, err := s.client.GetTransactions(ctx, &balancepb.GetTransactionsRequest{Limit: 10})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), resp.GetTransactions(), 1)
+	require.Equal(s.T(), "100.50", resp.GetTransactions()[0].GetAmount())
+	require.Equal(s.T(), grpcDeposit.Comment, resp.GetTransactions()[0].GetComment())
+}
+
+func (s *IntegrationTestSuite) TestGRPCGetReportInvalidMonth() {
+	_, err := s.client.GetReport(grpcContext(token1), &balancepb.GetReportRequest{Month: "2022/11"})
+	require.Equal(s.T(), codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"net/http"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *IntegrationTestSuite) TestIdempotencyKeyReplaysResponse() {
+	headers := map[string]string{"Idempotency-Key": "deposit-1"}
+	resp, code, err := s.processRequestWithHeaders(http.MethodPost, "/wallet/addDeposit", token1, transaction1, headers)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "{\"response\":\"OK\"}\n", string(resp))
+	resp, code, err = s.processRequestWithHeaders(http.MethodPost, "/wallet/addDeposit", token1, transaction1, headers)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "{\"response\":\"OK\"}\n", string(resp))
+	checkBalance(s.T(), s, token1, balance1)
+}
+
+func (s *IntegrationTestSuite) TestIdempotencyKeyReusedWithDifferentPayload() {
+	headers := map[string]string{"Idempotency-Key": "deposit-1"}
+	_, code, err := s.processRequestWithHeaders(http.MethodPost, "/wallet/addDeposit", token1, transaction1, headers)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	resp, code, err := s.processRequestWithHeaders(http.MethodPost, "/wallet/addDeposit", token1, transaction4, headers)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusUnprocessableEntity, code)
+	require.Equal(s.T(), "{\"error\":\"idempotency key reused with a different payload\"}\n", string(resp))
+	checkBalance(s.T(), s, token1, balance1)
+}
+
+func (s *IntegrationTestSuite) TestIdempotencyKeyCancelReserve() {
+	depositMoney(s.T(), s, token1, transaction1)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	headers := map[string]string{"Idempotency-Key": "cancel-111"}
+	for i := 0; i < 2; i++ {
+		resp, code, err := s.processRequestWithHeaders(http.MethodPost, "/wallet/cancelReserve", token1,
+			reserveTransaction, headers)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), http.StatusOK, code)
+		require.Equal(s.T(), "{\"response\":\"OK\"}\n", string(resp))
+	}
+	checkBalance(s.T(), s, token1, balance1)
+}
+
+func (s *IntegrationTestSuite) TestIdempotencyKeyScopedByEndpoint() {
+	headers := map[string]string{"Idempotency-Key": "same-key"}
+	_, code, err := s.processRequestWithHeaders(http.MethodPost, "/wallet/addDeposit", token1, transaction5, headers)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	_, code, err = s.processRequestWithHeaders(http.MethodPost, "/wallet/withdrawMoney", token1, transaction2, headers)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	checkBalance(s.T(), s, token1, &models.Balance{Currency: "RUB", Amount: models.NewMoney(900, 0)})
+}
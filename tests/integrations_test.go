@@ -6,31 +6,45 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"testing"
 	"time"
 
 	"github.com/DANDA322/balance-service/internal"
+	"github.com/DANDA322/balance-service/internal/auth"
+	"github.com/DANDA322/balance-service/internal/exchange"
+	"github.com/DANDA322/balance-service/internal/grpcapi"
+	"github.com/DANDA322/balance-service/internal/models"
 	"github.com/DANDA322/balance-service/internal/pgstore"
 	"github.com/DANDA322/balance-service/internal/rest"
+	"github.com/DANDA322/balance-service/pkg/balancepb"
 	"github.com/DANDA322/balance-service/pkg/logging"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	migrate "github.com/rubenv/sql-migrate"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 const (
-	addr = ":3333"
+	addr     = ":3333"
+	grpcAddr = ":3334"
 )
 
 type IntegrationTestSuite struct {
 	suite.Suite
-	log     *logrus.Logger
-	store   *pgstore.DB
-	service *internal.App
-	server  *http.Server
+	log      *logrus.Logger
+	store    *pgstore.DB
+	service  *internal.App
+	verifier *auth.Verifier
+	server   *http.Server
+	grpc     *grpc.Server
+	conn     *grpc.ClientConn
+	client   balancepb.BalanceServiceClient
+	cancel   context.CancelFunc
 }
 
 func (s *IntegrationTestSuite) SetupSuite() {
@@ -43,8 +57,17 @@ func (s *IntegrationTestSuite) SetupSuite() {
 	require.NoError(s.T(), err)
 	err = s.store.Migrate(migrate.Up)
 	require.NoError(s.T(), err)
-	s.service = internal.NewApp(s.log, s.store)
-	router := rest.NewRouter(s.log, s.service)
+	rates := exchange.NewStaticProvider(map[string]models.Rate{
+		"USD/RUB": models.Rate(6125000000),
+	})
+	s.service = internal.NewApp(s.log, s.store, rates)
+	var listenCtx context.Context
+	listenCtx, s.cancel = context.WithCancel(ctx)
+	go s.service.RunBalanceNotifications(listenCtx)
+	keys, err := auth.NewFileKeySet(ctx, s.log, "testdata/jwks.json")
+	require.NoError(s.T(), err)
+	s.verifier = auth.NewVerifier(keys, auth.Config{ClockSkew: time.Minute})
+	router := rest.NewRouter(s.log, s.service, s.verifier)
 	s.server = &http.Server{
 		Addr:              addr,
 		Handler:           router,
@@ -53,6 +76,15 @@ func (s *IntegrationTestSuite) SetupSuite() {
 	go func() {
 		_ = s.server.ListenAndServe()
 	}()
+	s.grpc = grpcapi.NewServer(s.log, s.service, s.verifier)
+	lis, err := net.Listen("tcp", grpcAddr)
+	require.NoError(s.T(), err)
+	go func() {
+		_ = s.grpc.Serve(lis)
+	}()
+	s.conn, err = grpc.Dial("localhost"+grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(s.T(), err)
+	s.client = balancepb.NewBalanceServiceClient(s.conn)
 	time.Sleep(100 * time.Millisecond)
 }
 
@@ -64,7 +96,10 @@ func (s *IntegrationTestSuite) SetupTest() {
 }
 
 func (s *IntegrationTestSuite) TearDownSuite() {
+	s.cancel()
 	_ = s.server.Shutdown(context.Background())
+	_ = s.conn.Close()
+	s.grpc.GracefulStop()
 	err := s.store.Migrate(migrate.Down)
 	require.NoError(s.T(), err)
 	err = s.store.Migrate(migrate.Up)
@@ -72,12 +107,20 @@ func (s *IntegrationTestSuite) TearDownSuite() {
 }
 
 func (s *IntegrationTestSuite) processRequest(method, path, token string, body interface{}) ([]byte, int, error) {
+	return s.processRequestWithHeaders(method, path, token, body, nil)
+}
+
+func (s *IntegrationTestSuite) processRequestWithHeaders(method, path, token string, body interface{},
+	headers map[string]string) ([]byte, int, error) {
 	requestBody, err := json.Marshal(body)
 	require.NoError(s.T(), err)
 	path = fmt.Sprintf("http://localhost%s%s", addr, path)
 	req, err := http.NewRequestWithContext(context.Background(), method, path, bytes.NewReader(requestBody))
 	require.NoError(s.T(), err)
 	req.Header.Set("Authorization", "Bearer "+token)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 	resp, err := http.DefaultClient.Do(req)
 	require.NoError(s.T(), err)
 	defer resp.Body.Close()
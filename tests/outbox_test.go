@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/DANDA322/balance-service/internal/outbox"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+const kafkaAddr = "localhost:9092"
+
+type flakyPublisher struct {
+	failAccount int
+	published   []models.Event
+}
+
+func (p *flakyPublisher) Publish(_ context.Context, event models.Event) error {
+	if event.AccountID == p.failAccount {
+		p.failAccount = 0
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func (p *flakyPublisher) Close() error {
+	return nil
+}
+
+func (s *IntegrationTestSuite) TestOutboxEvents() {
+	depositMoney(s.T(), s, token1, transaction1)
+	depositMoney(s.T(), s, token2, transaction4)
+	_, code, err := s.processRequest(http.MethodPost, "/wallet/transferMoney", token1, transferTransaction)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	buf := &bytes.Buffer{}
+	relay := outbox.NewRelay(s.log, s.store, outbox.NewWriterPublisher(buf))
+	published, err := relay.Flush(context.Background())
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 4, published)
+	events := make(map[int][]models.Event)
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		event := models.Event{}
+		require.NoError(s.T(), json.Unmarshal(scanner.Bytes(), &event))
+		events[event.AccountID] = append(events[event.AccountID], event)
+	}
+	requireEvents(s, events[555], models.EventFundsDeposited, models.EventTransferCompleted)
+	requireEvents(s, events[333], models.EventFundsDeposited, models.EventTransferReceived)
+	payload := models.BalanceEvent{}
+	require.NoError(s.T(), json.Unmarshal(events[333][1].Payload, &payload))
+	require.Equal(s.T(), 555, payload.Counterparty)
+	require.Equal(s.T(), transferTransaction.Amount, payload.Amount)
+	published, err = relay.Flush(context.Background())
+	require.NoError(s.T(), err)
+	require.Zero(s.T(), published)
+}
+
+func (s *IntegrationTestSuite) TestOutboxRedeliversInOrder() {
+	depositMoney(s.T(), s, token1, transaction1)
+	depositMoney(s.T(), s, token2, transaction4)
+	withdrawMoney(s.T(), s, token1, transaction2)
+	publisher := &flakyPublisher{failAccount: 555}
+	relay := outbox.NewRelay(s.log, s.store, publisher)
+	published, err := relay.Flush(context.Background())
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 1, published)
+	published, err = relay.Flush(context.Background())
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 2, published)
+	require.Len(s.T(), publisher.published, 3)
+	require.Equal(s.T(), 333, publisher.published[0].AccountID)
+	requireEvents(s, publisher.published[1:], models.EventFundsDeposited, models.EventFundsWithdrawn)
+}
+
+// concurrentPublisher runs a second relay while the first one is publishing its batch.
+type concurrentPublisher struct {
+	flakyPublisher
+	relay    *outbox.Relay
+	relayed  int
+	relayErr error
+}
+
+func (p *concurrentPublisher) Publish(ctx context.Context, event models.Event) error {
+	if p.relay != nil {
+		p.relayed, p.relayErr = p.relay.Flush(ctx)
+		p.relay = nil
+	}
+	return p.flakyPublisher.Publish(ctx, event)
+}
+
+func (s *IntegrationTestSuite) TestOutboxSkipsClaimedBatch() {
+	depositMoney(s.T(), s, token1, transaction1)
+	withdrawMoney(s.T(), s, token1, transaction2)
+	second := &flakyPublisher{}
+	publisher := &concurrentPublisher{relay: outbox.NewRelay(s.log, s.store, second)}
+	published, err := outbox.NewRelay(s.log, s.store, publisher).Flush(context.Background())
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 2, published)
+	require.NoError(s.T(), publisher.relayErr)
+	require.Zero(s.T(), publisher.relayed)
+	require.Empty(s.T(), second.published)
+	requireEvents(s, publisher.published, models.EventFundsDeposited, models.EventFundsWithdrawn)
+}
+
+func (s *IntegrationTestSuite) TestOutboxCrashedRelayHoldsBackOnlyItsAccounts() {
+	depositMoney(s.T(), s, token1, transaction1)
+	depositMoney(s.T(), s, token2, transaction4)
+	func() {
+		defer func() {
+			require.NotNil(s.T(), recover())
+		}()
+		_, _ = s.store.RelayEvents(context.Background(), 1, func(context.Context, []models.Event) []int {
+			panic("relay crashed")
+		})
+	}()
+	publisher := &flakyPublisher{}
+	published, err := outbox.NewRelay(s.log, s.store, publisher).Flush(context.Background())
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 1, published)
+	require.Equal(s.T(), 333, publisher.published[0].AccountID)
+}
+
+func (s *IntegrationTestSuite) TestOutboxReservationEvents() {
+	depositMoney(s.T(), s, token1, transaction1)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	applyMoney(s.T(), s, token1, reserveTransaction)
+	publisher := &flakyPublisher{}
+	_, err := outbox.NewRelay(s.log, s.store, publisher).Flush(context.Background())
+	require.NoError(s.T(), err)
+	requireEvents(s, publisher.published, models.EventFundsDeposited, models.EventFundsReserved,
+		models.EventReserveApplied)
+}
+
+func (s *IntegrationTestSuite) TestOutboxKafka() {
+	conn, err := net.DialTimeout("tcp", kafkaAddr, time.Second)
+	if err != nil {
+		s.T().Skip("kafka is not available")
+	}
+	_ = conn.Close()
+	depositMoney(s.T(), s, token1, transaction1)
+	withdrawMoney(s.T(), s, token1, transaction2)
+	topic := fmt.Sprintf("balance-events-%d", time.Now().UnixNano())
+	publisher := outbox.NewKafkaPublisher([]string{kafkaAddr}, topic)
+	defer publisher.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	published, err := outbox.NewRelay(s.log, s.store, publisher).Flush(ctx)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 2, published)
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: []string{kafkaAddr},
+		Topic:   topic,
+	})
+	defer reader.Close()
+	events := make([]models.Event, 0, 2)
+	for len(events) < 2 {
+		message, err := reader.ReadMessage(ctx)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), "555", string(message.Key))
+		event := models.Event{}
+		require.NoError(s.T(), json.Unmarshal(message.Value, &event))
+		events = append(events, event)
+	}
+	requireEvents(s, events, models.EventFundsDeposited, models.EventFundsWithdrawn)
+}
+
+func requireEvents(s *IntegrationTestSuite, events []models.Event, types ...string) {
+	require.Len(s.T(), events, len(types))
+	for i, eventType := range types {
+		require.Equal(s.T(), eventType, events[i].Type)
+		require.Equal(s.T(), int64(i+1), events[i].Sequence, "event "+strconv.Itoa(events[i].ID))
+	}
+}
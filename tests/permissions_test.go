@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/DANDA322/balance-service/pkg/balancepb"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *IntegrationTestSuite) TestReportRequiresReportScope() {
+	path := "/wallet/getReport?month=" + time.Now().Format("2006-01")
+	user := signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "ec-2023", jwt.MapClaims{"account_id": 777})
+	resp, code, err := s.processRequest(http.MethodGet, path, user, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusForbidden, code)
+	require.Equal(s.T(), "{\"error\":\"scope report:read is required\"}\n", string(resp))
+	_, err = s.client.GetReport(grpcContext(user), &balancepb.GetReportRequest{Month: time.Now().Format("2006-01")})
+	require.Equal(s.T(), codes.PermissionDenied, status.Code(err))
+
+	finance := signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "ec-2023",
+		jwt.MapClaims{"account_id": 888, "role": "finance"})
+	_, code, err = s.processRequest(http.MethodGet, path, finance, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	_, code, err = s.processRequest(http.MethodPost, "/wallet/addDeposit", finance, transaction1)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusForbidden, code)
+}
+
+func (s *IntegrationTestSuite) TestUserScopes() {
+	user := signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "ec-2023", jwt.MapClaims{"account_id": 555})
+	depositMoney(s.T(), s, user, transaction1)
+	reserveMoney(s.T(), s, user, reserveTransaction)
+	for _, path := range []string{
+		"/wallet/checkLedger",
+		"/services/getServices",
+		"/webhooks/getWebhooks",
+		"/admin/getBalances?account_id=555",
+		"/admin/verifyAuditLog",
+	} {
+		_, code, err := s.processRequest(http.MethodGet, path, user, nil)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), http.StatusForbidden, code, path)
+	}
+	other := *reserveTransaction
+	other.AccountID = 333
+	other.OrderID = 112
+	_, code, err := s.processRequest(http.MethodPost, "/wallet/reserveMoney", user, other)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusForbidden, code)
+}
+
+func (s *IntegrationTestSuite) TestScopeClaimNarrowsRole() {
+	depositMoney(s.T(), s, token1, transaction1)
+	readOnly := signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "ec-2023",
+		jwt.MapClaims{"account_id": 555, "role": "admin", "scope": "wallet:read report:read"})
+	checkBalance(s.T(), s, readOnly, balance1)
+	_, code, err := s.processRequest(http.MethodGet, "/wallet/getReport?month="+time.Now().Format("2006-01"),
+		readOnly, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	_, code, err = s.processRequest(http.MethodPost, "/wallet/withdrawMoney", readOnly, transaction4)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusForbidden, code)
+	_, code, err = s.processRequest(http.MethodGet, "/admin/getBalances?account_id=555", readOnly, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusForbidden, code)
+
+	depositOnly := signToken(s, jwt.SigningMethodES256, "testdata/ec.pem", "ec-2023",
+		jwt.MapClaims{"account_id": 555, "scope": []string{"wallet:deposit"}})
+	_, code, err = s.processRequest(http.MethodGet, "/wallet/getBalance?currency=RUB", depositOnly, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusForbidden, code)
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *IntegrationTestSuite) TestCreateService() {
+	price := models.NewMoney(250, 0)
+	currency := "RUB"
+	service := &models.Service{Title: "Доставка", Description: "Курьерская доставка", DefaultPrice: &price,
+		Currency: &currency}
+	created := createService(s, service)
+	require.Equal(s.T(), 2, created.ID)
+	require.True(s.T(), created.Active)
+	require.Equal(s.T(), price, *created.DefaultPrice)
+	resp, code, err := s.processRequest(http.MethodGet, "/services/getService?id=2", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	fetched := models.Service{}
+	err = json.Unmarshal(resp, &fetched)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), service.Title, fetched.Title)
+	require.Equal(s.T(), service.Description, fetched.Description)
+	resp, code, err = s.processRequest(http.MethodGet, "/services/getServices", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	var services []models.Service
+	err = json.Unmarshal(resp, &services)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), services, 2)
+}
+
+func (s *IntegrationTestSuite) TestCreateServiceInvalid() {
+	resp, code, err := s.processRequest(http.MethodPost, "/services/createService", token1, &models.Service{})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusBadRequest, code)
+	require.Equal(s.T(), "{\"error\":\"invalid service: title is required\"}\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestApplyReserveInactiveService() {
+	depositMoney(s.T(), s, token1, transaction1)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	updateService(s, &models.Service{ID: 1, Title: "Услуга", Active: false})
+	resp, code, err := s.processRequest(http.MethodPost, "/wallet/applyReserve", token1, reserveTransaction)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusConflict, code)
+	require.Equal(s.T(), "{\"error\":\"service is inactive\"}\n", string(resp))
+	resp, code, err = s.processRequest(http.MethodPost, "/wallet/cancelReserve", token1, reserveTransaction)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "{\"response\":\"OK\"}\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestGetReportKeepsTitleAfterRename() {
+	depositMoney(s.T(), s, token1, transaction1)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	applyMoney(s.T(), s, token1, reserveTransaction)
+	updateService(s, &models.Service{ID: 1, Title: "Новая услуга", Active: true})
+	resp, code, err := s.processRequest(http.MethodGet, "/wallet/getReport?month="+time.Now().Format("2006-01"), token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "ServiceTitle;Currency;Amount\nУслуга;RUB;100.50\n", string(resp))
+}
+
+func (s *IntegrationTestSuite) TestDeleteService() {
+	created := createService(s, &models.Service{Title: "Доставка"})
+	depositMoney(s.T(), s, token1, transaction1)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	resp, code, err := s.processRequest(http.MethodPost, "/services/deleteService", token1, &models.Service{ID: 1})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusConflict, code)
+	require.Equal(s.T(), "{\"error\":\"service is referenced by orders\"}\n", string(resp))
+	resp, code, err = s.processRequest(http.MethodPost, "/services/deleteService", token1, created)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	require.Equal(s.T(), "{\"response\":\"OK\"}\n", string(resp))
+	resp, code, err = s.processRequest(http.MethodGet, "/services/getService?id=2", token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusNotFound, code)
+	require.Equal(s.T(), "{\"error\":\"service not found\"}\n", string(resp))
+}
+
+func createService(s *IntegrationTestSuite, service *models.Service) *models.Service {
+	s.T().Helper()
+	resp, code, err := s.processRequest(http.MethodPost, "/services/createService", token1, service)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	created := models.Service{}
+	err = json.Unmarshal(resp, &created)
+	require.NoError(s.T(), err)
+	return &created
+}
+
+func updateService(s *IntegrationTestSuite, service *models.Service) {
+	s.T().Helper()
+	_, code, err := s.processRequest(http.MethodPost, "/services/updateService", token1, service)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+type sseMessage struct {
+	id    string
+	event string
+	data  string
+}
+
+type sseStream struct {
+	resp     *http.Response
+	messages chan sseMessage
+}
+
+func (s *IntegrationTestSuite) openStream(token, lastEventID string) *sseStream {
+	s.T().Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet,
+		"http://localhost"+addr+"/wallet/stream", nil)
+	require.NoError(s.T(), err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	require.Equal(s.T(), "text/event-stream", resp.Header.Get("Content-Type"))
+	stream := &sseStream{resp: resp, messages: make(chan sseMessage, 100)}
+	go func() {
+		defer close(stream.messages)
+		scanner := bufio.NewScanner(resp.Body)
+		message := sseMessage{}
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if message.event != "" {
+					stream.messages <- message
+				}
+				message = sseMessage{}
+			case strings.HasPrefix(line, "id: "):
+				message.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				message.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				message.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return stream
+}
+
+func (st *sseStream) next(s *IntegrationTestSuite) sseMessage {
+	s.T().Helper()
+	select {
+	case message, ok := <-st.messages:
+		require.True(s.T(), ok, "stream closed")
+		return message
+	case <-time.After(5 * time.Second):
+		s.T().Fatal("no stream message")
+	}
+	return sseMessage{}
+}
+
+func (st *sseStream) close() {
+	_ = st.resp.Body.Close()
+}
+
+func (s *IntegrationTestSuite) TestStreamBalance() {
+	depositMoney(s.T(), s, token1, transaction1)
+	stream := s.openStream(token1, "")
+	defer stream.close()
+	snapshot := stream.next(s)
+	require.Equal(s.T(), "balance", snapshot.event)
+	requireBalances(s, snapshot.data, models.WalletBalance{Currency: "RUB", Balance: transaction1.Amount})
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	message := stream.next(s)
+	require.Equal(s.T(), "transaction", message.event)
+	require.Equal(s.T(), "2", message.id)
+	event := models.Event{}
+	require.NoError(s.T(), json.Unmarshal([]byte(message.data), &event))
+	require.Equal(s.T(), models.EventFundsReserved, event.Type)
+	snapshot = stream.next(s)
+	require.Equal(s.T(), "balance", snapshot.event)
+	requireBalances(s, snapshot.data, models.WalletBalance{Currency: "RUB", ReservedBalance: reserveTransaction.Amount})
+}
+
+func (s *IntegrationTestSuite) TestStreamBalanceResume() {
+	depositMoney(s.T(), s, token1, transaction1)
+	withdrawMoney(s.T(), s, token1, transaction2)
+	stream := s.openStream(token1, "1")
+	defer stream.close()
+	require.Equal(s.T(), "balance", stream.next(s).event)
+	message := stream.next(s)
+	require.Equal(s.T(), "transaction", message.event)
+	require.Equal(s.T(), "2", message.id)
+	event := models.Event{}
+	require.NoError(s.T(), json.Unmarshal([]byte(message.data), &event))
+	require.Equal(s.T(), models.EventFundsWithdrawn, event.Type)
+	require.Equal(s.T(), "balance", stream.next(s).event)
+}
+
+func (s *IntegrationTestSuite) TestStreamBalanceInvalidLastEventID() {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet,
+		"http://localhost"+addr+"/wallet/stream?last_event_id=abc", nil)
+	require.NoError(s.T(), err)
+	req.Header.Set("Authorization", "Bearer "+token1)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+	require.Equal(s.T(), http.StatusBadRequest, resp.StatusCode)
+}
+
+func requireBalances(s *IntegrationTestSuite, data string, expected ...models.WalletBalance) {
+	s.T().Helper()
+	balances := make([]models.WalletBalance, 0)
+	require.NoError(s.T(), json.Unmarshal([]byte(data), &balances))
+	require.Equal(s.T(), expected, balances)
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DANDA322/balance-service/internal/models"
+	"github.com/DANDA322/balance-service/internal/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+const webhookSecret = "0123456789abcdef"
+
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	rcv.requests = append(rcv.requests, r)
+	rcv.bodies = append(rcv.bodies, body)
+	w.WriteHeader(rcv.status)
+}
+
+func (s *IntegrationTestSuite) TestWebhookDelivery() {
+	receiver := &webhookReceiver{status: http.StatusOK}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	created := createWebhook(s, &models.Webhook{
+		URL:        server.URL,
+		Secret:     webhookSecret,
+		EventTypes: models.EventTypeList{models.EventReserveApplied, models.EventReserveCancelled},
+	})
+	require.Empty(s.T(), created.Secret)
+	depositMoney(s.T(), s, token1, transaction1)
+	reserveMoney(s.T(), s, token1, reserveTransaction)
+	applyMoney(s.T(), s, token1, reserveTransaction)
+	delivered, err := webhook.NewDispatcher(s.log, s.store).Flush(context.Background())
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 1, delivered)
+	require.Len(s.T(), receiver.requests, 1)
+	req, body := receiver.requests[0], receiver.bodies[0]
+	require.Equal(s.T(), models.EventReserveApplied, req.Header.Get(webhook.EventHeader))
+	signature := req.Header.Get(webhook.SignatureHeader)
+	unix, err := strconv.ParseInt(strings.TrimPrefix(strings.Split(signature, ",")[0], "t="), 10, 64)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), webhook.Sign(webhookSecret, time.Unix(unix, 0), body), signature)
+	event := models.Event{}
+	require.NoError(s.T(), json.Unmarshal(body, &event))
+	require.Equal(s.T(), 555, event.AccountID)
+	deliveries := getDeliveries(s, "?status="+models.DeliveryDelivered)
+	require.Len(s.T(), deliveries, 1)
+	require.Equal(s.T(), created.ID, deliveries[0].WebhookID)
+	require.Equal(s.T(), models.EventReserveApplied, deliveries[0].EventType)
+}
+
+func (s *IntegrationTestSuite) TestWebhookRetriesAndReplay() {
+	receiver := &webhookReceiver{status: http.StatusInternalServerError}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	createWebhook(s, &models.Webhook{
+		URL:        server.URL,
+		Secret:     webhookSecret,
+		EventTypes: models.EventTypeList{models.EventTransferCompleted},
+	})
+	depositMoney(s.T(), s, token1, transaction1)
+	depositMoney(s.T(), s, token2, transaction4)
+	_, code, err := s.processRequest(http.MethodPost, "/wallet/transferMoney", token1, transferTransaction)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code)
+	dispatcher := webhook.NewDispatcher(s.log, s.store)
+	dispatcher.SetRetryPolicy(2, 0, 0)
+	for i := 0; i < 3; i++ {
+		delivered, err := dispatcher.Flush(context.Background())
+		require.NoError(s.T(), err)
+		require.Zero(s.T(), delivered)
+	}
+	require.Len(s.T(), receiver.requests, 2)
+	deliveries := getDeliveries(s, "?status="+models.DeliveryDead)
+	require.Len(s.T(), deliveries, 1)
+	delivery := getDelivery(s, deliveries[0].ID)
+	require.Equal(s.T(), 2, delivery.AttemptCount)
+	require.Len(s.T(), delivery.Attempts, 2)
+	require.Equal(s.T(), http.StatusInternalServerError, *delivery.Attempts[1].ResponseStatus)
+	receiver.status = http.StatusNoContent
+	resp, code, err := s.processRequest(http.MethodPost, "/webhooks/replayDelivery", token1,
+		map[string]int{"id": delivery.ID})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code, string(resp))
+	delivered, err := dispatcher.Flush(context.Background())
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 1, delivered)
+	delivery = getDelivery(s, delivery.ID)
+	require.Equal(s.T(), models.DeliveryDelivered, delivery.Status)
+	require.Len(s.T(), delivery.Attempts, 3)
+	require.NotNil(s.T(), delivery.DeliveredAt)
+}
+
+func (s *IntegrationTestSuite) TestCreateWebhookInvalid() {
+	invalid := []*models.Webhook{
+		{URL: "ftp://example.com", Secret: webhookSecret, EventTypes: models.EventTypeList{models.EventFundsReserved}},
+		{URL: "https://example.com", Secret: "short", EventTypes: models.EventTypeList{models.EventFundsReserved}},
+		{URL: "https://example.com", Secret: webhookSecret},
+		{URL: "https://example.com", Secret: webhookSecret, EventTypes: models.EventTypeList{"BalanceChanged"}},
+	}
+	for _, hook := range invalid {
+		resp, code, err := s.processRequest(http.MethodPost, "/webhooks/createWebhook", token1, hook)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), http.StatusBadRequest, code, string(resp))
+	}
+}
+
+func (s *IntegrationTestSuite) TestReplayDeliveryNotFound() {
+	resp, code, err := s.processRequest(http.MethodPost, "/webhooks/replayDelivery", token1, map[string]int{"id": 1})
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusNotFound, code)
+	require.Equal(s.T(), "{\"error\":\"webhook delivery not found\"}\n", string(resp))
+}
+
+func createWebhook(s *IntegrationTestSuite, hook *models.Webhook) *models.Webhook {
+	s.T().Helper()
+	resp, code, err := s.processRequest(http.MethodPost, "/webhooks/createWebhook", token1, hook)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code, string(resp))
+	created := models.Webhook{}
+	err = json.Unmarshal(resp, &created)
+	require.NoError(s.T(), err)
+	return &created
+}
+
+func getDeliveries(s *IntegrationTestSuite, query string) []models.WebhookDelivery {
+	s.T().Helper()
+	resp, code, err := s.processRequest(http.MethodGet, "/webhooks/getDeliveries"+query, token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code, string(resp))
+	deliveries := make([]models.WebhookDelivery, 0)
+	err = json.Unmarshal(resp, &deliveries)
+	require.NoError(s.T(), err)
+	return deliveries
+}
+
+func getDelivery(s *IntegrationTestSuite, deliveryID int) models.WebhookDelivery {
+	s.T().Helper()
+	resp, code, err := s.processRequest(http.MethodGet, fmt.Sprintf("/webhooks/getDelivery?id=%d", deliveryID), token1, nil)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), http.StatusOK, code, string(resp))
+	delivery := models.WebhookDelivery{}
+	err = json.Unmarshal(resp, &delivery)
+	require.NoError(s.T(), err)
+	return delivery
+}